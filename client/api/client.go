@@ -3,12 +3,16 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"signal-chat/internal/apitypes"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ServerError struct {
@@ -20,6 +24,17 @@ func (e *ServerError) Error() string {
 	return fmt.Sprintf("server returned unsuccessful response: %d - %s", e.StatusCode, e.Message)
 }
 
+// RateLimitError is returned when the server rejects a request because of too many attempts
+type RateLimitError struct {
+	// RetryAfter is the time the client has to wait before trying again
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
+}
+
 // httpDoer defines the interface for HTTP operations
 type httpDoer interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -31,26 +46,41 @@ type webSocketHandler interface {
 	Close()
 	SetMessageHandler(messageType apitypes.WSMessageType, handler MessageHandler)
 	SetConnectionStateHandler(handler ConnectionStateHandler)
+	Send(messageType apitypes.WSMessageType, payload any) error
+	Request(messageType apitypes.WSMessageType, payload any) (json.RawMessage, error)
 }
 
 type Client struct {
-	ServerURL  string
-	authToken  string
-	httpClient httpDoer
-	wsClient   webSocketHandler
+	ServerURL    string
+	userID       string
+	authToken    string
+	refreshToken string
+	httpClient   httpDoer
+	wsClient     webSocketHandler
+	// codec encodes the HTTP bodies and websocket messages, JSON when nil
+	codec apitypes.Codec
 }
 
 func NewClient(serverURL string) *Client {
+	return NewClientWithCodec(serverURL, apitypes.JSONCodec)
+}
+
+// NewClientWithCodec creates a client speaking the given wire encoding over HTTP and the websocket. The websocket falls
+// back to JSON when the server doesn't negotiate the encoding.
+func NewClientWithCodec(serverURL string, codec apitypes.Codec) *Client {
 	panicIfEmpty("serverURL", serverURL)
 	if !strings.Contains(serverURL, "http://") && !strings.Contains(serverURL, "https://") {
 		panic("serverURL must start with either http:// or https://")
 	}
 
 	trimmed := strings.TrimSuffix(serverURL, "/")
+	wsClient := NewWebSocketClient(trimmed)
+	wsClient.codec = codec
 	return &Client{
 		ServerURL:  trimmed,
 		httpClient: &http.Client{},
-		wsClient:   NewWebSocketClient(trimmed),
+		wsClient:   wsClient,
+		codec:      codec,
 	}
 }
 
@@ -81,10 +111,12 @@ func (c *Client) SignUp(username, password string, keyBundle apitypes.KeyBundle)
 	}
 
 	var resp apitypes.SignUpResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
 		return apitypes.SignUpResponse{}, fmt.Errorf("got error unmarshalling response from server: %w", err)
 	}
+	c.userID = resp.UserID
 	c.authToken = resp.AuthToken
+	c.refreshToken = resp.RefreshToken
 
 	if err := c.wsClient.Connect(c.authToken); err != nil {
 		return apitypes.SignUpResponse{}, fmt.Errorf("failed to establish websocket connection: %w", err)
@@ -93,13 +125,20 @@ func (c *Client) SignUp(username, password string, keyBundle apitypes.KeyBundle)
 	return resp, nil
 }
 
-func (c *Client) SignIn(username, password string) (apitypes.SignInResponse, error) {
+// SignIn signs in on the registered device with the given ID. A new device is registered with the key bundle
+// instead when the device ID is 0.
+func (c *Client) SignIn(username, password string, deviceID uint32, keyBundle *apitypes.KeyBundle) (apitypes.SignInResponse, error) {
 	panicIfEmpty("username", username)
 	panicIfEmpty("password", password)
+	if (deviceID == 0) == (keyBundle == nil) {
+		panic("exactly one of deviceID and keyBundle must be set")
+	}
 
 	req := apitypes.SignInRequest{
-		Username: username,
-		Password: password,
+		Username:  username,
+		Password:  password,
+		DeviceID:  deviceID,
+		KeyBundle: keyBundle,
 	}
 
 	status, body, err := c.post(apitypes.EndpointSignIn, req)
@@ -111,10 +150,12 @@ func (c *Client) SignIn(username, password string) (apitypes.SignInResponse, err
 	}
 
 	var resp apitypes.SignInResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
 		return apitypes.SignInResponse{}, fmt.Errorf("got error unmarshalling response from server: %w", err)
 	}
+	c.userID = resp.UserID
 	c.authToken = resp.AuthToken
+	c.refreshToken = resp.RefreshToken
 
 	if err := c.wsClient.Connect(c.authToken); err != nil {
 		return apitypes.SignInResponse{}, fmt.Errorf("failed to establish websocket connection: %w", err)
@@ -123,11 +164,73 @@ func (c *Client) SignIn(username, password string) (apitypes.SignInResponse, err
 	return resp, nil
 }
 
+// UserID returns the ID of the signed-in user
+func (c *Client) UserID() string {
+	return c.userID
+}
+
 func (c *Client) Close() {
+	c.userID = ""
 	c.authToken = ""
+	c.refreshToken = ""
 	c.wsClient.Close()
 }
 
+// RefreshSession exchanges the current refresh token for a new pair of tokens
+func (c *Client) RefreshSession() (apitypes.RefreshSessionResponse, error) {
+	panicIfEmpty("refreshToken", c.refreshToken)
+
+	req := apitypes.RefreshSessionRequest{RefreshToken: c.refreshToken}
+	status, body, err := c.post(apitypes.EndpointRefresh, req)
+	if err != nil {
+		return apitypes.RefreshSessionResponse{}, fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return apitypes.RefreshSessionResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.RefreshSessionResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.RefreshSessionResponse{}, fmt.Errorf("got error unmarshalling response from server: %w", err)
+	}
+	c.authToken = resp.AuthToken
+	c.refreshToken = resp.RefreshToken
+
+	return resp, nil
+}
+
+func (c *Client) GetSessions() (apitypes.GetSessionsResponse, error) {
+	status, body, err := c.get(apitypes.EndpointSessions)
+	if err != nil {
+		return apitypes.GetSessionsResponse{}, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	if status != http.StatusOK {
+		return apitypes.GetSessionsResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.GetSessionsResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.GetSessionsResponse{}, fmt.Errorf("failed to unmarshal sessions response: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *Client) RevokeSession(id string) error {
+	panicIfEmpty("id", id)
+
+	path := strings.Replace(apitypes.EndpointSession, ":id", id, 1)
+	status, body, err := c.delete(path)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
 func (c *Client) GetUser(id string) (apitypes.GetUserResponse, error) {
 	panicIfEmpty("id", id)
 
@@ -141,7 +244,7 @@ func (c *Client) GetUser(id string) (apitypes.GetUserResponse, error) {
 	}
 
 	var resp apitypes.GetUserResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
 		return apitypes.GetUserResponse{}, fmt.Errorf("failed to unmarshal user response: %w", err)
 	}
 
@@ -158,33 +261,104 @@ func (c *Client) GetAllUsers() (apitypes.GetAllUsersResponse, error) {
 	}
 
 	var resp apitypes.GetAllUsersResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
 		return apitypes.GetAllUsersResponse{}, fmt.Errorf("failed to unmarshal user response: %w", err)
 	}
 
 	return resp, nil
 }
 
-func (c *Client) GetPreKeyBundle(id string) (apitypes.GetPreKeyBundleResponse, error) {
+func (c *Client) GetPreKeyBundle(id string, deviceID uint32) (apitypes.GetPreKeyBundleResponse, error) {
 	panicIfEmpty("id", id)
 
 	path := strings.Replace(apitypes.EndpointPreKeyBundle, ":id", id, 1)
+	path = strings.Replace(path, ":deviceId", strconv.FormatUint(uint64(deviceID), 10), 1)
 	status, body, err := c.get(path)
 	if err != nil {
-		return apitypes.GetPreKeyBundleResponse{}, fmt.Errorf("failed to get pre key bundle for user %s: %w", id, err)
+		return apitypes.GetPreKeyBundleResponse{}, fmt.Errorf("failed to get pre key bundle for device %d of user %s: %w", deviceID, id, err)
 	}
 	if status != http.StatusOK {
 		return apitypes.GetPreKeyBundleResponse{}, parseResponseError(status, body)
 	}
 
 	var resp apitypes.GetPreKeyBundleResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
 		return apitypes.GetPreKeyBundleResponse{}, fmt.Errorf("failed to unmarshal prekey bundle response: %w", err)
 	}
 
 	return resp, nil
 }
 
+// GetDevices returns the devices registered to the user together with their registration IDs
+func (c *Client) GetDevices(id string) (apitypes.GetDevicesResponse, error) {
+	panicIfEmpty("id", id)
+
+	path := strings.Replace(apitypes.EndpointUserDevices, ":id", id, 1)
+	status, body, err := c.get(path)
+	if err != nil {
+		return apitypes.GetDevicesResponse{}, fmt.Errorf("failed to get devices of user %s: %w", id, err)
+	}
+	if status != http.StatusOK {
+		return apitypes.GetDevicesResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.GetDevicesResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.GetDevicesResponse{}, fmt.Errorf("failed to unmarshal devices response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// RemoveDevice unregisters a secondary device of the current user and signs it out
+func (c *Client) RemoveDevice(deviceID uint32) error {
+	path := strings.Replace(apitypes.EndpointDevice, ":id", strconv.FormatUint(uint64(deviceID), 10), 1)
+	status, body, err := c.delete(path)
+	if err != nil {
+		return fmt.Errorf("failed to remove device: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
+func (c *Client) UploadPreKeys(preKeys []apitypes.PreKey) (apitypes.UploadPreKeysResponse, error) {
+	if len(preKeys) == 0 {
+		panic("preKeys must not be empty")
+	}
+
+	req := apitypes.UploadPreKeysRequest{PreKeys: preKeys}
+	status, body, err := c.post(apitypes.EndpointPreKeys, req)
+	if err != nil {
+		return apitypes.UploadPreKeysResponse{}, fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return apitypes.UploadPreKeysResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.UploadPreKeysResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.UploadPreKeysResponse{}, fmt.Errorf("got error unmarshalling response from server: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (c *Client) UploadSignedPreKey(signedPreKey apitypes.SignedPreKey) error {
+	req := apitypes.UploadSignedPreKeyRequest{SignedPreKey: signedPreKey}
+	status, body, err := c.post(apitypes.EndpointSignedPreKey, req)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
 func (c *Client) CreateConversation(id string, otherParticipants []apitypes.Participant) error {
 	panicIfEmpty("id", id)
 	if len(otherParticipants) == 0 {
@@ -196,7 +370,68 @@ func (c *Client) CreateConversation(id string, otherParticipants []apitypes.Part
 		OtherParticipants: otherParticipants,
 	}
 
-	status, body, err := c.post(apitypes.EndpointConversations, req)
+	var resp apitypes.CreateConversationResponse
+	return c.request(apitypes.MessageTypeCreateConversation, apitypes.EndpointConversations, req, &resp)
+}
+
+func (c *Client) SendMessage(conversationID string, content []byte) (apitypes.SendMessageResponse, error) {
+	return c.SendReference(conversationID, "", content)
+}
+
+// SendReference sends a message referring to an earlier message of the conversation, like an edit or a reaction,
+// which the server purges along with it. An empty refersTo sends a message referring to none.
+func (c *Client) SendReference(conversationID, refersTo string, content []byte) (apitypes.SendMessageResponse, error) {
+	panicIfEmpty("conversationID", conversationID)
+	if content == nil || len(content) == 0 {
+		panic("content must not be nil or empty")
+	}
+
+	req := apitypes.SendMessageRequest{
+		ConversationID: conversationID,
+		Content:        content,
+		RefersTo:       refersTo,
+	}
+
+	var resp apitypes.SendMessageResponse
+	if err := c.request(apitypes.MessageTypeSendMessage, apitypes.EndpointMessages, req, &resp); err != nil {
+		return apitypes.SendMessageResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// SendTyping tells the connected participants of the conversation that the user started or stopped typing. Typing
+// events aren't stored by the server, so they are dropped while the websocket isn't connected.
+func (c *Client) SendTyping(conversationID string, typing bool) error {
+	panicIfEmpty("conversationID", conversationID)
+
+	err := c.wsClient.Send(apitypes.MessageTypeTyping, apitypes.WSTypingPayload{
+		ConversationID: conversationID,
+		Typing:         typing,
+	})
+	if err != nil && !errors.Is(err, ErrNotConnected) {
+		return fmt.Errorf("failed to send typing event: %w", err)
+	}
+
+	return nil
+}
+
+// SendReceipt tells the devices of the author that the user received or read the messages. Receipts are sent from
+// the websocket message handlers, so the websocket doesn't wait for the response, which the read pump would have to
+// deliver.
+func (c *Client) SendReceipt(req apitypes.SendReceiptRequest) error {
+	panicIfEmpty("conversationID", req.ConversationID)
+	panicIfEmpty("authorID", req.AuthorID)
+	if len(req.MessageIDs) == 0 {
+		panic("messageIDs must not be empty")
+	}
+
+	err := c.wsClient.Send(apitypes.MessageTypeSendReceipt, req)
+	if !errors.Is(err, ErrNotConnected) {
+		return err
+	}
+
+	status, body, err := c.post(apitypes.EndpointReceipts, req)
 	if err != nil {
 		return fmt.Errorf("got error from server: %w", err)
 	}
@@ -204,41 +439,207 @@ func (c *Client) CreateConversation(id string, otherParticipants []apitypes.Part
 		return parseResponseError(status, body)
 	}
 
-	var resp apitypes.CreateConversationResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return fmt.Errorf("got error unmarshalling response from server: %w", err)
+	return nil
+}
+
+func (c *Client) AddParticipants(conversationID string, participants []apitypes.Participant) error {
+	panicIfEmpty("conversationID", conversationID)
+	if len(participants) == 0 {
+		panic("participants must not be empty")
+	}
+
+	req := apitypes.AddParticipantsRequest{Participants: participants}
+	path := strings.Replace(apitypes.EndpointConversationParticipants, ":id", conversationID, 1)
+	status, body, err := c.post(path, req)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
 	}
 
 	return nil
 }
 
-func (c *Client) SendMessage(conversationID string, content []byte) (apitypes.SendMessageResponse, error) {
+// RemoveParticipant removes the participant from the conversation. keyDistributions carry the rotated sender key of
+// the current user for each remaining participant.
+func (c *Client) RemoveParticipant(conversationID, participantID string, keyDistributions []apitypes.Participant) error {
 	panicIfEmpty("conversationID", conversationID)
-	if content == nil || len(content) == 0 {
-		panic("content must not be nil or empty")
+	panicIfEmpty("participantID", participantID)
+
+	req := apitypes.RemoveParticipantRequest{KeyDistributions: keyDistributions}
+	path := strings.Replace(apitypes.EndpointConversationParticipant, ":id", conversationID, 1)
+	path = strings.Replace(path, ":participantId", participantID, 1)
+	status, body, err := c.sendBody("DELETE", path, req)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
 	}
 
-	req := apitypes.SendMessageRequest{
-		ConversationID: conversationID,
-		Content:        content,
+	return nil
+}
+
+// DeleteMessage asks the server to purge a message the user sent
+func (c *Client) DeleteMessage(conversationID, messageID string) error {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageID", messageID)
+
+	path := strings.Replace(apitypes.EndpointConversationMessage, ":id", conversationID, 1)
+	path = strings.Replace(path, ":messageId", messageID, 1)
+	status, body, err := c.delete(path)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
+// SetExpirationTimer sets the number of seconds after which new messages of the conversation disappear, zero turns
+// disappearing messages off
+func (c *Client) SetExpirationTimer(conversationID string, seconds int64) error {
+	panicIfEmpty("conversationID", conversationID)
+
+	req := apitypes.SetExpirationTimerRequest{ExpirationTimer: seconds}
+	path := strings.Replace(apitypes.EndpointConversationTimer, ":id", conversationID, 1)
+	status, body, err := c.sendBody("PUT", path, req)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
+func (c *Client) DistributeSenderKeys(conversationID string, participants []apitypes.Participant) error {
+	panicIfEmpty("conversationID", conversationID)
+	if len(participants) == 0 {
+		panic("participants must not be empty")
 	}
 
-	status, body, err := c.post(apitypes.EndpointMessages, req)
+	req := apitypes.DistributeSenderKeysRequest{Participants: participants}
+	path := strings.Replace(apitypes.EndpointConversationKeys, ":id", conversationID, 1)
+	status, body, err := c.post(path, req)
 	if err != nil {
-		return apitypes.SendMessageResponse{}, fmt.Errorf("got error from server: %w", err)
+		return fmt.Errorf("got error from server: %w", err)
 	}
 	if status != http.StatusOK {
-		return apitypes.SendMessageResponse{}, parseResponseError(status, body)
+		return parseResponseError(status, body)
 	}
 
-	var resp apitypes.SendMessageResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return apitypes.SendMessageResponse{}, fmt.Errorf("got error unmarshalling response from server: %w", err)
+	return nil
+}
+
+func (c *Client) GetMessages(req apitypes.GetMessagesRequest) (apitypes.GetMessagesResponse, error) {
+	panicIfEmpty("conversationID", req.ConversationID)
+
+	query := url.Values{}
+	if req.Cursor != "" {
+		query.Set("cursor", req.Cursor)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	if req.Before > 0 {
+		query.Set("before", strconv.FormatInt(req.Before, 10))
+	}
+	if req.After > 0 {
+		query.Set("after", strconv.FormatInt(req.After, 10))
+	}
+
+	path := strings.Replace(apitypes.EndpointConversationMessages, ":id", req.ConversationID, 1)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	status, body, err := c.get(path)
+	if err != nil {
+		return apitypes.GetMessagesResponse{}, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if status != http.StatusOK {
+		return apitypes.GetMessagesResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.GetMessagesResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.GetMessagesResponse{}, fmt.Errorf("failed to unmarshal messages response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// GetPresence returns the presence of the given users. Users that don't share a conversation with the user are left
+// out of the response.
+func (c *Client) GetPresence(userIDs []string) (apitypes.GetPresenceResponse, error) {
+	if len(userIDs) == 0 {
+		panic("userIDs must not be empty")
+	}
+
+	query := url.Values{"id": userIDs}
+	status, body, err := c.get(apitypes.EndpointPresence + "?" + query.Encode())
+	if err != nil {
+		return apitypes.GetPresenceResponse{}, fmt.Errorf("failed to get presence: %w", err)
+	}
+	if status != http.StatusOK {
+		return apitypes.GetPresenceResponse{}, parseResponseError(status, body)
+	}
+
+	var resp apitypes.GetPresenceResponse
+	if err := c.bodyCodec().Unmarshal(body, &resp); err != nil {
+		return apitypes.GetPresenceResponse{}, fmt.Errorf("failed to unmarshal presence response: %w", err)
 	}
 
 	return resp, nil
 }
 
+// UpdatePresenceSettings sets whether the contacts of the user see when it was last online
+func (c *Client) UpdatePresenceSettings(hideLastSeen bool) error {
+	req := apitypes.UpdatePresenceSettingsRequest{HideLastSeen: hideLastSeen}
+	status, body, err := c.post(apitypes.EndpointPresenceSettings, req)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+
+	return nil
+}
+
+// request sends the payload over the websocket, which saves a round trip per request, and falls back to posting it
+// to the route when the websocket isn't connected. The response is decoded into resp.
+func (c *Client) request(messageType apitypes.WSMessageType, route string, payload, resp any) error {
+	// Websocket responses are handed over as JSON whatever the encoding of the connection
+	data, err := c.wsClient.Request(messageType, payload)
+	if err == nil {
+		if err := json.Unmarshal(data, resp); err != nil {
+			return fmt.Errorf("got error unmarshalling response from server: %w", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrNotConnected) {
+		return err
+	}
+
+	status, body, err := c.post(route, payload)
+	if err != nil {
+		return fmt.Errorf("got error from server: %w", err)
+	}
+	if status != http.StatusOK {
+		return parseResponseError(status, body)
+	}
+	if err := c.bodyCodec().Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("got error unmarshalling response from server: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) get(route string) (int, []byte, error) {
 	panicIfEmpty("route", route)
 
@@ -250,19 +651,36 @@ func (c *Client) get(route string) (int, []byte, error) {
 	return c.sendHTTP(req)
 }
 
+func (c *Client) delete(route string) (int, []byte, error) {
+	panicIfEmpty("route", route)
+
+	req, err := c.newHTTPRequest("DELETE", route, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return c.sendHTTP(req)
+}
+
 func (c *Client) post(route string, payload any) (int, []byte, error) {
+	return c.sendBody("POST", route, payload)
+}
+
+// sendBody sends the payload encoded with the codec of the client
+func (c *Client) sendBody(method, route string, payload any) (int, []byte, error) {
 	panicIfEmpty("route", route)
 
-	b, err := json.Marshal(payload)
+	codec := c.bodyCodec()
+	b, err := codec.Marshal(payload)
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := c.newHTTPRequest("POST", route, b)
+	req, err := c.newHTTPRequest(method, route, b)
 	if err != nil {
 		return 0, nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", codec.ContentType())
 
 	return c.sendHTTP(req)
 }
@@ -277,10 +695,19 @@ func (c *Client) newHTTPRequest(method, route string, payload []byte) (*http.Req
 	if c.authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.authToken)
 	}
+	// Errors are always sent as JSON, the server only answers successful requests in the accepted encoding
+	req.Header.Set("Accept", c.bodyCodec().ContentType())
 
 	return req, nil
 }
 
+func (c *Client) bodyCodec() apitypes.Codec {
+	if c.codec == nil {
+		return apitypes.JSONCodec
+	}
+	return c.codec
+}
+
 func (c *Client) sendHTTP(req *http.Request) (int, []byte, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -297,6 +724,10 @@ func (c *Client) sendHTTP(req *http.Request) (int, []byte, error) {
 		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return resp.StatusCode, body, parseRateLimitError(resp.Header, body)
+	}
+
 	return resp.StatusCode, body, nil
 }
 
@@ -317,3 +748,17 @@ func parseResponseError(status int, body []byte) error {
 		Message:    errResp.Message,
 	}
 }
+
+func parseRateLimitError(header http.Header, body []byte) error {
+	rateLimitErr := &RateLimitError{}
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		rateLimitErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	var errResp apitypes.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		rateLimitErr.Message = errResp.Message
+	}
+
+	return rateLimitErr
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"signal-chat/internal/apitypes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +32,12 @@ type WebsocketClientSpy struct {
 	closeCalled   bool
 	connectErr    error
 	authToken     string
+	// connected makes requests go over the websocket, they fail with ErrNotConnected otherwise
+	connected  bool
+	requests   []apitypes.WSMessageType
+	response   json.RawMessage
+	requestErr error
+	sent       []apitypes.WSMessage
 }
 
 func (s *WebsocketClientSpy) Connect(authToken string) error {
@@ -49,6 +56,26 @@ func (s *WebsocketClientSpy) SetMessageHandler(messageType apitypes.WSMessageTyp
 func (s *WebsocketClientSpy) SetConnectionStateHandler(handler ConnectionStateHandler) {
 }
 
+func (s *WebsocketClientSpy) Send(messageType apitypes.WSMessageType, payload any) error {
+	if !s.connected {
+		return ErrNotConnected
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	s.sent = append(s.sent, apitypes.WSMessage{Type: messageType, Data: data})
+	return nil
+}
+
+func (s *WebsocketClientSpy) Request(messageType apitypes.WSMessageType, payload any) (json.RawMessage, error) {
+	if !s.connected {
+		return nil, ErrNotConnected
+	}
+	s.requests = append(s.requests, messageType)
+	return s.response, s.requestErr
+}
+
 func TestNewClient(t *testing.T) {
 	t.Run("panics when URL has no protocol", func(t *testing.T) {
 		// Act & Assert
@@ -265,7 +292,7 @@ func TestClient_SignIn(t *testing.T) {
 		}
 
 		// Act
-		resp, err := client.SignIn("testuser", "testpass")
+		resp, err := client.SignIn("testuser", "testpass", 1, nil)
 
 		// Assert
 		require.NoError(t, err)
@@ -288,7 +315,7 @@ func TestClient_SignIn(t *testing.T) {
 		}
 
 		// Act
-		_, err := client.SignIn("testuser", "testpass")
+		_, err := client.SignIn("testuser", "testpass", 1, nil)
 
 		// Assert
 		assert.Error(t, err)
@@ -307,7 +334,7 @@ func TestClient_SignIn(t *testing.T) {
 		}
 
 		// Act
-		_, err := client.SignIn("testuser", "testpass")
+		_, err := client.SignIn("testuser", "testpass", 1, nil)
 
 		// Assert
 		require.Error(t, err)
@@ -318,6 +345,28 @@ func TestClient_SignIn(t *testing.T) {
 		assert.False(t, wsSpy.connectCalled)
 	})
 
+	t.Run("returns rate limit error with retry time when server throttles sign-in", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusTooManyRequests, apitypes.ErrorResponse{Message: "too many requests"})
+		httpSpy.response.Header = http.Header{"Retry-After": []string{"120"}}
+		wsSpy := &WebsocketClientSpy{}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   wsSpy,
+		}
+
+		// Act
+		_, err := client.SignIn("testuser", "testpass", 1, nil)
+
+		// Assert
+		var rateLimitErr *RateLimitError
+		require.ErrorAs(t, err, &rateLimitErr)
+		assert.Equal(t, 2*time.Minute, rateLimitErr.RetryAfter)
+		assert.Equal(t, "too many requests", rateLimitErr.Message)
+		assert.False(t, wsSpy.connectCalled)
+	})
+
 	t.Run("returns error when websocket connection fails", func(t *testing.T) {
 		// Arrange
 		resp := apitypes.SignInResponse{
@@ -335,7 +384,7 @@ func TestClient_SignIn(t *testing.T) {
 		}
 
 		// Act
-		_, err := client.SignIn("testuser", "testpass")
+		_, err := client.SignIn("testuser", "testpass", 1, nil)
 
 		// Assert
 		require.Error(t, err)
@@ -344,6 +393,45 @@ func TestClient_SignIn(t *testing.T) {
 	})
 }
 
+func TestClient_SignInNewDevice(t *testing.T) {
+	t.Run("sends key bundle to register a new device", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.SignInResponse{
+			UserID:    "user123",
+			DeviceID:  2,
+			AuthToken: "token123",
+		}
+		httpSpy := testHTTPClient(t, http.StatusOK, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+		}
+		keyBundle := apitypes.KeyBundle{RegistrationID: 42}
+
+		// Act
+		resp, err := client.SignIn("testuser", "testpass", 0, &keyBundle)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), resp.DeviceID)
+		require.Len(t, httpSpy.requests, 1)
+		var body apitypes.SignInRequest
+		require.NoError(t, json.NewDecoder(httpSpy.requests[0].Body).Decode(&body))
+		assert.Zero(t, body.DeviceID)
+		require.NotNil(t, body.KeyBundle)
+		assert.Equal(t, uint32(42), body.KeyBundle.RegistrationID)
+	})
+
+	t.Run("panics when both device ID and key bundle are given", func(t *testing.T) {
+		client := &Client{ServerURL: "http://example.com"}
+
+		assert.Panics(t, func() {
+			_, _ = client.SignIn("testuser", "testpass", 1, &apitypes.KeyBundle{})
+		})
+	})
+}
+
 func TestClient_GetUser(t *testing.T) {
 	t.Run("retrieves user by ID successfully", func(t *testing.T) {
 		// Arrange
@@ -581,6 +669,44 @@ func TestClient_SendMessage(t *testing.T) {
 		assert.Equal(t, int64(1234567890), resp.CreatedAt)
 	})
 
+	t.Run("sends and receives protobuf when created with the protobuf codec", func(t *testing.T) {
+		// Arrange
+		body, err := apitypes.ProtobufCodec.Marshal(apitypes.SendMessageResponse{MessageID: "msg123", CreatedAt: 1234567890})
+		require.NoError(t, err)
+		httpSpy := &HTTPClientSpy{
+			response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBuffer(body)),
+			},
+		}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+			codec:      apitypes.ProtobufCodec,
+		}
+
+		// Act
+		resp, err := client.SendMessage("conv123", []byte("Hello, world!"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "msg123", resp.MessageID)
+		assert.Equal(t, int64(1234567890), resp.CreatedAt)
+
+		require.Len(t, httpSpy.requests, 1)
+		req := httpSpy.requests[0]
+		assert.Equal(t, apitypes.ContentTypeProtobuf, req.Header.Get("Content-Type"))
+		assert.Equal(t, apitypes.ContentTypeProtobuf, req.Header.Get("Accept"))
+		reqBody, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		var sent apitypes.SendMessageRequest
+		require.NoError(t, apitypes.ProtobufCodec.Unmarshal(reqBody, &sent))
+		assert.Equal(t, "conv123", sent.ConversationID)
+		assert.Equal(t, []byte("Hello, world!"), sent.Content)
+	})
+
 	t.Run("returns error when HTTP request fails", func(t *testing.T) {
 		// Arrange
 		httpSpy := &HTTPClientSpy{
@@ -625,6 +751,513 @@ func TestClient_SendMessage(t *testing.T) {
 	})
 }
 
+func TestClient_SendMessageOverWebsocket(t *testing.T) {
+	t.Run("sends message over websocket when connected", func(t *testing.T) {
+		// Arrange
+		httpSpy := &HTTPClientSpy{}
+		wsSpy := &WebsocketClientSpy{
+			connected: true,
+			response:  mustMarshal(apitypes.SendMessageResponse{MessageID: "msg123", CreatedAt: 1234567890}),
+		}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   wsSpy,
+			authToken:  "test-token",
+		}
+
+		// Act
+		resp, err := client.SendMessage("conv123", []byte("Hello, world!"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "msg123", resp.MessageID)
+		assert.Equal(t, int64(1234567890), resp.CreatedAt)
+		assert.Equal(t, []apitypes.WSMessageType{apitypes.MessageTypeSendMessage}, wsSpy.requests)
+		assert.Empty(t, httpSpy.requests, "no HTTP request should be made")
+	})
+
+	t.Run("returns server error from websocket without falling back to HTTP", func(t *testing.T) {
+		// Arrange
+		httpSpy := &HTTPClientSpy{}
+		wsSpy := &WebsocketClientSpy{
+			connected:  true,
+			requestErr: &ServerError{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"},
+		}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   wsSpy,
+			authToken:  "test-token",
+		}
+
+		// Act
+		_, err := client.SendMessage("conv123", []byte("Hello, world!"))
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+		assert.Empty(t, httpSpy.requests, "no HTTP request should be made")
+	})
+
+	t.Run("falls back to HTTP when websocket is not connected", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, apitypes.SendMessageResponse{MessageID: "msg123"})
+		wsSpy := &WebsocketClientSpy{}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   wsSpy,
+			authToken:  "test-token",
+		}
+
+		// Act
+		resp, err := client.SendMessage("conv123", []byte("Hello, world!"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "msg123", resp.MessageID)
+		require.Len(t, httpSpy.requests, 1)
+		assert.Equal(t, apitypes.EndpointMessages, httpSpy.requests[0].URL.Path)
+	})
+}
+
+func TestClient_SendTyping(t *testing.T) {
+	t.Run("sends typing event over websocket", func(t *testing.T) {
+		// Arrange
+		wsSpy := &WebsocketClientSpy{connected: true}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: &HTTPClientSpy{},
+			wsClient:   wsSpy,
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SendTyping("conv123", true)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, wsSpy.sent, 1)
+		assert.Equal(t, apitypes.MessageTypeTyping, wsSpy.sent[0].Type)
+		assert.JSONEq(t, `{"conversationID":"conv123","typing":true}`, string(wsSpy.sent[0].Data))
+	})
+
+	t.Run("drops typing event when websocket is not connected", func(t *testing.T) {
+		// Arrange
+		httpSpy := &HTTPClientSpy{}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SendTyping("conv123", true)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, httpSpy.requests, "typing events should not fall back to HTTP")
+	})
+}
+
+func TestClient_SendReceipt(t *testing.T) {
+	req := apitypes.SendReceiptRequest{
+		ConversationID: "conv123",
+		AuthorID:       "user2",
+		MessageIDs:     []string{"msg1", "msg2"},
+		Type:           apitypes.ReceiptTypeRead,
+	}
+
+	t.Run("sends receipt over websocket without waiting for a response", func(t *testing.T) {
+		// Arrange
+		httpSpy := &HTTPClientSpy{}
+		wsSpy := &WebsocketClientSpy{connected: true}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   wsSpy,
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SendReceipt(req)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, wsSpy.sent, 1)
+		assert.Equal(t, apitypes.MessageTypeSendReceipt, wsSpy.sent[0].Type)
+		assert.JSONEq(t, `{"conversationID":"conv123","authorID":"user2","messageIDs":["msg1","msg2"],"type":2}`, string(wsSpy.sent[0].Data))
+		assert.Empty(t, wsSpy.requests)
+		assert.Empty(t, httpSpy.requests)
+	})
+
+	t.Run("falls back to HTTP when websocket is not connected", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, struct{}{})
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SendReceipt(req)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, httpSpy.requests, 1)
+		assert.Equal(t, http.MethodPost, httpSpy.requests[0].Method)
+		assert.Equal(t, "/v1/receipts", httpSpy.requests[0].URL.Path)
+		var body apitypes.SendReceiptRequest
+		require.NoError(t, json.NewDecoder(httpSpy.requests[0].Body).Decode(&body))
+		assert.Equal(t, req, body)
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Bad Request"}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: testHTTPClient(t, http.StatusBadRequest, resp),
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SendReceipt(req)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_RemoveParticipant(t *testing.T) {
+	t.Run("sends rotated keys in delete request body", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, struct{}{})
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+		keys := []apitypes.Participant{{ID: "user2", KeyDistributionMessage: []byte("rotated-key")}}
+
+		// Act
+		err := client.RemoveParticipant("conv123", "user3", keys)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, httpSpy.requests, 1)
+		req := httpSpy.requests[0]
+		assert.Equal(t, http.MethodDelete, req.Method)
+		assert.Equal(t, "/v1/conversations/conv123/participants/user3", req.URL.Path)
+		var body apitypes.RemoveParticipantRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(t, keys, body.KeyDistributions)
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Not Found"}
+		httpSpy := testHTTPClient(t, http.StatusNotFound, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.RemoveParticipant("conv123", "user3", nil)
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusNotFound, respErr.StatusCode)
+	})
+}
+
+func TestClient_DeleteMessage(t *testing.T) {
+	t.Run("deletes the message of the conversation", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, struct{}{})
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.DeleteMessage("conv123", "msg123")
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, httpSpy.requests, 1)
+		assert.Equal(t, http.MethodDelete, httpSpy.requests[0].Method)
+		assert.Equal(t, "/v1/conversations/conv123/messages/msg123", httpSpy.requests[0].URL.Path)
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Unauthorized"}
+		httpSpy := testHTTPClient(t, http.StatusUnauthorized, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.DeleteMessage("conv123", "msg123")
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	})
+}
+
+func TestClient_SetExpirationTimer(t *testing.T) {
+	t.Run("puts the timer of the conversation", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, struct{}{})
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SetExpirationTimer("conv123", 3600)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, httpSpy.requests, 1)
+		req := httpSpy.requests[0]
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.Equal(t, "/v1/conversations/conv123/timer", req.URL.Path)
+		var body apitypes.SetExpirationTimerRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(t, int64(3600), body.ExpirationTimer)
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Unauthorized"}
+		httpSpy := testHTTPClient(t, http.StatusUnauthorized, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.SetExpirationTimer("conv123", 60)
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	})
+}
+
+func TestClient_GetMessages(t *testing.T) {
+	t.Run("sends pagination parameters as query string", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.GetMessagesResponse{
+			Messages: []apitypes.Message{{
+				ID:             "msg1",
+				ConversationID: "conv123",
+				SenderID:       "user1",
+				Content:        []byte("ciphertext"),
+				CreatedAt:      1234567890,
+			}},
+			NextCursor: "msg1",
+		}
+		httpSpy := testHTTPClient(t, http.StatusOK, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		got, err := client.GetMessages(apitypes.GetMessagesRequest{
+			ConversationID: "conv123",
+			Cursor:         "msg0",
+			Limit:          10,
+			Before:         2000,
+		})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, resp, got)
+		require.Len(t, httpSpy.requests, 1)
+		reqURL := httpSpy.requests[0].URL
+		assert.Equal(t, "/v1/conversations/conv123/messages", reqURL.Path)
+		assert.Equal(t, "msg0", reqURL.Query().Get("cursor"))
+		assert.Equal(t, "10", reqURL.Query().Get("limit"))
+		assert.Equal(t, "2000", reqURL.Query().Get("before"))
+		assert.False(t, reqURL.Query().Has("after"))
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Unauthorized"}
+		httpSpy := testHTTPClient(t, http.StatusUnauthorized, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		_, err := client.GetMessages(apitypes.GetMessagesRequest{ConversationID: "conv123"})
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	})
+}
+
+func TestClient_GetPresence(t *testing.T) {
+	t.Run("sends user IDs as query string", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.GetPresenceResponse{Presence: []apitypes.Presence{
+			{UserID: "user1", Online: true},
+			{UserID: "user2", LastSeen: 1234567890},
+		}}
+		httpSpy := testHTTPClient(t, http.StatusOK, resp)
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		got, err := client.GetPresence([]string{"user1", "user2"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, resp, got)
+		require.Len(t, httpSpy.requests, 1)
+		reqURL := httpSpy.requests[0].URL
+		assert.Equal(t, "/v1/presence", reqURL.Path)
+		assert.Equal(t, []string{"user1", "user2"}, reqURL.Query()["id"])
+	})
+
+	t.Run("returns error when server returns non-OK status", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "Unauthorized"}
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: testHTTPClient(t, http.StatusUnauthorized, resp),
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		_, err := client.GetPresence([]string{"user1"})
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	})
+}
+
+func TestClient_UpdatePresenceSettings(t *testing.T) {
+	t.Run("sends hide last seen setting", func(t *testing.T) {
+		// Arrange
+		httpSpy := testHTTPClient(t, http.StatusOK, struct{}{})
+		client := &Client{
+			ServerURL:  "http://example.com",
+			httpClient: httpSpy,
+			wsClient:   &WebsocketClientSpy{},
+			authToken:  "test-token",
+		}
+
+		// Act
+		err := client.UpdatePresenceSettings(true)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, httpSpy.requests, 1)
+		assert.Equal(t, "/v1/presence/settings", httpSpy.requests[0].URL.Path)
+		var body apitypes.UpdatePresenceSettingsRequest
+		require.NoError(t, json.NewDecoder(httpSpy.requests[0].Body).Decode(&body))
+		assert.True(t, body.HideLastSeen)
+	})
+}
+
+func TestClient_RefreshSession(t *testing.T) {
+	t.Run("replaces tokens used by future requests", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.RefreshSessionResponse{
+			AuthToken:    "new-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresAt:    1234567890,
+		}
+		httpSpy := testHTTPClient(t, http.StatusOK, resp)
+		client := &Client{
+			ServerURL:    "http://example.com",
+			httpClient:   httpSpy,
+			wsClient:     &WebsocketClientSpy{},
+			authToken:    "old-token",
+			refreshToken: "old-refresh-token",
+		}
+
+		// Act
+		got, err := client.RefreshSession()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, resp, got)
+		assert.Equal(t, "new-token", client.authToken)
+		assert.Equal(t, "new-refresh-token", client.refreshToken)
+		require.Len(t, httpSpy.requests, 1)
+		var sent apitypes.RefreshSessionRequest
+		err = json.NewDecoder(httpSpy.requests[0].Body).Decode(&sent)
+		require.NoError(t, err)
+		assert.Equal(t, "old-refresh-token", sent.RefreshToken)
+	})
+
+	t.Run("keeps old tokens when server rejects refresh token", func(t *testing.T) {
+		// Arrange
+		resp := apitypes.ErrorResponse{Message: "invalid or expired refresh token"}
+		httpSpy := testHTTPClient(t, http.StatusUnauthorized, resp)
+		client := &Client{
+			ServerURL:    "http://example.com",
+			httpClient:   httpSpy,
+			wsClient:     &WebsocketClientSpy{},
+			authToken:    "old-token",
+			refreshToken: "old-refresh-token",
+		}
+
+		// Act
+		_, err := client.RefreshSession()
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+		assert.Equal(t, "old-token", client.authToken)
+	})
+}
+
 func TestClient_Close(t *testing.T) {
 	t.Run("closes websocket connection successfully", func(t *testing.T) {
 		// Arrange
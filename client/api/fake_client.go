@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"signal-chat/internal/apitypes"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,40 +13,61 @@ import (
 )
 
 type user struct {
-	id                string
-	username          string
-	password          string
+	id           string
+	username     string
+	password     string
+	authToken    string
+	devices      map[uint32]*device
+	lastDeviceID uint32
+}
+
+// deviceIDs returns the IDs of the user's devices in ascending order
+func (u *user) deviceIDs() []uint32 {
+	ids := make([]uint32, 0, len(u.devices))
+	for id := range u.devices {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+type device struct {
 	keyBundle         *apitypes.KeyBundle
-	authToken         string
 	pendingWSMessages []apitypes.WSMessage
 }
 
+// deviceAddress identifies a single device of a user
+type deviceAddress struct {
+	userID   string
+	deviceID uint32
+}
+
 type conversation struct {
-	ID             string
-	ParticipantIDs []string
+	ID              string
+	ParticipantIDs  []string
+	ExpirationTimer int64
 }
 
 type FakeClient struct {
-	users       map[string]*user // by ID
-	currentUser *user
-	authTokens  map[string]string // authToken -> user ID
+	users           map[string]*user // by ID
+	currentUser     *user
+	currentDeviceID uint32
+	authTokens      map[string]string // authToken -> user ID
 
 	mu                     sync.RWMutex
 	username               string
 	password               string
 	conversations          map[string]conversation                   // all conversations
 	handlers               map[apitypes.WSMessageType]MessageHandler // only for current user
-	registrationIDs        map[string]uint32                         // for all users
 	connectionStateHandler ConnectionStateHandler
 }
 
 func NewFakeClient() *FakeClient {
 	return &FakeClient{
-		users:           make(map[string]*user),
-		authTokens:      make(map[string]string),
-		handlers:        make(map[apitypes.WSMessageType]MessageHandler),
-		conversations:   make(map[string]conversation),
-		registrationIDs: make(map[string]uint32),
+		users:         make(map[string]*user),
+		authTokens:    make(map[string]string),
+		handlers:      make(map[apitypes.WSMessageType]MessageHandler),
+		conversations: make(map[string]conversation),
 	}
 }
 
@@ -62,44 +84,69 @@ func (f *FakeClient) SetConnectionStateHandler(handler ConnectionStateHandler) {
 
 func (f *FakeClient) Close() {
 	f.currentUser = nil
+	f.currentDeviceID = 0
 	f.handlers = make(map[apitypes.WSMessageType]MessageHandler)
 }
 
+func (f *FakeClient) UserID() string {
+	if f.currentUser == nil {
+		return ""
+	}
+	return f.currentUser.id
+}
+
 func (f *FakeClient) SignUp(username, password string, keyBundle apitypes.KeyBundle) (apitypes.SignUpResponse, error) {
 	userID := uuid.New().String()
 	user := &user{
-		id:        userID,
-		username:  username,
-		password:  password,
-		keyBundle: &keyBundle,
+		id:           userID,
+		username:     username,
+		password:     password,
+		devices:      map[uint32]*device{1: {keyBundle: &keyBundle}},
+		lastDeviceID: 1,
 	}
 
 	f.users[userID] = user
 	f.currentUser = user
+	f.currentDeviceID = 1
 
 	if f.connectionStateHandler != nil {
 		f.connectionStateHandler(StateConnected)
 	}
 
 	return apitypes.SignUpResponse{
-		UserID: userID,
+		UserID:   userID,
+		DeviceID: 1,
 	}, nil
 }
 
-func (f *FakeClient) SignIn(username, password string) (apitypes.SignInResponse, error) {
+// SignIn signs in an existing device of the user, or registers a new device when a key bundle is given
+func (f *FakeClient) SignIn(username, password string, deviceID uint32, keyBundle *apitypes.KeyBundle) (apitypes.SignInResponse, error) {
 	for _, user := range f.users {
 		if user.username == username && user.password == password {
+			if keyBundle != nil {
+				user.lastDeviceID++
+				deviceID = user.lastDeviceID
+				user.devices[deviceID] = &device{keyBundle: keyBundle}
+			}
+
+			dev, exists := user.devices[deviceID]
+			if !exists {
+				return apitypes.SignInResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "device not found"}
+			}
+
 			f.currentUser = user
+			f.currentDeviceID = deviceID
 
 			handler, handleExists := f.handlers[apitypes.MessageTypeSync]
-			if handleExists && len(user.pendingWSMessages) > 0 {
-				wsPayload := apitypes.WSSyncPayload{Messages: user.pendingWSMessages}
+			if handleExists && len(dev.pendingWSMessages) > 0 {
+				wsPayload := apitypes.WSSyncPayload{Messages: dev.pendingWSMessages}
 				handler(mustMarshal(wsPayload))
-				user.pendingWSMessages = []apitypes.WSMessage{}
+				dev.pendingWSMessages = []apitypes.WSMessage{}
 			}
 
 			return apitypes.SignInResponse{
-				UserID: user.id,
+				UserID:   user.id,
+				DeviceID: deviceID,
 			}, nil
 		}
 	}
@@ -114,38 +161,86 @@ func (f *FakeClient) SignIn(username, password string) (apitypes.SignInResponse,
 	}
 }
 
-func (f *FakeClient) GetPreKeyBundle(id string) (apitypes.GetPreKeyBundleResponse, error) {
+func (f *FakeClient) GetPreKeyBundle(id string, deviceID uint32) (apitypes.GetPreKeyBundleResponse, error) {
 	user, exists := f.users[id]
 	if !exists {
 		return apitypes.GetPreKeyBundleResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "User not found"}
 	}
 
-	if user.keyBundle == nil {
-		return apitypes.GetPreKeyBundleResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "User has no key bundle"}
+	dev, exists := user.devices[deviceID]
+	if !exists || dev.keyBundle == nil {
+		return apitypes.GetPreKeyBundleResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "Device has no key bundle"}
 	}
 
-	registrationID := uint32(1234)
-	if id, exists := f.registrationIDs[id]; exists {
-		registrationID = id
+	// Take the first preKey from the bundle, like the server the bundle has no preKey once they run out
+	var preKey *apitypes.PreKey
+	if len(dev.keyBundle.PreKeys) > 0 {
+		preKey = &dev.keyBundle.PreKeys[0]
+		dev.keyBundle.PreKeys = dev.keyBundle.PreKeys[1:]
 	}
 
-	// Get first preKey from the bundle
-	if len(user.keyBundle.PreKeys) == 0 {
-		return apitypes.GetPreKeyBundleResponse{}, &ServerError{StatusCode: http.StatusInternalServerError, Message: "No prekeys available"}
-	}
-
-	preKey := user.keyBundle.PreKeys[0]
-
 	return apitypes.GetPreKeyBundleResponse{
 		PreKeyBundle: apitypes.PreKeyBundle{
-			RegistrationID: registrationID,
-			IdentityKey:    user.keyBundle.IdentityKey,
-			SignedPreKey:   user.keyBundle.SignedPreKey,
+			RegistrationID: dev.keyBundle.RegistrationID,
+			IdentityKey:    dev.keyBundle.IdentityKey,
+			SignedPreKey:   dev.keyBundle.SignedPreKey,
 			PreKey:         preKey,
 		},
 	}, nil
 }
 
+func (f *FakeClient) GetDevices(id string) (apitypes.GetDevicesResponse, error) {
+	user, exists := f.users[id]
+	if !exists {
+		return apitypes.GetDevicesResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "User not found"}
+	}
+
+	devices := make([]apitypes.Device, 0, len(user.devices))
+	for _, deviceID := range user.deviceIDs() {
+		devices = append(devices, apitypes.Device{
+			ID:             deviceID,
+			RegistrationID: user.devices[deviceID].keyBundle.RegistrationID,
+		})
+	}
+
+	return apitypes.GetDevicesResponse{Devices: devices}, nil
+}
+
+func (f *FakeClient) RemoveDevice(deviceID uint32) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+	if deviceID == 1 {
+		return &ServerError{StatusCode: http.StatusBadRequest, Message: "the primary device can't be removed"}
+	}
+	if _, exists := f.currentUser.devices[deviceID]; !exists {
+		return &ServerError{StatusCode: http.StatusNotFound, Message: "device not found"}
+	}
+
+	delete(f.currentUser.devices, deviceID)
+	return nil
+}
+
+func (f *FakeClient) UploadPreKeys(preKeys []apitypes.PreKey) (apitypes.UploadPreKeysResponse, error) {
+	dev := f.currentDevice()
+	if dev.keyBundle == nil {
+		return apitypes.UploadPreKeysResponse{}, &ServerError{StatusCode: http.StatusNotFound, Message: "Device has no key bundle"}
+	}
+
+	dev.keyBundle.PreKeys = append(dev.keyBundle.PreKeys, preKeys...)
+	return apitypes.UploadPreKeysResponse{Count: len(dev.keyBundle.PreKeys)}, nil
+}
+
+func (f *FakeClient) UploadSignedPreKey(signedPreKey apitypes.SignedPreKey) error {
+	dev := f.currentDevice()
+	if dev.keyBundle == nil {
+		return &ServerError{StatusCode: http.StatusNotFound, Message: "Device has no key bundle"}
+	}
+
+	dev.keyBundle.SignedPreKey = signedPreKey
+	return nil
+}
+
 func (f *FakeClient) GetUser(id string) (apitypes.GetUserResponse, error) {
 	user, exists := f.users[id]
 	if !exists {
@@ -176,35 +271,20 @@ func (f *FakeClient) CreateConversation(id string, otherParticipants []apitypes.
 	}
 
 	participantIDs := []string{f.currentUser.id}
-
 	for _, participant := range otherParticipants {
-		participantIDs = append(participantIDs, participant.ID)
+		if !slices.Contains(participantIDs, participant.ID) {
+			participantIDs = append(participantIDs, participant.ID)
+		}
+	}
 
-		// Prepare websocket message for the participant
-		wsPayload := apitypes.WSNewConversationPayload{
+	for _, participant := range otherParticipants {
+		f.queueWSMessage(deviceAddress{participant.ID, participant.DeviceID}, apitypes.MessageTypeNewConversation, apitypes.WSNewConversationPayload{
 			ConversationID:         id,
 			SenderID:               f.currentUser.id,
-			ParticipantIDs:         []string{f.currentUser.id},
+			SenderDeviceID:         f.currentDeviceID,
+			ParticipantIDs:         participantIDs,
 			KeyDistributionMessage: participant.KeyDistributionMessage,
-		}
-		for _, wsParticipant := range otherParticipants {
-			if wsParticipant.ID != participant.ID {
-				wsPayload.ParticipantIDs = append(wsPayload.ParticipantIDs, wsParticipant.ID)
-			}
-		}
-
-		wsMessage := apitypes.WSMessage{
-			ID:   uuid.New().String(),
-			Type: apitypes.MessageTypeNewConversation,
-			Data: mustMarshal(wsPayload),
-		}
-
-		participant, exists := f.users[participant.ID]
-		if !exists {
-			panic(fmt.Sprintf("Participant %s is not registered in the api client", participant.id))
-		}
-
-		participant.pendingWSMessages = append(participant.pendingWSMessages, wsMessage)
+		})
 	}
 
 	conv := conversation{
@@ -224,37 +304,232 @@ func (f *FakeClient) SendMessage(conversationID string, content []byte) (apitype
 	conversation := f.conversations[conversationID]
 	msgID := uuid.New().String()
 	timestamp := time.Now().UnixMilli()
+	var expiresAt int64
+	if conversation.ExpirationTimer > 0 {
+		expiresAt = timestamp + conversation.ExpirationTimer*1000
+	}
 
-	for _, id := range conversation.ParticipantIDs {
-		if id != f.currentUser.id {
-			wsPayload := apitypes.WSNewMessagePayload{
-				ConversationID: conversationID,
-				MessageID:      msgID,
-				SenderID:       f.currentUser.id,
-				Content:        content,
-				CreatedAt:      timestamp,
-			}
-			wsMessage := apitypes.WSMessage{
-				ID:   uuid.New().String(),
-				Type: apitypes.MessageTypeNewMessage,
-				Data: mustMarshal(wsPayload),
-			}
-
-			participant, exists := f.users[id]
-			if !exists {
-				panic(fmt.Sprintf("Participant %s is not registered in the api client", id))
-			}
-
-			participant.pendingWSMessages = append(participant.pendingWSMessages, wsMessage)
-		}
+	for _, addr := range f.devicesOf(conversation.ParticipantIDs) {
+		f.queueWSMessage(addr, apitypes.MessageTypeNewMessage, apitypes.WSNewMessagePayload{
+			ConversationID: conversationID,
+			MessageID:      msgID,
+			SenderID:       f.currentUser.id,
+			SenderDeviceID: f.currentDeviceID,
+			Content:        content,
+			CreatedAt:      timestamp,
+			ExpiresAt:      expiresAt,
+		})
 	}
 
 	return apitypes.SendMessageResponse{
 		MessageID: msgID,
 		CreatedAt: timestamp,
+		ExpiresAt: expiresAt,
 	}, nil
 }
 
+// SendReference sends the message like SendMessage, the fake doesn't keep messages to purge
+func (f *FakeClient) SendReference(conversationID, refersTo string, content []byte) (apitypes.SendMessageResponse, error) {
+	return f.SendMessage(conversationID, content)
+}
+
+// DeleteMessage does nothing since the fake doesn't keep messages, their notifications stay queued
+func (f *FakeClient) DeleteMessage(conversationID, messageID string) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	return nil
+}
+
+func (f *FakeClient) SetExpirationTimer(conversationID string, seconds int64) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	conv := f.conversations[conversationID]
+	conv.ExpirationTimer = seconds
+	f.conversations[conversationID] = conv
+
+	for _, addr := range f.devicesOf(conv.ParticipantIDs) {
+		f.queueWSMessage(addr, apitypes.MessageTypeExpirationTimer, apitypes.WSExpirationTimerPayload{
+			ConversationID:  conversationID,
+			SenderID:        f.currentUser.id,
+			SenderDeviceID:  f.currentDeviceID,
+			ExpirationTimer: seconds,
+			CreatedAt:       time.Now().UnixMilli(),
+		})
+	}
+
+	return nil
+}
+
+func (f *FakeClient) AddParticipants(conversationID string, participants []apitypes.Participant) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	conv := f.conversations[conversationID]
+	addedIDs := make([]string, 0, len(participants))
+	keyMessages := make(map[deviceAddress][]byte, len(participants))
+	for _, p := range participants {
+		if p.ID != f.currentUser.id && !slices.Contains(addedIDs, p.ID) {
+			addedIDs = append(addedIDs, p.ID)
+			if !slices.Contains(conv.ParticipantIDs, p.ID) {
+				conv.ParticipantIDs = append(conv.ParticipantIDs, p.ID)
+			}
+		}
+		keyMessages[deviceAddress{p.ID, p.DeviceID}] = p.KeyDistributionMessage
+	}
+	f.conversations[conversationID] = conv
+
+	for _, addr := range f.devicesOf(conv.ParticipantIDs) {
+		f.queueWSMessage(addr, apitypes.MessageTypeParticipantAdded, apitypes.WSParticipantAddedPayload{
+			ConversationID:         conversationID,
+			SenderID:               f.currentUser.id,
+			SenderDeviceID:         f.currentDeviceID,
+			ParticipantIDs:         conv.ParticipantIDs,
+			AddedIDs:               addedIDs,
+			KeyDistributionMessage: keyMessages[addr],
+		})
+	}
+
+	return nil
+}
+
+func (f *FakeClient) RemoveParticipant(conversationID, participantID string, keyDistributions []apitypes.Participant) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	conv := f.conversations[conversationID]
+	remaining := make([]string, 0, len(conv.ParticipantIDs))
+	for _, id := range conv.ParticipantIDs {
+		if id != participantID {
+			remaining = append(remaining, id)
+		}
+	}
+	conv.ParticipantIDs = remaining
+	f.conversations[conversationID] = conv
+
+	keyMessages := make(map[deviceAddress][]byte, len(keyDistributions))
+	for _, p := range keyDistributions {
+		keyMessages[deviceAddress{p.ID, p.DeviceID}] = p.KeyDistributionMessage
+	}
+
+	recipientIDs := append(slices.Clone(remaining), participantID)
+	for _, addr := range f.devicesOf(recipientIDs) {
+		f.queueWSMessage(addr, apitypes.MessageTypeParticipantRemoved, apitypes.WSParticipantRemovedPayload{
+			ConversationID:         conversationID,
+			SenderID:               f.currentUser.id,
+			SenderDeviceID:         f.currentDeviceID,
+			RemovedID:              participantID,
+			ParticipantIDs:         remaining,
+			KeyDistributionMessage: keyMessages[addr],
+		})
+	}
+
+	return nil
+}
+
+func (f *FakeClient) DistributeSenderKeys(conversationID string, participants []apitypes.Participant) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	for _, p := range participants {
+		f.queueWSMessage(deviceAddress{p.ID, p.DeviceID}, apitypes.MessageTypeSenderKeyDistribution, apitypes.WSSenderKeyPayload{
+			ConversationID:         conversationID,
+			SenderID:               f.currentUser.id,
+			SenderDeviceID:         f.currentDeviceID,
+			KeyDistributionMessage: p.KeyDistributionMessage,
+		})
+	}
+
+	return nil
+}
+
+// SendTyping drops the typing event, like the server does for participants that aren't connected
+func (f *FakeClient) SendTyping(conversationID string, typing bool) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	return nil
+}
+
+// SendReceipt queues the receipt for every device of the author
+func (f *FakeClient) SendReceipt(req apitypes.SendReceiptRequest) error {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+
+	for _, addr := range f.devicesOf([]string{req.AuthorID}) {
+		f.queueWSMessage(addr, apitypes.MessageTypeReceipt, apitypes.WSReceiptPayload{
+			ConversationID: req.ConversationID,
+			SenderID:       f.currentUser.id,
+			SenderDeviceID: f.currentDeviceID,
+			MessageIDs:     req.MessageIDs,
+			Type:           req.Type,
+			CreatedAt:      time.Now().UnixMilli(),
+		})
+	}
+
+	return nil
+}
+
+// ReplaceKeyBundle swaps the key bundle of a registered user, which simulates the user reinstalling the app
+func (f *FakeClient) ReplaceKeyBundle(userID string, keyBundle apitypes.KeyBundle) {
+	user, exists := f.users[userID]
+	if !exists {
+		panic(fmt.Sprintf("User %s is not registered in the api client", userID))
+	}
+
+	user.devices[1].keyBundle = &keyBundle
+}
+
+func (f *FakeClient) currentDevice() *device {
+	if f.currentUser == nil {
+		panic("This endpoint can only be used by authenticated user. Use SignUp or SignIn function for user authentication.")
+	}
+	return f.currentUser.devices[f.currentDeviceID]
+}
+
+// devicesOf returns the addresses of all devices of the given users except the current device
+func (f *FakeClient) devicesOf(userIDs []string) []deviceAddress {
+	var addresses []deviceAddress
+	for _, id := range userIDs {
+		user, exists := f.users[id]
+		if !exists {
+			panic(fmt.Sprintf("Participant %s is not registered in the api client", id))
+		}
+
+		for _, deviceID := range user.deviceIDs() {
+			if id != f.currentUser.id || deviceID != f.currentDeviceID {
+				addresses = append(addresses, deviceAddress{id, deviceID})
+			}
+		}
+	}
+	return addresses
+}
+
+func (f *FakeClient) queueWSMessage(addr deviceAddress, msgType apitypes.WSMessageType, payload any) {
+	participant, exists := f.users[addr.userID]
+	if !exists {
+		panic(fmt.Sprintf("Participant %s is not registered in the api client", addr.userID))
+	}
+	dev, exists := participant.devices[addr.deviceID]
+	if !exists {
+		panic(fmt.Sprintf("Device %d of participant %s is not registered in the api client", addr.deviceID, addr.userID))
+	}
+
+	dev.pendingWSMessages = append(dev.pendingWSMessages, apitypes.WSMessage{
+		ID:   uuid.New().String(),
+		Type: msgType,
+		Data: mustMarshal(payload),
+	})
+}
+
 func mustMarshal(v any) []byte {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -5,19 +5,49 @@ import (
 )
 
 type StubClient struct {
-	SignUpResponse          apitypes.SignUpResponse
-	SignUpError             error
-	SignInResponse          apitypes.SignInResponse
-	SignInError             error
-	GetPreKeyBundleResponse apitypes.GetPreKeyBundleResponse
-	GetPreKeyBundleError    error
-	GetUserResponse         apitypes.GetUserResponse
-	GetUserError            error
-	GetAllUsersResponse     apitypes.GetAllUsersResponse
-	GetAllUsersError        error
-	CreateConversationError error
-	SendMessageResponse     apitypes.SendMessageResponse
-	SendMessageError        error
+	SignUpResponse            apitypes.SignUpResponse
+	SignUpError               error
+	SignInResponse            apitypes.SignInResponse
+	SignInError               error
+	GetPreKeyBundleResponse   apitypes.GetPreKeyBundleResponse
+	GetPreKeyBundleError      error
+	GetDevicesResponse        apitypes.GetDevicesResponse
+	GetDevicesError           error
+	RemoveDeviceError         error
+	UploadPreKeysError        error
+	UploadSignedPreKeyError   error
+	GetUserResponse           apitypes.GetUserResponse
+	GetUserError              error
+	GetAllUsersResponse       apitypes.GetAllUsersResponse
+	GetAllUsersError          error
+	CreateConversationError   error
+	SendMessageResponse       apitypes.SendMessageResponse
+	SendMessageError          error
+	AddParticipantsError      error
+	RemoveParticipantError    error
+	DistributeSenderKeysError error
+	SetExpirationTimerError   error
+	DeleteMessageError        error
+	SendTypingError           error
+	SendReceiptError          error
+	CurrentUserID             string
+
+	// Sender key distribution messages handed to the stub, keyed by recipient ID
+	SentKeyDistributions map[string][]byte
+	// Pre-keys uploaded through the stub
+	UploadedPreKeys []apitypes.PreKey
+	// Signed pre-keys uploaded through the stub
+	UploadedSignedPreKeys []apitypes.SignedPreKey
+	// Typing events sent through the stub
+	SentTyping []apitypes.WSTypingPayload
+	// Receipts sent through the stub
+	SentReceipts []apitypes.SendReceiptRequest
+	// Expiration timers set through the stub, keyed by conversation ID
+	ExpirationTimers map[string]int64
+	// IDs of the messages purged through the stub
+	DeletedMessages []string
+	// IDs of the messages referred to by the messages sent through the stub
+	SentReferences []string
 
 	connectionStateHandler ConnectionStateHandler
 	wsHandlers             map[apitypes.WSMessageType]MessageHandler
@@ -25,7 +55,9 @@ type StubClient struct {
 
 func NewStubClient() *StubClient {
 	return &StubClient{
-		wsHandlers: make(map[apitypes.WSMessageType]MessageHandler),
+		wsHandlers:           make(map[apitypes.WSMessageType]MessageHandler),
+		SentKeyDistributions: make(map[string][]byte),
+		ExpirationTimers:     make(map[string]int64),
 	}
 }
 
@@ -47,6 +79,10 @@ func (s *StubClient) SetConnectionStateHandler(handler ConnectionStateHandler) {
 
 func (s *StubClient) Close() {}
 
+func (s *StubClient) UserID() string {
+	return s.CurrentUserID
+}
+
 func (s *StubClient) SignUp(username, password string, keyBundle apitypes.KeyBundle) (apitypes.SignUpResponse, error) {
 	if s.SignUpError != nil {
 		return apitypes.SignUpResponse{}, s.SignUpError
@@ -55,7 +91,7 @@ func (s *StubClient) SignUp(username, password string, keyBundle apitypes.KeyBun
 	return s.SignUpResponse, nil
 }
 
-func (s *StubClient) SignIn(username, password string) (apitypes.SignInResponse, error) {
+func (s *StubClient) SignIn(username, password string, deviceID uint32, keyBundle *apitypes.KeyBundle) (apitypes.SignInResponse, error) {
 	if s.SignInError != nil {
 		return apitypes.SignInResponse{}, s.SignInError
 	}
@@ -63,7 +99,7 @@ func (s *StubClient) SignIn(username, password string) (apitypes.SignInResponse,
 	return s.SignInResponse, nil
 }
 
-func (s *StubClient) GetPreKeyBundle(id string) (apitypes.GetPreKeyBundleResponse, error) {
+func (s *StubClient) GetPreKeyBundle(id string, deviceID uint32) (apitypes.GetPreKeyBundleResponse, error) {
 	if s.GetPreKeyBundleError != nil {
 		return apitypes.GetPreKeyBundleResponse{}, s.GetPreKeyBundleError
 	}
@@ -71,6 +107,40 @@ func (s *StubClient) GetPreKeyBundle(id string) (apitypes.GetPreKeyBundleRespons
 	return s.GetPreKeyBundleResponse, nil
 }
 
+// GetDevices returns the configured response. Without one, every user has a single device with ID 1.
+func (s *StubClient) GetDevices(id string) (apitypes.GetDevicesResponse, error) {
+	if s.GetDevicesError != nil {
+		return apitypes.GetDevicesResponse{}, s.GetDevicesError
+	}
+	if s.GetDevicesResponse.Devices == nil {
+		return apitypes.GetDevicesResponse{Devices: []apitypes.Device{{ID: 1}}}, nil
+	}
+
+	return s.GetDevicesResponse, nil
+}
+
+func (s *StubClient) RemoveDevice(deviceID uint32) error {
+	return s.RemoveDeviceError
+}
+
+func (s *StubClient) UploadPreKeys(preKeys []apitypes.PreKey) (apitypes.UploadPreKeysResponse, error) {
+	if s.UploadPreKeysError != nil {
+		return apitypes.UploadPreKeysResponse{}, s.UploadPreKeysError
+	}
+
+	s.UploadedPreKeys = append(s.UploadedPreKeys, preKeys...)
+	return apitypes.UploadPreKeysResponse{Count: len(s.UploadedPreKeys)}, nil
+}
+
+func (s *StubClient) UploadSignedPreKey(signedPreKey apitypes.SignedPreKey) error {
+	if s.UploadSignedPreKeyError != nil {
+		return s.UploadSignedPreKeyError
+	}
+
+	s.UploadedSignedPreKeys = append(s.UploadedSignedPreKeys, signedPreKey)
+	return nil
+}
+
 func (s *StubClient) GetUser(id string) (apitypes.GetUserResponse, error) {
 	if s.GetUserError != nil {
 		return apitypes.GetUserResponse{}, s.GetUserError
@@ -102,3 +172,81 @@ func (s *StubClient) SendMessage(conversationID string, content []byte) (apitype
 
 	return s.SendMessageResponse, nil
 }
+
+func (s *StubClient) SendReference(conversationID, refersTo string, content []byte) (apitypes.SendMessageResponse, error) {
+	if s.SendMessageError != nil {
+		return apitypes.SendMessageResponse{}, s.SendMessageError
+	}
+
+	s.SentReferences = append(s.SentReferences, refersTo)
+	return s.SendMessageResponse, nil
+}
+
+func (s *StubClient) AddParticipants(conversationID string, participants []apitypes.Participant) error {
+	if s.AddParticipantsError != nil {
+		return s.AddParticipantsError
+	}
+
+	s.recordKeyDistributions(participants)
+	return nil
+}
+
+func (s *StubClient) RemoveParticipant(conversationID, participantID string, keyDistributions []apitypes.Participant) error {
+	if s.RemoveParticipantError != nil {
+		return s.RemoveParticipantError
+	}
+
+	s.recordKeyDistributions(keyDistributions)
+	return nil
+}
+
+func (s *StubClient) DistributeSenderKeys(conversationID string, participants []apitypes.Participant) error {
+	if s.DistributeSenderKeysError != nil {
+		return s.DistributeSenderKeysError
+	}
+
+	s.recordKeyDistributions(participants)
+	return nil
+}
+
+func (s *StubClient) DeleteMessage(conversationID, messageID string) error {
+	if s.DeleteMessageError != nil {
+		return s.DeleteMessageError
+	}
+
+	s.DeletedMessages = append(s.DeletedMessages, messageID)
+	return nil
+}
+
+func (s *StubClient) SetExpirationTimer(conversationID string, seconds int64) error {
+	if s.SetExpirationTimerError != nil {
+		return s.SetExpirationTimerError
+	}
+
+	s.ExpirationTimers[conversationID] = seconds
+	return nil
+}
+
+func (s *StubClient) SendTyping(conversationID string, typing bool) error {
+	if s.SendTypingError != nil {
+		return s.SendTypingError
+	}
+
+	s.SentTyping = append(s.SentTyping, apitypes.WSTypingPayload{ConversationID: conversationID, Typing: typing})
+	return nil
+}
+
+func (s *StubClient) SendReceipt(req apitypes.SendReceiptRequest) error {
+	if s.SendReceiptError != nil {
+		return s.SendReceiptError
+	}
+
+	s.SentReceipts = append(s.SentReceipts, req)
+	return nil
+}
+
+func (s *StubClient) recordKeyDistributions(participants []apitypes.Participant) {
+	for _, p := range participants {
+		s.SentKeyDistributions[p.ID] = p.KeyDistributionMessage
+	}
+}
@@ -2,18 +2,25 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"signal-chat/internal/apitypes"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// ErrNotConnected is returned for requests made while the websocket isn't connected
+var ErrNotConnected = errors.New("websocket is not connected")
+
 type MessageHandler func(payload json.RawMessage)
 
 type ConnectionStateHandler func(state ConnectionState)
@@ -29,33 +36,53 @@ type WebSocketClient struct {
 	maxMessageSize         int64
 	baseReconnectDelay     time.Duration
 	maxReconnectDelay      time.Duration
+	restartReconnectDelay  time.Duration
 	writeWait              time.Duration
 	readWait               time.Duration
-	conn                   *websocket.Conn
+	requestTimeout         time.Duration
 	serverURL              string
 	authToken              string
-	send                   chan []byte
+	send                   chan *apitypes.WSMessage
 	mu                     sync.RWMutex
 	closeOnce              sync.Once
 	closed                 atomic.Bool
 	reconnectMu            sync.Mutex
 	handlers               map[apitypes.WSMessageType][]MessageHandler
 	connectionStateHandler ConnectionStateHandler
-	writeDone              chan struct{}
+	connected              atomic.Bool
+	// pending holds the channels awaiting the response to each request sent over the connection
+	pending map[string]chan apitypes.WSMessage
+	// codec is the encoding offered to the server, every connection uses the one negotiated when it was opened
+	codec apitypes.Codec
+	// cursor is the sequence number of the last message processed by the handlers, the server resumes delivery after
+	// it on reconnect
+	cursor atomic.Uint64
+	// incoming holds the received messages waiting for their handlers. Handlers run on a single worker, in order of
+	// delivery, so that the ones making requests don't hold up reading the responses.
+	incoming      []apitypes.WSMessage
+	incomingMu    sync.Mutex
+	incomingReady chan struct{}
+	workerOnce    sync.Once
+	done          chan struct{}
 }
 
 func NewWebSocketClient(serverURL string) *WebSocketClient {
 	wsURL := strings.Replace(strings.Replace(serverURL, "https://", "wss://", 1), "http://", "ws://", 1) + "/ws"
 	return &WebSocketClient{
-		maxMessageSize:     512,
-		baseReconnectDelay: 1 * time.Second,
-		maxReconnectDelay:  30 * time.Second,
-		writeWait:          10 * time.Second,
-		readWait:           60 * time.Second,
-		serverURL:          wsURL,
-		send:               make(chan []byte, 256),
-		handlers:           make(map[apitypes.WSMessageType][]MessageHandler),
-		writeDone:          make(chan struct{}, 1),
+		maxMessageSize:        apitypes.WSMaxMessageSize,
+		baseReconnectDelay:    1 * time.Second,
+		maxReconnectDelay:     30 * time.Second,
+		restartReconnectDelay: 100 * time.Millisecond,
+		writeWait:             10 * time.Second,
+		readWait:              60 * time.Second,
+		requestTimeout:        10 * time.Second,
+		serverURL:             wsURL,
+		send:                  make(chan *apitypes.WSMessage, 256),
+		handlers:              make(map[apitypes.WSMessageType][]MessageHandler),
+		pending:               make(map[string]chan apitypes.WSMessage),
+		codec:                 apitypes.JSONCodec,
+		incomingReady:         make(chan struct{}, 1),
+		done:                  make(chan struct{}),
 	}
 }
 
@@ -83,18 +110,36 @@ func (c *WebSocketClient) Connect(authToken string) error {
 		panic("client has been closed")
 	}
 
+	c.mu.Lock()
 	c.authToken = authToken
+	c.mu.Unlock()
+
+	serverURL := c.serverURL
+	if cursor := c.cursor.Load(); cursor > 0 {
+		serverURL += "?" + url.Values{apitypes.WSCursorParam: []string{strconv.FormatUint(cursor, 10)}}.Encode()
+	}
 
 	header := http.Header{"Authorization": []string{"Bearer " + authToken}}
 	dialer := websocket.Dialer{HandshakeTimeout: 45 * time.Second}
-	conn, _, err := dialer.Dial(c.serverURL, header)
+	if subprotocol := c.codec.Subprotocol(); subprotocol != "" {
+		dialer.Subprotocols = []string{subprotocol}
+	}
+	conn, _, err := dialer.Dial(serverURL, header)
 	if err != nil {
 		return fmt.Errorf("failed to connect to websocket server: %w", err)
 	}
 
-	c.conn = conn
-	go c.writePump()
-	go c.readPump()
+	// The pumps only use the connection they were started for, readPump waits for writePump to exit before a new
+	// connection is opened
+	codec := apitypes.CodecForSubprotocol(conn.Subprotocol())
+	stopWriting := make(chan struct{})
+	writerDone := make(chan struct{})
+	c.connected.Store(true)
+	c.workerOnce.Do(func() {
+		go c.processMessages()
+	})
+	go c.writePump(conn, codec, stopWriting, writerDone)
+	go c.readPump(conn, codec, stopWriting, writerDone)
 	c.notifyConnectionState(StateConnected)
 
 	return nil
@@ -103,13 +148,14 @@ func (c *WebSocketClient) Connect(authToken string) error {
 func (c *WebSocketClient) Close() {
 	c.closeOnce.Do(func() {
 		c.closed.Store(true)
+		close(c.done)
 		close(c.send)
 	})
 }
 
-// reconnect handles automatic reconnection attempts
-func (c *WebSocketClient) reconnect() {
-	delay := c.baseReconnectDelay
+// reconnect handles automatic reconnection attempts, the delay between them starts at the given one and doubles after
+// every failed attempt
+func (c *WebSocketClient) reconnect(delay time.Duration) {
 	c.notifyConnectionState(StateReconnecting)
 
 	for {
@@ -119,7 +165,10 @@ func (c *WebSocketClient) reconnect() {
 
 		log.Printf("attempting to reconnect...")
 
-		if err := c.Connect(c.authToken); err != nil {
+		c.mu.RLock()
+		authToken := c.authToken
+		c.mu.RUnlock()
+		if err := c.Connect(authToken); err != nil {
 			log.Printf("reconnection attempt failed: %v", err)
 			time.Sleep(delay)
 			delay *= 2
@@ -135,93 +184,263 @@ func (c *WebSocketClient) reconnect() {
 	log.Printf("successfully reconnected to WebSocket server")
 }
 
-func (c *WebSocketClient) sendACK(messageID string) {
-	ack := &apitypes.WSMessage{
-		ID:   messageID,
-		Type: apitypes.MessageTypeAck,
+// Send sends the payload as a message of the given type without waiting for an answer. It returns ErrNotConnected
+// when the message couldn't be sent.
+func (c *WebSocketClient) Send(messageType apitypes.WSMessageType, payload any) error {
+	if !c.connected.Load() || c.IsClosed() {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+	message := &apitypes.WSMessage{
+		ID:   uuid.NewString(),
+		Type: messageType,
+		Data: data,
 	}
 
-	payload, err := json.Marshal(ack)
+	select {
+	case c.send <- message:
+		return nil
+	case <-time.After(c.writeWait):
+		return ErrNotConnected
+	}
+}
+
+// Request sends the payload as a request of the given type and waits for the response. It returns ErrNotConnected when
+// the request couldn't be sent, and a ServerError when the server rejected it.
+func (c *WebSocketClient) Request(messageType apitypes.WSMessageType, payload any) (json.RawMessage, error) {
+	if !c.connected.Load() || c.IsClosed() {
+		return nil, ErrNotConnected
+	}
+
+	data, err := json.Marshal(payload)
 	if err != nil {
-		panic(fmt.Errorf("internal error: failed to marshal ACK: %w", err))
+		return nil, fmt.Errorf("failed to marshal request payload: %w", err)
+	}
+	requestID := uuid.NewString()
+	request := &apitypes.WSMessage{
+		ID:   requestID,
+		Type: messageType,
+		Data: data,
+	}
+
+	responseChan := make(chan apitypes.WSMessage, 1)
+	c.mu.Lock()
+	c.pending[requestID] = responseChan
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, requestID)
+		c.mu.Unlock()
+	}()
+
+	select {
+	case c.send <- request:
+	case <-time.After(c.writeWait):
+		return nil, ErrNotConnected
+	}
+
+	select {
+	case response, ok := <-responseChan:
+		if !ok {
+			return nil, errors.New("websocket connection closed before the response arrived")
+		}
+		if response.Type == apitypes.MessageTypeError {
+			var errPayload apitypes.WSErrorPayload
+			if err := json.Unmarshal(response.Data, &errPayload); err != nil {
+				return nil, fmt.Errorf("got error unmarshalling error response from server: %w", err)
+			}
+			return nil, &ServerError{StatusCode: errPayload.Status, Message: errPayload.Message}
+		}
+		return response.Data, nil
+	case <-time.After(c.requestTimeout):
+		return nil, errors.New("timed out waiting for the response")
+	}
+}
+
+// resolveRequest hands the response to the request waiting for it
+func (c *WebSocketClient) resolveRequest(response apitypes.WSMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if responseChan, exists := c.pending[response.ID]; exists {
+		responseChan <- response
+		delete(c.pending, response.ID)
+	}
+}
+
+// failPendingRequests fails the requests that were sent over a connection that is gone
+func (c *WebSocketClient) failPendingRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, responseChan := range c.pending {
+		close(responseChan)
+		delete(c.pending, id)
 	}
+}
 
-	c.send <- payload
+// sendACK confirms that all messages up to and including seq were processed
+func (c *WebSocketClient) sendACK(messageID string, seq uint64) {
+	if c.IsClosed() {
+		return
+	}
+	c.send <- &apitypes.WSMessage{
+		ID:   messageID,
+		Seq:  seq,
+		Type: apitypes.MessageTypeAck,
+	}
 }
 
-func (c *WebSocketClient) readPump() {
+func (c *WebSocketClient) readPump(conn *websocket.Conn, codec apitypes.Codec, stopWriting chan<- struct{}, writerDone <-chan struct{}) {
+	// restarting is set when the server closed the connection because it's restarting, it's expected back shortly
+	restarting := false
+
 	defer func() {
-		c.writeDone <- struct{}{}
-		_ = c.conn.Close()
+		c.connected.Store(false)
+		c.failPendingRequests()
+		close(stopWriting)
+		<-writerDone
+		_ = conn.Close()
 
-		if !c.IsClosed() {
-			c.reconnect()
+		if c.IsClosed() {
+			return
+		}
+		if restarting {
+			c.reconnect(c.restartReconnectDelay)
+		} else {
+			c.reconnect(c.baseReconnectDelay)
 		}
 	}()
 
-	c.conn.SetReadLimit(c.maxMessageSize)
-	_ = c.conn.SetReadDeadline(time.Now().Add(c.readWait))
-	c.conn.SetPongHandler(func(string) error {
-		_ = c.conn.SetReadDeadline(time.Now().Add(c.readWait))
+	conn.SetReadLimit(c.maxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(c.readWait))
+	conn.SetPongHandler(func(string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(c.readWait))
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure) {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseServiceRestart) {
 				log.Printf("received unexpected close error: %v", err)
 			}
+			restarting = websocket.IsCloseError(err, websocket.CloseServiceRestart)
 			break
 		}
 
 		var wsMsg apitypes.WSMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
+		if err := codec.UnmarshalWSMessage(message, &wsMsg); err != nil {
 			log.Printf("received malformed websocket message: %v", err)
 			continue
 		}
 
-		// Send ACK for received message if it's not an ACK itself
-		if wsMsg.Type != apitypes.MessageTypeAck {
-			c.sendACK(wsMsg.ID)
+		// Responses answer a request and aren't stored in the inbox of the device, so they aren't acknowledged
+		if wsMsg.Type == apitypes.MessageTypeResponse || wsMsg.Type == apitypes.MessageTypeError {
+			c.resolveRequest(wsMsg)
+			continue
 		}
 
-		// Notify wsHandlers
-		c.mu.RLock()
-		handlers := c.handlers[wsMsg.Type]
-		c.mu.RUnlock()
-
-		for _, handler := range handlers {
-			go handler(wsMsg.Data)
-		}
+		c.enqueue(wsMsg)
 	}
 }
 
-func (c *WebSocketClient) writePump() {
-	// Drain old leftover signal if any
+// enqueue hands a received message to the worker running the handlers
+func (c *WebSocketClient) enqueue(msg apitypes.WSMessage) {
+	c.incomingMu.Lock()
+	c.incoming = append(c.incoming, msg)
+	c.incomingMu.Unlock()
+
 	select {
-	case <-c.writeDone:
+	case c.incomingReady <- struct{}{}:
 	default:
 	}
+}
+
+// processMessages runs the handlers of the received messages one after the other until the client is closed
+func (c *WebSocketClient) processMessages() {
+	for {
+		c.incomingMu.Lock()
+		if len(c.incoming) == 0 {
+			c.incomingMu.Unlock()
+			select {
+			case <-c.incomingReady:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		msg := c.incoming[0]
+		c.incoming = c.incoming[1:]
+		c.incomingMu.Unlock()
+
+		if c.IsClosed() {
+			return
+		}
+		c.handleMessage(msg)
+	}
+}
+
+func (c *WebSocketClient) handleMessage(wsMsg apitypes.WSMessage) {
+	// The server redelivers messages that weren't acknowledged in time, skip the ones that were already processed
+	if wsMsg.Seq != 0 && wsMsg.Seq <= c.cursor.Load() {
+		c.sendACK(wsMsg.ID, c.cursor.Load())
+		return
+	}
+
+	// Handlers run in order of delivery, so that e.g. a new conversation is processed before its first message
+	c.mu.RLock()
+	handlers := c.handlers[wsMsg.Type]
+	c.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(wsMsg.Data)
+	}
+
+	// Send ACK for processed message if it's not an ACK itself
+	if wsMsg.Type != apitypes.MessageTypeAck {
+		if wsMsg.Seq != 0 {
+			c.cursor.Store(wsMsg.Seq)
+		}
+		c.sendACK(wsMsg.ID, c.cursor.Load())
+	}
+}
+
+func (c *WebSocketClient) writePump(conn *websocket.Conn, codec apitypes.Codec, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
 
 	for {
 		select {
 		case message, ok := <-c.send:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+			_ = conn.SetWriteDeadline(time.Now().Add(c.writeWait))
 			if !ok {
-				if err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+				if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
 					log.Printf("failed to write closing websocket message: %v", err)
-					_ = c.conn.Close() // make readPump fail fast
+					_ = conn.Close() // make readPump fail fast
 				}
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			data, err := codec.MarshalWSMessage(message)
+			if err != nil {
+				log.Printf("failed to marshal websocket message: %v", err)
+				continue
+			}
+			frameType := websocket.TextMessage
+			if codec.Binary() {
+				frameType = websocket.BinaryMessage
+			}
+			if err := conn.WriteMessage(frameType, data); err != nil {
 				log.Printf("failed to write websocket message: %v", err)
-				_ = c.conn.Close() // make readPump fail fast
+				_ = conn.Close() // make readPump fail fast
 				return
 			}
-		case <-c.writeDone:
+		case <-stop:
 			return
 		}
 	}
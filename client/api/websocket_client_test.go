@@ -200,6 +200,7 @@ func TestWebSocketClient_MessageHandling(t *testing.T) {
 			// Send test message
 			msg := apitypes.WSMessage{
 				ID:   "test-id",
+				Seq:  7,
 				Type: apitypes.MessageTypeNewMessage,
 				Data: json.RawMessage(`{"text":"Hello, world!"}`),
 			}
@@ -233,11 +234,63 @@ func TestWebSocketClient_MessageHandling(t *testing.T) {
 		case ack := <-ackReceived:
 			assert.Equal(t, apitypes.MessageTypeAck, ack.Type)
 			assert.Equal(t, "test-id", ack.ID)
+			assert.Equal(t, uint64(7), ack.Seq)
 		case <-time.After(time.Second):
 			t.Fatal("Timeout waiting for ACK message")
 		}
 	})
 
+	t.Run("skips redelivered message and acknowledges its cursor", func(t *testing.T) {
+		// Arrange
+		acks := make(chan apitypes.WSMessage, 2)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, connClose := testUpgradeToWebSocket(t, w, r)
+			defer connClose()
+
+			// Send the same message twice, as the server does when the ACK is late
+			msg := apitypes.WSMessage{
+				ID:   "test-id",
+				Seq:  3,
+				Type: apitypes.MessageTypeNewMessage,
+				Data: json.RawMessage(`{"text":"Hello, world!"}`),
+			}
+			require.NoError(t, conn.WriteJSON(msg))
+			require.NoError(t, conn.WriteJSON(msg))
+
+			for {
+				var ack apitypes.WSMessage
+				if err := conn.ReadJSON(&ack); err != nil {
+					break
+				}
+				acks <- ack
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+
+		var handled atomic.Int32
+		client.SetMessageHandler(apitypes.MessageTypeNewMessage, func(payload json.RawMessage) {
+			handled.Add(1)
+		})
+
+		// Act
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+
+		// Assert
+		for i := 0; i < 2; i++ {
+			select {
+			case ack := <-acks:
+				assert.Equal(t, uint64(3), ack.Seq)
+			case <-time.After(time.Second):
+				t.Fatal("Timeout waiting for ACK message")
+			}
+		}
+		assert.Equal(t, int32(1), handled.Load(), "redelivered message should be handled only once")
+	})
+
 	t.Run("doesn't call handler for different message type", func(t *testing.T) {
 		// Arrange
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -348,6 +401,186 @@ func TestWebSocketClient_MessageHandling(t *testing.T) {
 	})
 }
 
+func TestWebSocketClient_Request(t *testing.T) {
+	t.Run("returns data of the response with the request ID", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, connClose := testUpgradeToWebSocket(t, w, r)
+			defer connClose()
+
+			var request apitypes.WSMessage
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			assert.Equal(t, apitypes.MessageTypeSendMessage, request.Type)
+			assert.JSONEq(t, `{"text":"Hello, world!"}`, string(request.Data))
+
+			// Answer an unrelated request first, the client must wait for the one with its ID
+			_ = conn.WriteJSON(apitypes.WSMessage{ID: "other-id", Type: apitypes.MessageTypeResponse, Data: json.RawMessage(`{"other":true}`)})
+			_ = conn.WriteJSON(apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse, Data: json.RawMessage(`{"messageID":"msg123"}`)})
+
+			// Keep connection open
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+
+		// Act
+		data, err := client.Request(apitypes.MessageTypeSendMessage, map[string]string{"text": "Hello, world!"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"messageID":"msg123"}`, string(data))
+	})
+
+	t.Run("resolves requests made by message handlers", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, connClose := testUpgradeToWebSocket(t, w, r)
+			defer connClose()
+
+			_ = conn.WriteJSON(apitypes.WSMessage{ID: "msg-1", Seq: 1, Type: apitypes.MessageTypeNewMessage, Data: json.RawMessage(`{}`)})
+			for {
+				var msg apitypes.WSMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					return
+				}
+				if msg.Type == apitypes.MessageTypeReceipt {
+					_ = conn.WriteJSON(apitypes.WSMessage{ID: msg.ID, Type: apitypes.MessageTypeResponse, Data: json.RawMessage(`{}`)})
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		client.requestTimeout = time.Second
+		requestErr := make(chan error, 1)
+		client.SetMessageHandler(apitypes.MessageTypeNewMessage, func(payload json.RawMessage) {
+			_, err := client.Request(apitypes.MessageTypeReceipt, map[string]string{})
+			requestErr <- err
+		})
+
+		// Act
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+		defer client.Close()
+
+		// Assert
+		select {
+		case err := <-requestErr:
+			assert.NoError(t, err, "the response should be read while the handler waits for it")
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for the handler")
+		}
+	})
+
+	t.Run("speaks protobuf in binary frames when the server accepts the subprotocol", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := websocket.Upgrader{Subprotocols: []string{apitypes.WSSubprotocolProtobuf}}
+			conn, err := u.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			frameType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			assert.Equal(t, websocket.BinaryMessage, frameType)
+			var request apitypes.WSMessage
+			require.NoError(t, apitypes.ProtobufCodec.UnmarshalWSMessage(data, &request))
+			assert.Equal(t, apitypes.MessageTypeSendMessage, request.Type)
+			assert.JSONEq(t, `{"conversationID":"conv-1","content":"aGk="}`, string(request.Data))
+
+			response, err := apitypes.ProtobufCodec.MarshalWSResponse(&apitypes.WSMessage{
+				ID:   request.ID,
+				Type: apitypes.MessageTypeResponse,
+				Data: json.RawMessage(`{"messageID":"msg123","timestamp":1234567890}`),
+			}, request.Type)
+			require.NoError(t, err)
+			_ = conn.WriteMessage(websocket.BinaryMessage, response)
+
+			// Keep connection open
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		client.codec = apitypes.ProtobufCodec
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+		defer client.Close()
+
+		// Act
+		data, err := client.Request(apitypes.MessageTypeSendMessage, apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("hi")})
+
+		// Assert
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"messageID":"msg123","timestamp":1234567890}`, string(data))
+	})
+
+	t.Run("returns server error for error response", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, connClose := testUpgradeToWebSocket(t, w, r)
+			defer connClose()
+
+			var request apitypes.WSMessage
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			payload, _ := json.Marshal(apitypes.WSErrorPayload{Status: http.StatusNotFound, Message: "Not Found"})
+			_ = conn.WriteJSON(apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeError, Data: payload})
+
+			// Keep connection open
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+
+		// Act
+		_, err = client.Request(apitypes.MessageTypeSendMessage, map[string]string{"text": "Hello, world!"})
+
+		// Assert
+		var respErr *ServerError
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusNotFound, respErr.StatusCode)
+		assert.Equal(t, "Not Found", respErr.Message)
+	})
+
+	t.Run("returns ErrNotConnected when not connected", func(t *testing.T) {
+		// Arrange
+		client := NewWebSocketClient("http://example.com")
+
+		// Act
+		_, err := client.Request(apitypes.MessageTypeSendMessage, map[string]string{"text": "Hello, world!"})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrNotConnected)
+	})
+}
+
 func TestWebSocketClient_ConnectionStateHandling(t *testing.T) {
 	t.Run("notifies about connection state changes", func(t *testing.T) {
 		// Arrange
@@ -449,6 +682,61 @@ func TestWebSocketClient_Reconnect(t *testing.T) {
 		}
 	})
 
+	t.Run("resumes after the last processed message on reconnect", func(t *testing.T) {
+		// Arrange
+		connectionCount := 0
+		cursors := make(chan string, 2)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			connectionCount++
+			cursors <- r.URL.Query().Get(apitypes.WSCursorParam)
+			conn, connClose := testUpgradeToWebSocket(t, w, r)
+			defer connClose()
+
+			// Deliver a message on the first connection and close it after its ACK to trigger reconnect
+			if connectionCount == 1 {
+				msg := apitypes.WSMessage{
+					ID:   "test-id",
+					Seq:  5,
+					Type: apitypes.MessageTypeNewMessage,
+					Data: json.RawMessage(`{"text":"Hello, world!"}`),
+				}
+				require.NoError(t, conn.WriteJSON(msg))
+
+				var ack apitypes.WSMessage
+				require.NoError(t, conn.ReadJSON(&ack))
+				require.NoError(t, conn.Close())
+				return
+			}
+
+			// Keep connection open
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		client.baseReconnectDelay = 10 * time.Millisecond // Reduce reconnect delay for test to make it faster
+
+		// Act
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+
+		// Assert
+		for _, expected := range []string{"", "5"} {
+			select {
+			case cursor := <-cursors:
+				assert.Equal(t, expected, cursor)
+			case <-time.After(time.Second):
+				t.Fatal("Timeout waiting for connection")
+			}
+		}
+	})
+
 	t.Run("notifies state handler of reconnection attempts", func(t *testing.T) {
 		// Arrange
 		connectionCount := 0
@@ -513,6 +801,56 @@ func TestWebSocketClient_Reconnect(t *testing.T) {
 		}
 	})
 
+	t.Run("reconnects quickly when the server restarts", func(t *testing.T) {
+		// Arrange
+		var connectionCount atomic.Int32
+		reconnected := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch connectionCount.Add(1) {
+			case 1:
+				conn, connClose := testUpgradeToWebSocket(t, w, r)
+				defer connClose()
+
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseServiceRestart, apitypes.WSCloseReasonRestart)
+				require.NoError(t, conn.WriteMessage(websocket.CloseMessage, closeMessage))
+				_, _, _ = conn.ReadMessage()
+			case 2:
+				// The server is still restarting
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				conn, connClose := testUpgradeToWebSocket(t, w, r)
+				defer connClose()
+				close(reconnected)
+
+				// Keep connection open
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						break
+					}
+				}
+			}
+		}))
+		defer server.Close()
+
+		client := NewWebSocketClient(server.URL)
+		client.baseReconnectDelay = time.Minute
+		client.restartReconnectDelay = 10 * time.Millisecond
+
+		// Act
+		err := client.Connect("test-token")
+		require.NoError(t, err)
+
+		// Assert
+		select {
+		case <-reconnected:
+			assert.Equal(t, int32(3), connectionCount.Load())
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for reconnection, the client should not back off as after a failure")
+		}
+		client.Close()
+	})
+
 	t.Run("does not reconnect when closed by client", func(t *testing.T) {
 		// Arrange
 		connectionCount := 0
@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"signal-chat/client/models"
 	"signal-chat/internal/apitypes"
+	"time"
 )
 
+// signedPreKeyCheckInterval is how often a signed in client checks whether its signed pre-key is due for rotation
+const signedPreKeyCheckInterval = time.Hour
+
 var ErrAuthInvalidEmail = errors.New("email is not a valid email address")
 var ErrAuthPwdTooShort = errors.New("password too short")
 
 type AuthAPI interface {
 	SignUp(username, password string, keyBundle apitypes.KeyBundle) (apitypes.SignUpResponse, error)
-	SignIn(username, password string) (apitypes.SignInResponse, error)
+	SignIn(username, password string, deviceID uint32, keyBundle *apitypes.KeyBundle) (apitypes.SignInResponse, error)
 	Close()
 }
 
@@ -24,6 +30,9 @@ type AuthDatabase interface {
 
 type EncryptionInitializer interface {
 	InitializeKeyStore() (apitypes.KeyBundle, error)
+	RotateSignedPreKeyIfDue() error
+	StoreLocalAddress(userID string, deviceID uint32) error
+	LocalDeviceID() (uint32, error)
 }
 
 type Auth struct {
@@ -31,10 +40,14 @@ type Auth struct {
 	apiClient AuthAPI
 	encryptor EncryptionInitializer
 	signedIn  bool
+	// rotationInterval is how often the signed pre-key rotation is checked while signed in
+	rotationInterval time.Duration
+	stopRotation     context.CancelFunc
+	rotationDone     chan struct{}
 }
 
 func NewAuth(db AuthDatabase, apiClient AuthAPI, encryptor EncryptionInitializer) *Auth {
-	return &Auth{db: db, apiClient: apiClient, encryptor: encryptor}
+	return &Auth{db: db, apiClient: apiClient, encryptor: encryptor, rotationInterval: signedPreKeyCheckInterval}
 }
 
 func (a *Auth) SignUp(email, pwd string) (models.User, error) {
@@ -59,7 +72,12 @@ func (a *Auth) SignUp(email, pwd string) (models.User, error) {
 		return models.User{}, fmt.Errorf("failed to sign up: %w", err)
 	}
 
+	if err := a.encryptor.StoreLocalAddress(resp.UserID, resp.DeviceID); err != nil {
+		return models.User{}, err
+	}
+
 	a.signedIn = true
+	a.startSignedPreKeyRotation()
 	user := models.User{
 		ID:       resp.UserID,
 		Username: email,
@@ -80,12 +98,40 @@ func (a *Auth) SignIn(email, pwd string) (models.User, error) {
 		return models.User{}, fmt.Errorf("failed to open user database: %w", err)
 	}
 
-	resp, err := a.apiClient.SignIn(email, pwd)
+	deviceID, err := a.encryptor.LocalDeviceID()
 	if err != nil {
-		return models.User{}, fmt.Errorf("failed to sign in: %w", err)
+		return models.User{}, err
+	}
+
+	// A database without a device ID belongs to a device that was never registered, it gets its own keys
+	var resp apitypes.SignInResponse
+	if deviceID == 0 {
+		bundle, err := a.encryptor.InitializeKeyStore()
+		if err != nil {
+			return models.User{}, err
+		}
+		resp, err = a.apiClient.SignIn(email, pwd, 0, &bundle)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to register device: %w", err)
+		}
+	} else {
+		resp, err = a.apiClient.SignIn(email, pwd, deviceID, nil)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to sign in: %w", err)
+		}
+	}
+
+	if err := a.encryptor.StoreLocalAddress(resp.UserID, resp.DeviceID); err != nil {
+		return models.User{}, err
+	}
+
+	// A failed rotation leaves the current signed pre-key in place, it's retried on the next check
+	if deviceID != 0 {
+		a.rotateSignedPreKey()
 	}
 
 	a.signedIn = true
+	a.startSignedPreKeyRotation()
 	user := models.User{
 		ID:       resp.UserID,
 		Username: email,
@@ -98,6 +144,7 @@ func (a *Auth) SignOut() error {
 		panic("not signed in")
 	}
 
+	a.stopSignedPreKeyRotation()
 	a.apiClient.Close()
 	if err := a.db.Close(); err != nil {
 		return fmt.Errorf("failed to close database: %w", err)
@@ -106,6 +153,49 @@ func (a *Auth) SignOut() error {
 	return nil
 }
 
+// startSignedPreKeyRotation rotates the signed pre-key when it's due for as long as the user stays signed in, so that
+// clients that keep running don't publish the same key forever
+func (a *Auth) startSignedPreKeyRotation() {
+	a.stopSignedPreKeyRotation()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.stopRotation = cancel
+	a.rotationDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(a.rotationInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.rotateSignedPreKey()
+			}
+		}
+	}()
+}
+
+// stopSignedPreKeyRotation stops the rotation checks and waits for a running one, which uses the database
+func (a *Auth) stopSignedPreKeyRotation() {
+	if a.stopRotation == nil {
+		return
+	}
+
+	a.stopRotation()
+	<-a.rotationDone
+	a.stopRotation = nil
+	a.rotationDone = nil
+}
+
+func (a *Auth) rotateSignedPreKey() {
+	if err := a.encryptor.RotateSignedPreKeyIfDue(); err != nil {
+		log.Printf("failed to rotate signed pre-key: %v", err)
+	}
+}
+
 func isValidEmail(email string) bool {
 	// Basic email regex
 	regex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
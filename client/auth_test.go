@@ -6,7 +6,9 @@ import (
 	"signal-chat/client/api"
 	"signal-chat/client/database"
 	"signal-chat/client/encryption"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -154,6 +156,25 @@ func TestAuth_SignIn(t *testing.T) {
 		assert.Equal(t, username, signedIn.Username, "username should match the username of the user returned from SignUp")
 	})
 
+	t.Run("registers a new device when the key store has no device ID", func(t *testing.T) {
+		// Arrange
+		client := api.NewFakeClient()
+		registered, err := NewAuth(database.NewFake(), client, encryption.NewFakeManager()).SignUp(DummyEmail, DummyPassword)
+		require.NoError(t, err)
+		encryptor := encryption.NewFakeManager()
+		auth := NewAuth(database.NewFake(), client, encryptor)
+
+		// Act
+		signedIn, err := auth.SignIn(DummyEmail, DummyPassword)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, registered.ID, signedIn.ID)
+		deviceID, err := encryptor.LocalDeviceID()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), deviceID)
+	})
+
 	t.Run("returns error when database fails to open", func(t *testing.T) {
 		// Arrange
 		db := database.NewStub()
@@ -196,6 +217,37 @@ func TestAuth_SignIn(t *testing.T) {
 	})
 }
 
+func TestAuth_SignedPreKeyRotation(t *testing.T) {
+	t.Run("checks the signed pre-key rotation while signed in", func(t *testing.T) {
+		// Arrange
+		encryptor := &countingRotationManager{FakeManager: encryption.NewFakeManager()}
+		auth := NewAuth(database.NewFake(), api.NewFakeClient(), encryptor)
+		auth.rotationInterval = time.Millisecond
+		_, err := auth.SignUp(DummyEmail, DummyPassword)
+		require.NoError(t, err)
+
+		// Act
+		time.Sleep(20 * time.Millisecond)
+		require.NoError(t, auth.SignOut())
+
+		// Assert
+		checks := encryptor.rotations.Load()
+		assert.Greater(t, checks, int32(1), "rotation should have been checked periodically")
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, checks, encryptor.rotations.Load(), "rotation should not be checked after sign out")
+	})
+}
+
+type countingRotationManager struct {
+	*encryption.FakeManager
+	rotations atomic.Int32
+}
+
+func (m *countingRotationManager) RotateSignedPreKeyIfDue() error {
+	m.rotations.Add(1)
+	return nil
+}
+
 func TestAuth_SignOut(t *testing.T) {
 	t.Run("closes database on sign out", func(t *testing.T) {
 		// Arrange
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"signal-chat/client/api"
@@ -9,6 +11,12 @@ import (
 	"signal-chat/client/encryption"
 	"signal-chat/client/models"
 	"signal-chat/internal/apitypes"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -17,17 +25,61 @@ type ConversationCallback func(conv models.Conversation)
 
 type MessageCallback func(msg models.Message)
 
+// maxReceiptMessages is the number of message IDs the server accepts in a single receipt
+const maxReceiptMessages = 100
+
+// readReceiptsSetting is the name of the setting that opts the user out of read receipts
+const readReceiptsSetting = "readReceipts"
+
+// expiryIndexSetting is the name of the setting recording that the disappearing messages stored before the expiry index
+// existed were indexed
+const expiryIndexSetting = "expiryIndex"
+
+var (
+	errConversationNotFound = errors.New("conversation not found")
+	errMessageNotFound      = errors.New("message not found")
+	errMessageNotEditable   = errors.New("only the author of a message can edit it")
+	errMessageNotDeletable  = errors.New("only the author of a message can delete it for everyone")
+	errInvalidReaction      = fmt.Errorf("reaction must be an emoji of at most %d bytes", maxReactionLength)
+	errEditWindowExpired    = errors.New("message can no longer be edited")
+)
+
+// maxReactionLength is the number of bytes a reaction may have, enough for emoji made of several code points
+const maxReactionLength = 32
+
+// defaultEditWindow is the time after sending a message during which its author can edit it
+const defaultEditWindow = 15 * time.Minute
+
+// TypingCallback reports that a participant started or stopped typing in a conversation
+type TypingCallback func(conversationID, userID string, typing bool)
+
+// SyncProgressCallback reports the number of offline messages synced so far, done is set after the last page
+type SyncProgressCallback func(synced int, done bool)
+
 type ConversationAPI interface {
 	CreateConversation(id string, otherParticipants []apitypes.Participant) error
 	SendMessage(conversationID string, content []byte) (apitypes.SendMessageResponse, error)
+	SendReference(conversationID, refersTo string, content []byte) (apitypes.SendMessageResponse, error)
+	AddParticipants(conversationID string, participants []apitypes.Participant) error
+	RemoveParticipant(conversationID, participantID string, keyDistributions []apitypes.Participant) error
+	DistributeSenderKeys(conversationID string, participants []apitypes.Participant) error
+	SendTyping(conversationID string, typing bool) error
+	SendReceipt(req apitypes.SendReceiptRequest) error
+	SetExpirationTimer(conversationID string, seconds int64) error
+	DeleteMessage(conversationID, messageID string) error
 	SetWSMessageHandler(messageType apitypes.WSMessageType, handler api.MessageHandler)
+	UserID() string
 }
 
 type Encryptor interface {
-	CreateEncryptionGroup(groupID string, recipientIDs []string) (map[string][]byte, error)
-	ProcessSenderKeyDistributionMessage(groupID, senderID string, encryptedMsg []byte) error
+	CreateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error)
+	RotateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error)
+	DeleteSenderKey(groupID, senderID string) error
+	DeleteEncryptionGroup(groupID string) error
+	ReplenishPreKeys() error
+	ProcessSenderKeyDistributionMessage(groupID, senderID string, senderDeviceID uint32, encryptedMsg []byte) error
 	GroupEncrypt(groupID string, plaintext []byte) (*encryption.EncryptedMessage, error)
-	GroupDecrypt(groupID, senderID string, ciphertext []byte) (*encryption.DecryptedMessage, error)
+	GroupDecrypt(groupID, senderID string, senderDeviceID uint32, ciphertext []byte) (*encryption.DecryptedMessage, error)
 }
 
 type ConversationService struct {
@@ -37,13 +89,39 @@ type ConversationService struct {
 	ConversationAdded   ConversationCallback
 	ConversationUpdated ConversationCallback
 	MessageAdded        MessageCallback
+	MessageUpdated      MessageCallback
+	SyncProgressed      SyncProgressCallback
+	TypingChanged       TypingCallback
+	// MessageExpired reports a disappearing message deleted from the database
+	MessageExpired MessageCallback
+	// MessageDeleted reports a message deleted by the user or, for everyone, by its author
+	MessageDeleted MessageCallback
+	// ConversationDeleted reports a conversation the user deleted with all its messages
+	ConversationDeleted ConversationCallback
+	// ReactionsChanged reports a message whose reactions changed, with their updated counts
+	ReactionsChanged MessageCallback
+	// EditWindow is the time after sending a message during which its author can edit it
+	EditWindow time.Duration
+	// typingTimeout is the time after which a participant that didn't send a stop is no longer shown as typing
+	typingTimeout time.Duration
+	typing        map[typingKey]*time.Timer
+	typingMu      sync.Mutex
+	// synced counts the messages of the sync in progress, the websocket client handles its pages one after another
+	synced int
+	// expirationChanged wakes up runExpiration when a disappearing message is stored, as it may expire first
+	expirationChanged chan struct{}
 }
 
 func NewConversationService(db database.DB, apiClient ConversationAPI, encryptor Encryptor) *ConversationService {
 	svc := &ConversationService{
-		db:        db,
-		api:       apiClient,
-		encryptor: encryptor,
+		db:            db,
+		api:           apiClient,
+		encryptor:     encryptor,
+		typingTimeout: 6 * time.Second,
+		EditWindow:    defaultEditWindow,
+		typing:        make(map[typingKey]*time.Timer),
+		// Buffered so that storing a message never waits for runExpiration
+		expirationChanged: make(chan struct{}, 1),
 	}
 
 	svc.api.SetWSMessageHandler(apitypes.MessageTypeSync, func(data json.RawMessage) {
@@ -64,9 +142,57 @@ func NewConversationService(db database.DB, apiClient ConversationAPI, encryptor
 		}
 	})
 
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeParticipantAdded, func(data json.RawMessage) {
+		if err := svc.handleParticipantAdded(data); err != nil {
+			log.Printf("error handling participant added message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeParticipantRemoved, func(data json.RawMessage) {
+		if err := svc.handleParticipantRemoved(data); err != nil {
+			log.Printf("error handling participant removed message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeSenderKeyDistribution, func(data json.RawMessage) {
+		if err := svc.handleSenderKey(data); err != nil {
+			log.Printf("error handling sender key message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypePreKeysLow, func(data json.RawMessage) {
+		if err := svc.handlePreKeysLow(data); err != nil {
+			log.Printf("error handling pre-keys low message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeReceipt, func(data json.RawMessage) {
+		if err := svc.handleReceipt(data); err != nil {
+			log.Printf("error handling receipt message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeTyping, func(data json.RawMessage) {
+		if err := svc.handleTyping(data); err != nil {
+			log.Printf("error handling typing message: %v", err)
+		}
+	})
+
+	svc.api.SetWSMessageHandler(apitypes.MessageTypeExpirationTimer, func(data json.RawMessage) {
+		if err := svc.handleExpirationTimer(data); err != nil {
+			log.Printf("error handling expiration timer message: %v", err)
+		}
+	})
+
 	return svc
 }
 
+// typingKey identifies a participant typing in a conversation
+type typingKey struct {
+	conversationID string
+	userID         string
+}
+
 func (c *ConversationService) handleSync(data json.RawMessage) error {
 	var syncPayload apitypes.WSSyncPayload
 	if err := json.Unmarshal(data, &syncPayload); err != nil {
@@ -80,14 +206,35 @@ func (c *ConversationService) handleSync(data json.RawMessage) error {
 			err = c.handleNewMessage(message.Data)
 		case apitypes.MessageTypeNewConversation:
 			err = c.handleNewConversation(message.Data)
+		case apitypes.MessageTypeParticipantAdded:
+			err = c.handleParticipantAdded(message.Data)
+		case apitypes.MessageTypeParticipantRemoved:
+			err = c.handleParticipantRemoved(message.Data)
+		case apitypes.MessageTypeSenderKeyDistribution:
+			err = c.handleSenderKey(message.Data)
+		case apitypes.MessageTypePreKeysLow:
+			err = c.handlePreKeysLow(message.Data)
+		case apitypes.MessageTypeReceipt:
+			err = c.handleReceipt(message.Data)
+		case apitypes.MessageTypeExpirationTimer:
+			err = c.handleExpirationTimer(message.Data)
 		default:
 			log.Printf("unhandled websocket message type: %d", message.Type)
 		}
+		// The page is acknowledged as a whole, so a message that can't be handled mustn't hold back the others
 		if err != nil {
-			return err
+			log.Printf("error handling synced message %s: %v", message.ID, err)
 		}
 	}
 
+	c.synced += len(syncPayload.Messages)
+	if c.SyncProgressed != nil {
+		c.SyncProgressed(c.synced, !syncPayload.HasMore)
+	}
+	if !syncPayload.HasMore {
+		c.synced = 0
+	}
+
 	return nil
 }
 
@@ -97,14 +244,14 @@ func (c *ConversationService) handleNewConversation(data json.RawMessage) error
 		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
 	}
 
-	err := c.encryptor.ProcessSenderKeyDistributionMessage(p.ConversationID, p.SenderID, p.KeyDistributionMessage)
+	err := c.encryptor.ProcessSenderKeyDistributionMessage(p.ConversationID, p.SenderID, p.SenderDeviceID, p.KeyDistributionMessage)
 	if err != nil {
 		return fmt.Errorf("failed to process key distribution message: %w", err)
 	}
 
 	conv := models.Conversation{
 		ID:             p.ConversationID,
-		ParticipantIDs: p.ParticipantIDs,
+		ParticipantIDs: c.otherParticipants(p.ParticipantIDs),
 	}
 	if err := c.writeConversation(conv); err != nil {
 		return fmt.Errorf("failed to store new conversation in the database: %w", err)
@@ -128,12 +275,26 @@ func (c *ConversationService) handleNewMessage(data json.RawMessage) error {
 		return fmt.Errorf("failed to retrieve conversation for the given message: %w", err)
 	}
 
-	decrypted, err := c.encryptor.GroupDecrypt(conv.ID, payload.SenderID, payload.Content)
+	decrypted, err := c.encryptor.GroupDecrypt(conv.ID, payload.SenderID, payload.SenderDeviceID, payload.Content)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt message: %w", err)
 	}
 
-	conv.LastMessagePreview = messagePreview(string(decrypted.Plaintext))
+	content, err := models.DeserializeContent(decrypted.Plaintext)
+	if err != nil {
+		return err
+	}
+	if content.EditOf != "" {
+		return c.applyEdit(conv, payload, content)
+	}
+	if content.DeleteOf != "" {
+		return c.applyDelete(conv, payload.SenderID, content.DeleteOf)
+	}
+	if content.Reaction != nil {
+		return c.applyReaction(conv, payload.SenderID, *content.Reaction)
+	}
+
+	conv.LastMessagePreview = messagePreview(content.Text)
 	conv.LastMessageSenderID = payload.SenderID
 	conv.LastMessageTimestamp = payload.CreatedAt
 	if err := c.writeConversation(conv); err != nil {
@@ -146,23 +307,362 @@ func (c *ConversationService) handleNewMessage(data json.RawMessage) error {
 
 	msg := models.Message{
 		ID:         payload.MessageID,
-		Text:       string(decrypted.Plaintext),
+		Text:       content.Text,
 		SenderID:   payload.SenderID,
 		Timestamp:  payload.CreatedAt,
 		Ciphertext: decrypted.Ciphertext,
 		Envelope:   decrypted.Envelope,
+		ExpiresAt:  payload.ExpiresAt,
+		Quote:      content.Quote,
 	}
 	if err := c.writeMessage(conv.ID, msg); err != nil {
 		return fmt.Errorf("failed to store new message in the database: %w", err)
 	}
 
 	if c.MessageAdded != nil {
+		if err := c.resolveQuote(conv.ID, &msg); err != nil {
+			return err
+		}
 		c.MessageAdded(msg)
 	}
 
+	// Messages of our other devices are acknowledged by the actual recipients
+	if payload.SenderID != c.api.UserID() {
+		err := c.api.SendReceipt(apitypes.SendReceiptRequest{
+			ConversationID: conv.ID,
+			AuthorID:       payload.SenderID,
+			MessageIDs:     []string{msg.ID},
+			Type:           apitypes.ReceiptTypeDelivered,
+		})
+		if err != nil {
+			log.Printf("failed to send delivery receipt for message %s: %v", msg.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// applyEdit replaces the text of the edited message with the revision, provided the author of the message sent it
+// within the edit window. Edits of messages the user deleted or that expired are skipped.
+func (c *ConversationService) applyEdit(conv models.Conversation, payload apitypes.WSNewMessagePayload, content models.Content) error {
+	msg, err := c.getMessage(conv.ID, content.EditOf)
+	if errors.Is(err, errMessageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve edited message %s: %w", content.EditOf, err)
+	}
+	if !c.sentBy(msg, payload.SenderID) {
+		return fmt.Errorf("user %s can't edit message %s of another user", payload.SenderID, msg.ID)
+	}
+	if payload.CreatedAt-msg.Timestamp > c.EditWindow.Milliseconds() {
+		log.Printf("ignoring edit of message %s that arrived after the edit window", msg.ID)
+		return nil
+	}
+
+	if !msg.Edit(content.Text, payload.CreatedAt) {
+		return nil
+	}
+	return c.storeEdit(conv, msg)
+}
+
+// storeEdit stores the edited message and updates the conversation preview when the message is the latest one
+func (c *ConversationService) storeEdit(conv models.Conversation, msg models.Message) error {
+	if err := c.writeMessage(conv.ID, msg); err != nil {
+		return fmt.Errorf("failed to store edited message: %w", err)
+	}
+
+	if c.MessageUpdated != nil {
+		c.MessageUpdated(msg)
+	}
+
+	if conv.LastMessageTimestamp != msg.Timestamp {
+		return nil
+	}
+	conv.LastMessagePreview = messagePreview(msg.Text)
+	if err := c.writeConversation(conv); err != nil {
+		return fmt.Errorf("failed to store updated conversation: %w", err)
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return nil
+}
+
+// applyDelete removes the message its author deleted for everyone. Messages the user already deleted are skipped.
+func (c *ConversationService) applyDelete(conv models.Conversation, senderID, messageID string) error {
+	msg, err := c.getMessage(conv.ID, messageID)
+	if errors.Is(err, errMessageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve deleted message %s: %w", messageID, err)
+	}
+	if !c.sentBy(msg, senderID) {
+		return fmt.Errorf("user %s can't delete message %s of another user", senderID, msg.ID)
+	}
+
+	return c.removeMessage(conv, msg)
+}
+
+// applyReaction adds or removes the reaction of the sender to a message. Reactions to messages the user deleted are
+// skipped.
+func (c *ConversationService) applyReaction(conv models.Conversation, senderID string, reaction models.Reaction) error {
+	if !reaction.Remove && !validReaction(reaction.Emoji) {
+		return errInvalidReaction
+	}
+
+	msg, err := c.getMessage(conv.ID, reaction.MessageID)
+	if errors.Is(err, errMessageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve message %s: %w", reaction.MessageID, err)
+	}
+
+	if !react(&msg, senderID, reaction) {
+		return nil
+	}
+	_, err = c.storeReactions(conv.ID, msg)
+	return err
+}
+
+func (c *ConversationService) handleParticipantAdded(data json.RawMessage) error {
+	var p apitypes.WSParticipantAddedPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
+	}
+
+	otherIDs := c.otherParticipants(p.ParticipantIDs)
+	conv, err := c.getConversation(p.ConversationID)
+	isNew := errors.Is(err, errConversationNotFound)
+	if err != nil && !isNew {
+		return err
+	}
+	if isNew {
+		// We are one of the added participants
+		if err := c.encryptor.ProcessSenderKeyDistributionMessage(p.ConversationID, p.SenderID, p.SenderDeviceID, p.KeyDistributionMessage); err != nil {
+			return fmt.Errorf("failed to process key distribution message: %w", err)
+		}
+		conv = models.Conversation{ID: p.ConversationID}
+	}
+	conv.ParticipantIDs = otherIDs
+	if err := c.writeConversation(conv); err != nil {
+		return fmt.Errorf("failed to store conversation in the database: %w", err)
+	}
+
+	// Every member sends its sender key to the participants that don't have it yet. Added participants also need the
+	// keys of each other and our other devices need the key of this one.
+	recipientIDs := c.otherParticipants(p.AddedIDs)
+	if isNew {
+		recipientIDs = c.withCurrentUser(otherIDs)
+	}
+	if err := c.distributeSenderKeys(conv.ID, recipientIDs); err != nil {
+		return err
+	}
+
+	if isNew && c.ConversationAdded != nil {
+		c.ConversationAdded(conv)
+	} else if !isNew && c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return nil
+}
+
+func (c *ConversationService) handleParticipantRemoved(data json.RawMessage) error {
+	var p apitypes.WSParticipantRemovedPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
+	}
+
+	conv, err := c.getConversation(p.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve conversation for the given participant: %w", err)
+	}
+
+	if p.RemovedID == c.api.UserID() {
+		conv.ParticipantIDs = nil
+		if err := c.writeConversation(conv); err != nil {
+			return fmt.Errorf("failed to update conversation in the database: %w", err)
+		}
+		if c.ConversationUpdated != nil {
+			c.ConversationUpdated(conv)
+		}
+		return nil
+	}
+
+	if err := c.encryptor.DeleteSenderKey(conv.ID, p.RemovedID); err != nil {
+		return fmt.Errorf("failed to delete sender key of removed participant: %w", err)
+	}
+	if len(p.KeyDistributionMessage) > 0 {
+		if err := c.encryptor.ProcessSenderKeyDistributionMessage(conv.ID, p.SenderID, p.SenderDeviceID, p.KeyDistributionMessage); err != nil {
+			return fmt.Errorf("failed to process key distribution message: %w", err)
+		}
+	}
+
+	conv.ParticipantIDs = c.otherParticipants(p.ParticipantIDs)
+	if err := c.writeConversation(conv); err != nil {
+		return fmt.Errorf("failed to update conversation in the database: %w", err)
+	}
+
+	// The removed participant knows our current sender key, so it has to be replaced
+	if err := c.rotateSenderKey(conv.ID, c.withCurrentUser(conv.ParticipantIDs)); err != nil {
+		return err
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return nil
+}
+
+func (c *ConversationService) handleSenderKey(data json.RawMessage) error {
+	var p apitypes.WSSenderKeyPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
+	}
+
+	err := c.encryptor.ProcessSenderKeyDistributionMessage(p.ConversationID, p.SenderID, p.SenderDeviceID, p.KeyDistributionMessage)
+	if err != nil {
+		return fmt.Errorf("failed to process key distribution message: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ConversationService) handlePreKeysLow(data json.RawMessage) error {
+	var p apitypes.WSPreKeysLowPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
+	}
+
+	log.Printf("server has %d one-time pre-keys left, uploading a new batch", p.Remaining)
+	if err := c.encryptor.ReplenishPreKeys(); err != nil {
+		return fmt.Errorf("failed to replenish pre-keys: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ConversationService) handleReceipt(data json.RawMessage) error {
+	var p apitypes.WSReceiptPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket receipt payload: %w", err)
+	}
+
+	status := models.MessageStatusDelivered
+	if p.Type == apitypes.ReceiptTypeRead {
+		// Read receipts are reciprocal, users that don't send them don't see them either
+		enabled, err := c.ReadReceiptsEnabled()
+		if err != nil {
+			return err
+		}
+		if enabled {
+			status = models.MessageStatusRead
+		}
+	}
+
+	for _, id := range p.MessageIDs {
+		msg, err := c.getMessage(p.ConversationID, id)
+		if errors.Is(err, errMessageNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// Only the recipients of the message at the time it was sent have a status
+		if _, recipient := msg.Statuses[p.SenderID]; !recipient || !msg.UpdateStatus(p.SenderID, status) {
+			continue
+		}
+		if err := c.writeMessage(p.ConversationID, msg); err != nil {
+			return fmt.Errorf("failed to store message status: %w", err)
+		}
+
+		if c.MessageUpdated != nil {
+			c.MessageUpdated(msg)
+		}
+	}
+
+	return nil
+}
+
+// handleExpirationTimer stores the expiration timer a participant set for the conversation
+func (c *ConversationService) handleExpirationTimer(data json.RawMessage) error {
+	var p apitypes.WSExpirationTimerPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket message payload: %w", err)
+	}
+
+	conv, err := c.getConversation(p.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve conversation for the given timer: %w", err)
+	}
+
+	conv.ExpirationTimer = p.ExpirationTimer
+	if err := c.writeConversation(conv); err != nil {
+		return fmt.Errorf("failed to update conversation in the database: %w", err)
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return nil
+}
+
+func (c *ConversationService) handleTyping(data json.RawMessage) error {
+	var p apitypes.WSTypingPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to unmarshall websocket typing payload: %w", err)
+	}
+
+	key := typingKey{conversationID: p.ConversationID, userID: p.SenderID}
+
+	c.typingMu.Lock()
+	timer, typing := c.typing[key]
+	switch {
+	case p.Typing && typing:
+		// Still typing, the participant is shown as typing for another timeout
+		timer.Reset(c.typingTimeout)
+		c.typingMu.Unlock()
+		return nil
+	case p.Typing:
+		c.typing[key] = time.AfterFunc(c.typingTimeout, func() { c.expireTyping(key) })
+	case typing:
+		timer.Stop()
+		delete(c.typing, key)
+	default:
+		c.typingMu.Unlock()
+		return nil
+	}
+	c.typingMu.Unlock()
+
+	if c.TypingChanged != nil {
+		c.TypingChanged(p.ConversationID, p.SenderID, p.Typing)
+	}
 	return nil
 }
 
+// expireTyping stops showing a participant as typing when its stop event never arrived
+func (c *ConversationService) expireTyping(key typingKey) {
+	c.typingMu.Lock()
+	if _, typing := c.typing[key]; !typing {
+		c.typingMu.Unlock()
+		return
+	}
+	delete(c.typing, key)
+	c.typingMu.Unlock()
+
+	if c.TypingChanged != nil {
+		c.TypingChanged(key.conversationID, key.userID, false)
+	}
+}
+
 func (c *ConversationService) ListConversations() ([]models.Conversation, error) {
 	data, err := c.db.Query(conversationKey(""))
 	if err != nil {
@@ -195,17 +695,63 @@ func (c *ConversationService) ListMessages(conversationID string) ([]models.Mess
 	}
 
 	messages := make([]models.Message, 0, len(data))
+	stored := make(map[string]bool, len(data))
 	for k, v := range data {
 		msg, err := models.DeserializeMessage(v)
 		if err != nil {
 			return nil, fmt.Errorf("failed to deserialize message with key %s: %w", k, err)
 		}
+		msg.ReactionCounts = msg.CountReactions()
 		messages = append(messages, msg)
+		stored[msg.ID] = true
+	}
+
+	for i := range messages {
+		if quote := messages[i].Quote; quote != nil && !stored[quote.MessageID] {
+			messages[i].Quote = unavailableQuote(*quote)
+		}
 	}
 
 	return messages, nil
 }
 
+// ListThread returns the replies to the root message, including replies to replies, in the order they were sent
+func (c *ConversationService) ListThread(conversationID, rootMessageID string) ([]models.Message, error) {
+	panicIfEmpty("rootMessageID", rootMessageID)
+
+	messages, err := c.ListMessages(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[string][]models.Message)
+	for _, msg := range messages {
+		if msg.Quote != nil {
+			replies[msg.Quote.MessageID] = append(replies[msg.Quote.MessageID], msg)
+		}
+	}
+
+	var thread []models.Message
+	parents := []string{rootMessageID}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+		for _, reply := range replies[parent] {
+			thread = append(thread, reply)
+			parents = append(parents, reply.ID)
+		}
+	}
+
+	sort.Slice(thread, func(i, j int) bool {
+		if thread[i].Timestamp != thread[j].Timestamp {
+			return thread[i].Timestamp < thread[j].Timestamp
+		}
+		return thread[i].ID < thread[j].ID
+	})
+
+	return thread, nil
+}
+
 func (c *ConversationService) CreateConversation(recipientIDs []string) (models.Conversation, error) {
 	if len(recipientIDs) == 0 {
 		panic("recipientIDs must not be empty")
@@ -213,20 +759,13 @@ func (c *ConversationService) CreateConversation(recipientIDs []string) (models.
 
 	id := uuid.New().String()
 
-	keyMessages, err := c.encryptor.CreateEncryptionGroup(id, recipientIDs)
+	// Our other devices join the conversation too
+	participants, err := c.encryptor.CreateEncryptionGroup(id, c.withCurrentUser(recipientIDs))
 	if err != nil {
 		return models.Conversation{}, fmt.Errorf("failed to generate key distribution messages: %w", err)
 	}
 
-	otherParticipants := make([]apitypes.Participant, len(recipientIDs))
-	for i, id := range recipientIDs {
-		otherParticipants[i] = apitypes.Participant{
-			ID:                     id,
-			KeyDistributionMessage: keyMessages[id],
-		}
-	}
-
-	if err := c.api.CreateConversation(id, otherParticipants); err != nil {
+	if err := c.api.CreateConversation(id, participants); err != nil {
 		return models.Conversation{}, fmt.Errorf("failed to create conversation: %w", err)
 	}
 
@@ -241,37 +780,168 @@ func (c *ConversationService) CreateConversation(recipientIDs []string) (models.
 	return conv, nil
 }
 
-func (c *ConversationService) SendMessage(conversationID, messageText string) (models.Message, error) {
+// AddParticipants adds the users to an existing conversation and hands them our sender key
+func (c *ConversationService) AddParticipants(conversationID string, userIDs []string) (models.Conversation, error) {
 	panicIfEmpty("conversationID", conversationID)
-	panicIfEmpty("messageText", messageText)
+	if len(userIDs) == 0 {
+		panic("userIDs must not be empty")
+	}
 
 	conv, err := c.getConversation(conversationID)
 	if err != nil {
-		return models.Message{}, err
+		return models.Conversation{}, err
 	}
 
-	encrypted, err := c.encryptor.GroupEncrypt(conv.ID, []byte(messageText))
+	participants, err := c.encryptor.CreateEncryptionGroup(conv.ID, userIDs)
 	if err != nil {
-		return models.Message{}, fmt.Errorf("failed to encrypt message content: %w", err)
+		return models.Conversation{}, fmt.Errorf("failed to generate key distribution messages: %w", err)
 	}
 
-	resp, err := c.api.SendMessage(conv.ID, encrypted.Serialized)
-	if err != nil {
-		return models.Message{}, fmt.Errorf("failed to send message: %w", err)
+	if err := c.api.AddParticipants(conv.ID, participants); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to add participants: %w", err)
 	}
 
-	msg := models.Message{
-		ID:         resp.MessageID,
-		Text:       messageText,
-		Timestamp:  resp.CreatedAt,
-		Ciphertext: encrypted.Ciphertext,
-		Envelope:   encrypted.Envelope,
+	conv.ParticipantIDs = append(conv.ParticipantIDs, userIDs...)
+	if err := c.writeConversation(conv); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to store updated conversation: %w", err)
 	}
-	if err := c.writeMessage(conv.ID, msg); err != nil {
-		return models.Message{}, fmt.Errorf("failed to store message: %w", err)
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
 	}
 
-	conv.LastMessagePreview = messagePreview(messageText)
+	return conv, nil
+}
+
+// RemoveParticipant removes the user from the conversation. Removing the current user leaves the conversation.
+// Otherwise, our sender key is rotated so the removed user can't read any new messages.
+func (c *ConversationService) RemoveParticipant(conversationID, userID string) (models.Conversation, error) {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("userID", userID)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+
+	var remainingIDs []string
+	var keyDistributions []apitypes.Participant
+	if userID != c.api.UserID() {
+		for _, id := range conv.ParticipantIDs {
+			if id != userID {
+				remainingIDs = append(remainingIDs, id)
+			}
+		}
+
+		keyDistributions, err = c.encryptor.RotateEncryptionGroup(conv.ID, c.withCurrentUser(remainingIDs))
+		if err != nil {
+			return models.Conversation{}, fmt.Errorf("failed to rotate sender key: %w", err)
+		}
+	}
+
+	if err := c.api.RemoveParticipant(conv.ID, userID, keyDistributions); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to remove participant: %w", err)
+	}
+
+	if userID != c.api.UserID() {
+		if err := c.encryptor.DeleteSenderKey(conv.ID, userID); err != nil {
+			return models.Conversation{}, fmt.Errorf("failed to delete sender key of removed participant: %w", err)
+		}
+	}
+
+	conv.ParticipantIDs = remainingIDs
+	if err := c.writeConversation(conv); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to store updated conversation: %w", err)
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return conv, nil
+}
+
+func (c *ConversationService) SendMessage(conversationID, messageText string) (models.Message, error) {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageText", messageText)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	return c.sendContent(conv, models.Content{Text: messageText})
+}
+
+// SendReply sends a message quoting another message of the conversation with a snippet of its text
+func (c *ConversationService) SendReply(conversationID, quotedMessageID, messageText string) (models.Message, error) {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("quotedMessageID", quotedMessageID)
+	panicIfEmpty("messageText", messageText)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	quoted, err := c.getMessage(conv.ID, quotedMessageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	// Messages sent from this device have no sender, the recipients need to know it's us
+	senderID := quoted.SenderID
+	if senderID == "" {
+		senderID = c.api.UserID()
+	}
+	quote := &models.Quote{MessageID: quoted.ID, SenderID: senderID, Text: messagePreview(quoted.Text)}
+
+	return c.sendContent(conv, models.Content{Text: messageText, Quote: quote})
+}
+
+// sendContent encrypts the content for the conversation, sends it and stores the message
+func (c *ConversationService) sendContent(conv models.Conversation, content models.Content) (models.Message, error) {
+	encrypted, err := c.encryptContent(conv.ID, content)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	// The message is stored as pending under a local ID until the server assigns its ID
+	msg := models.Message{
+		ID:         uuid.New().String(),
+		Text:       content.Text,
+		Timestamp:  time.Now().UnixMilli(),
+		Ciphertext: encrypted.Ciphertext,
+		Envelope:   encrypted.Envelope,
+		Statuses:   make(map[string]models.MessageStatus, len(conv.ParticipantIDs)),
+		Quote:      content.Quote,
+	}
+	for _, id := range conv.ParticipantIDs {
+		msg.Statuses[id] = models.MessageStatusPending
+	}
+	if err := c.writeMessage(conv.ID, msg); err != nil {
+		return models.Message{}, fmt.Errorf("failed to store pending message: %w", err)
+	}
+
+	resp, err := c.api.SendMessage(conv.ID, encrypted.Serialized)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	if err := c.db.Delete(messageKey(conv.ID, msg.ID)); err != nil {
+		return models.Message{}, fmt.Errorf("failed to delete pending message: %w", err)
+	}
+	msg.ID = resp.MessageID
+	msg.Timestamp = resp.CreatedAt
+	msg.ExpiresAt = resp.ExpiresAt
+	for id := range msg.Statuses {
+		msg.UpdateStatus(id, models.MessageStatusSent)
+	}
+	if err := c.writeMessage(conv.ID, msg); err != nil {
+		return models.Message{}, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	conv.LastMessagePreview = messagePreview(msg.Text)
 	conv.LastMessageTimestamp = msg.Timestamp
 	conv.LastMessageSenderID = msg.SenderID
 	if err := c.writeConversation(conv); err != nil {
@@ -285,6 +955,629 @@ func (c *ConversationService) SendMessage(conversationID, messageText string) (m
 	return msg, nil
 }
 
+// SetExpirationTimer sets the number of seconds after which new messages of the conversation disappear for every
+// participant, zero turns disappearing messages off
+func (c *ConversationService) SetExpirationTimer(conversationID string, seconds int64) (models.Conversation, error) {
+	panicIfEmpty("conversationID", conversationID)
+	if seconds < 0 {
+		panic("seconds must not be negative")
+	}
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+
+	if err := c.api.SetExpirationTimer(conv.ID, seconds); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to set expiration timer: %w", err)
+	}
+
+	conv.ExpirationTimer = seconds
+	if err := c.writeConversation(conv); err != nil {
+		return models.Conversation{}, fmt.Errorf("failed to store updated conversation: %w", err)
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(conv)
+	}
+
+	return conv, nil
+}
+
+// runExpiration deletes expired messages right away, which catches up on the ones that expired while the app was
+// closed, and then whenever the next disappearing message expires until the context is done. Disappearing messages are
+// found through the expiry index, which is checked again at least every maxWait in case the clock changed.
+func (c *ConversationService) runExpiration(ctx context.Context, maxWait time.Duration) {
+	if err := c.indexExpiringMessages(); err != nil {
+		log.Printf("error indexing disappearing messages: %v", err)
+	}
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	for {
+		next, err := c.deleteExpiredMessages()
+		if err != nil {
+			log.Printf("error deleting expired messages: %v", err)
+		}
+
+		wait := maxWait
+		if next > 0 {
+			wait = min(max(time.Until(time.UnixMilli(next)), 0), maxWait)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-c.expirationChanged:
+		}
+	}
+}
+
+// indexExpiringMessages adds the disappearing messages stored before the expiry index existed to it, once
+func (c *ConversationService) indexExpiringMessages() error {
+	indexed, err := c.db.Read(settingKey(expiryIndexSetting))
+	if err != nil {
+		return fmt.Errorf("failed to read expiry index setting: %w", err)
+	}
+	if indexed != nil {
+		return nil
+	}
+
+	conversations, err := c.ListConversations()
+	if err != nil {
+		return err
+	}
+	for _, conv := range conversations {
+		messages, err := c.ListMessages(conv.ID)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if msg.ExpiresAt == 0 {
+				continue
+			}
+			if err := c.db.Write(expiryKey(conv.ID, msg), []byte(conv.ID)); err != nil {
+				return fmt.Errorf("failed to index disappearing message %s: %w", msg.ID, err)
+			}
+		}
+	}
+
+	if err := c.db.Write(settingKey(expiryIndexSetting), []byte("true")); err != nil {
+		return fmt.Errorf("failed to store expiry index setting: %w", err)
+	}
+	return nil
+}
+
+// deleteExpiredMessages deletes the disappearing messages whose time is up from the database, and returns when the
+// next one expires in Unix milliseconds, zero when none is left. The expiry index is walked in time order and only up
+// to the first message that didn't expire. Entries of messages that were deleted or got another expiry time are
+// dropped along the way. The preview of a conversation whose latest message expired falls back to the latest
+// remaining one.
+func (c *ConversationService) deleteExpiredMessages() (int64, error) {
+	index, err := c.db.Query(expiryPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expiry index: %w", err)
+	}
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	now := time.Now().UnixMilli()
+	var next int64
+	var changed []string
+	for _, key := range keys {
+		conversationID := string(index[key])
+		expiresAt, messageID, err := parseExpiryKey(key, conversationID)
+		if err != nil {
+			return 0, err
+		}
+		if expiresAt > now {
+			next = expiresAt
+			break
+		}
+
+		msg, err := c.getMessage(conversationID, messageID)
+		if err != nil && !errors.Is(err, errMessageNotFound) {
+			return 0, err
+		}
+		if err == nil && msg.ExpiresAt == expiresAt {
+			if err := c.db.Delete(messageKey(conversationID, msg.ID)); err != nil {
+				return 0, fmt.Errorf("failed to delete expired message %s: %w", msg.ID, err)
+			}
+			if c.MessageExpired != nil {
+				c.MessageExpired(msg)
+			}
+			if !slices.Contains(changed, conversationID) {
+				changed = append(changed, conversationID)
+			}
+		}
+		if err := c.db.Delete(key); err != nil {
+			return 0, fmt.Errorf("failed to delete expiry index entry of message %s: %w", messageID, err)
+		}
+	}
+
+	for _, conversationID := range changed {
+		conv, err := c.getConversation(conversationID)
+		if errors.Is(err, errConversationNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := c.refreshPreview(conv); err != nil {
+			return 0, err
+		}
+	}
+
+	return next, nil
+}
+
+// DeleteMessage deletes a message from this device only
+func (c *ConversationService) DeleteMessage(conversationID, messageID string) error {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageID", messageID)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.getMessage(conv.ID, messageID)
+	if err != nil {
+		return err
+	}
+
+	return c.removeMessage(conv, msg)
+}
+
+// DeleteMessageForEveryone deletes a message the user sent from every device. The server purges the stored
+// ciphertext, along with the edits and reactions referring to it, and the participants apply an encrypted tombstone.
+func (c *ConversationService) DeleteMessageForEveryone(conversationID, messageID string) error {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageID", messageID)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.getMessage(conv.ID, messageID)
+	if err != nil {
+		return err
+	}
+	if !c.sentBy(msg, c.api.UserID()) {
+		return errMessageNotDeletable
+	}
+
+	if err := c.api.DeleteMessage(conv.ID, msg.ID); err != nil {
+		return fmt.Errorf("failed to purge message: %w", err)
+	}
+
+	encrypted, err := c.encryptContent(conv.ID, models.Content{DeleteOf: msg.ID})
+	if err != nil {
+		return err
+	}
+	if _, err := c.api.SendMessage(conv.ID, encrypted.Serialized); err != nil {
+		return fmt.Errorf("failed to send tombstone: %w", err)
+	}
+
+	return c.removeMessage(conv, msg)
+}
+
+// DeleteConversation deletes a conversation with all its messages and sender keys from this device. The user stays
+// a participant, but can't read the messages sent to it afterward.
+func (c *ConversationService) DeleteConversation(conversationID string) error {
+	panicIfEmpty("conversationID", conversationID)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return err
+	}
+
+	messages, err := c.db.Query(messageKey(conv.ID, ""))
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	for key := range messages {
+		if err := c.db.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete message with key %s: %w", key, err)
+		}
+	}
+
+	if err := c.encryptor.DeleteEncryptionGroup(conv.ID); err != nil {
+		return fmt.Errorf("failed to delete sender keys: %w", err)
+	}
+
+	if err := c.db.Delete(conversationKey(conv.ID)); err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+
+	if c.ConversationDeleted != nil {
+		c.ConversationDeleted(conv)
+	}
+
+	return nil
+}
+
+// EditMessage sends a new revision of a message the user sent. The other participants apply it, keeping the previous
+// text in the history of the message, if it was sent within the edit window.
+func (c *ConversationService) EditMessage(conversationID, messageID, messageText string) (models.Message, error) {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageID", messageID)
+	panicIfEmpty("messageText", messageText)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	msg, err := c.getMessage(conv.ID, messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if !c.sentBy(msg, c.api.UserID()) {
+		return models.Message{}, errMessageNotEditable
+	}
+	if time.Since(time.UnixMilli(msg.Timestamp)) > c.EditWindow {
+		return models.Message{}, errEditWindowExpired
+	}
+
+	encrypted, err := c.encryptContent(conv.ID, models.Content{Text: messageText, EditOf: msg.ID})
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	resp, err := c.api.SendReference(conv.ID, msg.ID, encrypted.Serialized)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("failed to send edit: %w", err)
+	}
+
+	msg.Edit(messageText, resp.CreatedAt)
+	if err := c.storeEdit(conv, msg); err != nil {
+		return models.Message{}, err
+	}
+
+	return msg, nil
+}
+
+// AddReaction reacts to a message with an emoji, replacing the previous reaction of the user
+func (c *ConversationService) AddReaction(conversationID, messageID, emoji string) (models.Message, error) {
+	if !validReaction(emoji) {
+		return models.Message{}, errInvalidReaction
+	}
+
+	return c.sendReaction(conversationID, models.Reaction{MessageID: messageID, Emoji: emoji})
+}
+
+// RemoveReaction takes back the reaction of the user to a message
+func (c *ConversationService) RemoveReaction(conversationID, messageID string) (models.Message, error) {
+	return c.sendReaction(conversationID, models.Reaction{MessageID: messageID, Remove: true})
+}
+
+// sendReaction sends the reaction to the participants and applies it to the stored message
+func (c *ConversationService) sendReaction(conversationID string, reaction models.Reaction) (models.Message, error) {
+	panicIfEmpty("conversationID", conversationID)
+	panicIfEmpty("messageID", reaction.MessageID)
+
+	conv, err := c.getConversation(conversationID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	msg, err := c.getMessage(conv.ID, reaction.MessageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	encrypted, err := c.encryptContent(conv.ID, models.Content{Reaction: &reaction})
+	if err != nil {
+		return models.Message{}, err
+	}
+	if _, err := c.api.SendReference(conv.ID, msg.ID, encrypted.Serialized); err != nil {
+		return models.Message{}, fmt.Errorf("failed to send reaction: %w", err)
+	}
+
+	if !react(&msg, c.api.UserID(), reaction) {
+		msg.ReactionCounts = msg.CountReactions()
+		return msg, nil
+	}
+	return c.storeReactions(conv.ID, msg)
+}
+
+// storeReactions stores the message with its changed reactions and reports their updated counts. The conversation
+// preview is left as it is.
+func (c *ConversationService) storeReactions(conversationID string, msg models.Message) (models.Message, error) {
+	msg.ReactionCounts = msg.CountReactions()
+	if err := c.writeMessage(conversationID, msg); err != nil {
+		return models.Message{}, fmt.Errorf("failed to store reactions: %w", err)
+	}
+
+	if c.ReactionsChanged != nil {
+		c.ReactionsChanged(msg)
+	}
+
+	return msg, nil
+}
+
+// SetTyping tells the other participants of the conversation that the user started or stopped typing
+func (c *ConversationService) SetTyping(conversationID string, typing bool) error {
+	panicIfEmpty("conversationID", conversationID)
+
+	if err := c.api.SendTyping(conversationID, typing); err != nil {
+		return fmt.Errorf("failed to send typing event: %w", err)
+	}
+
+	return nil
+}
+
+// MarkConversationRead marks the received messages of the conversation as read and, unless the user opted out, sends
+// read receipts to their authors
+func (c *ConversationService) MarkConversationRead(conversationID string) error {
+	panicIfEmpty("conversationID", conversationID)
+
+	messages, err := c.ListMessages(conversationID)
+	if err != nil {
+		return err
+	}
+
+	unread := make(map[string][]string)
+	for _, msg := range messages {
+		if msg.SenderID == "" || msg.SenderID == c.api.UserID() || msg.Read {
+			continue
+		}
+
+		msg.Read = true
+		if err := c.writeMessage(conversationID, msg); err != nil {
+			return fmt.Errorf("failed to store read message: %w", err)
+		}
+		unread[msg.SenderID] = append(unread[msg.SenderID], msg.ID)
+	}
+
+	enabled, err := c.ReadReceiptsEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	authorIDs := make([]string, 0, len(unread))
+	for id := range unread {
+		authorIDs = append(authorIDs, id)
+	}
+	sort.Strings(authorIDs)
+
+	for _, authorID := range authorIDs {
+		messageIDs := unread[authorID]
+		sort.Strings(messageIDs)
+		for start := 0; start < len(messageIDs); start += maxReceiptMessages {
+			end := min(start+maxReceiptMessages, len(messageIDs))
+			err := c.api.SendReceipt(apitypes.SendReceiptRequest{
+				ConversationID: conversationID,
+				AuthorID:       authorID,
+				MessageIDs:     messageIDs[start:end],
+				Type:           apitypes.ReceiptTypeRead,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to send read receipt: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadReceiptsEnabled reports whether the user sends read receipts, which is the default
+func (c *ConversationService) ReadReceiptsEnabled() (bool, error) {
+	bytes, err := c.db.Read(settingKey(readReceiptsSetting))
+	if err != nil {
+		return false, fmt.Errorf("failed to read read receipts setting: %w", err)
+	}
+	if bytes == nil {
+		return true, nil
+	}
+
+	var enabled bool
+	if err := json.Unmarshal(bytes, &enabled); err != nil {
+		return false, fmt.Errorf("failed to deserialize read receipts setting: %w", err)
+	}
+
+	return enabled, nil
+}
+
+// SetReadReceiptsEnabled opts the user in or out of read receipts. Users that opted out don't see the read receipts
+// of others either.
+func (c *ConversationService) SetReadReceiptsEnabled(enabled bool) error {
+	bytes, err := json.Marshal(enabled)
+	if err != nil {
+		return fmt.Errorf("failed to serialize read receipts setting: %w", err)
+	}
+
+	if err := c.db.Write(settingKey(readReceiptsSetting), bytes); err != nil {
+		return fmt.Errorf("failed to store read receipts setting: %w", err)
+	}
+
+	return nil
+}
+
+// distributeSenderKeys sends our current sender key of the conversation to the given participants
+func (c *ConversationService) distributeSenderKeys(conversationID string, recipientIDs []string) error {
+	if len(recipientIDs) == 0 {
+		return nil
+	}
+
+	participants, err := c.encryptor.CreateEncryptionGroup(conversationID, recipientIDs)
+	if err != nil {
+		return fmt.Errorf("failed to generate key distribution messages: %w", err)
+	}
+	if len(participants) == 0 {
+		return nil
+	}
+
+	if err := c.api.DistributeSenderKeys(conversationID, participants); err != nil {
+		return fmt.Errorf("failed to distribute sender keys: %w", err)
+	}
+
+	return nil
+}
+
+// rotateSenderKey replaces our sender key of the conversation and sends the new key to the given participants
+func (c *ConversationService) rotateSenderKey(conversationID string, recipientIDs []string) error {
+	if len(recipientIDs) == 0 {
+		return nil
+	}
+
+	participants, err := c.encryptor.RotateEncryptionGroup(conversationID, recipientIDs)
+	if err != nil {
+		return fmt.Errorf("failed to rotate sender key: %w", err)
+	}
+	if len(participants) == 0 {
+		return nil
+	}
+
+	if err := c.api.DistributeSenderKeys(conversationID, participants); err != nil {
+		return fmt.Errorf("failed to distribute sender keys: %w", err)
+	}
+
+	return nil
+}
+
+// otherParticipants returns the given participant IDs without the current user
+func (c *ConversationService) otherParticipants(participantIDs []string) []string {
+	userID := c.api.UserID()
+	others := make([]string, 0, len(participantIDs))
+	for _, id := range participantIDs {
+		if id != userID {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// withCurrentUser returns a copy of the given participant IDs that also contains the current user, so that key
+// distribution reaches our other devices
+func (c *ConversationService) withCurrentUser(participantIDs []string) []string {
+	ids := make([]string, 0, len(participantIDs)+1)
+	ids = append(ids, participantIDs...)
+	return append(ids, c.api.UserID())
+}
+
+// removeMessage deletes the message and its expiry index entry from the database and recomputes the conversation
+// preview
+func (c *ConversationService) removeMessage(conv models.Conversation, msg models.Message) error {
+	if err := c.db.Delete(messageKey(conv.ID, msg.ID)); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	if msg.ExpiresAt > 0 {
+		if err := c.db.Delete(expiryKey(conv.ID, msg)); err != nil {
+			return fmt.Errorf("failed to delete expiry index entry: %w", err)
+		}
+	}
+
+	if c.MessageDeleted != nil {
+		c.MessageDeleted(msg)
+	}
+
+	return c.refreshPreview(conv)
+}
+
+// refreshPreview sets the preview of the conversation to its latest remaining message, or clears it when there are
+// none left
+func (c *ConversationService) refreshPreview(conv models.Conversation) error {
+	messages, err := c.ListMessages(conv.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := conv
+	updated.LastMessagePreview, updated.LastMessageSenderID, updated.LastMessageTimestamp = "", "", 0
+	for _, msg := range messages {
+		if msg.Timestamp > updated.LastMessageTimestamp {
+			updated.LastMessagePreview = messagePreview(msg.Text)
+			updated.LastMessageSenderID = msg.SenderID
+			updated.LastMessageTimestamp = msg.Timestamp
+		}
+	}
+	if updated.LastMessagePreview == conv.LastMessagePreview && updated.LastMessageSenderID == conv.LastMessageSenderID &&
+		updated.LastMessageTimestamp == conv.LastMessageTimestamp {
+		return nil
+	}
+
+	if err := c.writeConversation(updated); err != nil {
+		return fmt.Errorf("failed to store updated conversation: %w", err)
+	}
+
+	if c.ConversationUpdated != nil {
+		c.ConversationUpdated(updated)
+	}
+
+	return nil
+}
+
+// resolveQuote marks the quote of the message as unavailable when the quoted message isn't stored
+func (c *ConversationService) resolveQuote(conversationID string, msg *models.Message) error {
+	if msg.Quote == nil {
+		return nil
+	}
+
+	_, err := c.getMessage(conversationID, msg.Quote.MessageID)
+	if errors.Is(err, errMessageNotFound) {
+		msg.Quote = unavailableQuote(*msg.Quote)
+		return nil
+	}
+	return err
+}
+
+// unavailableQuote hides the snippet of a quoted message that was deleted or never received
+func unavailableQuote(quote models.Quote) *models.Quote {
+	quote.Text = models.QuoteUnavailable
+	quote.Unavailable = true
+	return &quote
+}
+
+// sentBy tells whether the user is the author of the message. Messages sent from this device have no sender.
+func (c *ConversationService) sentBy(msg models.Message, userID string) bool {
+	return msg.SenderID == userID || (msg.SenderID == "" && userID == c.api.UserID())
+}
+
+// encryptContent encrypts the content of a message with the sender key of the conversation
+func (c *ConversationService) encryptContent(conversationID string, content models.Content) (*encryption.EncryptedMessage, error) {
+	plaintext, err := content.Serialize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize message content: %w", err)
+	}
+
+	encrypted, err := c.encryptor.GroupEncrypt(conversationID, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message content: %w", err)
+	}
+
+	return encrypted, nil
+}
+
+// react applies the reaction of the user to the message and reports whether it changed
+func react(msg *models.Message, userID string, reaction models.Reaction) bool {
+	if reaction.Remove {
+		return msg.Unreact(userID)
+	}
+	return msg.React(userID, reaction.Emoji)
+}
+
+func validReaction(emoji string) bool {
+	return emoji != "" && len(emoji) <= maxReactionLength
+}
+
 func messagePreview(text string) string {
 	l := min(len(text), 100)
 	return text[0:l]
@@ -296,7 +1589,7 @@ func (c *ConversationService) getConversation(conversationID string) (models.Con
 		return models.Conversation{}, fmt.Errorf("failed to read conversation: %w", err)
 	}
 	if bytes == nil {
-		return models.Conversation{}, fmt.Errorf("conversation not found")
+		return models.Conversation{}, errConversationNotFound
 	}
 
 	conv, err := models.DeserializeConversation(bytes)
@@ -307,6 +1600,23 @@ func (c *ConversationService) getConversation(conversationID string) (models.Con
 	return conv, nil
 }
 
+func (c *ConversationService) getMessage(conversationID, messageID string) (models.Message, error) {
+	bytes, err := c.db.Read(messageKey(conversationID, messageID))
+	if err != nil {
+		return models.Message{}, fmt.Errorf("failed to read message: %w", err)
+	}
+	if bytes == nil {
+		return models.Message{}, errMessageNotFound
+	}
+
+	msg, err := models.DeserializeMessage(bytes)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("failed to deserialize message: %w", err)
+	}
+
+	return msg, nil
+}
+
 func (c *ConversationService) writeConversation(conv models.Conversation) error {
 	bytes, err := conv.Serialize()
 	if err != nil {
@@ -330,6 +1640,16 @@ func (c *ConversationService) writeMessage(conversationID string, msg models.Mes
 		return err
 	}
 
+	if msg.ExpiresAt > 0 {
+		if err := c.db.Write(expiryKey(conversationID, msg), []byte(conversationID)); err != nil {
+			return fmt.Errorf("failed to index disappearing message: %w", err)
+		}
+		select {
+		case c.expirationChanged <- struct{}{}:
+		default:
+		}
+	}
+
 	return nil
 }
 
@@ -340,3 +1660,31 @@ func conversationKey(conversationID string) string {
 func messageKey(conversationID string, messageID string) string {
 	return fmt.Sprintf("message#%s:%s", conversationID, messageID)
 }
+
+// expiryPrefix is the prefix of the expiry index, its keys sort by the time the messages expire at and hold the ID of
+// their conversation
+const expiryPrefix = "expires#"
+
+func expiryKey(conversationID string, msg models.Message) string {
+	return fmt.Sprintf("%s%020d#%s:%s", expiryPrefix, msg.ExpiresAt, conversationID, msg.ID)
+}
+
+// parseExpiryKey returns the expiry time and the message ID of an expiry index key of the given conversation
+func parseExpiryKey(key, conversationID string) (int64, string, error) {
+	rest, found := strings.CutPrefix(key, expiryPrefix)
+	timestamp, rest, sep := strings.Cut(rest, "#")
+	messageID, matches := strings.CutPrefix(rest, conversationID+":")
+	if !found || !sep || !matches {
+		return 0, "", fmt.Errorf("malformed expiry index key %s", key)
+	}
+
+	expiresAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed expiry index key %s: %w", key, err)
+	}
+	return expiresAt, messageID, nil
+}
+
+func settingKey(name string) string {
+	return fmt.Sprintf("setting#%s", name)
+}
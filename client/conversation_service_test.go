@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -66,7 +67,7 @@ func TestConversationService_WebsocketHandlers(t *testing.T) {
 		en := encryption.NewFakeManager()
 		svc := NewConversationService(db, ac, en)
 		text := "Hello world!"
-		encrypted, _ := en.GroupEncrypt("123", []byte(text))
+		encrypted := mustEncrypt(en, "123", models.Content{Text: text})
 		convPayload := apitypes.WSNewConversationPayload{
 			ConversationID:         "123",
 			ParticipantIDs:         []string{"alice", "bob"},
@@ -187,7 +188,7 @@ func TestConversationService_WebsocketHandlers(t *testing.T) {
 		en := encryption.NewFakeManager()
 		svc := NewConversationService(db, ac, en)
 		text := "Hello world!"
-		encrypted, _ := en.GroupEncrypt("123", []byte(text))
+		encrypted := mustEncrypt(en, "123", models.Content{Text: text})
 
 		// First create a conversation
 		convPayload := apitypes.WSNewConversationPayload{
@@ -247,7 +248,7 @@ func TestConversationService_WebsocketHandlers(t *testing.T) {
 		en := encryption.NewFakeManager()
 		svc := NewConversationService(db, ac, en)
 		text := "Hello world!"
-		encrypted, _ := en.GroupEncrypt("123", []byte(text))
+		encrypted := mustEncrypt(en, "123", models.Content{Text: text})
 
 		// First create a conversation
 		convPayload := apitypes.WSNewConversationPayload{
@@ -296,6 +297,289 @@ func TestConversationService_WebsocketHandlers(t *testing.T) {
 		assert.True(t, msgCallback, "new message callback should have been invoked")
 		assert.True(t, convCallback, "updated conversation callback should have been invoked")
 	})
+
+	t.Run("Sync websocket message handler processes pages in order and reports progress", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		type progress struct {
+			synced int
+			done   bool
+		}
+		var reported []progress
+		svc.SyncProgressed = func(synced int, done bool) {
+			reported = append(reported, progress{synced: synced, done: done})
+		}
+
+		encrypted := mustEncrypt(en, "123", models.Content{Text: "Hello world!"})
+		firstPage := apitypes.WSSyncPayload{
+			Messages: []apitypes.WSMessage{{
+				ID:   "msg1",
+				Type: apitypes.MessageTypeNewConversation,
+				Data: mustMarshal(apitypes.WSNewConversationPayload{
+					ConversationID:         "123",
+					ParticipantIDs:         []string{"alice", "bob"},
+					SenderID:               "alice",
+					KeyDistributionMessage: []byte("key-distribution-message"),
+				}),
+			}},
+			HasMore: true,
+		}
+		secondPage := apitypes.WSSyncPayload{
+			Messages: []apitypes.WSMessage{{
+				ID:   "msg2",
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: "123",
+					MessageID:      "def",
+					SenderID:       "alice",
+					Content:        encrypted.Serialized,
+					CreatedAt:      time.Now().UnixMilli(),
+				}),
+			}},
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			{Type: apitypes.MessageTypeSync, Data: mustMarshal(firstPage)},
+			{Type: apitypes.MessageTypeSync, Data: mustMarshal(secondPage)},
+		})
+
+		// Assert
+		messages, err := svc.ListMessages("123")
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "message of the second page should be added to the conversation of the first page")
+		assert.Equal(t, "def", messages[0].ID)
+		assert.Equal(t, []progress{{synced: 1, done: false}, {synced: 2, done: true}}, reported)
+	})
+
+	t.Run("Sync websocket message handler handles the rest of a page after a failing message", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "123"}))
+		var done bool
+		svc.SyncProgressed = func(synced int, d bool) {
+			done = d
+		}
+		newMessage := func(conversationID, messageID string) apitypes.WSMessage {
+			return apitypes.WSMessage{
+				ID:   messageID,
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: conversationID,
+					MessageID:      messageID,
+					SenderID:       "alice",
+					Content:        mustEncrypt(en, conversationID, models.Content{Text: "Hello world!"}).Serialized,
+					CreatedAt:      time.Now().UnixMilli(),
+				}),
+			}
+		}
+		page := apitypes.WSSyncPayload{Messages: []apitypes.WSMessage{
+			newMessage("unknown", "abc"),
+			newMessage("123", "def"),
+		}}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{Type: apitypes.MessageTypeSync, Data: mustMarshal(page)}})
+
+		// Assert
+		messages, err := svc.ListMessages("123")
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "messages after the failing one should still be handled")
+		assert.Equal(t, "def", messages[0].ID)
+		assert.True(t, done, "sync should be reported as done")
+	})
+
+	t.Run("Sync websocket message handler uploads new pre-keys when server runs low", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		encryptor := encryption.NewEncryptionManager(db, ac)
+		_, err := encryptor.InitializeKeyStore()
+		require.NoError(t, err)
+		NewConversationService(db, ac, encryptor)
+
+		syncPayload := apitypes.WSSyncPayload{Messages: []apitypes.WSMessage{{
+			Type: apitypes.MessageTypePreKeysLow,
+			Data: mustMarshal(apitypes.WSPreKeysLowPayload{Remaining: 5}),
+		}}}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeSync,
+			Data: mustMarshal(syncPayload),
+		}})
+
+		// Assert
+		assert.NotEmpty(t, ac.UploadedPreKeys)
+	})
+}
+
+func TestConversationService_ParticipantHandlers(t *testing.T) {
+	t.Run("ParticipantAdded websocket message handler creates conversation for added participant and distributes sender key", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "carol"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		var added models.Conversation
+		svc.ConversationAdded = func(conv models.Conversation) { added = conv }
+
+		payload := apitypes.WSParticipantAddedPayload{
+			ConversationID:         "123",
+			SenderID:               "alice",
+			ParticipantIDs:         []string{"alice", "bob", "carol"},
+			AddedIDs:               []string{"carol"},
+			KeyDistributionMessage: []byte("key-distribution-message"),
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeParticipantAdded,
+			Data: mustMarshal(payload),
+		}})
+
+		// Assert
+		assert.Equal(t, "123", added.ID)
+		assert.Equal(t, []string{"alice", "bob"}, added.ParticipantIDs, "current user should not be included in participants")
+		assert.Contains(t, ac.SentKeyDistributions, "alice")
+		assert.Contains(t, ac.SentKeyDistributions, "bob")
+	})
+
+	t.Run("ParticipantAdded websocket message handler updates conversation and sends sender key only to added participants", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "bob"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		payload := apitypes.WSParticipantAddedPayload{
+			ConversationID: conv.ID,
+			SenderID:       "alice",
+			ParticipantIDs: []string{"alice", "bob", "carol"},
+			AddedIDs:       []string{"carol"},
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeParticipantAdded,
+			Data: mustMarshal(payload),
+		}})
+
+		// Assert
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		require.Len(t, conversations, 1)
+		assert.Equal(t, []string{"alice", "carol"}, conversations[0].ParticipantIDs)
+		assert.Len(t, ac.SentKeyDistributions, 1)
+		assert.Contains(t, ac.SentKeyDistributions, "carol")
+	})
+
+	t.Run("ParticipantAdded websocket message handler keeps the conversation when it can't be read", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "bob"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice"})
+		require.NoError(t, err)
+		stored := db.Items[conversationKey(conv.ID)]
+		db.ReadErr = errors.New("disk unavailable")
+
+		payload := apitypes.WSParticipantAddedPayload{
+			ConversationID: conv.ID,
+			SenderID:       "alice",
+			ParticipantIDs: []string{"alice", "bob", "carol"},
+			AddedIDs:       []string{"carol"},
+		}
+
+		// Act
+		err = svc.handleParticipantAdded(mustMarshal(payload))
+
+		// Assert
+		assert.ErrorContains(t, err, "disk unavailable")
+		assert.Equal(t, stored, db.Items[conversationKey(conv.ID)], "the conversation should not be overwritten")
+	})
+
+	t.Run("ParticipantRemoved websocket message handler rotates sender key for remaining participants", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "bob"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice", "carol"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		payload := apitypes.WSParticipantRemovedPayload{
+			ConversationID:         conv.ID,
+			SenderID:               "alice",
+			RemovedID:              "carol",
+			ParticipantIDs:         []string{"alice", "bob"},
+			KeyDistributionMessage: []byte("rotated-key"),
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeParticipantRemoved,
+			Data: mustMarshal(payload),
+		}})
+
+		// Assert
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		require.Len(t, conversations, 1)
+		assert.Equal(t, []string{"alice"}, conversations[0].ParticipantIDs)
+		assert.Contains(t, ac.SentKeyDistributions, "alice")
+		assert.NotContains(t, ac.SentKeyDistributions, "carol", "removed participant must not receive the rotated key")
+	})
+
+	t.Run("ParticipantRemoved websocket message handler clears participants when current user was removed", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "carol"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice", "bob"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		payload := apitypes.WSParticipantRemovedPayload{
+			ConversationID: conv.ID,
+			SenderID:       "alice",
+			RemovedID:      "carol",
+			ParticipantIDs: []string{"alice", "bob"},
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeParticipantRemoved,
+			Data: mustMarshal(payload),
+		}})
+
+		// Assert
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		require.Len(t, conversations, 1)
+		assert.Empty(t, conversations[0].ParticipantIDs)
+		assert.Empty(t, ac.SentKeyDistributions)
+	})
 }
 
 func TestConversationService_ListConversations(t *testing.T) {
@@ -430,6 +714,93 @@ func TestConversationService_CreateConversation(t *testing.T) {
 	})
 }
 
+func TestConversationService_AddParticipants(t *testing.T) {
+	t.Run("adds participants and sends them the sender key", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		// Act
+		updated, err := svc.AddParticipants(conv.ID, []string{"bob", "carol"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob", "carol"}, updated.ParticipantIDs)
+		assert.Len(t, ac.SentKeyDistributions, 2)
+		assert.Contains(t, ac.SentKeyDistributions, "bob")
+		assert.Contains(t, ac.SentKeyDistributions, "carol")
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		assert.Contains(t, conversations, updated)
+	})
+
+	t.Run("returns error if API client fails to send request", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice"})
+		require.NoError(t, err)
+		ac.AddParticipantsError = errors.New("test error")
+
+		// Act
+		_, err = svc.AddParticipants(conv.ID, []string{"bob"})
+
+		// Assert
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to add participants")
+	})
+}
+
+func TestConversationService_RemoveParticipant(t *testing.T) {
+	t.Run("rotates sender key for remaining participants", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "me"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice", "bob"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		// Act
+		updated, err := svc.RemoveParticipant(conv.ID, "bob")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, updated.ParticipantIDs)
+		assert.Contains(t, ac.SentKeyDistributions, "alice")
+		assert.NotContains(t, ac.SentKeyDistributions, "bob", "removed participant must not receive the rotated key")
+	})
+
+	t.Run("leaves conversation without rotating sender key when removing current user", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "me"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"alice", "bob"})
+		require.NoError(t, err)
+		clear(ac.SentKeyDistributions)
+
+		// Act
+		updated, err := svc.RemoveParticipant(conv.ID, "me")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, updated.ParticipantIDs)
+		assert.Empty(t, ac.SentKeyDistributions)
+	})
+}
+
 func TestConversationService_SendMessage(t *testing.T) {
 	t.Run("creates a new message on successful response from server", func(t *testing.T) {
 		// Arrange
@@ -520,6 +891,48 @@ func TestConversationService_SendMessage(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to send message")
 	})
+	t.Run("marks message as sent to every recipient", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendMessageResponse = apitypes.SendMessageResponse{MessageID: "123", CreatedAt: time.Now().UnixMilli()}
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob", "carol"})
+		require.NoError(t, err)
+
+		// Act
+		msg, err := svc.SendMessage(conv.ID, "Hello")
+
+		// Assert
+		require.NoError(t, err)
+		expected := map[string]models.MessageStatus{"bob": models.MessageStatusSent, "carol": models.MessageStatusSent}
+		assert.Equal(t, expected, msg.Statuses)
+		messages, err := svc.ListMessages(conv.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.Message{msg}, messages, "pending message should have been replaced")
+	})
+	t.Run("keeps message pending when sending fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendMessageError = errors.New("test error")
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob"})
+		require.NoError(t, err)
+
+		// Act
+		_, err = svc.SendMessage(conv.ID, "Hello")
+
+		// Assert
+		require.Error(t, err)
+		messages, err := svc.ListMessages(conv.ID)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "Hello", messages[0].Text)
+		assert.Equal(t, map[string]models.MessageStatus{"bob": models.MessageStatusPending}, messages[0].Statuses)
+	})
 	t.Run("returns error when database write fails", func(t *testing.T) {
 		// Arrange
 		db := database.NewStub()
@@ -554,33 +967,339 @@ func TestConversationService_SendMessage(t *testing.T) {
 	})
 }
 
-func TestConversationService_ListMessages(t *testing.T) {
-	t.Run("returns all messages from the given conversation", func(t *testing.T) {
+func TestConversationService_Typing(t *testing.T) {
+	t.Run("Typing websocket message handler reports participants starting and stopping to type", func(t *testing.T) {
 		// Arrange
 		db := database.NewFake()
 		_ = db.Open(DummyValue)
-
-		ac := api.NewFakeClient()
-		user1, _ := ac.SignUp("user1", "password", apitypes.KeyBundle{})
-		_, _ = ac.SignUp("me", "password", apitypes.KeyBundle{})
-
+		ac := api.NewStubClient()
 		svc := NewConversationService(db, ac, encryption.NewFakeManager())
-		conv, err := svc.CreateConversation([]string{user1.UserID})
-		require.NoError(t, err)
-
-		msg, err := svc.SendMessage(conv.ID, "Test message")
-		require.NoError(t, err)
+		var changes []bool
+		svc.TypingChanged = func(conversationID, userID string, typing bool) {
+			assert.Equal(t, "123", conversationID)
+			assert.Equal(t, "alice", userID)
+			changes = append(changes, typing)
+		}
 
 		// Act
-		messages, err := svc.ListMessages(conv.ID)
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			{Type: apitypes.MessageTypeTyping, Data: mustMarshal(apitypes.WSTypingPayload{ConversationID: "123", SenderID: "alice", Typing: true})},
+			{Type: apitypes.MessageTypeTyping, Data: mustMarshal(apitypes.WSTypingPayload{ConversationID: "123", SenderID: "alice", Typing: true})},
+			{Type: apitypes.MessageTypeTyping, Data: mustMarshal(apitypes.WSTypingPayload{ConversationID: "123", SenderID: "alice", Typing: false})},
+		})
 
 		// Assert
-		assert.NoError(t, err)
-		assert.Len(t, messages, 1)
-		assert.Contains(t, messages, msg)
+		assert.Equal(t, []bool{true, false}, changes, "repeated typing events should be reported once")
 	})
 
-	t.Run("panics when empty conversation ID", func(t *testing.T) {
+	t.Run("Typing expires when stop is never received", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		svc.typingTimeout = 50 * time.Millisecond
+		changes := make(chan bool, 2)
+		svc.TypingChanged = func(conversationID, userID string, typing bool) {
+			changes <- typing
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			{Type: apitypes.MessageTypeTyping, Data: mustMarshal(apitypes.WSTypingPayload{ConversationID: "123", SenderID: "alice", Typing: true})},
+		})
+
+		// Assert
+		assert.True(t, <-changes)
+		select {
+		case typing := <-changes:
+			assert.False(t, typing)
+		case <-time.After(time.Second):
+			t.Fatal("typing did not expire")
+		}
+	})
+
+	t.Run("SetTyping sends typing event for the conversation", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+
+		// Act
+		err := svc.SetTyping("123", true)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.WSTypingPayload{{ConversationID: "123", Typing: true}}, ac.SentTyping)
+	})
+
+	t.Run("SetTyping returns error when sending fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendTypingError = errors.New("send failed")
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+
+		// Act
+		err := svc.SetTyping("123", true)
+
+		// Assert
+		assert.ErrorContains(t, err, "failed to send typing event")
+	})
+}
+
+func TestConversationService_Receipts(t *testing.T) {
+	// sentMessage sets up a conversation with bob and carol and a message sent to both
+	sentMessage := func(t *testing.T) (*ConversationService, *api.StubClient, models.Message) {
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "me"
+		ac.SendMessageResponse = apitypes.SendMessageResponse{MessageID: "msg1", CreatedAt: time.Now().UnixMilli()}
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob", "carol"})
+		require.NoError(t, err)
+		msg, err := svc.SendMessage(conv.ID, "Hello")
+		require.NoError(t, err)
+		return svc, ac, msg
+	}
+
+	receipt := func(senderID string, receiptType apitypes.ReceiptType, conversationID string, messageIDs ...string) apitypes.WSMessage {
+		return apitypes.WSMessage{Type: apitypes.MessageTypeReceipt, Data: mustMarshal(apitypes.WSReceiptPayload{
+			ConversationID: conversationID,
+			SenderID:       senderID,
+			MessageIDs:     messageIDs,
+			Type:           receiptType,
+		})}
+	}
+
+	t.Run("NewMessage websocket message handler sends delivery receipt to the author", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "me"
+		en := encryption.NewFakeManager()
+		_ = NewConversationService(db, ac, en)
+		encrypted := mustEncrypt(en, "123", models.Content{Text: "Hello"})
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			{Type: apitypes.MessageTypeNewConversation, Data: mustMarshal(apitypes.WSNewConversationPayload{ConversationID: "123", ParticipantIDs: []string{"alice", "me"}, SenderID: "alice"})},
+			{Type: apitypes.MessageTypeNewMessage, Data: mustMarshal(apitypes.WSNewMessagePayload{ConversationID: "123", MessageID: "def", SenderID: "alice", Content: encrypted.Serialized})},
+			{Type: apitypes.MessageTypeNewMessage, Data: mustMarshal(apitypes.WSNewMessagePayload{ConversationID: "123", MessageID: "ghi", SenderID: "me", Content: encrypted.Serialized})},
+		})
+
+		// Assert
+		expected := []apitypes.SendReceiptRequest{{ConversationID: "123", AuthorID: "alice", MessageIDs: []string{"def"}, Type: apitypes.ReceiptTypeDelivered}}
+		assert.Equal(t, expected, ac.SentReceipts, "messages of our other devices should not be acknowledged")
+	})
+
+	t.Run("NewMessage websocket message handler stores message when delivery receipt fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendReceiptError = errors.New("send failed")
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		encrypted := mustEncrypt(en, "123", models.Content{Text: "Hello"})
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			{Type: apitypes.MessageTypeNewConversation, Data: mustMarshal(apitypes.WSNewConversationPayload{ConversationID: "123", ParticipantIDs: []string{"alice"}, SenderID: "alice"})},
+			{Type: apitypes.MessageTypeNewMessage, Data: mustMarshal(apitypes.WSNewMessagePayload{ConversationID: "123", MessageID: "def", SenderID: "alice", Content: encrypted.Serialized})},
+		})
+
+		// Assert
+		messages, err := svc.ListMessages("123")
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+
+	t.Run("Receipt websocket message handler advances status per recipient", func(t *testing.T) {
+		// Arrange
+		svc, ac, msg := sentMessage(t)
+		conv, err := svc.ListConversations()
+		require.NoError(t, err)
+		var updates []models.Message
+		svc.MessageUpdated = func(msg models.Message) {
+			updates = append(updates, msg)
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			receipt("bob", apitypes.ReceiptTypeDelivered, conv[0].ID, msg.ID),
+			receipt("bob", apitypes.ReceiptTypeRead, conv[0].ID, msg.ID),
+			receipt("bob", apitypes.ReceiptTypeDelivered, conv[0].ID, msg.ID),
+			receipt("carol", apitypes.ReceiptTypeDelivered, conv[0].ID, msg.ID, "unknown"),
+			receipt("mallory", apitypes.ReceiptTypeRead, conv[0].ID, msg.ID),
+		})
+
+		// Assert
+		expected := map[string]models.MessageStatus{"bob": models.MessageStatusRead, "carol": models.MessageStatusDelivered}
+		messages, err := svc.ListMessages(conv[0].ID)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, expected, messages[0].Statuses, "statuses should never go back")
+		require.Len(t, updates, 3, "only changed statuses should be reported")
+		assert.Equal(t, expected, updates[2].Statuses)
+	})
+
+	t.Run("Receipt websocket message handler ignores read receipts when user opted out", func(t *testing.T) {
+		// Arrange
+		svc, ac, msg := sentMessage(t)
+		conv, err := svc.ListConversations()
+		require.NoError(t, err)
+		require.NoError(t, svc.SetReadReceiptsEnabled(false))
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{receipt("bob", apitypes.ReceiptTypeRead, conv[0].ID, msg.ID)})
+
+		// Assert
+		messages, err := svc.ListMessages(conv[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.MessageStatusDelivered, messages[0].Statuses["bob"], "a read message was delivered too")
+	})
+
+	t.Run("MarkConversationRead marks received messages read and sends read receipts per author", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "me"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "123", ParticipantIDs: []string{"alice", "bob"}}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m1", SenderID: "bob"}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m2", SenderID: "alice"}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m3", SenderID: "bob"}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m4", SenderID: "alice", Read: true}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m5", Statuses: map[string]models.MessageStatus{"bob": models.MessageStatusSent}}))
+
+		// Act
+		err := svc.MarkConversationRead("123")
+
+		// Assert
+		require.NoError(t, err)
+		expected := []apitypes.SendReceiptRequest{
+			{ConversationID: "123", AuthorID: "alice", MessageIDs: []string{"m2"}, Type: apitypes.ReceiptTypeRead},
+			{ConversationID: "123", AuthorID: "bob", MessageIDs: []string{"m1", "m3"}, Type: apitypes.ReceiptTypeRead},
+		}
+		assert.Equal(t, expected, ac.SentReceipts)
+		messages, err := svc.ListMessages("123")
+		require.NoError(t, err)
+		for _, msg := range messages {
+			assert.Equal(t, msg.SenderID != "", msg.Read, "message %s", msg.ID)
+		}
+	})
+
+	t.Run("MarkConversationRead splits large read receipts", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "123", ParticipantIDs: []string{"bob"}}))
+		for i := 0; i < maxReceiptMessages+1; i++ {
+			require.NoError(t, svc.writeMessage("123", models.Message{ID: fmt.Sprintf("m%03d", i), SenderID: "bob"}))
+		}
+
+		// Act
+		err := svc.MarkConversationRead("123")
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, ac.SentReceipts, 2)
+		assert.Len(t, ac.SentReceipts[0].MessageIDs, maxReceiptMessages)
+		assert.Equal(t, []string{"m100"}, ac.SentReceipts[1].MessageIDs)
+	})
+
+	t.Run("MarkConversationRead doesn't send read receipts when user opted out", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "123", ParticipantIDs: []string{"bob"}}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m1", SenderID: "bob"}))
+		require.NoError(t, svc.SetReadReceiptsEnabled(false))
+
+		// Act
+		err := svc.MarkConversationRead("123")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, ac.SentReceipts)
+		messages, err := svc.ListMessages("123")
+		require.NoError(t, err)
+		assert.True(t, messages[0].Read, "message should be read locally")
+	})
+
+	t.Run("MarkConversationRead returns error when sending fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendReceiptError = errors.New("send failed")
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "123", ParticipantIDs: []string{"bob"}}))
+		require.NoError(t, svc.writeMessage("123", models.Message{ID: "m1", SenderID: "bob"}))
+
+		// Act
+		err := svc.MarkConversationRead("123")
+
+		// Assert
+		assert.ErrorContains(t, err, "failed to send read receipt")
+	})
+
+	t.Run("read receipts are enabled by default", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+
+		// Act
+		defaultEnabled, err := svc.ReadReceiptsEnabled()
+		require.NoError(t, err)
+		require.NoError(t, svc.SetReadReceiptsEnabled(false))
+		enabled, err := svc.ReadReceiptsEnabled()
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, defaultEnabled)
+		assert.False(t, enabled)
+	})
+}
+
+func TestConversationService_ListMessages(t *testing.T) {
+	t.Run("returns all messages from the given conversation", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+
+		ac := api.NewFakeClient()
+		user1, _ := ac.SignUp("user1", "password", apitypes.KeyBundle{})
+		_, _ = ac.SignUp("me", "password", apitypes.KeyBundle{})
+
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{user1.UserID})
+		require.NoError(t, err)
+
+		msg, err := svc.SendMessage(conv.ID, "Test message")
+		require.NoError(t, err)
+
+		// Act
+		messages, err := svc.ListMessages(conv.ID)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, messages, 1)
+		assert.Contains(t, messages, msg)
+	})
+
+	t.Run("panics when empty conversation ID", func(t *testing.T) {
 		// Arrange
 		db := database.NewFake()
 		_ = db.Open(DummyValue)
@@ -628,6 +1347,846 @@ func TestConversationService_ListMessages(t *testing.T) {
 	})
 }
 
+func TestConversationService_ExpirationTimer(t *testing.T) {
+	t.Run("sets the timer of the conversation", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob"})
+		require.NoError(t, err)
+
+		// Act
+		updated, err := svc.SetExpirationTimer(conv.ID, 3600)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), updated.ExpirationTimer)
+		assert.Equal(t, int64(3600), ac.ExpirationTimers[conv.ID])
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), conversations[0].ExpirationTimer)
+	})
+
+	t.Run("keeps the timer unchanged when the server rejects it", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SetExpirationTimerError = errors.New("unauthorized")
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob"})
+		require.NoError(t, err)
+
+		// Act
+		_, err = svc.SetExpirationTimer(conv.ID, 3600)
+
+		// Assert
+		assert.Error(t, err)
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		assert.Zero(t, conversations[0].ExpirationTimer)
+	})
+
+	t.Run("stores the timer announced by another participant", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", ParticipantIDs: []string{"bob"}}))
+		var updated models.Conversation
+		svc.ConversationUpdated = func(conv models.Conversation) {
+			updated = conv
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeExpirationTimer,
+			Data: mustMarshal(apitypes.WSExpirationTimerPayload{ConversationID: "conv-1", SenderID: "bob", ExpirationTimer: 60}),
+		}})
+
+		// Assert
+		assert.Equal(t, int64(60), updated.ExpirationTimer)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(60), conv.ExpirationTimer)
+	})
+
+	t.Run("stores the expiration time of sent messages", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.SendMessageResponse = apitypes.SendMessageResponse{MessageID: "msg-1", CreatedAt: 1000, ExpiresAt: 61_000}
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob"})
+		require.NoError(t, err)
+
+		// Act
+		msg, err := svc.SendMessage(conv.ID, "Disappearing message")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int64(61_000), msg.ExpiresAt)
+		stored, err := svc.getMessage(conv.ID, "msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(61_000), stored.ExpiresAt)
+	})
+}
+
+func TestConversationService_EditMessage(t *testing.T) {
+	t.Run("sends a revision of own message and keeps the previous text", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		now := time.Now().UnixMilli()
+		ac.SendMessageResponse = apitypes.SendMessageResponse{MessageID: "msg-1", CreatedAt: now}
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv, err := svc.CreateConversation([]string{"bob"})
+		require.NoError(t, err)
+		_, err = svc.SendMessage(conv.ID, "Helo")
+		require.NoError(t, err)
+		ac.SendMessageResponse = apitypes.SendMessageResponse{MessageID: "msg-2", CreatedAt: now + 1000}
+		var updated models.Message
+		svc.MessageUpdated = func(msg models.Message) {
+			updated = msg
+		}
+
+		// Act
+		msg, err := svc.EditMessage(conv.ID, "msg-1", "Hello")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", msg.Text)
+		assert.Equal(t, now+1000, msg.EditedAt)
+		assert.Equal(t, []models.MessageRevision{{Text: "Helo", Timestamp: now}}, msg.Revisions)
+		assert.Equal(t, msg, updated, "updated message callback should have been invoked")
+		assert.Equal(t, []string{"msg-1"}, ac.SentReferences, "the revision should refer to the edited message")
+		stored, err := svc.getMessage(conv.ID, "msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, msg, stored)
+		_, err = svc.getMessage(conv.ID, "msg-2")
+		assert.ErrorIs(t, err, errMessageNotFound, "the revision should not be stored as a message")
+		conv, err = svc.getConversation(conv.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", conv.LastMessagePreview)
+	})
+
+	t.Run("refuses to edit messages of other users", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", SenderID: "bob", Timestamp: time.Now().UnixMilli()}))
+
+		// Act
+		_, err := svc.EditMessage("conv-1", "msg-1", "Bye")
+
+		// Assert
+		assert.ErrorIs(t, err, errMessageNotEditable)
+	})
+
+	t.Run("refuses to edit messages after the edit window", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		svc.EditWindow = time.Minute
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", Timestamp: time.Now().Add(-time.Hour).UnixMilli()}))
+
+		// Act
+		_, err := svc.EditMessage("conv-1", "msg-1", "Bye")
+
+		// Assert
+		assert.ErrorIs(t, err, errEditWindowExpired)
+	})
+
+	t.Run("applies a revision received from the author", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		now := time.Now().UnixMilli()
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "Helo", LastMessageTimestamp: now}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Helo", SenderID: "bob", Timestamp: now}))
+		var updated models.Message
+		svc.MessageUpdated = func(msg models.Message) {
+			updated = msg
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{{
+			Type: apitypes.MessageTypeNewMessage,
+			Data: mustMarshal(apitypes.WSNewMessagePayload{
+				ConversationID: "conv-1",
+				MessageID:      "msg-2",
+				SenderID:       "bob",
+				Content:        mustEncrypt(en, "conv-1", models.Content{Text: "Hello", EditOf: "msg-1"}).Serialized,
+				CreatedAt:      now + 1000,
+			}),
+		}})
+
+		// Assert
+		assert.Equal(t, "Hello", updated.Text, "updated message callback should have been invoked")
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "the revision should not be stored as a message")
+		assert.Equal(t, "Hello", messages[0].Text)
+		assert.Equal(t, []models.MessageRevision{{Text: "Helo", Timestamp: now}}, messages[0].Revisions)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", conv.LastMessagePreview)
+		assert.Empty(t, ac.SentReceipts, "revisions should not be acknowledged")
+	})
+
+	t.Run("ignores revisions from other participants and after the edit window", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		svc.EditWindow = time.Minute
+		now := time.Now().UnixMilli()
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		original := models.Message{ID: "msg-1", Text: "Hi", SenderID: "bob", Timestamp: now}
+		require.NoError(t, svc.writeMessage("conv-1", original))
+		edit := func(senderID string, createdAt int64) apitypes.WSMessage {
+			return apitypes.WSMessage{
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: "conv-1",
+					MessageID:      "msg-2",
+					SenderID:       senderID,
+					Content:        mustEncrypt(en, "conv-1", models.Content{Text: "Bye", EditOf: "msg-1"}).Serialized,
+					CreatedAt:      createdAt,
+				}),
+			}
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			edit("mallory", now+1000),
+			edit("bob", now+2*time.Minute.Milliseconds()),
+		})
+
+		// Assert
+		stored, err := svc.getMessage("conv-1", "msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, original, stored)
+	})
+
+	t.Run("skips edits of missing messages without failing the sync page", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		payload := apitypes.WSNewMessagePayload{
+			ConversationID: "conv-1",
+			MessageID:      "msg-2",
+			SenderID:       "bob",
+			Content:        mustEncrypt(en, "conv-1", models.Content{Text: "Bye", EditOf: "deleted"}).Serialized,
+			CreatedAt:      time.Now().UnixMilli(),
+		}
+
+		// Act
+		err := svc.handleNewMessage(mustMarshal(payload))
+
+		// Assert
+		assert.NoError(t, err)
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		assert.Empty(t, messages, "the edit should not be stored as a message")
+	})
+}
+
+func TestConversationService_DeleteMessage(t *testing.T) {
+	t.Run("deletes message locally and recomputes the preview", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "second", LastMessageSenderID: "bob", LastMessageTimestamp: 2000}))
+		first := models.Message{ID: "msg-1", Text: "first", Timestamp: 1000}
+		second := models.Message{ID: "msg-2", Text: "second", SenderID: "bob", Timestamp: 2000}
+		require.NoError(t, svc.writeMessage("conv-1", first))
+		require.NoError(t, svc.writeMessage("conv-1", second))
+		var deleted models.Message
+		svc.MessageDeleted = func(msg models.Message) {
+			deleted = msg
+		}
+
+		// Act
+		err := svc.DeleteMessage("conv-1", "msg-2")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, second, deleted, "deleted message callback should have been invoked")
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, []models.Message{first}, messages)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, "first", conv.LastMessagePreview)
+		assert.Empty(t, conv.LastMessageSenderID)
+		assert.Equal(t, int64(1000), conv.LastMessageTimestamp)
+		assert.Empty(t, ac.DeletedMessages, "deleting for me should not purge the message on the server")
+	})
+
+	t.Run("deletes own message for everyone", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "oops", LastMessageTimestamp: 1000}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "oops", Timestamp: 1000}))
+
+		// Act
+		err := svc.DeleteMessageForEveryone("conv-1", "msg-1")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"msg-1"}, ac.DeletedMessages, "the message should have been purged on the server")
+		_, err = svc.getMessage("conv-1", "msg-1")
+		assert.ErrorIs(t, err, errMessageNotFound)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Empty(t, conv.LastMessagePreview)
+	})
+
+	t.Run("refuses to delete messages of other users for everyone", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", SenderID: "bob", Timestamp: 1000}))
+
+		// Act
+		err := svc.DeleteMessageForEveryone("conv-1", "msg-1")
+
+		// Assert
+		assert.ErrorIs(t, err, errMessageNotDeletable)
+		assert.Empty(t, ac.DeletedMessages)
+		_, err = svc.getMessage("conv-1", "msg-1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("applies tombstones of the author only", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "Hi", LastMessageSenderID: "bob", LastMessageTimestamp: 1000}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", SenderID: "bob", Timestamp: 1000}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-2", Text: "Hey", SenderID: "carol", Timestamp: 500}))
+		tombstone := func(senderID, messageID string) apitypes.WSMessage {
+			return apitypes.WSMessage{
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: "conv-1",
+					MessageID:      "tombstone-" + messageID,
+					SenderID:       senderID,
+					Content:        mustEncrypt(en, "conv-1", models.Content{DeleteOf: messageID}).Serialized,
+					CreatedAt:      2000,
+				}),
+			}
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{tombstone("bob", "msg-1"), tombstone("bob", "msg-2")})
+
+		// Assert
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "only the message of the author should have been deleted")
+		assert.Equal(t, "msg-2", messages[0].ID)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Hey", conv.LastMessagePreview)
+		assert.Equal(t, "carol", conv.LastMessageSenderID)
+	})
+}
+
+func TestConversationService_Reactions(t *testing.T) {
+	t.Run("reacts to a message without changing the preview", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "alice"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		conv := models.Conversation{ID: "conv-1", LastMessagePreview: "Hi", LastMessageSenderID: "bob", LastMessageTimestamp: 1000}
+		require.NoError(t, svc.writeConversation(conv))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", SenderID: "bob", Timestamp: 1000}))
+		var changed models.Message
+		svc.ReactionsChanged = func(msg models.Message) {
+			changed = msg
+		}
+		svc.ConversationUpdated = func(conv models.Conversation) {
+			t.Error("reactions should not update the conversation")
+		}
+
+		// Act
+		_, err := svc.AddReaction("conv-1", "msg-1", "👍")
+		require.NoError(t, err)
+		msg, err := svc.AddReaction("conv-1", "msg-1", "❤️")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"alice": "❤️"}, msg.Reactions, "a new reaction should replace the previous one")
+		assert.Equal(t, []models.ReactionCount{{Emoji: "❤️", Count: 1}}, msg.ReactionCounts)
+		assert.Equal(t, msg, changed, "reactions changed callback should have been invoked")
+		assert.Equal(t, []string{"msg-1", "msg-1"}, ac.SentReferences, "the reactions should refer to the message")
+		stored, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, conv, stored)
+	})
+
+	t.Run("removes the reaction of the user", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "alice"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Reactions: map[string]string{"alice": "👍", "bob": "👍"}}))
+
+		// Act
+		msg, err := svc.RemoveReaction("conv-1", "msg-1")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"bob": "👍"}, msg.Reactions)
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, []models.ReactionCount{{Emoji: "👍", Count: 1}}, messages[0].ReactionCounts)
+	})
+
+	t.Run("rejects reactions that aren't a short emoji", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+
+		// Act
+		_, err := svc.AddReaction("conv-1", "msg-1", strings.Repeat("👍", 10))
+
+		// Assert
+		assert.ErrorIs(t, err, errInvalidReaction)
+	})
+
+	t.Run("aggregates reactions received from participants", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		conv := models.Conversation{ID: "conv-1", LastMessagePreview: "Hi", LastMessageTimestamp: 1000}
+		require.NoError(t, svc.writeConversation(conv))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi", Timestamp: 1000}))
+		var changes int
+		svc.ReactionsChanged = func(msg models.Message) {
+			changes++
+		}
+		reaction := func(senderID string, r models.Reaction) apitypes.WSMessage {
+			return apitypes.WSMessage{
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: "conv-1",
+					MessageID:      "reaction-" + senderID,
+					SenderID:       senderID,
+					Content:        mustEncrypt(en, "conv-1", models.Content{Reaction: &r}).Serialized,
+					CreatedAt:      2000,
+				}),
+			}
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			reaction("bob", models.Reaction{MessageID: "msg-1", Emoji: "👍"}),
+			reaction("carol", models.Reaction{MessageID: "msg-1", Emoji: "👍"}),
+			reaction("dave", models.Reaction{MessageID: "msg-1", Emoji: "😂"}),
+			reaction("dave", models.Reaction{MessageID: "msg-1", Remove: true}),
+			reaction("erin", models.Reaction{MessageID: "missing", Emoji: "👍"}),
+		})
+
+		// Assert
+		assert.Equal(t, 4, changes, "reactions changed callback should have been invoked for every change")
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "reactions should not be stored as messages")
+		assert.Equal(t, []models.ReactionCount{{Emoji: "👍", Count: 2}}, messages[0].ReactionCounts)
+		stored, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, conv, stored, "reactions should not change the preview")
+		assert.Empty(t, ac.SentReceipts, "reactions should not be acknowledged")
+	})
+}
+
+func TestConversationService_Replies(t *testing.T) {
+	t.Run("quotes the original message in the reply", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		ac.CurrentUserID = "alice"
+		svc := NewConversationService(db, ac, encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", ParticipantIDs: []string{"alice", "bob"}}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hello there", Timestamp: 1000}))
+
+		// Act
+		msg, err := svc.SendReply("conv-1", "msg-1", "General Kenobi")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "General Kenobi", msg.Text)
+		assert.Equal(t, &models.Quote{MessageID: "msg-1", SenderID: "alice", Text: "Hello there"}, msg.Quote,
+			"own messages should be quoted with the user as sender")
+		thread, err := svc.ListThread("conv-1", "msg-1")
+		require.NoError(t, err)
+		require.Len(t, thread, 1)
+		assert.Equal(t, msg.ID, thread[0].ID)
+	})
+
+	t.Run("returns error when the quoted message doesn't exist", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+
+		// Act
+		_, err := svc.SendReply("conv-1", "missing", "General Kenobi")
+
+		// Assert
+		assert.ErrorIs(t, err, errMessageNotFound)
+	})
+
+	t.Run("stores received replies and shows missing originals as unavailable", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		ac := api.NewStubClient()
+		en := encryption.NewFakeManager()
+		svc := NewConversationService(db, ac, en)
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hello there", SenderID: "bob", Timestamp: 1000}))
+		var added []models.Message
+		svc.MessageAdded = func(msg models.Message) {
+			added = append(added, msg)
+		}
+		reply := func(messageID string, quote models.Quote) apitypes.WSMessage {
+			return apitypes.WSMessage{
+				Type: apitypes.MessageTypeNewMessage,
+				Data: mustMarshal(apitypes.WSNewMessagePayload{
+					ConversationID: "conv-1",
+					MessageID:      messageID,
+					SenderID:       "carol",
+					Content:        mustEncrypt(en, "conv-1", models.Content{Text: "Reply", Quote: &quote}).Serialized,
+					CreatedAt:      2000,
+				}),
+			}
+		}
+
+		// Act
+		ac.TriggerWebsocketMessages([]apitypes.WSMessage{
+			reply("msg-2", models.Quote{MessageID: "msg-1", SenderID: "bob", Text: "Hello there"}),
+			reply("msg-3", models.Quote{MessageID: "deleted", SenderID: "bob", Text: "Secret"}),
+		})
+
+		// Assert
+		require.Len(t, added, 2)
+		assert.Equal(t, &models.Quote{MessageID: "msg-1", SenderID: "bob", Text: "Hello there"}, added[0].Quote)
+		assert.Equal(t, &models.Quote{MessageID: "deleted", SenderID: "bob", Text: models.QuoteUnavailable, Unavailable: true}, added[1].Quote)
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		for _, msg := range messages {
+			if msg.ID == "msg-3" {
+				assert.Equal(t, models.QuoteUnavailable, msg.Quote.Text, "the snippet of a missing original should be hidden")
+			}
+		}
+	})
+
+	t.Run("lists the replies of a thread in order", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		quote := func(messageID string) *models.Quote {
+			return &models.Quote{MessageID: messageID, SenderID: "bob", Text: "Hi"}
+		}
+		for _, msg := range []models.Message{
+			{ID: "root", Text: "Hi", Timestamp: 1000},
+			{ID: "other", Text: "Unrelated", Timestamp: 1500},
+			{ID: "reply-3", Timestamp: 4000, Quote: quote("root")},
+			{ID: "reply-1", Timestamp: 2000, Quote: quote("root")},
+			{ID: "nested", Timestamp: 3000, Quote: quote("reply-1")},
+			{ID: "elsewhere", Timestamp: 3500, Quote: quote("other")},
+		} {
+			require.NoError(t, svc.writeMessage("conv-1", msg))
+		}
+
+		// Act
+		thread, err := svc.ListThread("conv-1", "root")
+
+		// Assert
+		require.NoError(t, err)
+		ids := make([]string, 0, len(thread))
+		for _, msg := range thread {
+			ids = append(ids, msg.ID)
+		}
+		assert.Equal(t, []string{"reply-1", "nested", "reply-3"}, ids)
+	})
+}
+
+func TestConversationService_DeleteConversation(t *testing.T) {
+	t.Run("deletes the conversation with its messages", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-2"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "msg-1", Text: "Hi"}))
+		require.NoError(t, svc.writeMessage("conv-2", models.Message{ID: "msg-2", Text: "Hey"}))
+		var deleted models.Conversation
+		svc.ConversationDeleted = func(conv models.Conversation) {
+			deleted = conv
+		}
+
+		// Act
+		err := svc.DeleteConversation("conv-1")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "conv-1", deleted.ID, "deleted conversation callback should have been invoked")
+		conversations, err := svc.ListConversations()
+		require.NoError(t, err)
+		require.Len(t, conversations, 1)
+		assert.Equal(t, "conv-2", conversations[0].ID)
+		messages, err := db.Query(messageKey("conv-1", ""))
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+		messages, err = db.Query(messageKey("conv-2", ""))
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+
+	t.Run("returns error when sender keys can't be deleted", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		en := encryption.NewStubManager()
+		en.DeleteEncryptionGroupError = errors.New("key store error")
+		svc := NewConversationService(db, api.NewStubClient(), en)
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+
+		// Act
+		err := svc.DeleteConversation("conv-1")
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestConversationService_deleteExpiredMessages(t *testing.T) {
+	t.Run("deletes expired messages and keeps the others", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		now := time.Now().UnixMilli()
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "gone", LastMessageTimestamp: now - 1000}))
+		kept := models.Message{ID: "kept", Text: "kept", Timestamp: now - 2000, ExpiresAt: now + 60_000}
+		permanent := models.Message{ID: "permanent", Text: "permanent", Timestamp: now - 3000}
+		gone := models.Message{ID: "gone", Text: "gone", Timestamp: now - 1000, ExpiresAt: now - 1}
+		for _, msg := range []models.Message{kept, permanent, gone} {
+			require.NoError(t, svc.writeMessage("conv-1", msg))
+		}
+		var expired []models.Message
+		svc.MessageExpired = func(msg models.Message) {
+			expired = append(expired, msg)
+		}
+
+		// Act
+		next, err := svc.deleteExpiredMessages()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, kept.ExpiresAt, next, "should return when the next message expires")
+		assert.Equal(t, []models.Message{gone}, expired)
+		messages, err := svc.ListMessages("conv-1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []models.Message{kept, permanent}, messages)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, "kept", conv.LastMessagePreview, "preview should fall back to the latest remaining message")
+		assert.Equal(t, kept.Timestamp, conv.LastMessageTimestamp)
+	})
+
+	t.Run("clears the preview when every message expired", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		now := time.Now().UnixMilli()
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1", LastMessagePreview: "gone", LastMessageSenderID: "bob", LastMessageTimestamp: now - 1000}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "gone", Text: "gone", SenderID: "bob", Timestamp: now - 1000, ExpiresAt: now - 1}))
+
+		// Act
+		next, err := svc.deleteExpiredMessages()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Zero(t, next)
+		conv, err := svc.getConversation("conv-1")
+		require.NoError(t, err)
+		assert.Empty(t, conv.LastMessagePreview)
+		assert.Empty(t, conv.LastMessageSenderID)
+		assert.Zero(t, conv.LastMessageTimestamp)
+	})
+
+	t.Run("drops the index entry of a message deleted before it expired", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		now := time.Now().UnixMilli()
+		conv := models.Conversation{ID: "conv-1"}
+		require.NoError(t, svc.writeConversation(conv))
+		deleted := models.Message{ID: "deleted", ExpiresAt: now - 1}
+		require.NoError(t, svc.writeMessage(conv.ID, deleted))
+		require.NoError(t, db.Delete(messageKey(conv.ID, deleted.ID)))
+		svc.MessageExpired = func(msg models.Message) {
+			t.Errorf("message %s should not be reported as expired", msg.ID)
+		}
+
+		// Act
+		next, err := svc.deleteExpiredMessages()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Zero(t, next)
+		index, err := db.Query(expiryPrefix)
+		require.NoError(t, err)
+		assert.Empty(t, index)
+	})
+
+	t.Run("indexes disappearing messages stored before the expiry index", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		legacy := models.Message{ID: "legacy", ExpiresAt: time.Now().Add(-time.Hour).UnixMilli()}
+		bytes, err := legacy.Serialize()
+		require.NoError(t, err)
+		require.NoError(t, db.Write(messageKey("conv-1", legacy.ID), bytes))
+
+		next, err := svc.deleteExpiredMessages()
+		require.NoError(t, err)
+		require.Zero(t, next)
+		_, err = svc.getMessage("conv-1", legacy.ID)
+		require.NoError(t, err, "messages missing from the index should not be read")
+
+		// Act
+		require.NoError(t, svc.indexExpiringMessages())
+		_, err = svc.deleteExpiredMessages()
+
+		// Assert
+		require.NoError(t, err)
+		_, err = svc.getMessage("conv-1", legacy.ID)
+		assert.ErrorIs(t, err, errMessageNotFound)
+		setting, err := db.Read(settingKey(expiryIndexSetting))
+		require.NoError(t, err)
+		assert.NotNil(t, setting)
+	})
+
+	t.Run("deletes messages that expired while the app was closed on startup", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "gone", ExpiresAt: time.Now().Add(-time.Hour).UnixMilli()}))
+		expired := make(chan models.Message, 1)
+		svc.MessageExpired = func(msg models.Message) {
+			expired <- msg
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Act
+		go svc.runExpiration(ctx, time.Hour)
+
+		// Assert
+		select {
+		case msg := <-expired:
+			assert.Equal(t, "gone", msg.ID)
+		case <-time.After(time.Second):
+			t.Fatal("expired message should have been deleted without waiting for the interval")
+		}
+	})
+
+	t.Run("deletes a disappearing message stored later when it expires", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		_ = db.Open(DummyValue)
+		svc := NewConversationService(db, api.NewStubClient(), encryption.NewFakeManager())
+		require.NoError(t, svc.writeConversation(models.Conversation{ID: "conv-1"}))
+		expired := make(chan models.Message, 1)
+		svc.MessageExpired = func(msg models.Message) {
+			expired <- msg
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go svc.runExpiration(ctx, time.Hour)
+
+		// Act
+		require.NoError(t, svc.writeMessage("conv-1", models.Message{ID: "soon", ExpiresAt: time.Now().Add(50 * time.Millisecond).UnixMilli()}))
+
+		// Assert
+		select {
+		case msg := <-expired:
+			assert.Equal(t, "soon", msg.ID)
+		case <-time.After(time.Second):
+			t.Fatal("message should have been deleted when it expired")
+		}
+	})
+}
+
+// mustEncrypt encrypts the content the way ConversationService sends it
+func mustEncrypt(en Encryptor, groupID string, content models.Content) *encryption.EncryptedMessage {
+	plaintext, err := content.Serialize()
+	if err != nil {
+		panic(fmt.Sprintf("failed to serialize content: %v", err))
+	}
+	encrypted, err := en.GroupEncrypt(groupID, plaintext)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encrypt: %v", err))
+	}
+	return encrypted
+}
+
 func mustMarshal(v any) []byte {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -1,11 +1,17 @@
 package database
 
-import "strings"
+import (
+	"strings"
+	"sync"
+)
 
 type Fake struct {
 	Items        map[string][]byte
 	Opened       bool
 	ActiveUserID string
+	// ReadErr is returned by Read when set
+	ReadErr error
+	mu      sync.RWMutex
 }
 
 func NewFake() *Fake {
@@ -27,16 +33,25 @@ func (f *Fake) Close() error {
 
 func (f *Fake) Read(key string) ([]byte, error) {
 	f.panicIfNotOpened()
+	if f.ReadErr != nil {
+		return nil, f.ReadErr
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return f.Items[key], nil
 }
 
 func (f *Fake) Write(key string, value []byte) error {
 	f.panicIfNotOpened()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.Items[key] = value
 	return nil
 }
 
 func (f *Fake) Query(prefix string) (map[string][]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	prefixStr := prefix
 	result := make(map[string][]byte)
 	for k, v := range f.Items {
@@ -49,6 +64,8 @@ func (f *Fake) Query(prefix string) (map[string][]byte, error) {
 
 func (f *Fake) Delete(key string) error {
 	f.panicIfNotOpened()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	delete(f.Items, key)
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 )
 
 type FakeManager struct {
+	userID   string
+	deviceID uint32
 }
 
 func NewFakeManager() *FakeManager {
@@ -27,15 +29,53 @@ func (s *FakeManager) InitializeKeyStore() (apitypes.KeyBundle, error) {
 	}, nil
 }
 
-func (s *FakeManager) CreateEncryptionGroup(groupID string, recipientIDs []string) (map[string][]byte, error) {
-	messages := make(map[string][]byte)
+func (s *FakeManager) StoreLocalAddress(userID string, deviceID uint32) error {
+	s.userID = userID
+	s.deviceID = deviceID
+	return nil
+}
+
+func (s *FakeManager) LocalDeviceID() (uint32, error) {
+	return s.deviceID, nil
+}
+
+// CreateEncryptionGroup pretends that every recipient has a single device with ID 1
+func (s *FakeManager) CreateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
+	participants := make([]apitypes.Participant, 0, len(recipientIDs))
 	for _, id := range recipientIDs {
-		messages[id] = makeArr(64)
+		if id == s.userID {
+			continue
+		}
+		participants = append(participants, apitypes.Participant{
+			ID:                     id,
+			DeviceID:               1,
+			KeyDistributionMessage: makeArr(64),
+		})
 	}
-	return messages, nil
+	return participants, nil
+}
+
+func (s *FakeManager) RotateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
+	return s.CreateEncryptionGroup(groupID, recipientIDs)
+}
+
+func (s *FakeManager) ReplenishPreKeys() error {
+	return nil
+}
+
+func (s *FakeManager) RotateSignedPreKeyIfDue() error {
+	return nil
+}
+
+func (s *FakeManager) DeleteSenderKey(groupID, senderID string) error {
+	return nil
+}
+
+func (s *FakeManager) DeleteEncryptionGroup(groupID string) error {
+	return nil
 }
 
-func (s *FakeManager) ProcessSenderKeyDistributionMessage(groupID, senderID string, encryptedMsg []byte) error {
+func (s *FakeManager) ProcessSenderKeyDistributionMessage(groupID, senderID string, senderDeviceID uint32, encryptedMsg []byte) error {
 	return nil
 }
 
@@ -53,7 +93,7 @@ func (s *FakeManager) GroupEncrypt(groupID string, plaintext []byte) (*Encrypted
 	}, nil
 }
 
-func (s *FakeManager) GroupDecrypt(groupID, senderID string, ciphertext []byte) (*DecryptedMessage, error) {
+func (s *FakeManager) GroupDecrypt(groupID, senderID string, senderDeviceID uint32, ciphertext []byte) (*DecryptedMessage, error) {
 	return &DecryptedMessage{
 		Plaintext:  simpleDecrypt(ciphertext),
 		Ciphertext: ciphertext,
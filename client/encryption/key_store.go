@@ -65,6 +65,68 @@ func (k *KeyStore) GetLocalRegistrationId() uint32 {
 	return binary.BigEndian.Uint32(bytes)
 }
 
+// LastPreKeyID returns the ID of the most recently generated one-time pre-key, or 0 if none was generated yet
+func (k *KeyStore) LastPreKeyID() (uint32, error) {
+	bytes, err := k.db.Read("lastPreKeyId")
+	if err != nil {
+		return 0, err
+	}
+	if bytes == nil {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint32(bytes), nil
+}
+
+func (k *KeyStore) StoreLastPreKeyID(id uint32) error {
+	return k.db.Write("lastPreKeyId", binary.BigEndian.AppendUint32(nil, id))
+}
+
+// CurrentSignedPreKeyID returns the ID of the signed pre-key most recently published to the server
+func (k *KeyStore) CurrentSignedPreKeyID() (uint32, error) {
+	bytes, err := k.db.Read("currentSignedPreKeyId")
+	if err != nil {
+		return 0, err
+	}
+	if bytes == nil {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint32(bytes), nil
+}
+
+func (k *KeyStore) StoreCurrentSignedPreKeyID(id uint32) error {
+	return k.db.Write("currentSignedPreKeyId", binary.BigEndian.AppendUint32(nil, id))
+}
+
+func (k *KeyStore) StoreLocalRegistrationId(registrationID uint32) error {
+	return k.db.Write("registrationId", binary.BigEndian.AppendUint32(nil, registrationID))
+}
+
+// LocalAddress returns the user ID and device ID this client is registered as, or empty values before sign-in
+func (k *KeyStore) LocalAddress() (string, uint32, error) {
+	userID, err := k.db.Read("localUserId")
+	if err != nil {
+		return "", 0, err
+	}
+	deviceID, err := k.db.Read("localDeviceId")
+	if err != nil {
+		return "", 0, err
+	}
+	if userID == nil || deviceID == nil {
+		return "", 0, nil
+	}
+
+	return string(userID), binary.BigEndian.Uint32(deviceID), nil
+}
+
+func (k *KeyStore) StoreLocalAddress(userID string, deviceID uint32) error {
+	if err := k.db.Write("localUserId", []byte(userID)); err != nil {
+		return err
+	}
+	return k.db.Write("localDeviceId", binary.BigEndian.AppendUint32(nil, deviceID))
+}
+
 func (k *KeyStore) SaveIdentity(address *protocol.SignalAddress, identityKey *identity.Key) {
 	key := fmt.Sprintf("identity#%v", address.String())
 	bytes := identityKey.PublicKey().PublicKey()
@@ -74,6 +136,48 @@ func (k *KeyStore) SaveIdentity(address *protocol.SignalAddress, identityKey *id
 	}
 }
 
+// DeleteIdentity forgets the identity key of the remote address so that the next key presented for it is trusted
+func (k *KeyStore) DeleteIdentity(address *protocol.SignalAddress) {
+	key := fmt.Sprintf("identity#%v", address.String())
+	err := k.db.Delete(key)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SavePendingIdentity keeps a changed identity key of the remote address until the user approves it
+func (k *KeyStore) SavePendingIdentity(address *protocol.SignalAddress, identityKey *identity.Key) {
+	key := fmt.Sprintf("pendingIdentity#%v", address.String())
+	bytes := identityKey.PublicKey().PublicKey()
+	err := k.db.Write(key, bytes[:])
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LoadPendingIdentity returns the changed identity key of the remote address waiting for approval, or nil if there
+// is none
+func (k *KeyStore) LoadPendingIdentity(address *protocol.SignalAddress) *identity.Key {
+	key := fmt.Sprintf("pendingIdentity#%v", address.String())
+	bytes, err := k.db.Read(key)
+	if err != nil {
+		panic(err)
+	}
+	if bytes == nil {
+		return nil
+	}
+	identityKey := identity.NewKeyFromBytes([32]byte(bytes), 0)
+	return &identityKey
+}
+
+func (k *KeyStore) DeletePendingIdentity(address *protocol.SignalAddress) {
+	key := fmt.Sprintf("pendingIdentity#%v", address.String())
+	err := k.db.Delete(key)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func (k *KeyStore) IsTrustedIdentity(address *protocol.SignalAddress, identityKey *identity.Key) bool {
 	key := fmt.Sprintf("identity#%v", address.String())
 	bytes, err := k.db.Read(key)
@@ -151,8 +255,9 @@ func (k *KeyStore) LoadSession(address *protocol.SignalAddress) *record.Session
 	return session
 }
 
+// GetSubDeviceSessions returns the IDs of all devices of the given user that we have a session with
 func (k *KeyStore) GetSubDeviceSessions(name string) []uint32 {
-	prefix := fmt.Sprintf("session#%v", name)
+	prefix := fmt.Sprintf("session#%v%v", name, protocol.ADDRESS_SEPARATOR)
 	items, err := k.db.Query(prefix)
 	if err != nil {
 		panic(err)
@@ -277,7 +382,6 @@ func (k *KeyStore) RemoveSignedPreKey(signedPreKeyID uint32) {
 }
 
 func (k *KeyStore) StoreSenderKey(senderKeyName *protocol.SenderKeyName, keyRecord *grouprecord.SenderKey) {
-	// TODO: Use different key name, device is separate from sender name also by ':'
 	key := fmt.Sprintf("senderKey#%v:%v", senderKeyName.GroupID(), senderKeyName.Sender().String())
 	err := k.db.Write(key, keyRecord.Serialize())
 	if err != nil {
@@ -302,3 +406,35 @@ func (k *KeyStore) LoadSenderKey(senderKeyName *protocol.SenderKeyName) *groupre
 
 	return senderKey
 }
+
+func (k *KeyStore) DeleteSenderKey(senderKeyName *protocol.SenderKeyName) {
+	key := fmt.Sprintf("senderKey#%v:%v", senderKeyName.GroupID(), senderKeyName.Sender().String())
+	err := k.db.Delete(key)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeleteSenderKeys removes the sender keys of all devices of the given user in the group
+func (k *KeyStore) DeleteSenderKeys(groupID, name string) {
+	k.deleteSenderKeys(fmt.Sprintf("senderKey#%v:%v%v", groupID, name, protocol.ADDRESS_SEPARATOR))
+}
+
+// DeleteGroupSenderKeys removes the sender keys of all members of the group, ours included
+func (k *KeyStore) DeleteGroupSenderKeys(groupID string) {
+	k.deleteSenderKeys(fmt.Sprintf("senderKey#%v:", groupID))
+}
+
+func (k *KeyStore) deleteSenderKeys(prefix string) {
+	items, err := k.db.Query(prefix)
+	if err != nil {
+		panic(err)
+	}
+
+	for key := range items {
+		err := k.db.Delete(key)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"github.com/crossle/libsignal-protocol-go/protocol"
 	"github.com/crossle/libsignal-protocol-go/serialize"
 	"github.com/crossle/libsignal-protocol-go/util/keyhelper"
 	"github.com/stretchr/testify/assert"
@@ -60,3 +61,27 @@ func TestKeyStore_StoreSignedPreKey(t *testing.T) {
 		//was serialized. Instead if should strip the prefix and keep the last byte.
 	})
 }
+
+func TestKeyStore_GetSubDeviceSessions(t *testing.T) {
+	t.Run("returns device IDs of the user's sessions only", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("me")
+		require.NoError(t, err)
+		serializer := serialize.NewJSONSerializer()
+		store := NewKeyStore(db, serializer)
+		for _, addr := range []*protocol.SignalAddress{
+			protocol.NewSignalAddress("alice", 1),
+			protocol.NewSignalAddress("alice", 3),
+			protocol.NewSignalAddress("alice2", 2),
+		} {
+			require.NoError(t, db.Write("session#"+addr.String(), []byte("session")))
+		}
+
+		// Act
+		got := store.GetSubDeviceSessions("alice")
+
+		// Assert
+		assert.ElementsMatch(t, []uint32{1, 3}, got)
+	})
+}
@@ -10,29 +10,62 @@ import (
 	"github.com/crossle/libsignal-protocol-go/protocol"
 	"github.com/crossle/libsignal-protocol-go/serialize"
 	"github.com/crossle/libsignal-protocol-go/session"
+	"github.com/crossle/libsignal-protocol-go/state/record"
 	"github.com/crossle/libsignal-protocol-go/util/keyhelper"
 	"github.com/crossle/libsignal-protocol-go/util/optional"
+	"log"
 	"signal-chat/client/database"
 	"signal-chat/internal/apitypes"
+	"slices"
+	"time"
 )
 
 type encryptor interface {
 	InitializeKeyStore() (apitypes.KeyBundle, error)
-	CreateEncryptionGroup(groupID string, recipientIDs []string) (map[string][]byte, error)
-	ProcessSenderKeyDistributionMessage(groupID, senderID string, encryptedMsg []byte) error
+	ReplenishPreKeys() error
+	RotateSignedPreKeyIfDue() error
+	StoreLocalAddress(userID string, deviceID uint32) error
+	LocalDeviceID() (uint32, error)
+	CreateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error)
+	RotateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error)
+	DeleteSenderKey(groupID, senderID string) error
+	DeleteEncryptionGroup(groupID string) error
+	ProcessSenderKeyDistributionMessage(groupID, senderID string, senderDeviceID uint32, encryptedMsg []byte) error
 	GroupEncrypt(groupID string, plaintext []byte) (*EncryptedMessage, error)
-	GroupDecrypt(groupID, senderID string, ciphertext []byte) (*DecryptedMessage, error)
+	GroupDecrypt(groupID, senderID string, senderDeviceID uint32, ciphertext []byte) (*DecryptedMessage, error)
 }
 
+// ErrUntrustedIdentity is returned when a device presents an identity key other than the one trusted for it. The
+// new key is kept until the user approves it with TrustIdentity.
+var ErrUntrustedIdentity = errors.New("identity key changed")
+
 type PreKeyAPI interface {
-	GetPreKeyBundle(id string) (apitypes.GetPreKeyBundleResponse, error)
+	GetPreKeyBundle(id string, deviceID uint32) (apitypes.GetPreKeyBundleResponse, error)
+	GetDevices(id string) (apitypes.GetDevicesResponse, error)
+	UploadPreKeys(preKeys []apitypes.PreKey) (apitypes.UploadPreKeysResponse, error)
+	UploadSignedPreKey(signedPreKey apitypes.SignedPreKey) error
 }
 
+const (
+	// preKeyBatchSize is the number of one-time pre-keys generated on sign-up and on every replenishment
+	preKeyBatchSize = 100
+	// maxPreKeyID is the largest pre-key and signed pre-key ID, IDs wrap around to 1 after it
+	maxPreKeyID = 0xFFFFFF
+	// signedPreKeyRotationInterval is how long a signed pre-key is published before it's replaced
+	signedPreKeyRotationInterval = 7 * 24 * time.Hour
+	// signedPreKeyGracePeriod is how long a replaced signed pre-key is kept so that sessions initiated with it can
+	// still be established
+	signedPreKeyGracePeriod = 14 * 24 * time.Hour
+)
+
 type Manager struct {
 	apiClient  PreKeyAPI
 	store      *KeyStore
 	serializer *serialize.Serializer
 	ciphers    map[string]*session.Cipher
+	now        func() time.Time
+	// IdentityChanged is called when a device of the user presents a new identity key that needs to be approved
+	IdentityChanged func(userID string, deviceID uint32)
 }
 
 func NewEncryptionManager(db database.DB, apiClient PreKeyAPI) *Manager {
@@ -43,6 +76,7 @@ func NewEncryptionManager(db database.DB, apiClient PreKeyAPI) *Manager {
 		store:      NewKeyStore(db, serializer),
 		serializer: serializer,
 		ciphers:    make(map[string]*session.Cipher),
+		now:        time.Now,
 	}
 }
 
@@ -57,43 +91,193 @@ func (s *Manager) InitializeKeyStore() (apitypes.KeyBundle, error) {
 		return apitypes.KeyBundle{}, fmt.Errorf("failed to keyStore identity key pair: %w", err)
 	}
 
+	// Registration IDs are 14 bits wide and never 0, like in Signal
+	registrationID := keyhelper.GenerateRegistrationID()%0x3FFF + 1
+	if err := s.store.StoreLocalRegistrationId(registrationID); err != nil {
+		return apitypes.KeyBundle{}, fmt.Errorf("failed to store registration id: %w", err)
+	}
+
 	// TODO: handle errors from store methods -> now they panic on error
-	signedPreKeyPair, err := keyhelper.GenerateSignedPreKey(identityPair, 0, s.serializer.SignedPreKeyRecord)
+	signedPreKeyPair, err := s.generateSignedPreKey(identityPair, 0)
 	if err != nil {
 		return apitypes.KeyBundle{}, fmt.Errorf("error generating signed pre keys: %w", err)
 	}
 	s.store.StoreSignedPreKey(signedPreKeyPair.ID(), signedPreKeyPair)
+	if err := s.store.StoreCurrentSignedPreKeyID(signedPreKeyPair.ID()); err != nil {
+		return apitypes.KeyBundle{}, fmt.Errorf("failed to store current signed pre key id: %w", err)
+	}
 
-	preKeyPairs, err := keyhelper.GeneratePreKeys(1, 10, s.serializer.PreKeyRecord)
+	preKeys, err := s.generatePreKeys(preKeyBatchSize)
 	if err != nil {
-		return apitypes.KeyBundle{}, fmt.Errorf("error generating pre keys: %w", err)
-	}
-	var preKeys []apitypes.PreKey
-	for _, preKeyPair := range preKeyPairs {
-		s.store.StorePreKey(preKeyPair.ID().Value, preKeyPair)
-		preKeyPub := preKeyPair.KeyPair().PublicKey().PublicKey()
-		preKeys = append(preKeys, apitypes.PreKey{
-			ID:        preKeyPair.ID().Value,
-			PublicKey: preKeyPub[:],
-		})
+		return apitypes.KeyBundle{}, err
 	}
 
-	signature := signedPreKeyPair.Signature()
-
 	identityPub := identityPair.PublicKey().PublicKey().PublicKey()
-	signedPub := signedPreKeyPair.KeyPair().PublicKey().PublicKey()
 	return apitypes.KeyBundle{
-		IdentityKey: identityPub[:],
-		SignedPreKey: apitypes.SignedPreKey{
-			ID:        signedPreKeyPair.ID(),
-			PublicKey: signedPub[:],
-			Signature: signature[:],
-		},
-		PreKeys: preKeys,
+		RegistrationID: registrationID,
+		IdentityKey:    identityPub[:],
+		SignedPreKey:   toAPISignedPreKey(signedPreKeyPair),
+		PreKeys:        preKeys,
 	}, nil
 }
 
-func (s *Manager) CreateEncryptionGroup(groupID string, recipientIDs []string) (map[string][]byte, error) {
+// StoreLocalAddress remembers the user ID and device ID the server assigned to this client
+func (s *Manager) StoreLocalAddress(userID string, deviceID uint32) error {
+	if err := s.store.StoreLocalAddress(userID, deviceID); err != nil {
+		return fmt.Errorf("failed to store local address: %w", err)
+	}
+	return nil
+}
+
+// LocalDeviceID returns the device ID of this client, or 0 if the device was never registered
+func (s *Manager) LocalDeviceID() (uint32, error) {
+	_, deviceID, err := s.store.LocalAddress()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load local address: %w", err)
+	}
+	return deviceID, nil
+}
+
+// RotateSignedPreKeyIfDue replaces the signed pre-key once it has been published for longer than the rotation
+// interval and prunes replaced signed pre-keys whose grace period is over
+func (s *Manager) RotateSignedPreKeyIfDue() error {
+	currentID, err := s.store.CurrentSignedPreKeyID()
+	if err != nil {
+		return fmt.Errorf("failed to load current signed pre key id: %w", err)
+	}
+
+	current := s.store.LoadSignedPreKey(currentID)
+	if current != nil && s.now().Sub(time.Unix(current.Timestamp(), 0)) < signedPreKeyRotationInterval {
+		s.pruneSignedPreKeys(currentID)
+		return nil
+	}
+
+	return s.RotateSignedPreKey()
+}
+
+// RotateSignedPreKey generates a new signed pre-key and publishes it on the server. The replaced key stays in the
+// key store for the grace period.
+func (s *Manager) RotateSignedPreKey() error {
+	identityPair := s.store.GetIdentityKeyPair()
+	if identityPair == nil {
+		return errors.New("identity key pair not found")
+	}
+
+	currentID, err := s.store.CurrentSignedPreKeyID()
+	if err != nil {
+		return fmt.Errorf("failed to load current signed pre key id: %w", err)
+	}
+
+	signedPreKey, err := s.generateSignedPreKey(identityPair, currentID%maxPreKeyID+1)
+	if err != nil {
+		return fmt.Errorf("error generating signed pre key: %w", err)
+	}
+	s.store.StoreSignedPreKey(signedPreKey.ID(), signedPreKey)
+
+	if err := s.apiClient.UploadSignedPreKey(toAPISignedPreKey(signedPreKey)); err != nil {
+		s.store.RemoveSignedPreKey(signedPreKey.ID())
+		return fmt.Errorf("failed to upload signed pre key: %w", err)
+	}
+
+	if err := s.store.StoreCurrentSignedPreKeyID(signedPreKey.ID()); err != nil {
+		return fmt.Errorf("failed to store current signed pre key id: %w", err)
+	}
+
+	s.pruneSignedPreKeys(signedPreKey.ID())
+	return nil
+}
+
+// pruneSignedPreKeys removes signed pre-keys that were replaced more than the grace period ago. A key counts as
+// replaced from the moment the next newer key was generated.
+func (s *Manager) pruneSignedPreKeys(currentID uint32) {
+	cutoff := s.now().Add(-signedPreKeyGracePeriod).Unix()
+	signedPreKeys := s.store.LoadSignedPreKeys()
+	for _, key := range signedPreKeys {
+		if key.ID() == currentID {
+			continue
+		}
+
+		for _, newer := range signedPreKeys {
+			if newer.Timestamp() > key.Timestamp() && newer.Timestamp() <= cutoff {
+				s.store.RemoveSignedPreKey(key.ID())
+				break
+			}
+		}
+	}
+}
+
+func (s *Manager) generateSignedPreKey(identityPair *identity.KeyPair, id uint32) (*record.SignedPreKey, error) {
+	keyPair, err := ecc.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	signature := ecc.CalculateSignature(identityPair.PrivateKey(), keyPair.PublicKey().Serialize())
+	return record.NewSignedPreKey(id, s.now().Unix(), keyPair, signature, s.serializer.SignedPreKeyRecord), nil
+}
+
+func toAPISignedPreKey(signedPreKey *record.SignedPreKey) apitypes.SignedPreKey {
+	signature := signedPreKey.Signature()
+	pub := signedPreKey.KeyPair().PublicKey().PublicKey()
+	return apitypes.SignedPreKey{
+		ID:        signedPreKey.ID(),
+		PublicKey: pub[:],
+		Signature: signature[:],
+	}
+}
+
+// ReplenishPreKeys generates a new batch of one-time pre-keys and uploads their public parts to the server
+func (s *Manager) ReplenishPreKeys() error {
+	preKeys, err := s.generatePreKeys(preKeyBatchSize)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.apiClient.UploadPreKeys(preKeys); err != nil {
+		return fmt.Errorf("failed to upload pre keys: %w", err)
+	}
+
+	return nil
+}
+
+// generatePreKeys stores count new one-time pre-keys whose IDs continue after the last generated one
+func (s *Manager) generatePreKeys(count int) ([]apitypes.PreKey, error) {
+	lastID, err := s.store.LastPreKeyID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last pre key id: %w", err)
+	}
+
+	preKeys := make([]apitypes.PreKey, 0, count)
+	for len(preKeys) < count {
+		start := lastID%maxPreKeyID + 1
+		end := min(start+uint32(count-len(preKeys))-1, maxPreKeyID)
+		preKeyPairs, err := keyhelper.GeneratePreKeys(int(start), int(end), s.serializer.PreKeyRecord)
+		if err != nil {
+			return nil, fmt.Errorf("error generating pre keys: %w", err)
+		}
+
+		for _, preKeyPair := range preKeyPairs {
+			s.store.StorePreKey(preKeyPair.ID().Value, preKeyPair)
+			preKeyPub := preKeyPair.KeyPair().PublicKey().PublicKey()
+			preKeys = append(preKeys, apitypes.PreKey{
+				ID:        preKeyPair.ID().Value,
+				PublicKey: preKeyPub[:],
+			})
+		}
+		lastID = end
+	}
+
+	if err := s.store.StoreLastPreKeyID(lastID); err != nil {
+		return nil, fmt.Errorf("failed to store last pre key id: %w", err)
+	}
+
+	return preKeys, nil
+}
+
+// CreateEncryptionGroup creates our sender key of the group and encrypts its distribution message for every device
+// of the recipients. Our own user ID may be among the recipients to reach our other devices, this device is always
+// skipped.
+func (s *Manager) CreateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
 	keyName := protocol.NewSenderKeyName(groupID, protocol.NewSignalAddress("-", 1)) // - is name for my key
 	builder := groups.NewGroupSessionBuilder(s.store, s.serializer)
 	keyMsg, err := builder.Create(keyName)
@@ -101,26 +285,83 @@ func (s *Manager) CreateEncryptionGroup(groupID string, recipientIDs []string) (
 		return nil, fmt.Errorf("failed to create group session: %w", err)
 	}
 
+	localUserID, localDeviceID, err := s.store.LocalAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local address: %w", err)
+	}
+
 	keyMsgBytes := keyMsg.Serialize()
-	messages := make(map[string][]byte)
+	var participants []apitypes.Participant
 	for _, id := range recipientIDs {
-		ciphertext, err := s.pairwiseEncrypt(keyMsgBytes, id)
+		resp, err := s.apiClient.GetDevices(id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt key distribution message for user %s: %w", id, err)
+			return nil, fmt.Errorf("failed to get devices of user %s: %w", id, err)
+		}
+		s.pruneSessions(id, resp.Devices)
+
+		for _, device := range resp.Devices {
+			if id == localUserID && device.ID == localDeviceID {
+				continue
+			}
+
+			addr := protocol.NewSignalAddress(id, device.ID)
+			ciphertext, err := s.pairwiseEncrypt(keyMsgBytes, addr, device.RegistrationID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt key distribution message for device %s: %w", addr, err)
+			}
+			participants = append(participants, apitypes.Participant{
+				ID:                     id,
+				DeviceID:               device.ID,
+				KeyDistributionMessage: ciphertext,
+			})
+		}
+	}
+
+	return participants, nil
+}
+
+// pruneSessions drops the sessions with devices of the user that are no longer registered
+func (s *Manager) pruneSessions(userID string, devices []apitypes.Device) {
+	for _, deviceID := range s.store.GetSubDeviceSessions(userID) {
+		if !slices.ContainsFunc(devices, func(d apitypes.Device) bool { return d.ID == deviceID }) {
+			addr := protocol.NewSignalAddress(userID, deviceID)
+			s.store.DeleteSession(addr)
+			s.store.DeleteIdentity(addr)
 		}
-		messages[id] = ciphertext
 	}
+}
+
+// RotateEncryptionGroup discards the current sender key of the group and distributes a freshly generated one to the
+// given recipients. Messages encrypted afterward can't be decrypted by anyone holding only the old key.
+func (s *Manager) RotateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
+	keyName := protocol.NewSenderKeyName(groupID, protocol.NewSignalAddress("-", 1))
+	s.store.DeleteSenderKey(keyName)
+
+	return s.CreateEncryptionGroup(groupID, recipientIDs)
+}
+
+// DeleteSenderKey forgets the sender keys of all devices of the given group member so their messages can no longer
+// be decrypted
+func (s *Manager) DeleteSenderKey(groupID, senderID string) error {
+	s.store.DeleteSenderKeys(groupID, senderID)
+	return nil
+}
 
-	return messages, nil
+// DeleteEncryptionGroup forgets the sender keys of all group members, ours included, so that none of the group
+// messages can be decrypted anymore
+func (s *Manager) DeleteEncryptionGroup(groupID string) error {
+	s.store.DeleteGroupSenderKeys(groupID)
+	return nil
 }
 
-func (s *Manager) ProcessSenderKeyDistributionMessage(groupID, senderID string, encryptedMsg []byte) error {
-	plaintext, err := s.pairwiseDecrypt(encryptedMsg, senderID)
+func (s *Manager) ProcessSenderKeyDistributionMessage(groupID, senderID string, senderDeviceID uint32, encryptedMsg []byte) error {
+	addr := protocol.NewSignalAddress(senderID, senderDeviceID)
+	plaintext, err := s.pairwiseDecrypt(encryptedMsg, addr)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt key distribution message from user %s: %w", senderID, err)
+		return fmt.Errorf("failed to decrypt key distribution message from device %s: %w", addr, err)
 	}
 
-	keyName := protocol.NewSenderKeyName(groupID, protocol.NewSignalAddress(senderID, 1))
+	keyName := protocol.NewSenderKeyName(groupID, addr)
 	builder := groups.NewGroupSessionBuilder(s.store, s.serializer)
 	keyMessage, err := protocol.NewSenderKeyDistributionMessageFromBytes(plaintext, s.store.serializer.SenderKeyDistributionMessage)
 	if err != nil {
@@ -149,8 +390,8 @@ func (s *Manager) GroupEncrypt(groupID string, plaintext []byte) (*EncryptedMess
 	return newEncryptedMessage(ciphertext), nil
 }
 
-func (s *Manager) GroupDecrypt(groupID, senderID string, ciphertext []byte) (*DecryptedMessage, error) {
-	keyName := protocol.NewSenderKeyName(groupID, protocol.NewSignalAddress(senderID, 1))
+func (s *Manager) GroupDecrypt(groupID, senderID string, senderDeviceID uint32, ciphertext []byte) (*DecryptedMessage, error) {
+	keyName := protocol.NewSenderKeyName(groupID, protocol.NewSignalAddress(senderID, senderDeviceID))
 	senderKey := s.store.LoadSenderKey(keyName)
 	if senderKey == nil {
 		return nil, errors.New("sender key for group not found")
@@ -171,26 +412,17 @@ func (s *Manager) GroupDecrypt(groupID, senderID string, ciphertext []byte) (*De
 	return newDecryptedMessage(plaintext, msg), nil
 }
 
-func (s *Manager) pairwiseEncrypt(plaintext []byte, recipientID string) ([]byte, error) {
-	addr := protocol.NewSignalAddress(recipientID, 1)
-	var cipher *session.Cipher
-	if !s.store.ContainsSession(addr) {
-		bundle, err := s.getPreKeyBundle(recipientID)
-		if err != nil {
+// pairwiseEncrypt encrypts the plaintext for a single device. registrationID is the one the server currently
+// publishes for the device, a session with a different one is rebuilt on top of the existing one, which stays
+// archived in the session record.
+func (s *Manager) pairwiseEncrypt(plaintext []byte, addr *protocol.SignalAddress, registrationID uint32) ([]byte, error) {
+	if !s.store.ContainsSession(addr) || s.remoteRegistrationID(addr) != registrationID {
+		if err := s.buildSession(addr); err != nil {
 			return nil, err
 		}
-
-		builder := session.NewBuilderFromSignal(s.store, addr, s.serializer)
-		err = builder.ProcessBundle(bundle)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create session with user '%s' due to error: %w", addr.Name(), err)
-		}
-
-		cipher = session.NewCipher(builder, addr)
-	} else {
-		cipher = session.NewCipherFromSession(addr, s.store, s.store, s.store, s.serializer.PreKeySignalMessage, s.serializer.SignalMessage)
 	}
 
+	cipher := session.NewCipherFromSession(addr, s.store, s.store, s.store, s.serializer.PreKeySignalMessage, s.serializer.SignalMessage)
 	encrypted, err := cipher.Encrypt(plaintext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt the message: %w", err)
@@ -199,17 +431,40 @@ func (s *Manager) pairwiseEncrypt(plaintext []byte, recipientID string) ([]byte,
 	return encrypted.Serialize(), nil
 }
 
-func (s *Manager) pairwiseDecrypt(encryptedMsg []byte, senderID string) ([]byte, error) {
-	addr := protocol.NewSignalAddress(senderID, 1)
-	if !s.store.ContainsSession(addr) {
-		msg, err := protocol.NewPreKeySignalMessageFromBytes(encryptedMsg, s.serializer.PreKeySignalMessage, s.serializer.SignalMessage)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshall pre key signal message: %w", err)
+// buildSession establishes a session from the pre-key bundle the server publishes for the device
+func (s *Manager) buildSession(addr *protocol.SignalAddress) error {
+	bundle, err := s.getPreKeyBundle(addr)
+	if err != nil {
+		return err
+	}
+	if !s.store.IsTrustedIdentity(addr, bundle.IdentityKey()) {
+		return s.untrustedIdentity(addr, bundle.IdentityKey())
+	}
+
+	builder := session.NewBuilderFromSignal(s.store, addr, s.serializer)
+	if err := builder.ProcessBundle(bundle); err != nil {
+		return fmt.Errorf("failed to create session with user '%s' due to error: %w", addr.Name(), err)
+	}
+	return nil
+}
+
+func (s *Manager) pairwiseDecrypt(encryptedMsg []byte, addr *protocol.SignalAddress) ([]byte, error) {
+	// The sender keeps sending pre key messages until it receives a reply, so those can arrive even when the session
+	// already exists
+	preKeyMsg, err := protocol.NewPreKeySignalMessageFromBytes(encryptedMsg, s.serializer.PreKeySignalMessage, s.serializer.SignalMessage)
+	if err != nil && !s.store.ContainsSession(addr) {
+		return nil, fmt.Errorf("failed to unmarshall pre key signal message: %w", err)
+	}
+	if err == nil {
+		// A pre key message from a reinstalled device builds a new session next to the existing one, which is only
+		// replaced once the message decrypts. Until then the message is unauthenticated and can't be trusted.
+		if !s.store.IsTrustedIdentity(addr, preKeyMsg.IdentityKey()) {
+			return nil, s.untrustedIdentity(addr, preKeyMsg.IdentityKey())
 		}
 
 		builder := session.NewBuilderFromSignal(s.store, addr, s.serializer)
 		cipher := session.NewCipher(builder, addr)
-		plaintext, err := cipher.DecryptMessage(msg)
+		plaintext, err := cipher.DecryptMessage(preKeyMsg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt pre key signal message: %w", err)
 		}
@@ -229,19 +484,77 @@ func (s *Manager) pairwiseDecrypt(encryptedMsg []byte, senderID string) ([]byte,
 	return plaintext, nil
 }
 
-func (s *Manager) getPreKeyBundle(recipientID string) (*prekey.Bundle, error) {
-	resp, err := s.apiClient.GetPreKeyBundle(recipientID)
+func (s *Manager) remoteRegistrationID(addr *protocol.SignalAddress) uint32 {
+	return s.store.LoadSession(addr).SessionState().RemoteRegistrationID()
+}
+
+// untrustedIdentity keeps the changed identity key of the device for the user to approve and reports the change
+func (s *Manager) untrustedIdentity(addr *protocol.SignalAddress, identityKey *identity.Key) error {
+	log.Printf("identity key of device %s changed, waiting for approval", addr)
+	s.store.SavePendingIdentity(addr, identityKey)
+	if s.IdentityChanged != nil {
+		s.IdentityChanged(addr.Name(), addr.DeviceID())
+	}
+	return fmt.Errorf("%w for device %s", ErrUntrustedIdentity, addr)
+}
+
+// TrustIdentity approves the changed identity key of the device of the user, sessions with the device can then be
+// rebuilt with the new key
+func (s *Manager) TrustIdentity(userID string, deviceID uint32) error {
+	addr := protocol.NewSignalAddress(userID, deviceID)
+	identityKey := s.store.LoadPendingIdentity(addr)
+	if identityKey == nil {
+		return fmt.Errorf("no changed identity key for device %s", addr)
+	}
+
+	s.store.SaveIdentity(addr, identityKey)
+	s.store.DeletePendingIdentity(addr)
+	return nil
+}
+
+func (s *Manager) getPreKeyBundle(addr *protocol.SignalAddress) (*prekey.Bundle, error) {
+	resp, err := s.apiClient.GetPreKeyBundle(addr.Name(), addr.DeviceID())
 	if err != nil {
 		return nil, err
 	}
+	if err := checkPreKeyBundle(resp.PreKeyBundle); err != nil {
+		return nil, fmt.Errorf("invalid pre-key bundle of device %s: %w", addr, err)
+	}
+
+	// Without a one-time pre-key the session is established with the signed pre-key alone. The public key must be
+	// an untyped nil so libsignal skips the one-time pre-key in the key agreement.
+	preKeyID := optional.NewEmptyUint32()
+	var preKeyPublic ecc.ECPublicKeyable
+	if preKey := resp.PreKeyBundle.PreKey; preKey != nil {
+		preKeyID = optional.NewOptionalUint32(preKey.ID)
+		preKeyPublic = ecc.NewDjbECPublicKey([32]byte(preKey.PublicKey))
+	}
 
 	return prekey.NewBundle(
 		resp.PreKeyBundle.RegistrationID,
-		1,
-		optional.NewOptionalUint32(resp.PreKeyBundle.PreKey.ID),
+		addr.DeviceID(),
+		preKeyID,
 		resp.PreKeyBundle.SignedPreKey.ID,
-		ecc.NewDjbECPublicKey([32]byte(resp.PreKeyBundle.PreKey.PublicKey)),
+		preKeyPublic,
 		ecc.NewDjbECPublicKey([32]byte(resp.PreKeyBundle.SignedPreKey.PublicKey)),
 		[64]byte(resp.PreKeyBundle.SignedPreKey.Signature),
 		identity.NewKey(ecc.NewDjbECPublicKey([32]byte(resp.PreKeyBundle.IdentityKey)))), nil
 }
+
+// checkPreKeyBundle makes sure the keys of a bundle have the length of Curve25519 keys and signatures, so that a
+// malformed bundle served for a peer can't crash the key agreement
+func checkPreKeyBundle(bundle apitypes.PreKeyBundle) error {
+	if len(bundle.IdentityKey) != 32 {
+		return fmt.Errorf("identity key is %d bytes long", len(bundle.IdentityKey))
+	}
+	if len(bundle.SignedPreKey.PublicKey) != 32 {
+		return fmt.Errorf("signed pre-key is %d bytes long", len(bundle.SignedPreKey.PublicKey))
+	}
+	if len(bundle.SignedPreKey.Signature) != 64 {
+		return fmt.Errorf("signed pre-key signature is %d bytes long", len(bundle.SignedPreKey.Signature))
+	}
+	if bundle.PreKey != nil && len(bundle.PreKey.PublicKey) != 32 {
+		return fmt.Errorf("one-time pre-key is %d bytes long", len(bundle.PreKey.PublicKey))
+	}
+	return nil
+}
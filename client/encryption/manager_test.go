@@ -4,7 +4,9 @@ import (
 	"errors"
 	"signal-chat/client/api"
 	"signal-chat/client/database"
+	"signal-chat/internal/apitypes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -48,6 +50,155 @@ func TestManager_InitializeKeyStore(t *testing.T) {
 	})
 }
 
+func TestManager_ReplenishPreKeys(t *testing.T) {
+	t.Run("should upload pre-keys with IDs following the last generated ones", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		manager := NewEncryptionManager(db, apiClient)
+		keyBundle, err := manager.InitializeKeyStore()
+		require.NoError(t, err)
+		lastID := keyBundle.PreKeys[len(keyBundle.PreKeys)-1].ID
+
+		// Act
+		err = manager.ReplenishPreKeys()
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, apiClient.UploadedPreKeys, preKeyBatchSize)
+		for i, preKey := range apiClient.UploadedPreKeys {
+			assert.Equal(t, lastID+uint32(i)+1, preKey.ID)
+			assert.True(t, manager.store.ContainsPreKey(preKey.ID))
+		}
+	})
+
+	t.Run("should wrap pre-key IDs around after the maximum ID", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		manager := NewEncryptionManager(db, apiClient)
+		require.NoError(t, manager.store.StoreLastPreKeyID(maxPreKeyID-1))
+
+		// Act
+		err = manager.ReplenishPreKeys()
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, apiClient.UploadedPreKeys, preKeyBatchSize)
+		assert.Equal(t, uint32(maxPreKeyID), apiClient.UploadedPreKeys[0].ID)
+		assert.Equal(t, uint32(1), apiClient.UploadedPreKeys[1].ID)
+		lastID, err := manager.store.LastPreKeyID()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(preKeyBatchSize-1), lastID)
+	})
+
+	t.Run("should return error when upload fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		apiClient.UploadPreKeysError = errors.New("server error")
+		manager := NewEncryptionManager(db, apiClient)
+
+		// Act
+		err = manager.ReplenishPreKeys()
+
+		// Assert
+		assert.ErrorContains(t, err, "failed to upload pre keys")
+	})
+}
+
+func TestManager_RotateSignedPreKeyIfDue(t *testing.T) {
+	t.Run("should keep signed pre-key that isn't due for rotation", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		manager := NewEncryptionManager(db, apiClient)
+		_, err = manager.InitializeKeyStore()
+		require.NoError(t, err)
+
+		// Act
+		err = manager.RotateSignedPreKeyIfDue()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, apiClient.UploadedSignedPreKeys)
+	})
+
+	t.Run("should upload new signed pre-key and prune replaced keys after grace period", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		manager := NewEncryptionManager(db, apiClient)
+		now := time.Now()
+		manager.now = func() time.Time { return now }
+		_, err = manager.InitializeKeyStore()
+		require.NoError(t, err)
+
+		// Act
+		now = now.Add(signedPreKeyRotationInterval)
+		err = manager.RotateSignedPreKeyIfDue()
+		require.NoError(t, err)
+		afterFirstRotation := manager.store.ContainsSignedPreKey(0)
+
+		now = now.Add(signedPreKeyGracePeriod + time.Hour)
+		err = manager.RotateSignedPreKeyIfDue()
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, apiClient.UploadedSignedPreKeys, 2)
+		assert.Equal(t, uint32(1), apiClient.UploadedSignedPreKeys[0].ID)
+		assert.Equal(t, uint32(2), apiClient.UploadedSignedPreKeys[1].ID)
+		assert.True(t, afterFirstRotation, "replaced key must be kept during the grace period")
+		assert.False(t, manager.store.ContainsSignedPreKey(0))
+		assert.True(t, manager.store.ContainsSignedPreKey(1))
+		assert.True(t, manager.store.ContainsSignedPreKey(2))
+		currentID, err := manager.store.CurrentSignedPreKeyID()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), currentID)
+	})
+
+	t.Run("should keep current signed pre-key when upload fails", func(t *testing.T) {
+		// Arrange
+		db := database.NewFake()
+		err := db.Open("test-user")
+		require.NoError(t, err)
+
+		apiClient := api.NewStubClient()
+		apiClient.UploadSignedPreKeyError = errors.New("server error")
+		manager := NewEncryptionManager(db, apiClient)
+		now := time.Now()
+		manager.now = func() time.Time { return now }
+		_, err = manager.InitializeKeyStore()
+		require.NoError(t, err)
+
+		// Act
+		now = now.Add(signedPreKeyRotationInterval)
+		err = manager.RotateSignedPreKeyIfDue()
+
+		// Assert
+		assert.ErrorContains(t, err, "failed to upload signed pre key")
+		assert.False(t, manager.store.ContainsSignedPreKey(1))
+		currentID, err := manager.store.CurrentSignedPreKeyID()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(0), currentID)
+	})
+}
+
 func TestManager_CreateEncryptionGroup(t *testing.T) {
 	t.Run("should create encryption group with key distribution messages for each participant", func(t *testing.T) {
 		// Arrange
@@ -88,10 +239,30 @@ func TestManager_CreateEncryptionGroup(t *testing.T) {
 		// Assert
 		require.NoError(t, err)
 		require.Len(t, keyMessages, 2)
-		assert.Contains(t, keyMessages, user1.UserID)
-		assert.Contains(t, keyMessages, user2.UserID)
-		assert.NotEmpty(t, keyMessages[user1.UserID])
-		assert.NotEmpty(t, keyMessages[user2.UserID])
+		assert.NotEmpty(t, keyMessage(keyMessages, user1.UserID, user1.DeviceID))
+		assert.NotEmpty(t, keyMessage(keyMessages, user2.UserID, user2.DeviceID))
+	})
+
+	t.Run("should return error instead of panicking for a pre-key bundle with malformed keys", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewStubClient()
+		apiClient.GetPreKeyBundleResponse = apitypes.GetPreKeyBundleResponse{PreKeyBundle: apitypes.PreKeyBundle{
+			IdentityKey:  make([]byte, 32),
+			SignedPreKey: apitypes.SignedPreKey{ID: 1, PublicKey: make([]byte, 32), Signature: make([]byte, 64)},
+			PreKey:       &apitypes.PreKey{ID: 1, PublicKey: []byte("short")},
+		}}
+
+		db := database.NewFake()
+		require.NoError(t, db.Open("test-user"))
+		manager := NewEncryptionManager(db, apiClient)
+		_, err := manager.InitializeKeyStore()
+		require.NoError(t, err)
+
+		// Act
+		_, err = manager.CreateEncryptionGroup("group1", []string{"user1"})
+
+		// Assert
+		assert.ErrorContains(t, err, "one-time pre-key is 5 bytes long")
 	})
 
 	t.Run("should return error when API client fails", func(t *testing.T) {
@@ -130,13 +301,250 @@ func TestManager_ProcessSenderKeyDistributionMessage(t *testing.T) {
 
 		// Act
 		corruptedMessage := []byte("corrupted-message")
-		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", "sender", corruptedMessage)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", "sender", 1, corruptedMessage)
 
 		// Assert
 		require.Error(t, err)
 	})
 }
 
+func TestManager_RotateEncryptionGroup(t *testing.T) {
+	t.Run("should prevent members without the rotated key from decrypting new messages", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+
+		senderDB := database.NewFake()
+		err := senderDB.Open("sender-user")
+		require.NoError(t, err)
+		senderManager := NewEncryptionManager(senderDB, apiClient)
+		senderBundle, err := senderManager.InitializeKeyStore()
+		require.NoError(t, err)
+		sender, err := apiClient.SignUp("sender", "password", senderBundle)
+		require.NoError(t, err)
+
+		remainingDB := database.NewFake()
+		err = remainingDB.Open("remaining-user")
+		require.NoError(t, err)
+		remainingManager := NewEncryptionManager(remainingDB, apiClient)
+		remainingBundle, err := remainingManager.InitializeKeyStore()
+		require.NoError(t, err)
+		remaining, err := apiClient.SignUp("remaining", "password", remainingBundle)
+		require.NoError(t, err)
+
+		removedDB := database.NewFake()
+		err = removedDB.Open("removed-user")
+		require.NoError(t, err)
+		removedManager := NewEncryptionManager(removedDB, apiClient)
+		removedBundle, err := removedManager.InitializeKeyStore()
+		require.NoError(t, err)
+		removed, err := apiClient.SignUp("removed", "password", removedBundle)
+		require.NoError(t, err)
+
+		groupID := "group1"
+		keyMessages, err := senderManager.CreateEncryptionGroup(groupID, []string{remaining.UserID, removed.UserID})
+		require.NoError(t, err)
+		err = remainingManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, remaining.UserID, remaining.DeviceID))
+		require.NoError(t, err)
+		err = removedManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, removed.UserID, removed.DeviceID))
+		require.NoError(t, err)
+
+		// Act
+		rotated, err := senderManager.RotateEncryptionGroup(groupID, []string{remaining.UserID})
+		require.NoError(t, err)
+		err = remainingManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(rotated, remaining.UserID, remaining.DeviceID))
+		require.NoError(t, err)
+
+		plaintext := []byte("after rotation")
+		encryptedMsg, err := senderManager.GroupEncrypt(groupID, plaintext)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Empty(t, keyMessage(rotated, removed.UserID, removed.DeviceID))
+		decryptedMsg, err := remainingManager.GroupDecrypt(groupID, sender.UserID, sender.DeviceID, encryptedMsg.Serialized)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decryptedMsg.Plaintext)
+		_, err = removedManager.GroupDecrypt(groupID, sender.UserID, sender.DeviceID, encryptedMsg.Serialized)
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_StaleSessions(t *testing.T) {
+	t.Run("should rebuild session with reinstalled recipient once its identity is approved", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		sender, senderManager := newTestUser(t, apiClient, "sender")
+		receiver, receiverManager := newTestUser(t, apiClient, "receiver")
+
+		keyMessages, err := senderManager.CreateEncryptionGroup("group1", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+		require.NoError(t, err)
+
+		reinstalledDB := database.NewFake()
+		require.NoError(t, reinstalledDB.Open("receiver-reinstalled"))
+		reinstalledManager := NewEncryptionManager(reinstalledDB, apiClient)
+		reinstalledBundle, err := reinstalledManager.InitializeKeyStore()
+		require.NoError(t, err)
+		apiClient.ReplaceKeyBundle(receiver.UserID, reinstalledBundle)
+		var changed []string
+		senderManager.IdentityChanged = func(userID string, deviceID uint32) {
+			changed = append(changed, userID)
+		}
+
+		// Act
+		_, untrustedErr := senderManager.CreateEncryptionGroup("group2", []string{receiver.UserID})
+		require.NoError(t, senderManager.TrustIdentity(receiver.UserID, receiver.DeviceID))
+		keyMessages, err = senderManager.CreateEncryptionGroup("group2", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = reinstalledManager.ProcessSenderKeyDistributionMessage("group2", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+
+		// Assert
+		assert.ErrorIs(t, untrustedErr, ErrUntrustedIdentity, "the new identity key should not be trusted silently")
+		assert.Equal(t, []string{receiver.UserID}, changed)
+		require.NoError(t, err)
+	})
+
+	t.Run("should accept new session with reinstalled sender once its identity is approved", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		sender, senderManager := newTestUser(t, apiClient, "sender")
+		receiver, receiverManager := newTestUser(t, apiClient, "receiver")
+
+		keyMessages, err := senderManager.CreateEncryptionGroup("group1", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+		require.NoError(t, err)
+
+		reinstalledDB := database.NewFake()
+		require.NoError(t, reinstalledDB.Open("sender-reinstalled"))
+		reinstalledManager := NewEncryptionManager(reinstalledDB, apiClient)
+		reinstalledBundle, err := reinstalledManager.InitializeKeyStore()
+		require.NoError(t, err)
+		apiClient.ReplaceKeyBundle(sender.UserID, reinstalledBundle)
+		require.NoError(t, reinstalledManager.StoreLocalAddress(sender.UserID, sender.DeviceID))
+		keyMessages, err = reinstalledManager.CreateEncryptionGroup("group2", []string{receiver.UserID})
+		require.NoError(t, err)
+		reinstalledKeyMessage := keyMessage(keyMessages, receiver.UserID, receiver.DeviceID)
+
+		// Act
+		untrustedErr := receiverManager.ProcessSenderKeyDistributionMessage("group2", sender.UserID, sender.DeviceID, reinstalledKeyMessage)
+		require.NoError(t, receiverManager.TrustIdentity(sender.UserID, sender.DeviceID))
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group2", sender.UserID, sender.DeviceID, reinstalledKeyMessage)
+
+		// Assert
+		assert.ErrorIs(t, untrustedErr, ErrUntrustedIdentity, "the new identity key should not be trusted silently")
+		require.NoError(t, err)
+	})
+
+	t.Run("should keep session when a pre key message with another identity arrives", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		sender, senderManager := newTestUser(t, apiClient, "sender")
+		receiver, receiverManager := newTestUser(t, apiClient, "receiver")
+
+		keyMessages, err := senderManager.CreateEncryptionGroup("group1", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+		require.NoError(t, err)
+
+		_, forgerManager := newTestUser(t, apiClient, "forger")
+		forged, err := forgerManager.CreateEncryptionGroup("group2", []string{receiver.UserID})
+		require.NoError(t, err)
+
+		// Act
+		forgedErr := receiverManager.ProcessSenderKeyDistributionMessage("group2", sender.UserID, sender.DeviceID, keyMessage(forged, receiver.UserID, receiver.DeviceID))
+		keyMessages, err = senderManager.CreateEncryptionGroup("group3", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group3", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+
+		// Assert
+		assert.ErrorIs(t, forgedErr, ErrUntrustedIdentity)
+		require.NoError(t, err, "the session with the sender should still work")
+	})
+
+	t.Run("should return error when approving an identity that didn't change", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		_, manager := newTestUser(t, apiClient, "user")
+
+		// Act
+		err := manager.TrustIdentity("someone", 1)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func newTestUser(t *testing.T, apiClient *api.FakeClient, name string) (apitypes.SignUpResponse, *Manager) {
+	t.Helper()
+
+	db := database.NewFake()
+	require.NoError(t, db.Open(name+"-user"))
+	manager := NewEncryptionManager(db, apiClient)
+	keyBundle, err := manager.InitializeKeyStore()
+	require.NoError(t, err)
+	user, err := apiClient.SignUp(name, "password", keyBundle)
+	require.NoError(t, err)
+	require.NoError(t, manager.StoreLocalAddress(user.UserID, user.DeviceID))
+
+	return user, manager
+}
+
+// keyMessage returns the key distribution message encrypted for the given device, or nil if there is none
+func keyMessage(participants []apitypes.Participant, userID string, deviceID uint32) []byte {
+	for _, p := range participants {
+		if p.ID == userID && p.DeviceID == deviceID {
+			return p.KeyDistributionMessage
+		}
+	}
+	return nil
+}
+
+func TestManager_DeleteEncryptionGroup(t *testing.T) {
+	t.Run("should prevent decrypting messages of the deleted group", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+
+		senderDB := database.NewFake()
+		err := senderDB.Open("sender-user")
+		require.NoError(t, err)
+		senderManager := NewEncryptionManager(senderDB, apiClient)
+		senderBundle, err := senderManager.InitializeKeyStore()
+		require.NoError(t, err)
+		sender, err := apiClient.SignUp("sender", "password", senderBundle)
+		require.NoError(t, err)
+
+		receiverDB := database.NewFake()
+		err = receiverDB.Open("receiver-user")
+		require.NoError(t, err)
+		receiverManager := NewEncryptionManager(receiverDB, apiClient)
+		receiverBundle, err := receiverManager.InitializeKeyStore()
+		require.NoError(t, err)
+		receiver, err := apiClient.SignUp("receiver", "password", receiverBundle)
+		require.NoError(t, err)
+
+		keyMessages, err := senderManager.CreateEncryptionGroup("group1", []string{receiver.UserID})
+		require.NoError(t, err)
+		err = receiverManager.ProcessSenderKeyDistributionMessage("group1", sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+		require.NoError(t, err)
+		_, err = receiverManager.CreateEncryptionGroup("group2", []string{sender.UserID})
+		require.NoError(t, err)
+
+		// Act
+		err = receiverManager.DeleteEncryptionGroup("group1")
+		require.NoError(t, err)
+
+		// Assert
+		encryptedMsg, err := senderManager.GroupEncrypt("group1", []byte("after deletion"))
+		require.NoError(t, err)
+		_, err = receiverManager.GroupDecrypt("group1", sender.UserID, sender.DeviceID, encryptedMsg.Serialized)
+		assert.Error(t, err)
+		remaining, err := receiverDB.Query("senderKey#group2:")
+		require.NoError(t, err)
+		assert.NotEmpty(t, remaining, "sender keys of other groups should be kept")
+	})
+}
+
 func TestManager_GroupEncryptDecrypt(t *testing.T) {
 	t.Run("should encrypt and decrypt messages in a group", func(t *testing.T) {
 		// Arrange
@@ -165,9 +573,9 @@ func TestManager_GroupEncryptDecrypt(t *testing.T) {
 		groupID := "group1"
 		keyMessages, err := senderManager.CreateEncryptionGroup(groupID, []string{receiver.UserID})
 		require.NoError(t, err)
-		require.Contains(t, keyMessages, receiver.UserID)
+		require.NotEmpty(t, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 
-		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, keyMessages[receiver.UserID])
+		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 		require.NoError(t, err)
 
 		// Act
@@ -175,7 +583,48 @@ func TestManager_GroupEncryptDecrypt(t *testing.T) {
 		encryptedMsg, err := senderManager.GroupEncrypt(groupID, plaintext)
 		require.NoError(t, err)
 
-		decryptedMsg, err := receiverManager.GroupDecrypt(groupID, sender.UserID, encryptedMsg.Serialized)
+		decryptedMsg, err := receiverManager.GroupDecrypt(groupID, sender.UserID, sender.DeviceID, encryptedMsg.Serialized)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decryptedMsg.Plaintext)
+	})
+
+	t.Run("should establish session with recipient whose one-time pre-keys are exhausted", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+
+		senderDB := database.NewFake()
+		err := senderDB.Open("sender-user")
+		require.NoError(t, err)
+		senderManager := NewEncryptionManager(senderDB, apiClient)
+		senderBundle, err := senderManager.InitializeKeyStore()
+		require.NoError(t, err)
+		sender, err := apiClient.SignUp("sender", "password", senderBundle)
+		require.NoError(t, err)
+
+		receiverDB := database.NewFake()
+		err = receiverDB.Open("receiver-user")
+		require.NoError(t, err)
+		receiverManager := NewEncryptionManager(receiverDB, apiClient)
+		receiverBundle, err := receiverManager.InitializeKeyStore()
+		require.NoError(t, err)
+		receiverBundle.PreKeys = nil
+		receiver, err := apiClient.SignUp("receiver", "password", receiverBundle)
+		require.NoError(t, err)
+
+		groupID := "group1"
+		keyMessages, err := senderManager.CreateEncryptionGroup(groupID, []string{receiver.UserID})
+		require.NoError(t, err)
+
+		// Act
+		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
+		require.NoError(t, err)
+
+		plaintext := []byte("hello without one-time pre-key")
+		encryptedMsg, err := senderManager.GroupEncrypt(groupID, plaintext)
+		require.NoError(t, err)
+		decryptedMsg, err := receiverManager.GroupDecrypt(groupID, sender.UserID, sender.DeviceID, encryptedMsg.Serialized)
 
 		// Assert
 		require.NoError(t, err)
@@ -209,9 +658,9 @@ func TestManager_GroupEncryptDecrypt(t *testing.T) {
 		groupID := "group1"
 		keyMessages, err := senderManager.CreateEncryptionGroup(groupID, []string{receiver.UserID})
 		require.NoError(t, err)
-		require.Contains(t, keyMessages, receiver.UserID)
+		require.NotEmpty(t, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 
-		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, keyMessages[receiver.UserID])
+		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 		require.NoError(t, err)
 
 		// Act
@@ -220,7 +669,7 @@ func TestManager_GroupEncryptDecrypt(t *testing.T) {
 		require.NoError(t, err)
 
 		// Try to decrypt with wrong sender ID
-		_, err = receiverManager.GroupDecrypt(groupID, "wrong-sender", encryptedMsg.Serialized)
+		_, err = receiverManager.GroupDecrypt(groupID, "wrong-sender", sender.DeviceID, encryptedMsg.Serialized)
 
 		// Assert
 		require.Error(t, err)
@@ -270,17 +719,86 @@ func TestManager_GroupEncryptDecrypt(t *testing.T) {
 		groupID := "group1"
 		keyMessages, err := senderManager.CreateEncryptionGroup(groupID, []string{receiver.UserID})
 		require.NoError(t, err)
-		require.Contains(t, keyMessages, receiver.UserID)
+		require.NotEmpty(t, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 
-		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, keyMessages[receiver.UserID])
+		err = receiverManager.ProcessSenderKeyDistributionMessage(groupID, sender.UserID, sender.DeviceID, keyMessage(keyMessages, receiver.UserID, receiver.DeviceID))
 		require.NoError(t, err)
 
 		// Act - try to decrypt corrupted message
 		corruptedCiphertext := []byte("corrupted-ciphertext")
-		_, err = receiverManager.GroupDecrypt(groupID, sender.UserID, corruptedCiphertext)
+		_, err = receiverManager.GroupDecrypt(groupID, sender.UserID, sender.DeviceID, corruptedCiphertext)
 
 		// Assert
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to deserialize")
 	})
 }
+
+func TestManager_MultipleDevices(t *testing.T) {
+	t.Run("should distribute sender key to every device except the local one", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		alice, aliceManager := newTestUser(t, apiClient, "alice")
+		bob, bobManager := newTestUser(t, apiClient, "bob")
+		bobSecond, bobSecondManager := newTestDevice(t, apiClient, "bob")
+
+		// Act
+		keyMessages, err := bobSecondManager.CreateEncryptionGroup("group1", []string{alice.UserID, bob.UserID})
+		require.NoError(t, err)
+
+		// Assert
+		require.Len(t, keyMessages, 2)
+		assert.Empty(t, keyMessage(keyMessages, bobSecond.UserID, bobSecond.DeviceID))
+		err = aliceManager.ProcessSenderKeyDistributionMessage("group1", bobSecond.UserID, bobSecond.DeviceID, keyMessage(keyMessages, alice.UserID, alice.DeviceID))
+		require.NoError(t, err)
+		err = bobManager.ProcessSenderKeyDistributionMessage("group1", bobSecond.UserID, bobSecond.DeviceID, keyMessage(keyMessages, bob.UserID, bob.DeviceID))
+		require.NoError(t, err)
+
+		plaintext := []byte("hello from my second device")
+		encryptedMsg, err := bobSecondManager.GroupEncrypt("group1", plaintext)
+		require.NoError(t, err)
+		for _, manager := range []*Manager{aliceManager, bobManager} {
+			decryptedMsg, err := manager.GroupDecrypt("group1", bobSecond.UserID, bobSecond.DeviceID, encryptedMsg.Serialized)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decryptedMsg.Plaintext)
+		}
+	})
+
+	t.Run("should drop sessions with removed devices", func(t *testing.T) {
+		// Arrange
+		apiClient := api.NewFakeClient()
+		_, aliceManager := newTestUser(t, apiClient, "alice")
+		bob, _ := newTestUser(t, apiClient, "bob")
+		bobSecond, _ := newTestDevice(t, apiClient, "bob")
+
+		_, err := aliceManager.CreateEncryptionGroup("group1", []string{bob.UserID})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []uint32{1, bobSecond.DeviceID}, aliceManager.store.GetSubDeviceSessions(bob.UserID))
+		require.NoError(t, apiClient.RemoveDevice(bobSecond.DeviceID))
+
+		// Act
+		keyMessages, err := aliceManager.RotateEncryptionGroup("group1", []string{bob.UserID})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, keyMessages, 1)
+		assert.Equal(t, bob.DeviceID, keyMessages[0].DeviceID)
+		assert.Equal(t, []uint32{1}, aliceManager.store.GetSubDeviceSessions(bob.UserID))
+	})
+}
+
+// newTestDevice registers a new device for the user created by newTestUser
+func newTestDevice(t *testing.T, apiClient *api.FakeClient, name string) (apitypes.SignInResponse, *Manager) {
+	t.Helper()
+
+	db := database.NewFake()
+	require.NoError(t, db.Open(name+"-device"))
+	manager := NewEncryptionManager(db, apiClient)
+	keyBundle, err := manager.InitializeKeyStore()
+	require.NoError(t, err)
+	device, err := apiClient.SignIn(name, "password", 0, &keyBundle)
+	require.NoError(t, err)
+	require.NoError(t, manager.StoreLocalAddress(device.UserID, device.DeviceID))
+
+	return device, manager
+}
@@ -5,8 +5,17 @@ import "signal-chat/internal/apitypes"
 type StubManager struct {
 	InitializeKeyStoreResult                 apitypes.KeyBundle
 	InitializeKeyStoreError                  error
-	CreateEncryptionGroupResult              map[string][]byte
+	ReplenishPreKeysError                    error
+	RotateSignedPreKeyIfDueError             error
+	StoreLocalAddressError                   error
+	LocalDeviceIDResult                      uint32
+	LocalDeviceIDError                       error
+	CreateEncryptionGroupResult              []apitypes.Participant
 	CreateEncryptionGroupError               error
+	RotateEncryptionGroupResult              []apitypes.Participant
+	RotateEncryptionGroupError               error
+	DeleteSenderKeyError                     error
+	DeleteEncryptionGroupError               error
 	ProcessSenderKeyDistributionMessageError error
 	GroupEncryptResult                       *EncryptedMessage
 	GroupEncryptError                        error
@@ -22,11 +31,39 @@ func (m *StubManager) InitializeKeyStore() (apitypes.KeyBundle, error) {
 	return m.InitializeKeyStoreResult, m.InitializeKeyStoreError
 }
 
-func (m *StubManager) CreateEncryptionGroup(groupID string, recipientIDs []string) (map[string][]byte, error) {
+func (m *StubManager) ReplenishPreKeys() error {
+	return m.ReplenishPreKeysError
+}
+
+func (m *StubManager) RotateSignedPreKeyIfDue() error {
+	return m.RotateSignedPreKeyIfDueError
+}
+
+func (m *StubManager) StoreLocalAddress(userID string, deviceID uint32) error {
+	return m.StoreLocalAddressError
+}
+
+func (m *StubManager) LocalDeviceID() (uint32, error) {
+	return m.LocalDeviceIDResult, m.LocalDeviceIDError
+}
+
+func (m *StubManager) CreateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
 	return m.CreateEncryptionGroupResult, m.CreateEncryptionGroupError
 }
 
-func (m *StubManager) ProcessSenderKeyDistributionMessage(groupID string, senderID string, encryptedMsg []byte) error {
+func (m *StubManager) RotateEncryptionGroup(groupID string, recipientIDs []string) ([]apitypes.Participant, error) {
+	return m.RotateEncryptionGroupResult, m.RotateEncryptionGroupError
+}
+
+func (m *StubManager) DeleteSenderKey(groupID, senderID string) error {
+	return m.DeleteSenderKeyError
+}
+
+func (m *StubManager) DeleteEncryptionGroup(groupID string) error {
+	return m.DeleteEncryptionGroupError
+}
+
+func (m *StubManager) ProcessSenderKeyDistributionMessage(groupID string, senderID string, senderDeviceID uint32, encryptedMsg []byte) error {
 	return m.ProcessSenderKeyDistributionMessageError
 }
 
@@ -34,6 +71,6 @@ func (m *StubManager) GroupEncrypt(groupID string, plaintext []byte) (*Encrypted
 	return m.GroupEncryptResult, m.GroupEncryptError
 }
 
-func (m *StubManager) GroupDecrypt(groupID, senderID string, ciphertext []byte) (*DecryptedMessage, error) {
+func (m *StubManager) GroupDecrypt(groupID, senderID string, senderDeviceID uint32, ciphertext []byte) (*DecryptedMessage, error) {
 	return m.GroupDecryptResult, m.GroupDecryptError
 }
@@ -12,3 +12,5 @@ export function GroupEncrypt(arg1:string,arg2:Array<number>):Promise<encryption.
 export function InitializeKeyStore():Promise<api.KeyBundle>;
 
 export function ProcessSenderKeyDistributionMessage(arg1:string,arg2:string,arg3:Array<number>):Promise<void>;
+
+export function TrustIdentity(arg1:string,arg2:number):Promise<void>;
@@ -21,3 +21,7 @@ export function InitializeKeyStore() {
 export function ProcessSenderKeyDistributionMessage(arg1, arg2, arg3) {
   return window['go']['encryption']['Manager']['ProcessSenderKeyDistributionMessage'](arg1, arg2, arg3);
 }
+
+export function TrustIdentity(arg1, arg2) {
+  return window['go']['encryption']['Manager']['TrustIdentity'](arg1, arg2);
+}
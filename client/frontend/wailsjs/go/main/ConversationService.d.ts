@@ -2,10 +2,40 @@
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
 
+export function AddParticipants(arg1:string,arg2:Array<string>):Promise<models.Conversation>;
+
+export function AddReaction(arg1:string,arg2:string,arg3:string):Promise<models.Message>;
+
 export function CreateConversation(arg1:Array<string>):Promise<models.Conversation>;
 
+export function DeleteConversation(arg1:string):Promise<void>;
+
+export function DeleteMessage(arg1:string,arg2:string):Promise<void>;
+
+export function DeleteMessageForEveryone(arg1:string,arg2:string):Promise<void>;
+
+export function EditMessage(arg1:string,arg2:string,arg3:string):Promise<models.Message>;
+
 export function ListConversations():Promise<Array<models.Conversation>>;
 
 export function ListMessages(arg1:string):Promise<Array<models.Message>>;
 
+export function ListThread(arg1:string,arg2:string):Promise<Array<models.Message>>;
+
+export function MarkConversationRead(arg1:string):Promise<void>;
+
+export function ReadReceiptsEnabled():Promise<boolean>;
+
+export function RemoveParticipant(arg1:string,arg2:string):Promise<models.Conversation>;
+
+export function RemoveReaction(arg1:string,arg2:string):Promise<models.Message>;
+
 export function SendMessage(arg1:string,arg2:string):Promise<models.Message>;
+
+export function SendReply(arg1:string,arg2:string,arg3:string):Promise<models.Message>;
+
+export function SetExpirationTimer(arg1:string,arg2:number):Promise<models.Conversation>;
+
+export function SetReadReceiptsEnabled(arg1:boolean):Promise<void>;
+
+export function SetTyping(arg1:string,arg2:boolean):Promise<void>;
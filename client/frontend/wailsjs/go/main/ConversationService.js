@@ -2,10 +2,34 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddParticipants(arg1, arg2) {
+  return window['go']['main']['ConversationService']['AddParticipants'](arg1, arg2);
+}
+
+export function AddReaction(arg1, arg2, arg3) {
+  return window['go']['main']['ConversationService']['AddReaction'](arg1, arg2, arg3);
+}
+
 export function CreateConversation(arg1) {
   return window['go']['main']['ConversationService']['CreateConversation'](arg1);
 }
 
+export function DeleteConversation(arg1) {
+  return window['go']['main']['ConversationService']['DeleteConversation'](arg1);
+}
+
+export function DeleteMessage(arg1, arg2) {
+  return window['go']['main']['ConversationService']['DeleteMessage'](arg1, arg2);
+}
+
+export function DeleteMessageForEveryone(arg1, arg2) {
+  return window['go']['main']['ConversationService']['DeleteMessageForEveryone'](arg1, arg2);
+}
+
+export function EditMessage(arg1, arg2, arg3) {
+  return window['go']['main']['ConversationService']['EditMessage'](arg1, arg2, arg3);
+}
+
 export function ListConversations() {
   return window['go']['main']['ConversationService']['ListConversations']();
 }
@@ -14,6 +38,42 @@ export function ListMessages(arg1) {
   return window['go']['main']['ConversationService']['ListMessages'](arg1);
 }
 
+export function ListThread(arg1, arg2) {
+  return window['go']['main']['ConversationService']['ListThread'](arg1, arg2);
+}
+
+export function MarkConversationRead(arg1) {
+  return window['go']['main']['ConversationService']['MarkConversationRead'](arg1);
+}
+
+export function ReadReceiptsEnabled() {
+  return window['go']['main']['ConversationService']['ReadReceiptsEnabled']();
+}
+
+export function RemoveParticipant(arg1, arg2) {
+  return window['go']['main']['ConversationService']['RemoveParticipant'](arg1, arg2);
+}
+
+export function RemoveReaction(arg1, arg2) {
+  return window['go']['main']['ConversationService']['RemoveReaction'](arg1, arg2);
+}
+
 export function SendMessage(arg1, arg2) {
   return window['go']['main']['ConversationService']['SendMessage'](arg1, arg2);
 }
+
+export function SendReply(arg1, arg2, arg3) {
+  return window['go']['main']['ConversationService']['SendReply'](arg1, arg2, arg3);
+}
+
+export function SetExpirationTimer(arg1, arg2) {
+  return window['go']['main']['ConversationService']['SetExpirationTimer'](arg1, arg2);
+}
+
+export function SetReadReceiptsEnabled(arg1) {
+  return window['go']['main']['ConversationService']['SetReadReceiptsEnabled'](arg1);
+}
+
+export function SetTyping(arg1, arg2) {
+  return window['go']['main']['ConversationService']['SetTyping'](arg1, arg2);
+}
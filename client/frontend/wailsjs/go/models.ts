@@ -169,6 +169,7 @@ export namespace models {
 	    LastMessageSenderID: string;
 	    LastMessageTimestamp: number;
 	    RecipientIDs: string[];
+	    ExpirationTimer: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Conversation(source);
@@ -181,6 +182,53 @@ export namespace models {
 	        this.LastMessageSenderID = source["LastMessageSenderID"];
 	        this.LastMessageTimestamp = source["LastMessageTimestamp"];
 	        this.RecipientIDs = source["RecipientIDs"];
+	        this.ExpirationTimer = source["ExpirationTimer"];
+	    }
+	}
+	export class Quote {
+	    messageId: string;
+	    senderId: string;
+	    text: string;
+	    unavailable?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Quote(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.messageId = source["messageId"];
+	        this.senderId = source["senderId"];
+	        this.text = source["text"];
+	        this.unavailable = source["unavailable"];
+	    }
+	}
+	export class ReactionCount {
+	    Emoji: string;
+	    Count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReactionCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Emoji = source["Emoji"];
+	        this.Count = source["Count"];
+	    }
+	}
+	export class MessageRevision {
+	    Text: string;
+	    Timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageRevision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Text = source["Text"];
+	        this.Timestamp = source["Timestamp"];
 	    }
 	}
 	export class Message {
@@ -190,6 +238,14 @@ export namespace models {
 	    Timestamp: number;
 	    Ciphertext: number[];
 	    Envelope?: encryption.Envelope;
+	    Statuses: Record<string, number>;
+	    Read: boolean;
+	    ExpiresAt: number;
+	    EditedAt: number;
+	    Revisions: MessageRevision[];
+	    Reactions: Record<string, string>;
+	    ReactionCounts: ReactionCount[];
+	    Quote?: Quote;
 	
 	    static createFrom(source: any = {}) {
 	        return new Message(source);
@@ -203,6 +259,14 @@ export namespace models {
 	        this.Timestamp = source["Timestamp"];
 	        this.Ciphertext = source["Ciphertext"];
 	        this.Envelope = this.convertValues(source["Envelope"], encryption.Envelope);
+	        this.Statuses = source["Statuses"];
+	        this.Read = source["Read"];
+	        this.ExpiresAt = source["ExpiresAt"];
+	        this.EditedAt = source["EditedAt"];
+	        this.Revisions = this.convertValues(source["Revisions"], MessageRevision);
+	        this.Reactions = source["Reactions"];
+	        this.ReactionCounts = this.convertValues(source["ReactionCounts"], ReactionCount);
+	        this.Quote = this.convertValues(source["Quote"], Quote);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
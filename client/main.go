@@ -85,6 +85,31 @@ func main() {
 			conversations2.MessageAdded = func(msg models.Message) {
 				runtime.EventsEmit(ctx, "message_added", msg)
 			}
+			conversations2.MessageUpdated = func(msg models.Message) {
+				runtime.EventsEmit(ctx, "message_updated", msg)
+			}
+			conversations2.MessageExpired = func(msg models.Message) {
+				runtime.EventsEmit(ctx, "message_expired", msg)
+			}
+			conversations2.MessageDeleted = func(msg models.Message) {
+				runtime.EventsEmit(ctx, "message_deleted", msg)
+			}
+			conversations2.ConversationDeleted = func(conv models.Conversation) {
+				runtime.EventsEmit(ctx, "conversation_deleted", conv)
+			}
+			conversations2.ReactionsChanged = func(msg models.Message) {
+				runtime.EventsEmit(ctx, "reactions_changed", msg)
+			}
+			conversations2.SyncProgressed = func(synced int, done bool) {
+				runtime.EventsEmit(ctx, "sync_progress", synced, done)
+			}
+			conversations2.TypingChanged = func(conversationID, userID string, typing bool) {
+				runtime.EventsEmit(ctx, "typing_changed", conversationID, userID, typing)
+			}
+			encryptor2.IdentityChanged = func(userID string, deviceID uint32) {
+				runtime.EventsEmit(ctx, "identity_changed", userID, deviceID)
+			}
+			go conversations2.runExpiration(ctx, time.Minute)
 			ac.SetConnectionStateHandler(func(state api.ConnectionState) {
 				runtime.EventsEmit(ctx, "connection_changed", state)
 			})
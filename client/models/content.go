@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Content is the plaintext of an encrypted message. Besides the text, it carries the references to other messages
+// that only the participants may see.
+type Content struct {
+	Text string `json:"text"`
+	// EditOf is the ID of the message the content is a new revision of
+	EditOf string `json:"editOf,omitempty"`
+	// DeleteOf is the ID of the message the content is a tombstone of, its author deleted it for everyone
+	DeleteOf string `json:"deleteOf,omitempty"`
+	// Reaction adds or removes the reaction of the sender to another message
+	Reaction *Reaction `json:"reaction,omitempty"`
+	// Quote is the message the text replies to
+	Quote *Quote `json:"quote,omitempty"`
+}
+
+// QuoteUnavailable is the text shown for a quoted message that isn't stored on the device
+const QuoteUnavailable = "original not available"
+
+// Quote references the message a reply quotes with a snippet of its text, so that the reply can be shown without
+// looking the original up
+type Quote struct {
+	MessageID string `json:"messageId"`
+	SenderID  string `json:"senderId"`
+	Text      string `json:"text"`
+	// Unavailable is set when the quoted message is missing or was deleted, the text is then QuoteUnavailable. It's
+	// filled in when the reply is read.
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// Reaction is the emoji a user reacted to a message with. Users have a single reaction per message, a new one
+// replaces the previous one.
+type Reaction struct {
+	MessageID string `json:"messageId"`
+	Emoji     string `json:"emoji,omitempty"`
+	// Remove takes the reaction of the user back
+	Remove bool `json:"remove,omitempty"`
+}
+
+func (c *Content) Serialize() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func DeserializeContent(data []byte) (Content, error) {
+	var c Content
+	err := json.Unmarshal(data, &c)
+	if err != nil {
+		return Content{}, fmt.Errorf("failed to deserialize content: %w", err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContent_Serialize(t *testing.T) {
+	t.Run("roundtrip preserves content", func(t *testing.T) {
+		// Arrange
+		original := Content{
+			Text:     "Hello there",
+			EditOf:   "msg-1",
+			DeleteOf: "msg-2",
+			Reaction: &Reaction{MessageID: "msg-3", Emoji: "👍"},
+			Quote:    &Quote{MessageID: "msg-4", SenderID: "bob", Text: "General Kenobi"},
+		}
+
+		// Act
+		serialized, err := original.Serialize()
+
+		// Assert
+		assert.NoError(t, err)
+		deserialized, err := DeserializeContent(serialized)
+		assert.NoError(t, err)
+		assert.Equal(t, original, deserialized)
+	})
+}
+
+func TestContent_Deserialize(t *testing.T) {
+	t.Run("returns error for invalid data", func(t *testing.T) {
+		// Act
+		_, err := DeserializeContent([]byte("Hello there"))
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -11,6 +11,8 @@ type Conversation struct {
 	LastMessageSenderID  string
 	LastMessageTimestamp int64
 	ParticipantIDs       []string
+	// ExpirationTimer is the number of seconds after which new messages disappear, zero keeps them
+	ExpirationTimer int64
 }
 
 func (c *Conversation) Serialize() ([]byte, error) {
@@ -4,6 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"signal-chat/client/encryption"
+	"sort"
+)
+
+// MessageStatus is the progress of a sent message towards a single recipient, it only ever advances
+type MessageStatus int
+
+const (
+	MessageStatusPending MessageStatus = iota
+	MessageStatusSent
+	MessageStatusDelivered
+	MessageStatusRead
 )
 
 type Message struct {
@@ -13,6 +24,115 @@ type Message struct {
 	Timestamp  int64
 	Ciphertext []byte
 	Envelope   *encryption.Envelope
+	// Statuses tracks a message sent by the user per recipient ID
+	Statuses map[string]MessageStatus
+	// Read is set on a received message once the user read it
+	Read bool
+	// ExpiresAt is the time in Unix milliseconds the message disappears at, zero keeps it
+	ExpiresAt int64
+	// EditedAt is the time in Unix milliseconds of the latest edit, zero when the message was never edited
+	EditedAt int64
+	// Revisions are the previous versions of an edited message, oldest first
+	Revisions []MessageRevision
+	// Reactions maps the IDs of the users that reacted to the message to their emoji
+	Reactions map[string]string
+	// ReactionCounts aggregates the reactions by emoji, it's filled in when the message is read
+	ReactionCounts []ReactionCount
+	// Quote is the message this one replies to, nil when it isn't a reply
+	Quote *Quote
+}
+
+// ReactionCount is the number of users that reacted to a message with an emoji
+type ReactionCount struct {
+	Emoji string
+	Count int
+}
+
+// MessageRevision is a previous version of an edited message
+type MessageRevision struct {
+	Text string
+	// Timestamp is the time in Unix milliseconds the version was written at
+	Timestamp int64
+}
+
+// Edit replaces the text with a revision written at the given time and keeps the previous text in the history. It
+// reports whether the revision is newer than the current text, older ones are ignored.
+func (c *Message) Edit(text string, editedAt int64) bool {
+	written := max(c.Timestamp, c.EditedAt)
+	if editedAt <= written {
+		return false
+	}
+
+	c.Revisions = append(c.Revisions, MessageRevision{Text: c.Text, Timestamp: written})
+	c.Text = text
+	c.EditedAt = editedAt
+	return true
+}
+
+// Expired tells whether the message disappeared at the given time in Unix milliseconds
+func (c *Message) Expired(now int64) bool {
+	return c.ExpiresAt > 0 && c.ExpiresAt <= now
+}
+
+// UpdateStatus advances the status of the message for the recipient and reports whether it changed
+func (c *Message) UpdateStatus(recipientID string, status MessageStatus) bool {
+	if c.Statuses[recipientID] >= status {
+		return false
+	}
+	if c.Statuses == nil {
+		c.Statuses = make(map[string]MessageStatus)
+	}
+
+	c.Statuses[recipientID] = status
+	return true
+}
+
+// React sets the reaction of the user, replacing the previous one, and reports whether it changed
+func (c *Message) React(userID, emoji string) bool {
+	if c.Reactions[userID] == emoji {
+		return false
+	}
+	if c.Reactions == nil {
+		c.Reactions = make(map[string]string)
+	}
+
+	c.Reactions[userID] = emoji
+	return true
+}
+
+// Unreact removes the reaction of the user and reports whether there was one
+func (c *Message) Unreact(userID string) bool {
+	if _, reacted := c.Reactions[userID]; !reacted {
+		return false
+	}
+
+	delete(c.Reactions, userID)
+	return true
+}
+
+// CountReactions aggregates the reactions by emoji, the most frequent first
+func (c *Message) CountReactions() []ReactionCount {
+	if len(c.Reactions) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, emoji := range c.Reactions {
+		counts[emoji]++
+	}
+
+	result := make([]ReactionCount, 0, len(counts))
+	for emoji, count := range counts {
+		result = append(result, ReactionCount{Emoji: emoji, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Emoji < result[j].Emoji
+	})
+
+	return result
 }
 
 func (c *Message) Serialize() ([]byte, error) {
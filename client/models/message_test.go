@@ -0,0 +1,122 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessage_UpdateStatus(t *testing.T) {
+	t.Run("advances status of recipient", func(t *testing.T) {
+		// Arrange
+		msg := Message{Statuses: map[string]MessageStatus{"bob": MessageStatusSent, "carol": MessageStatusSent}}
+
+		// Act
+		changed := msg.UpdateStatus("bob", MessageStatusRead)
+
+		// Assert
+		assert.True(t, changed)
+		assert.Equal(t, map[string]MessageStatus{"bob": MessageStatusRead, "carol": MessageStatusSent}, msg.Statuses)
+	})
+
+	t.Run("never moves status back", func(t *testing.T) {
+		// Arrange
+		msg := Message{Statuses: map[string]MessageStatus{"bob": MessageStatusRead}}
+
+		// Act
+		changed := msg.UpdateStatus("bob", MessageStatusDelivered)
+
+		// Assert
+		assert.False(t, changed)
+		assert.Equal(t, MessageStatusRead, msg.Statuses["bob"])
+	})
+}
+
+func TestMessage_Expired(t *testing.T) {
+	t.Run("expires once its time is up", func(t *testing.T) {
+		// Arrange
+		msg := Message{ExpiresAt: 1000}
+
+		// Act & Assert
+		assert.False(t, msg.Expired(999))
+		assert.True(t, msg.Expired(1000))
+	})
+
+	t.Run("never expires without an expiration time", func(t *testing.T) {
+		// Arrange
+		msg := Message{}
+
+		// Act & Assert
+		assert.False(t, msg.Expired(1<<62))
+	})
+}
+
+func TestMessage_Edit(t *testing.T) {
+	t.Run("keeps previous versions in the history", func(t *testing.T) {
+		// Arrange
+		msg := Message{Text: "first", Timestamp: 1000}
+
+		// Act
+		msg.Edit("second", 2000)
+		changed := msg.Edit("third", 3000)
+
+		// Assert
+		assert.True(t, changed)
+		assert.Equal(t, "third", msg.Text)
+		assert.Equal(t, int64(3000), msg.EditedAt)
+		assert.Equal(t, []MessageRevision{{Text: "first", Timestamp: 1000}, {Text: "second", Timestamp: 2000}}, msg.Revisions)
+	})
+
+	t.Run("ignores revisions older than the current text", func(t *testing.T) {
+		// Arrange
+		msg := Message{Text: "second", Timestamp: 1000, EditedAt: 3000}
+
+		// Act
+		changed := msg.Edit("outdated", 2000)
+
+		// Assert
+		assert.False(t, changed)
+		assert.Equal(t, "second", msg.Text)
+		assert.Empty(t, msg.Revisions)
+	})
+}
+
+func TestMessage_React(t *testing.T) {
+	t.Run("replaces the previous reaction of the user", func(t *testing.T) {
+		// Arrange
+		msg := Message{}
+		msg.React("bob", "👍")
+
+		// Act
+		changed := msg.React("bob", "❤️")
+
+		// Assert
+		assert.True(t, changed)
+		assert.Equal(t, map[string]string{"bob": "❤️"}, msg.Reactions)
+	})
+
+	t.Run("removes the reaction of the user", func(t *testing.T) {
+		// Arrange
+		msg := Message{Reactions: map[string]string{"bob": "👍", "carol": "👍"}}
+
+		// Act
+		changed := msg.Unreact("bob")
+
+		// Assert
+		assert.True(t, changed)
+		assert.Equal(t, map[string]string{"carol": "👍"}, msg.Reactions)
+		assert.False(t, msg.Unreact("bob"), "removing a missing reaction should change nothing")
+	})
+}
+
+func TestMessage_CountReactions(t *testing.T) {
+	t.Run("counts reactions by emoji, most frequent first", func(t *testing.T) {
+		// Arrange
+		msg := Message{Reactions: map[string]string{"alice": "😂", "bob": "👍", "carol": "👍", "dave": "❤️"}}
+
+		// Act
+		counts := msg.CountReactions()
+
+		// Assert
+		assert.Equal(t, []ReactionCount{{Emoji: "👍", Count: 2}, {Emoji: "❤️", Count: 1}, {Emoji: "😂", Count: 1}}, counts)
+	})
+}
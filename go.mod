@@ -13,6 +13,7 @@ require (
 	github.com/stretchr/testify v1.10.0
 	github.com/wailsapp/wails/v2 v2.9.2
 	golang.org/x/crypto v0.29.0
+	google.golang.org/protobuf v1.35.2
 )
 
 require (
@@ -64,6 +65,5 @@ require (
 	golang.org/x/sys v0.27.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
 	golang.org/x/time v0.8.0 // indirect
-	google.golang.org/protobuf v1.35.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,22 +1,33 @@
 package apitypes
 
 type SignUpRequest struct {
-	Username  string    `json:"username" validate:"required"`
-	Password  string    `json:"password" validate:"required"`
-	KeyBundle KeyBundle `json:"keyBundle" validate:"required"`
+	Username    string    `json:"username" validate:"required"`
+	Password    string    `json:"password" validate:"required"`
+	DeviceLabel string    `json:"deviceLabel,omitempty" validate:"max=255"`
+	KeyBundle   KeyBundle `json:"keyBundle" validate:"required"`
 }
 
 type SignUpResponse struct {
-	UserID    string `json:"userId"`
-	AuthToken string `json:"authToken"`
+	UserID       string `json:"userId"`
+	DeviceID     uint32 `json:"deviceId"`
+	AuthToken    string `json:"authToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    int64  `json:"expiresAt"`
 }
 
 type SignInRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username    string `json:"username" validate:"required"`
+	Password    string `json:"password" validate:"required"`
+	DeviceLabel string `json:"deviceLabel,omitempty" validate:"max=255"`
+	// DeviceID identifies an already registered device. When it's empty, KeyBundle registers a new device.
+	DeviceID  uint32     `json:"deviceId,omitempty" validate:"required_without=KeyBundle"`
+	KeyBundle *KeyBundle `json:"keyBundle,omitempty" validate:"required_without=DeviceID,excluded_with=DeviceID"`
 }
 
 type SignInResponse struct {
-	UserID    string `json:"userId"`
-	AuthToken string `json:"authToken"`
+	UserID       string `json:"userId"`
+	DeviceID     uint32 `json:"deviceId"`
+	AuthToken    string `json:"authToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    int64  `json:"expiresAt"`
 }
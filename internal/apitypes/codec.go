@@ -0,0 +1,147 @@
+package apitypes
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	// WSSubprotocolProtobuf selects the protobuf encoding on the websocket, messages are JSON without a subprotocol
+	WSSubprotocolProtobuf = "signal-chat.protobuf"
+)
+
+// Codec is a wire encoding of the API types. Payloads of websocket messages are always kept as JSON in WSMessage.Data,
+// the codec converts them when encoding and decoding the message, so that the handlers on both ends don't depend on
+// the encoding of the connection.
+type Codec interface {
+	// ContentType is the media type of HTTP bodies in the encoding
+	ContentType() string
+	// Subprotocol is the websocket subprotocol selecting the encoding
+	Subprotocol() string
+	// Binary tells whether websocket messages are sent in binary frames
+	Binary() bool
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	MarshalWSMessage(msg *WSMessage) ([]byte, error)
+	// MarshalWSResponse encodes the response to a websocket request of the given type, which tells the type of its
+	// payload
+	MarshalWSResponse(msg *WSMessage, requestType WSMessageType) ([]byte, error)
+	UnmarshalWSMessage(data []byte, msg *WSMessage) error
+}
+
+var (
+	// JSONCodec encodes the API types as JSON, with byte slices in base64
+	JSONCodec Codec = jsonCodec{}
+	// ProtobufCodec encodes the API types as the protobuf messages of the same name
+	ProtobufCodec Codec = protobufCodec{}
+)
+
+// CodecForContentType returns the codec of an HTTP body. Bodies without content type are taken as JSON, false is
+// returned for unsupported ones.
+func CodecForContentType(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return JSONCodec, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return JSONCodec, true
+	case ContentTypeProtobuf:
+		return ProtobufCodec, true
+	default:
+		return nil, false
+	}
+}
+
+// AcceptsProtobuf tells whether the Accept header of an HTTP request asks for protobuf responses
+func AcceptsProtobuf(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		if mediaType, _, err := mime.ParseMediaType(mediaRange); err == nil && mediaType == ContentTypeProtobuf {
+			return true
+		}
+	}
+	return false
+}
+
+// CodecForSubprotocol returns the codec of a websocket connection from the negotiated subprotocol
+func CodecForSubprotocol(subprotocol string) Codec {
+	if subprotocol == WSSubprotocolProtobuf {
+		return ProtobufCodec
+	}
+	return JSONCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Subprotocol() string {
+	return ""
+}
+
+func (jsonCodec) Binary() bool {
+	return false
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) MarshalWSMessage(msg *WSMessage) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) MarshalWSResponse(msg *WSMessage, _ WSMessageType) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) UnmarshalWSMessage(data []byte, msg *WSMessage) error {
+	return json.Unmarshal(data, msg)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Subprotocol() string {
+	return WSSubprotocolProtobuf
+}
+
+func (protobufCodec) Binary() bool {
+	return true
+}
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	return marshalProtobuf(v)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	return unmarshalProtobuf(data, v)
+}
+
+func (protobufCodec) MarshalWSMessage(msg *WSMessage) ([]byte, error) {
+	return marshalWSMessageProtobuf(msg, noRequest)
+}
+
+func (protobufCodec) MarshalWSResponse(msg *WSMessage, requestType WSMessageType) ([]byte, error) {
+	return marshalWSMessageProtobuf(msg, requestType)
+}
+
+func (protobufCodec) UnmarshalWSMessage(data []byte, msg *WSMessage) error {
+	return unmarshalWSMessageProtobuf(data, msg)
+}
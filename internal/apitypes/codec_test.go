@@ -0,0 +1,305 @@
+package apitypes
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+)
+
+var (
+	testKeyBundle = KeyBundle{
+		RegistrationID: 7,
+		IdentityKey:    []byte{1, 2, 3},
+		SignedPreKey:   SignedPreKey{ID: 1, PublicKey: []byte{4, 5}, Signature: []byte{6, 7}},
+		PreKeys:        []PreKey{{ID: 2, PublicKey: []byte{8}}, {ID: 3, PublicKey: []byte{9}}},
+	}
+	testParticipants = []Participant{
+		{ID: "bob", DeviceID: 1, KeyDistributionMessage: []byte{10, 11}},
+		{ID: "carol", DeviceID: 2, KeyDistributionMessage: []byte{12}},
+	}
+)
+
+// testBodies are HTTP bodies and websocket payloads with every field set
+var testBodies = []any{
+	SignUpRequest{Username: "alice", Password: "secret", DeviceLabel: "laptop", KeyBundle: testKeyBundle},
+	SignUpResponse{UserID: "alice-id", DeviceID: 1, AuthToken: "auth", RefreshToken: "refresh", ExpiresAt: 1700000000},
+	SignInRequest{Username: "alice", Password: "secret", DeviceLabel: "phone", KeyBundle: &testKeyBundle},
+	SignInRequest{Username: "alice", Password: "secret", DeviceID: 2},
+	SignInResponse{UserID: "alice-id", DeviceID: 2, AuthToken: "auth", RefreshToken: "refresh", ExpiresAt: 1700000000},
+	RefreshSessionRequest{RefreshToken: "refresh"},
+	RefreshSessionResponse{AuthToken: "auth", RefreshToken: "refresh", ExpiresAt: 1700000000},
+	GetSessionsResponse{Sessions: []Session{{ID: "s1", DeviceID: 1, DeviceLabel: "laptop", IssuedAt: 1, ExpiresAt: 2, LastSeenAt: 3}}},
+	GetUserResponse{User: User{ID: "alice-id", Username: "alice"}},
+	GetAllUsersResponse{Users: []User{{ID: "alice-id", Username: "alice"}, {ID: "bob-id", Username: "bob"}}},
+	GetDevicesResponse{Devices: []Device{{ID: 1, RegistrationID: 7}}},
+	GetPreKeyBundleResponse{PreKeyBundle: PreKeyBundle{
+		RegistrationID: 7,
+		IdentityKey:    []byte{1, 2, 3},
+		SignedPreKey:   SignedPreKey{ID: 1, PublicKey: []byte{4, 5}, Signature: []byte{6, 7}},
+		PreKey:         &PreKey{ID: 2, PublicKey: []byte{8}},
+	}},
+	UploadPreKeysRequest{PreKeys: []PreKey{{ID: 2, PublicKey: []byte{8}}}},
+	UploadPreKeysResponse{Count: 42},
+	UploadSignedPreKeyRequest{SignedPreKey: SignedPreKey{ID: 1, PublicKey: []byte{4, 5}, Signature: []byte{6, 7}}},
+	CreateConversationRequest{ConversationID: "conv-1", OtherParticipants: testParticipants},
+	CreateConversationResponse{ConversationID: "conv-1"},
+	AddParticipantsRequest{Participants: testParticipants},
+	RemoveParticipantRequest{KeyDistributions: testParticipants},
+	DistributeSenderKeysRequest{Participants: testParticipants},
+	SetExpirationTimerRequest{ExpirationTimer: 3600},
+	SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")},
+	SendMessageResponse{MessageID: "msg-1", CreatedAt: 1700000000000, ExpiresAt: 1700003600000},
+	GetMessagesResponse{
+		Messages: []Message{{
+			ID:             "msg-1",
+			ConversationID: "conv-1",
+			SenderID:       "alice-id",
+			SenderDeviceID: 1,
+			Content:        []byte("ciphertext"),
+			CreatedAt:      1700000000000,
+			ExpiresAt:      1700003600000,
+		}},
+		NextCursor: "cursor",
+	},
+	SendReceiptRequest{ConversationID: "conv-1", AuthorID: "bob-id", MessageIDs: []string{"msg-1", "msg-2"}, Type: ReceiptTypeRead},
+	GetPresenceResponse{Presence: []Presence{{UserID: "bob-id", Online: true}, {UserID: "carol-id", LastSeen: 1700000000000}}},
+	UpdatePresenceSettingsRequest{HideLastSeen: true},
+	WSNewMessagePayload{
+		ConversationID: "conv-1",
+		MessageID:      "msg-1",
+		SenderID:       "alice-id",
+		SenderDeviceID: 1,
+		Content:        []byte("ciphertext"),
+		CreatedAt:      1700000000000,
+		ExpiresAt:      1700003600000,
+	},
+	WSNewConversationPayload{
+		ConversationID:         "conv-1",
+		SenderID:               "alice-id",
+		SenderDeviceID:         1,
+		ParticipantIDs:         []string{"alice-id", "bob-id"},
+		KeyDistributionMessage: []byte{10, 11},
+	},
+	WSParticipantAddedPayload{
+		ConversationID:         "conv-1",
+		SenderID:               "alice-id",
+		SenderDeviceID:         1,
+		ParticipantIDs:         []string{"alice-id", "bob-id", "carol-id"},
+		AddedIDs:               []string{"carol-id"},
+		KeyDistributionMessage: []byte{10, 11},
+	},
+	WSParticipantRemovedPayload{
+		ConversationID:         "conv-1",
+		SenderID:               "alice-id",
+		SenderDeviceID:         1,
+		RemovedID:              "carol-id",
+		ParticipantIDs:         []string{"alice-id", "bob-id"},
+		KeyDistributionMessage: []byte{10, 11},
+	},
+	WSSenderKeyPayload{ConversationID: "conv-1", SenderID: "alice-id", SenderDeviceID: 1, KeyDistributionMessage: []byte{10}},
+	WSPreKeysLowPayload{Remaining: 3},
+	WSErrorPayload{Status: 403, Message: "forbidden"},
+	WSTypingPayload{ConversationID: "conv-1", SenderID: "alice-id", SenderDeviceID: 1, Typing: true},
+	WSReceiptPayload{
+		ConversationID: "conv-1",
+		SenderID:       "bob-id",
+		SenderDeviceID: 1,
+		MessageIDs:     []string{"msg-1"},
+		Type:           ReceiptTypeDelivered,
+		CreatedAt:      1700000000000,
+	},
+	WSPresencePayload{UserID: "bob-id", LastSeen: 1700000000000},
+	WSExpirationTimerPayload{
+		ConversationID:  "conv-1",
+		SenderID:        "alice-id",
+		SenderDeviceID:  1,
+		ExpirationTimer: 3600,
+		CreatedAt:       1700000000000,
+	},
+}
+
+func TestProtobufCodec_Marshal(t *testing.T) {
+	for _, body := range testBodies {
+		t.Run("round-trips "+reflect.TypeOf(body).Name()+" like JSON", func(t *testing.T) {
+			// Arrange
+			fromJSON := reflect.New(reflect.TypeOf(body))
+			fromProtobuf := reflect.New(reflect.TypeOf(body))
+
+			// Act
+			jsonData, err := JSONCodec.Marshal(body)
+			require.NoError(t, err)
+			require.NoError(t, JSONCodec.Unmarshal(jsonData, fromJSON.Interface()))
+			protobufData, err := ProtobufCodec.Marshal(body)
+			require.NoError(t, err)
+			require.NoError(t, ProtobufCodec.Unmarshal(protobufData, fromProtobuf.Interface()))
+
+			// Assert
+			assert.Equal(t, body, fromProtobuf.Elem().Interface())
+			assert.Equal(t, fromJSON.Elem().Interface(), fromProtobuf.Elem().Interface())
+		})
+	}
+
+	t.Run("leaves out fields that aren't part of the body", func(t *testing.T) {
+		// Arrange
+		req := AddParticipantsRequest{ConversationID: "conv-1", Participants: testParticipants}
+		decoded := AddParticipantsRequest{ConversationID: "from-path"}
+
+		// Act
+		data, err := ProtobufCodec.Marshal(req)
+		require.NoError(t, err)
+		err = ProtobufCodec.Unmarshal(data, &decoded)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, AddParticipantsRequest{ConversationID: "from-path", Participants: testParticipants}, decoded)
+	})
+
+	t.Run("is smaller than JSON for binary content", func(t *testing.T) {
+		// Arrange
+		req := SendMessageRequest{ConversationID: "conv-1", Content: make([]byte, 3000)}
+
+		// Act
+		jsonData, err := JSONCodec.Marshal(req)
+		require.NoError(t, err)
+		protobufData, err := ProtobufCodec.Marshal(req)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Less(t, len(protobufData), 3100)
+		assert.Greater(t, len(jsonData), 4000)
+	})
+}
+
+func TestProtobufCodec_MarshalWSMessage(t *testing.T) {
+	// Every payload but sync pages is among the test bodies
+	payloads := make(map[WSMessageType]any)
+	for messageType, payloadType := range wsPayloads {
+		for _, body := range testBodies {
+			if reflect.TypeOf(body) == payloadType {
+				payloads[messageType] = body
+			}
+		}
+	}
+	require.Len(t, payloads, len(wsPayloads)-1)
+
+	for messageType, payload := range payloads {
+		t.Run("round-trips "+reflect.TypeOf(payload).Name()+" like JSON", func(t *testing.T) {
+			// Arrange
+			msg := testWSMessage(t, messageType, 5, payload)
+
+			// Act & Assert
+			testWSMessageRoundTrip(t, msg, noRequest)
+		})
+	}
+
+	t.Run("round-trips sync page with the payloads of the stored messages", func(t *testing.T) {
+		// Arrange
+		msg := testWSMessage(t, MessageTypeSync, 8, WSSyncPayload{
+			Messages: []WSMessage{
+				testWSMessage(t, MessageTypeNewMessage, 7, payloads[MessageTypeNewMessage]),
+				testWSMessage(t, MessageTypeReceipt, 8, payloads[MessageTypeReceipt]),
+			},
+			HasMore: true,
+		})
+
+		// Act & Assert
+		testWSMessageRoundTrip(t, msg, noRequest)
+	})
+
+	t.Run("round-trips response with the payload of the request type", func(t *testing.T) {
+		// Arrange
+		msg := testWSMessage(t, MessageTypeResponse, 0, SendMessageResponse{MessageID: "msg-1", CreatedAt: 1700000000000})
+
+		// Act & Assert
+		testWSMessageRoundTrip(t, msg, MessageTypeSendMessage)
+	})
+
+	t.Run("round-trips messages without payload", func(t *testing.T) {
+		// Arrange
+		ack := WSMessage{ID: "ack-1", Seq: 9, Type: MessageTypeAck}
+		receiptResponse := WSMessage{ID: "req-1", Type: MessageTypeResponse, Data: json.RawMessage("null")}
+
+		// Act
+		ackData, err := ProtobufCodec.MarshalWSMessage(&ack)
+		require.NoError(t, err)
+		responseData, err := ProtobufCodec.MarshalWSResponse(&receiptResponse, MessageTypeSendReceipt)
+		require.NoError(t, err)
+
+		// Assert
+		var decoded WSMessage
+		require.NoError(t, ProtobufCodec.UnmarshalWSMessage(ackData, &decoded))
+		assert.Equal(t, ack, decoded)
+		require.NoError(t, ProtobufCodec.UnmarshalWSMessage(responseData, &decoded))
+		assert.Equal(t, WSMessage{ID: "req-1", Type: MessageTypeResponse}, decoded)
+	})
+}
+
+func TestCodecForContentType(t *testing.T) {
+	t.Run("selects codec by media type", func(t *testing.T) {
+		// Arrange
+		cases := map[string]Codec{
+			"":                                JSONCodec,
+			"application/json":                JSONCodec,
+			"application/json; charset=UTF-8": JSONCodec,
+			"application/x-protobuf":          ProtobufCodec,
+		}
+
+		for contentType, expected := range cases {
+			// Act
+			codec, ok := CodecForContentType(contentType)
+
+			// Assert
+			assert.True(t, ok, contentType)
+			assert.Equal(t, expected, codec, contentType)
+		}
+	})
+
+	t.Run("rejects unsupported media type", func(t *testing.T) {
+		// Act
+		_, ok := CodecForContentType("application/xml")
+
+		// Assert
+		assert.False(t, ok)
+	})
+}
+
+func TestAcceptsProtobuf(t *testing.T) {
+	t.Run("finds protobuf among accepted media types", func(t *testing.T) {
+		// Act & Assert
+		assert.True(t, AcceptsProtobuf("application/x-protobuf"))
+		assert.True(t, AcceptsProtobuf("application/json;q=0.5, application/x-protobuf"))
+		assert.False(t, AcceptsProtobuf("application/json"))
+		assert.False(t, AcceptsProtobuf(""))
+	})
+}
+
+func testWSMessage(t *testing.T, messageType WSMessageType, seq uint64, payload any) WSMessage {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return WSMessage{ID: "msg-id", Seq: seq, Type: messageType, Data: data}
+}
+
+// testWSMessageRoundTrip encodes the message with both codecs and checks that the decoded messages carry the same
+// payload. requestType is the type of the request a response answers.
+func testWSMessageRoundTrip(t *testing.T, msg WSMessage, requestType WSMessageType) {
+	t.Helper()
+
+	jsonData, err := JSONCodec.MarshalWSResponse(&msg, requestType)
+	require.NoError(t, err)
+	var fromJSON WSMessage
+	require.NoError(t, JSONCodec.UnmarshalWSMessage(jsonData, &fromJSON))
+
+	protobufData, err := ProtobufCodec.MarshalWSResponse(&msg, requestType)
+	require.NoError(t, err)
+	var fromProtobuf WSMessage
+	require.NoError(t, ProtobufCodec.UnmarshalWSMessage(protobufData, &fromProtobuf))
+
+	assert.Equal(t, fromJSON.ID, fromProtobuf.ID)
+	assert.Equal(t, fromJSON.Seq, fromProtobuf.Seq)
+	assert.Equal(t, fromJSON.Type, fromProtobuf.Type)
+	assert.JSONEq(t, string(fromJSON.Data), string(fromProtobuf.Data))
+}
@@ -9,14 +9,75 @@ type CreateConversationResponse struct {
 	ConversationID string `json:"conversationID" validate:"required,max=255"`
 }
 
+// Participant carries a key distribution message encrypted for a single device of a conversation participant
 type Participant struct {
 	ID                     string `json:"id" validate:"required"`
+	DeviceID               uint32 `json:"deviceId" validate:"required"`
 	KeyDistributionMessage []byte `json:"keyDistributionMessage,omitempty" validate:"required"`
 }
 
 type WSNewConversationPayload struct {
 	ConversationID         string   `json:"conversationID" validate:"required"`
 	SenderID               string   `json:"senderId" validate:"required,max=255"`
+	SenderDeviceID         uint32   `json:"senderDeviceId" validate:"required"`
 	ParticipantIDs         []string `json:"participantIDs" validate:"required,min=1"`
 	KeyDistributionMessage []byte   `json:"keyDistributionMessage" validate:"required"`
 }
+
+type AddParticipantsRequest struct {
+	ConversationID string        `param:"id" json:"-" validate:"required,max=255"`
+	Participants   []Participant `json:"participants" validate:"required,min=1,dive"`
+}
+
+type RemoveParticipantRequest struct {
+	ConversationID string `param:"id" json:"-" validate:"required,max=255"`
+	ParticipantID  string `param:"participantId" json:"-" validate:"required,max=255"`
+	// KeyDistributions carries the remover's rotated sender key for each remaining participant
+	KeyDistributions []Participant `json:"keyDistributions" validate:"dive"`
+}
+
+type DistributeSenderKeysRequest struct {
+	ConversationID string        `param:"id" json:"-" validate:"required,max=255"`
+	Participants   []Participant `json:"participants" validate:"required,min=1,dive"`
+}
+
+type WSParticipantAddedPayload struct {
+	ConversationID         string   `json:"conversationID" validate:"required"`
+	SenderID               string   `json:"senderId" validate:"required,max=255"`
+	SenderDeviceID         uint32   `json:"senderDeviceId" validate:"required"`
+	ParticipantIDs         []string `json:"participantIDs" validate:"required,min=1"`
+	AddedIDs               []string `json:"addedIDs" validate:"required,min=1"`
+	KeyDistributionMessage []byte   `json:"keyDistributionMessage,omitempty"`
+}
+
+type WSParticipantRemovedPayload struct {
+	ConversationID         string   `json:"conversationID" validate:"required"`
+	SenderID               string   `json:"senderId" validate:"required,max=255"`
+	SenderDeviceID         uint32   `json:"senderDeviceId" validate:"required"`
+	RemovedID              string   `json:"removedId" validate:"required,max=255"`
+	ParticipantIDs         []string `json:"participantIDs" validate:"required"`
+	KeyDistributionMessage []byte   `json:"keyDistributionMessage,omitempty"`
+}
+
+type WSSenderKeyPayload struct {
+	ConversationID         string `json:"conversationID" validate:"required"`
+	SenderID               string `json:"senderId" validate:"required,max=255"`
+	SenderDeviceID         uint32 `json:"senderDeviceId" validate:"required"`
+	KeyDistributionMessage []byte `json:"keyDistributionMessage" validate:"required"`
+}
+
+// SetExpirationTimerRequest sets the number of seconds, up to a year, after which new messages of the conversation
+// disappear. Zero keeps them.
+type SetExpirationTimerRequest struct {
+	ConversationID  string `param:"id" json:"-" validate:"required,max=255"`
+	ExpirationTimer int64  `json:"expirationTimer" validate:"min=0,max=31536000"`
+}
+
+// WSExpirationTimerPayload announces that a participant changed the expiration timer of the conversation
+type WSExpirationTimerPayload struct {
+	ConversationID  string `json:"conversationID"`
+	SenderID        string `json:"senderId"`
+	SenderDeviceID  uint32 `json:"senderDeviceId"`
+	ExpirationTimer int64  `json:"expirationTimer"`
+	CreatedAt       int64  `json:"createdAt"`
+}
@@ -3,12 +3,28 @@ package apitypes
 const prefix = "/v1"
 
 const (
-	EndpointSignUp        = prefix + "/signup"
-	EndpointSignIn        = prefix + "/signin"
-	EndpointSignOut       = prefix + "/signout"
-	EndpointConversations = prefix + "/conversations"
-	EndpointMessages      = prefix + "/messages"
-	EndpointUsers         = prefix + "/users"
-	EndpointUser          = prefix + "/users/:id"
-	EndpointPreKeyBundle  = prefix + "/prekeys/:id"
+	EndpointSignUp                   = prefix + "/signup"
+	EndpointSignIn                   = prefix + "/signin"
+	EndpointSignOut                  = prefix + "/signout"
+	EndpointRefresh                  = prefix + "/refresh"
+	EndpointSessions                 = prefix + "/sessions"
+	EndpointSession                  = prefix + "/sessions/:id"
+	EndpointDevice                   = prefix + "/devices/:id"
+	EndpointConversations            = prefix + "/conversations"
+	EndpointConversationMessages     = prefix + "/conversations/:id/messages"
+	EndpointConversationMessage      = prefix + "/conversations/:id/messages/:messageId"
+	EndpointConversationParticipants = prefix + "/conversations/:id/participants"
+	EndpointConversationParticipant  = prefix + "/conversations/:id/participants/:participantId"
+	EndpointConversationKeys         = prefix + "/conversations/:id/keys"
+	EndpointConversationTimer        = prefix + "/conversations/:id/timer"
+	EndpointMessages                 = prefix + "/messages"
+	EndpointReceipts                 = prefix + "/receipts"
+	EndpointPresence                 = prefix + "/presence"
+	EndpointPresenceSettings         = prefix + "/presence/settings"
+	EndpointUsers                    = prefix + "/users"
+	EndpointUser                     = prefix + "/users/:id"
+	EndpointUserDevices              = prefix + "/users/:id/devices"
+	EndpointPreKeys                  = prefix + "/prekeys"
+	EndpointPreKeyBundle             = prefix + "/prekeys/:id/:deviceId"
+	EndpointSignedPreKey             = prefix + "/prekeys/signed"
 )
@@ -9,11 +9,11 @@ type KeyBundle struct {
 
 type SignedPreKey struct {
 	ID        uint32 `json:"id" validate:"required"`
-	PublicKey []byte `json:"publicKey" validate:"required"`
+	PublicKey []byte `json:"publicKey" validate:"required,32bytes"`
 	Signature []byte `json:"signature" validate:"required,64bytes"`
 }
 
 type PreKey struct {
 	ID        uint32 `json:"id" validate:"required"`
-	PublicKey []byte `json:"publicKey" validate:"required"`
+	PublicKey []byte `json:"publicKey" validate:"required,32bytes"`
 }
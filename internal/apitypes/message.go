@@ -1,19 +1,53 @@
 package apitypes
 
+// MaxMessageContentSize is the largest encrypted content of a message, it leaves room for the base64 encoding and the
+// envelope of the websocket message delivering it within WSMaxMessageSize
+const MaxMessageContentSize = 256 << 10
+
 type SendMessageRequest struct {
 	ConversationID string `json:"conversationID" validate:"required"`
-	Content        []byte `json:"content" validate:"required"`
+	// Content is limited to MaxMessageContentSize
+	Content []byte `json:"content" validate:"required,max=262144"`
+	// RefersTo is the ID of the message an edit or a reaction applies to, the server purges it along with that message
+	RefersTo string `json:"refersTo,omitempty"`
 }
 
 type SendMessageResponse struct {
 	MessageID string `json:"messageID,omitempty"`
 	CreatedAt int64  `json:"timestamp,omitempty"`
+	// ExpiresAt is the time in Unix milliseconds the message disappears at, zero when the conversation has no timer
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
 }
 
 type WSNewMessagePayload struct {
 	ConversationID string `json:"conversationID"`
 	MessageID      string `json:"messageID"`
 	SenderID       string `json:"senderID"`
+	SenderDeviceID uint32 `json:"senderDeviceID"`
+	Content        []byte `json:"content"`
+	CreatedAt      int64  `json:"createdAt"`
+	ExpiresAt      int64  `json:"expiresAt,omitempty"`
+}
+
+type GetMessagesRequest struct {
+	ConversationID string `param:"id" validate:"required"`
+	Cursor         string `query:"cursor"`
+	Limit          int    `query:"limit" validate:"min=0,max=100"`
+	Before         int64  `query:"before" validate:"min=0"`
+	After          int64  `query:"after" validate:"min=0"`
+}
+
+type GetMessagesResponse struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+type Message struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversationID"`
+	SenderID       string `json:"senderID"`
+	SenderDeviceID uint32 `json:"senderDeviceID"`
 	Content        []byte `json:"content"`
 	CreatedAt      int64  `json:"createdAt"`
+	ExpiresAt      int64  `json:"expiresAt,omitempty"`
 }
@@ -0,0 +1,4 @@
+// Package pb holds the protobuf messages the API types are encoded with on the wire, see apitypes.proto
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative apitypes.proto
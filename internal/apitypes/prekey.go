@@ -8,5 +8,24 @@ type PreKeyBundle struct {
 	RegistrationID uint32       `json:"registrationId"`
 	IdentityKey    []byte       `json:"identityKey"`
 	SignedPreKey   SignedPreKey `json:"signedPreKey"`
-	PreKey         PreKey       `json:"preKey"`
+	// PreKey is nil when the user has run out of one-time pre-keys, the session is then established with the signed
+	// pre-key only
+	PreKey *PreKey `json:"preKey,omitempty"`
+}
+
+type UploadPreKeysRequest struct {
+	PreKeys []PreKey `json:"preKeys" validate:"required,min=1,max=100,dive"`
+}
+
+type UploadPreKeysResponse struct {
+	// Count is the number of one-time pre-keys the server holds for the user after the upload
+	Count int `json:"count"`
+}
+
+type UploadSignedPreKeyRequest struct {
+	SignedPreKey SignedPreKey `json:"signedPreKey" validate:"required"`
+}
+
+type WSPreKeysLowPayload struct {
+	Remaining int `json:"remaining"`
 }
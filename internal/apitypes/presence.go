@@ -0,0 +1,30 @@
+package apitypes
+
+// GetPresenceRequest asks for the presence of the given users, e.g. /v1/presence?id=alice&id=bob. Only the presence of
+// contacts, the users sharing a conversation with the requester, is returned.
+type GetPresenceRequest struct {
+	UserIDs []string `query:"id" validate:"required,min=1,max=100,dive,required"`
+}
+
+type GetPresenceResponse struct {
+	Presence []Presence `json:"presence"`
+}
+
+type Presence struct {
+	UserID string `json:"userID"`
+	Online bool   `json:"online"`
+	// LastSeen is the time in unix milliseconds the user was last connected. It's omitted while the user is online
+	// and when the user hides it.
+	LastSeen int64 `json:"lastSeen,omitempty"`
+}
+
+type UpdatePresenceSettingsRequest struct {
+	HideLastSeen bool `json:"hideLastSeen"`
+}
+
+// WSPresencePayload tells that a contact came online or went offline
+type WSPresencePayload struct {
+	UserID   string `json:"userID"`
+	Online   bool   `json:"online"`
+	LastSeen int64  `json:"lastSeen,omitempty"`
+}
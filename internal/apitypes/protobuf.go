@@ -0,0 +1,355 @@
+package apitypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"reflect"
+	"strings"
+
+	// The messages are looked up by name in the registry
+	_ "signal-chat/internal/apitypes/pb"
+)
+
+// protobufPackage is the package of the protobuf messages. An API type is encoded as the message of the same name and
+// its fields as the message fields whose camel case name matches, e.g. SenderDeviceID as sender_device_id. Fields
+// that aren't part of the JSON body aren't encoded either.
+const protobufPackage = "signalchat.v1"
+
+var wsMessageType = reflect.TypeOf(WSMessage{})
+
+// noRequest is passed as request type for messages that aren't responses
+const noRequest WSMessageType = -1
+
+// wsPayloads are the payloads carried by the websocket message types, messages of the other types have none
+var wsPayloads = map[WSMessageType]reflect.Type{
+	MessageTypeSync:                  reflect.TypeOf(WSSyncPayload{}),
+	MessageTypeNewMessage:            reflect.TypeOf(WSNewMessagePayload{}),
+	MessageTypeNewConversation:       reflect.TypeOf(WSNewConversationPayload{}),
+	MessageTypeParticipantAdded:      reflect.TypeOf(WSParticipantAddedPayload{}),
+	MessageTypeParticipantRemoved:    reflect.TypeOf(WSParticipantRemovedPayload{}),
+	MessageTypeSenderKeyDistribution: reflect.TypeOf(WSSenderKeyPayload{}),
+	MessageTypePreKeysLow:            reflect.TypeOf(WSPreKeysLowPayload{}),
+	MessageTypeSendMessage:           reflect.TypeOf(SendMessageRequest{}),
+	MessageTypeCreateConversation:    reflect.TypeOf(CreateConversationRequest{}),
+	MessageTypeError:                 reflect.TypeOf(WSErrorPayload{}),
+	MessageTypeTyping:                reflect.TypeOf(WSTypingPayload{}),
+	MessageTypeSendReceipt:           reflect.TypeOf(SendReceiptRequest{}),
+	MessageTypeReceipt:               reflect.TypeOf(WSReceiptPayload{}),
+	MessageTypePresence:              reflect.TypeOf(WSPresencePayload{}),
+	MessageTypeExpirationTimer:       reflect.TypeOf(WSExpirationTimerPayload{}),
+}
+
+// wsResponses are the payloads of the responses to the websocket requests by request type, the responses to the
+// other requests have none
+var wsResponses = map[WSMessageType]reflect.Type{
+	MessageTypeSendMessage:        reflect.TypeOf(SendMessageResponse{}),
+	MessageTypeCreateConversation: reflect.TypeOf(CreateConversationResponse{}),
+}
+
+func marshalProtobuf(v any) ([]byte, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	msg, err := newProtoMessage(value.Type())
+	if err != nil {
+		return nil, err
+	}
+	if err := toProto(value, msg); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(msg.Interface())
+}
+
+func unmarshalProtobuf(data []byte, v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("protobuf: unmarshal into non-pointer %T", v)
+	}
+
+	msg, err := newProtoMessage(value.Elem().Type())
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(data, msg.Interface()); err != nil {
+		return fmt.Errorf("protobuf: %w", err)
+	}
+
+	return fromProto(msg, value.Elem())
+}
+
+func marshalWSMessageProtobuf(wsMsg *WSMessage, requestType WSMessageType) ([]byte, error) {
+	msg, err := newProtoMessage(wsMessageType)
+	if err != nil {
+		return nil, err
+	}
+	if err := wsMessageToProto(wsMsg, requestType, msg); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(msg.Interface())
+}
+
+func unmarshalWSMessageProtobuf(data []byte, wsMsg *WSMessage) error {
+	msg, err := newProtoMessage(wsMessageType)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(data, msg.Interface()); err != nil {
+		return fmt.Errorf("protobuf: %w", err)
+	}
+
+	return wsMessageFromProto(msg, wsMsg)
+}
+
+// newProtoMessage returns an empty protobuf message encoding the API type
+func newProtoMessage(t reflect.Type) (protoreflect.Message, error) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(protobufPackage + "." + t.Name()))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: no message encoding %s: %w", t, err)
+	}
+	return messageType.New(), nil
+}
+
+// protoField returns the field of the message encoding the Go field
+func protoField(msg protoreflect.MessageDescriptor, field reflect.StructField) (protoreflect.FieldDescriptor, error) {
+	fields := msg.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if strings.EqualFold(fields.Get(i).JSONName(), field.Name) {
+			return fields.Get(i), nil
+		}
+	}
+	return nil, fmt.Errorf("protobuf: message %s has no field encoding %s", msg.FullName(), field.Name)
+}
+
+// encodedFields returns the fields of the struct type that are part of the body
+func encodedFields(t reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("json") != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// toProto sets the fields of the message from the struct value
+func toProto(value reflect.Value, msg protoreflect.Message) error {
+	if value.Type() == wsMessageType {
+		wsMsg := value.Interface().(WSMessage)
+		// Only stored messages are nested in other messages, they are never responses
+		return wsMessageToProto(&wsMsg, noRequest, msg)
+	}
+
+	for _, field := range encodedFields(value.Type()) {
+		fd, err := protoField(msg.Descriptor(), field)
+		if err != nil {
+			return err
+		}
+
+		fieldValue := value.FieldByIndex(field.Index)
+		if fd.IsList() {
+			if fieldValue.Len() == 0 {
+				continue
+			}
+			list := msg.Mutable(fd).List()
+			for i := 0; i < fieldValue.Len(); i++ {
+				elem, err := toProtoValue(fieldValue.Index(i), fd, func() protoreflect.Message {
+					return list.NewElement().Message()
+				})
+				if err != nil {
+					return err
+				}
+				list.Append(elem)
+			}
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		v, err := toProtoValue(fieldValue, fd, func() protoreflect.Message {
+			return msg.NewField(fd).Message()
+		})
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, v)
+	}
+
+	return nil
+}
+
+func toProtoValue(value reflect.Value, fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Message) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value.String()), nil
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(value.Bool()), nil
+	case protoreflect.Uint32Kind:
+		return protoreflect.ValueOfUint32(uint32(value.Uint())), nil
+	case protoreflect.Uint64Kind:
+		return protoreflect.ValueOfUint64(value.Uint()), nil
+	case protoreflect.Int32Kind:
+		return protoreflect.ValueOfInt32(int32(value.Int())), nil
+	case protoreflect.Int64Kind:
+		return protoreflect.ValueOfInt64(value.Int()), nil
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(value.Int())), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(value.Bytes()), nil
+	case protoreflect.MessageKind:
+		msg := newMessage()
+		if err := toProto(value, msg); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(msg), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("protobuf: unsupported kind %s of field %s", fd.Kind(), fd.FullName())
+	}
+}
+
+// fromProto sets the fields of the struct value from the message, fields missing from the message are left as is
+func fromProto(msg protoreflect.Message, value reflect.Value) error {
+	if value.Type() == wsMessageType {
+		return wsMessageFromProto(msg, value.Addr().Interface().(*WSMessage))
+	}
+
+	for _, field := range encodedFields(value.Type()) {
+		fd, err := protoField(msg.Descriptor(), field)
+		if err != nil {
+			return err
+		}
+		if !msg.Has(fd) {
+			continue
+		}
+
+		fieldValue := value.FieldByIndex(field.Index)
+		if fd.IsList() {
+			list := msg.Get(fd).List()
+			slice := reflect.MakeSlice(fieldValue.Type(), list.Len(), list.Len())
+			for i := 0; i < list.Len(); i++ {
+				if err := fromProtoValue(list.Get(i), fd, slice.Index(i)); err != nil {
+					return err
+				}
+			}
+			fieldValue.Set(slice)
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Pointer {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			fieldValue = fieldValue.Elem()
+		}
+		if err := fromProtoValue(msg.Get(fd), fd, fieldValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fromProtoValue(v protoreflect.Value, fd protoreflect.FieldDescriptor, value reflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		value.SetString(v.String())
+	case protoreflect.BoolKind:
+		value.SetBool(v.Bool())
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		value.SetUint(v.Uint())
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		value.SetInt(v.Int())
+	case protoreflect.EnumKind:
+		value.SetInt(int64(v.Enum()))
+	case protoreflect.BytesKind:
+		value.SetBytes(v.Bytes())
+	case protoreflect.MessageKind:
+		return fromProto(v.Message(), value)
+	default:
+		return fmt.Errorf("protobuf: unsupported kind %s of field %s", fd.Kind(), fd.FullName())
+	}
+	return nil
+}
+
+// wsMessageToProto sets the fields of the protobuf envelope from the websocket message, its JSON payload is set as
+// the payload of the type of the message. requestType is the type of the request a response answers.
+func wsMessageToProto(wsMsg *WSMessage, requestType WSMessageType, msg protoreflect.Message) error {
+	fields := msg.Descriptor().Fields()
+	msg.Set(fields.ByName("id"), protoreflect.ValueOfString(wsMsg.ID))
+	msg.Set(fields.ByName("seq"), protoreflect.ValueOfUint64(wsMsg.Seq))
+	msg.Set(fields.ByName("type"), protoreflect.ValueOfEnum(protoreflect.EnumNumber(wsMsg.Type)))
+
+	if len(wsMsg.Data) == 0 || bytes.Equal(wsMsg.Data, []byte("null")) {
+		return nil
+	}
+
+	payloadType, ok := wsPayloads[wsMsg.Type]
+	if wsMsg.Type == MessageTypeResponse {
+		payloadType, ok = wsResponses[requestType]
+	}
+	if !ok {
+		return fmt.Errorf("protobuf: websocket message of type %d has no payload", wsMsg.Type)
+	}
+
+	payload := reflect.New(payloadType)
+	if err := json.Unmarshal(wsMsg.Data, payload.Interface()); err != nil {
+		return fmt.Errorf("protobuf: failed to decode payload of websocket message of type %d: %w", wsMsg.Type, err)
+	}
+
+	oneof := msg.Descriptor().Oneofs().ByName("payload")
+	for i := 0; i < oneof.Fields().Len(); i++ {
+		fd := oneof.Fields().Get(i)
+		if string(fd.Message().Name()) == payloadType.Name() {
+			return toProto(payload.Elem(), msg.Mutable(fd).Message())
+		}
+	}
+	return fmt.Errorf("protobuf: websocket message has no payload field for %s", payloadType.Name())
+}
+
+// wsMessageFromProto sets the websocket message from the protobuf envelope, its payload is encoded as JSON
+func wsMessageFromProto(msg protoreflect.Message, wsMsg *WSMessage) error {
+	fields := msg.Descriptor().Fields()
+	wsMsg.ID = msg.Get(fields.ByName("id")).String()
+	wsMsg.Seq = msg.Get(fields.ByName("seq")).Uint()
+	wsMsg.Type = WSMessageType(msg.Get(fields.ByName("type")).Enum())
+	wsMsg.Data = nil
+
+	fd := msg.WhichOneof(msg.Descriptor().Oneofs().ByName("payload"))
+	if fd == nil {
+		return nil
+	}
+
+	payloadType, ok := wsPayloadType(string(fd.Message().Name()))
+	if !ok {
+		return fmt.Errorf("protobuf: unknown websocket payload %s", fd.Message().Name())
+	}
+	payload := reflect.New(payloadType)
+	if err := fromProto(msg.Get(fd).Message(), payload.Elem()); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(payload.Interface())
+	if err != nil {
+		return fmt.Errorf("protobuf: failed to encode payload of websocket message of type %d: %w", wsMsg.Type, err)
+	}
+	wsMsg.Data = data
+	return nil
+}
+
+// wsPayloadType returns the payload or response type of the given name
+func wsPayloadType(name string) (reflect.Type, bool) {
+	for _, payloads := range []map[WSMessageType]reflect.Type{wsPayloads, wsResponses} {
+		for _, payloadType := range payloads {
+			if payloadType.Name() == name {
+				return payloadType, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,27 @@
+package apitypes
+
+type ReceiptType int
+
+const (
+	// ReceiptTypeDelivered is sent once the recipient's client stored the decrypted message
+	ReceiptTypeDelivered ReceiptType = iota + 1
+	// ReceiptTypeRead is sent once the recipient read the message, unless the recipient opted out of read receipts
+	ReceiptTypeRead
+)
+
+// SendReceiptRequest acknowledges messages of a single author in a conversation
+type SendReceiptRequest struct {
+	ConversationID string      `json:"conversationID" validate:"required"`
+	AuthorID       string      `json:"authorID" validate:"required"`
+	MessageIDs     []string    `json:"messageIDs" validate:"required,min=1,max=100,dive,required"`
+	Type           ReceiptType `json:"type" validate:"required,oneof=1 2"`
+}
+
+type WSReceiptPayload struct {
+	ConversationID string      `json:"conversationID"`
+	SenderID       string      `json:"senderID"`
+	SenderDeviceID uint32      `json:"senderDeviceID"`
+	MessageIDs     []string    `json:"messageIDs"`
+	Type           ReceiptType `json:"type"`
+	CreatedAt      int64       `json:"createdAt"`
+}
@@ -0,0 +1,24 @@
+package apitypes
+
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type RefreshSessionResponse struct {
+	AuthToken    string `json:"authToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    int64  `json:"expiresAt"`
+}
+
+type GetSessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+type Session struct {
+	ID          string `json:"id"`
+	DeviceID    uint32 `json:"deviceId"`
+	DeviceLabel string `json:"deviceLabel"`
+	IssuedAt    int64  `json:"issuedAt"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	LastSeenAt  int64  `json:"lastSeenAt"`
+}
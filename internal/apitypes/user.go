@@ -12,3 +12,12 @@ type User struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 }
+
+type GetDevicesResponse struct {
+	Devices []Device `json:"devices"`
+}
+
+type Device struct {
+	ID             uint32 `json:"id"`
+	RegistrationID uint32 `json:"registrationId"`
+}
@@ -12,14 +12,66 @@ const (
 	MessageTypeNewConversation
 	MessageTypeParticipantAdded
 	MessageTypeAck
+	MessageTypeParticipantRemoved
+	MessageTypeSenderKeyDistribution
+	MessageTypePreKeysLow
+	// MessageTypeSendMessage and MessageTypeCreateConversation are requests sent by the client. The server answers
+	// each with a MessageTypeResponse or MessageTypeError message carrying the ID of the request.
+	MessageTypeSendMessage
+	MessageTypeCreateConversation
+	MessageTypeResponse
+	MessageTypeError
+	// MessageTypeTyping is an ephemeral event, it's only relayed to connected devices and never stored
+	MessageTypeTyping
+	// MessageTypeSendReceipt is a request sent by the client, MessageTypeReceipt relays the receipt to the author
+	MessageTypeSendReceipt
+	MessageTypeReceipt
+	// MessageTypePresence is an ephemeral event telling the connected contacts of a user that it came online or went
+	// offline
+	MessageTypePresence
+	// MessageTypeExpirationTimer announces a change of the disappearing messages timer of a conversation
+	MessageTypeExpirationTimer
 )
 
+// WSMaxMessageSize is the read limit for websocket messages on both ends, sync pages stay well below it
+const WSMaxMessageSize = 1 << 20
+
+// WSCloseReasonRestart is the reason of the close frame, with code 1012 (service restart), the server sends to every
+// device when it shuts down. Devices reconnect right away instead of backing off, as the server is expected back soon.
+const WSCloseReasonRestart = "server restarting"
+
+// WSCursorParam is the query parameter of the websocket endpoint that carries the sequence number of the last message
+// the device processed, delivery resumes after it
+const WSCursorParam = "cursor"
+
 type WSMessage struct {
-	ID   string          `json:"id"`
+	ID string `json:"id"`
+	// Seq is the position of the message in the recipient's inbox. An ACK carries the sequence number up to which all
+	// messages were processed.
+	Seq  uint64          `json:"seq,omitempty"`
 	Type WSMessageType   `json:"type"`
 	Data json.RawMessage `json:"data,omitempty"`
 }
 
+// WSSyncPayload is a page of the messages stored while the device was offline. HasMore tells that the next page follows
+// once this one is acknowledged.
 type WSSyncPayload struct {
 	Messages []WSMessage `json:"messages"`
+	HasMore  bool        `json:"hasMore"`
+}
+
+// WSErrorPayload is the answer to a websocket request that failed. Status is the HTTP status code the same request
+// would have got from the HTTP API.
+type WSErrorPayload struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// WSTypingPayload tells that a user started or stopped typing in a conversation. The sender fields are set by the
+// server when it relays the event.
+type WSTypingPayload struct {
+	ConversationID string `json:"conversationID"`
+	SenderID       string `json:"senderID,omitempty"`
+	SenderDeviceID uint32 `json:"senderDeviceID,omitempty"`
+	Typing         bool   `json:"typing"`
 }
@@ -6,72 +6,277 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"net/http"
+	"signal-chat/server/session"
 	"strings"
-	"sync"
+	"time"
 )
 
 var (
 	ErrTokenUnauthorized = errors.New("invalid or revoked token")
+	ErrTokenExpired      = errors.New("token expired")
 	ErrMissingAuthHeader = errors.New("missing or invalid auth scheme")
 	ErrEmptyToken        = errors.New("empty token")
 	ErrDecodeToken       = errors.New("failed to decode token")
 )
 
+// Identity is the user and the device a request was authenticated as
+type Identity struct {
+	UserID   string
+	DeviceID uint32
+}
+
+// SessionTokens holds the credentials handed out to a client when a session is created or refreshed
+type SessionTokens struct {
+	AuthToken    string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
 type AuthManager struct {
-	tokens sync.Map
+	sessions   *session.Store
+	ttl        time.Duration
+	refreshTTL time.Duration
+	// touchInterval limits how often a session is written back to the database on authentication
+	touchInterval time.Duration
+	now           func() time.Time
 }
 
-func NewAuthManager() *AuthManager {
-	return &AuthManager{tokens: sync.Map{}}
+// NewAuthManager creates an auth manager whose access tokens expire after ttl of inactivity and whose
+// refresh tokens expire after refreshTTL
+func NewAuthManager(sessions *session.Store, ttl, refreshTTL time.Duration) *AuthManager {
+	return &AuthManager{
+		sessions:      sessions,
+		ttl:           ttl,
+		refreshTTL:    refreshTTL,
+		touchInterval: time.Minute,
+		now:           time.Now,
+	}
 }
 
-func (m *AuthManager) GenerateToken(userID string) (string, error) {
-	token := make([]byte, 32)
-	_, err := rand.Read(token)
+// GenerateToken starts a new session for the device of the user and returns its tokens
+func (m *AuthManager) GenerateToken(userID string, deviceID uint32, deviceLabel string) (SessionTokens, error) {
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	refreshToken, refreshTokenHash, err := newToken()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return SessionTokens{}, err
 	}
 
-	hash := hashToken(token)
-	m.tokens.Store(hash, userID)
+	now := m.now()
+	sess := session.Session{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		DeviceID:         deviceID,
+		TokenHash:        tokenHash,
+		RefreshTokenHash: refreshTokenHash,
+		DeviceLabel:      deviceLabel,
+		IssuedAt:         now,
+		LastSeenAt:       now,
+		RefreshExpiresAt: now.Add(m.refreshTTL),
+	}
+	sess.ExpiresAt = m.accessExpiry(sess, now)
+
+	if err := m.sessions.Save(sess, now); err != nil {
+		return SessionTokens{}, fmt.Errorf("failed to store session: %w", err)
+	}
 
-	return base64.RawURLEncoding.EncodeToString(token), nil
+	return SessionTokens{
+		AuthToken:    token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}, nil
 }
 
-func (m *AuthManager) Authenticate(r *http.Request) (string, error) {
+func (m *AuthManager) Authenticate(r *http.Request) (Identity, error) {
 	token, err := getToken(r)
 	if err != nil {
-		return "", err
+		return Identity{}, err
+	}
+
+	sess, err := m.sessions.GetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return Identity{}, ErrTokenUnauthorized
+		}
+		return Identity{}, err
+	}
+
+	now := m.now()
+	if sess.IsExpired(now) {
+		return Identity{}, ErrTokenExpired
+	}
+
+	// Slide the expiration window forward
+	if now.Sub(sess.LastSeenAt) >= m.touchInterval {
+		err := m.sessions.Touch(sess.ID, sess.TokenHash, now, m.accessExpiry(sess, now))
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return Identity{}, ErrTokenUnauthorized
+		}
+		if err != nil {
+			return Identity{}, fmt.Errorf("failed to update session: %w", err)
+		}
+	}
+
+	return Identity{UserID: sess.UserID, DeviceID: sess.DeviceID}, nil
+}
+
+// RefreshToken rotates both tokens of the session that owns the given refresh token
+func (m *AuthManager) RefreshToken(refreshToken string) (SessionTokens, error) {
+	decoded, err := decodeToken(refreshToken)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	newRefreshToken, refreshTokenHash, err := newToken()
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	// The lookup and the rotation share a transaction, so a refresh token can only be used once
+	now := m.now()
+	var sess session.Session
+	err = m.sessions.Rotate(hashToken(decoded), now, func(s *session.Session) error {
+		if s.IsRefreshExpired(now) {
+			return ErrTokenExpired
+		}
+
+		s.TokenHash = tokenHash
+		s.RefreshTokenHash = refreshTokenHash
+		s.LastSeenAt = now
+		s.RefreshExpiresAt = now.Add(m.refreshTTL)
+		s.ExpiresAt = m.accessExpiry(*s, now)
+		sess = *s
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrSessionNotFound):
+			return SessionTokens{}, ErrTokenUnauthorized
+		case errors.Is(err, ErrTokenExpired):
+			return SessionTokens{}, err
+		}
+		return SessionTokens{}, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return SessionTokens{
+		AuthToken:    token,
+		RefreshToken: newRefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}, nil
+}
+
+// ListSessions returns all active sessions of the user
+func (m *AuthManager) ListSessions(userID string) ([]session.Session, error) {
+	sessions, err := m.sessions.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	active := make([]session.Session, 0, len(sessions))
+	for _, sess := range sessions {
+		if !sess.IsRefreshExpired(now) {
+			active = append(active, sess)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeSession signs the user out of the given session. Sessions of other users are reported as not found.
+func (m *AuthManager) RevokeSession(userID, sessionID string) error {
+	sess, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != userID {
+		return session.ErrSessionNotFound
 	}
 
-	hash := hashToken(token)
-	if userID, ok := m.tokens.Load(hash); ok {
-		return userID.(string), nil
+	return m.sessions.Delete(sessionID)
+}
+
+// RevokeDevice signs the user out of every session of the given device
+func (m *AuthManager) RevokeDevice(userID string, deviceID uint32) error {
+	sessions, err := m.sessions.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		if sess.DeviceID != deviceID {
+			continue
+		}
+		if err := m.sessions.Delete(sess.ID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+			return err
+		}
 	}
 
-	return "", ErrTokenUnauthorized
+	return nil
 }
 
+// RevokeToken ends the session that owns the access token of the request
 func (m *AuthManager) RevokeToken(r *http.Request) error {
 	token, err := getToken(r)
 	if err != nil {
 		return err
 	}
 
-	hash := hashToken(token)
-	m.tokens.Delete(hash)
+	sess, err := m.sessions.GetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	err = m.sessions.Delete(sess.ID)
+	if err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		return err
+	}
 
 	return nil
 }
 
+// accessExpiry returns the expiration of an access token used at the given time. Access tokens never outlive the
+// refresh token of their session.
+func (m *AuthManager) accessExpiry(sess session.Session, now time.Time) time.Time {
+	expiresAt := now.Add(m.ttl)
+	if expiresAt.After(sess.RefreshExpiresAt) {
+		return sess.RefreshExpiresAt
+	}
+	return expiresAt
+}
+
+func newToken() (string, string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), hashToken(token), nil
+}
+
 func getToken(r *http.Request) ([]byte, error) {
 	authHeader := r.Header.Get("Authorization")
 	if !strings.HasPrefix(authHeader, "Bearer ") {
 		return nil, ErrMissingAuthHeader
 	}
 
-	encoded := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	return decodeToken(strings.TrimPrefix(authHeader, "Bearer "))
+}
+
+func decodeToken(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
 	if encoded == "" {
 		return nil, ErrEmptyToken
 	}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"signal-chat/server/session"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthManager_Authenticate(t *testing.T) {
+	t.Run("slides the access expiration forward on activity", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(50 * time.Minute)
+
+		// Act
+		identity, err := manager.Authenticate(authRequest(tokens.AuthToken))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, Identity{UserID: "alice", DeviceID: 1}, identity)
+		clock.Advance(50 * time.Minute)
+		_, err = manager.Authenticate(authRequest(tokens.AuthToken))
+		assert.NoError(t, err, "token should still be valid an hour after it was issued")
+	})
+
+	t.Run("rejects tokens after the inactivity timeout", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(time.Hour)
+
+		// Act
+		_, err = manager.Authenticate(authRequest(tokens.AuthToken))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("never extends the access token past the refresh token", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		for i := 0; i < 47; i++ {
+			clock.Advance(30 * time.Minute)
+			_, err := manager.Authenticate(authRequest(tokens.AuthToken))
+			require.NoError(t, err)
+		}
+
+		// Act
+		clock.Advance(30 * time.Minute)
+		_, err = manager.Authenticate(authRequest(tokens.AuthToken))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+}
+
+func TestAuthManager_RefreshToken(t *testing.T) {
+	t.Run("rotates both tokens", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(2 * time.Minute)
+
+		// Act
+		refreshed, err := manager.RefreshToken(tokens.RefreshToken)
+
+		// Assert
+		require.NoError(t, err)
+		_, err = manager.Authenticate(authRequest(tokens.AuthToken))
+		assert.ErrorIs(t, err, ErrTokenUnauthorized)
+		_, err = manager.RefreshToken(tokens.RefreshToken)
+		assert.ErrorIs(t, err, ErrTokenUnauthorized)
+		identity, err := manager.Authenticate(authRequest(refreshed.AuthToken))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", identity.UserID)
+	})
+
+	t.Run("isn't undone by a concurrent touch of the old token", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(2 * time.Minute)
+		// Authenticate read the session before the refresh and touches it after
+		stale, err := manager.sessions.GetByTokenHash(hashTokenString(t, tokens.AuthToken))
+		require.NoError(t, err)
+		refreshed, err := manager.RefreshToken(tokens.RefreshToken)
+		require.NoError(t, err)
+
+		// Act
+		err = manager.sessions.Touch(stale.ID, stale.TokenHash, clock.Now(), clock.Now().Add(time.Hour))
+
+		// Assert
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+		_, err = manager.Authenticate(authRequest(refreshed.AuthToken))
+		assert.NoError(t, err)
+		_, err = manager.RefreshToken(refreshed.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("lets only one of concurrent refreshes with the same token succeed", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(2 * time.Minute)
+
+		// Act
+		var wg sync.WaitGroup
+		results := make(chan error, 8)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := manager.RefreshToken(tokens.RefreshToken)
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		// Assert
+		succeeded := 0
+		for err := range results {
+			if err == nil {
+				succeeded++
+				continue
+			}
+			assert.ErrorIs(t, err, ErrTokenUnauthorized)
+		}
+		assert.Equal(t, 1, succeeded)
+	})
+
+	t.Run("rejects expired refresh tokens", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		manager, clock := testAuthManager(db)
+		tokens, err := manager.GenerateToken("alice", 1, "laptop")
+		require.NoError(t, err)
+		clock.Advance(24 * time.Hour)
+
+		// Act
+		_, err = manager.RefreshToken(tokens.RefreshToken)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// testAuthManager creates an auth manager with an hour of inactivity timeout and a day long refresh token
+func testAuthManager(db *badger.DB) (*AuthManager, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	manager := NewAuthManager(session.NewStore(db), time.Hour, 24*time.Hour)
+	manager.now = clock.Now
+	return manager, clock
+}
+
+func authRequest(token string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func hashTokenString(t *testing.T, token string) string {
+	decoded, err := decodeToken(token)
+	require.NoError(t, err)
+	return hashToken(decoded)
+}
@@ -2,4 +2,16 @@ package conversation
 
 type Conversation struct {
 	ParticipantIDs []string `json:"participant_ids"`
+	// ExpirationTimer is the number of seconds after which new messages disappear, zero keeps them
+	ExpirationTimer int64 `json:"expiration_timer,omitempty"`
+}
+
+// HasParticipant reports whether the user is a member of the conversation
+func (c *Conversation) HasParticipant(userID string) bool {
+	for _, id := range c.ParticipantIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,26 @@
+package conversation
+
+type Message struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	SenderDeviceID uint32 `json:"sender_device_id"`
+	Content        []byte `json:"content"`
+	CreatedAt      int64  `json:"created_at"`
+	// ExpiresAt is the time in Unix milliseconds the message is deleted at, zero when it's kept
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// MessageQuery selects a page of conversation messages. Before and After are exclusive bounds in Unix milliseconds,
+// zero means unbounded. When only Before is set, the page walks backwards from it (newest first), otherwise it walks
+// forwards from After (oldest first). Cursor is the ID of the last message of the previous page in the same direction.
+type MessageQuery struct {
+	Cursor string
+	Limit  int
+	Before int64
+	After  int64
+}
+
+func (q MessageQuery) reverse() bool {
+	return q.Before > 0 && q.After == 0
+}
@@ -6,12 +6,22 @@ import (
 	"fmt"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
 )
 
+// MaxMessagesPageSize is the maximum number of messages returned by a single GetMessages call
+const MaxMessagesPageSize = 100
+
 var (
 	ErrConversationExists       = errors.New("conversation already exists")
 	ErrConversationNotFound     = errors.New("conversation not found")
 	ErrConversationUnauthorized = errors.New("not authorized to access specified conversation")
+	ErrParticipantExists        = errors.New("user is already a participant of the conversation")
+	ErrParticipantNotFound      = errors.New("user is not a participant of the conversation")
+	ErrMessageNotFound          = errors.New("message not found")
+	ErrMessageUnauthorized      = errors.New("not authorized to delete specified message")
 )
 
 type Store struct {
@@ -36,11 +46,12 @@ func (s *Store) CreateConversation(id string, participantIDs []string) error {
 		conv := &Conversation{
 			ParticipantIDs: participantIDs,
 		}
-		convJSON, err := json.Marshal(conv)
-		if err != nil {
-			return fmt.Errorf("failed to marshall conversation: %w", err)
+		for _, participantID := range participantIDs {
+			if err := txn.Set(memberItemKey(participantID, id), nil); err != nil {
+				return err
+			}
 		}
-		return txn.Set(conversationItemKey(id), convJSON)
+		return writeConversation(txn, id, conv)
 	})
 
 	if err != nil {
@@ -50,60 +61,391 @@ func (s *Store) CreateConversation(id string, participantIDs []string) error {
 	return nil
 }
 
-func (s *Store) CreateMessage(senderID, conversationID string, content []byte) (string, error) {
-	msgID := uuid.New().String()
+func (s *Store) CreateMessage(senderID string, senderDeviceID uint32, conversationID string, content []byte) (Message, error) {
+	return s.CreateReference(senderID, senderDeviceID, conversationID, "", content)
+}
+
+// CreateReference stores a message referring to an earlier message of the conversation, like an edit or a reaction.
+// It's purged together with the message it refers to. An empty refersTo stores a message referring to none.
+func (s *Store) CreateReference(senderID string, senderDeviceID uint32, conversationID, refersTo string, content []byte) (Message, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to generate message ID: %w", err)
+	}
+
+	msg := Message{
+		ID:             id.String(),
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		SenderDeviceID: senderDeviceID,
+		Content:        content,
+		CreatedAt:      unixMilliFromUUIDv7(id),
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		conv, err := getAuthorizedConversation(txn, conversationID, senderID)
+		if err != nil {
+			return err
+		}
+
+		// Messages of conversations with disappearing messages are deleted by the database once they expire
+		timer := time.Duration(conv.ExpirationTimer) * time.Second
+		if timer > 0 {
+			msg.ExpiresAt = msg.CreatedAt + timer.Milliseconds()
+		}
+		msgJSON, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to marshall message: %w", err)
+		}
+
+		entry := badger.NewEntry(messageItem(conversationID, msg.ID), msgJSON)
+		if timer > 0 {
+			entry = entry.WithTTL(timer)
+		}
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		if refersTo == "" {
+			return nil
+		}
+
+		ref := badger.NewEntry(referenceItem(conversationID, refersTo, msg.ID), nil)
+		if timer > 0 {
+			ref = ref.WithTTL(timer)
+		}
+		return txn.SetEntry(ref)
+	})
+
+	if err != nil {
+		return Message{}, err
+	}
+
+	return msg, nil
+}
+
+// DeleteMessage purges a message from the conversation on behalf of its sender, together with the messages referring
+// to it
+func (s *Store) DeleteMessage(actorID, conversationID, messageID string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := authorizeParticipant(txn, conversationID, actorID); err != nil {
+			return err
+		}
+
+		item, err := txn.Get(messageItem(conversationID, messageID))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg Message
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &msg)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to unmarshall message: %w", err)
+		}
+		if msg.SenderID != actorID {
+			return ErrMessageUnauthorized
+		}
+
+		if err := deleteReferences(txn, conversationID, messageID); err != nil {
+			return err
+		}
+		return txn.Delete(messageItem(conversationID, messageID))
+	})
+}
+
+// deleteReferences deletes the messages referring to the message and their references
+func deleteReferences(txn *badger.Txn, conversationID, messageID string) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var keys [][]byte
+	prefix := referenceItem(conversationID, messageID, "")
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+
+	for _, key := range keys {
+		referringID := strings.TrimPrefix(string(key), string(prefix))
+		if err := txn.Delete(messageItem(conversationID, referringID)); err != nil {
+			return err
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetExpirationTimer sets the number of seconds after which new messages of the conversation disappear on behalf of a
+// participant and returns the updated conversation. Messages sent before keep their expiration time.
+func (s *Store) SetExpirationTimer(actorID, conversationID string, seconds int64) (*Conversation, error) {
+	var conv *Conversation
 
 	err := s.db.Update(func(txn *badger.Txn) error {
-		convItem, err := txn.Get(conversationItemKey(conversationID))
+		var err error
+		conv, err = getAuthorizedConversation(txn, conversationID, actorID)
 		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return ErrConversationNotFound
-			}
 			return err
 		}
 
-		var isAuthorized bool
-		err = convItem.Value(func(val []byte) error {
-			var conv Conversation
-			err = json.Unmarshal(val, &conv)
-			if err != nil {
+		conv.ExpirationTimer = seconds
+		return writeConversation(txn, conversationID, conv)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conv, nil
+}
+
+// AddParticipants adds new members to the conversation on behalf of an existing participant and returns the
+// updated conversation
+func (s *Store) AddParticipants(actorID, conversationID string, participantIDs []string) (*Conversation, error) {
+	var conv *Conversation
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		conv, err = getAuthorizedConversation(txn, conversationID, actorID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range participantIDs {
+			if conv.HasParticipant(id) {
+				return ErrParticipantExists
+			}
+			conv.ParticipantIDs = append(conv.ParticipantIDs, id)
+			if err := txn.Set(memberItemKey(id, conversationID), nil); err != nil {
 				return err
 			}
+		}
 
-			for _, id := range conv.ParticipantIDs {
-				if id == senderID {
-					isAuthorized = true
-					return nil
-				}
-			}
+		return writeConversation(txn, conversationID, conv)
+	})
 
-			return nil
-		})
+	if err != nil {
+		return nil, err
+	}
+
+	return conv, nil
+}
+
+// RemoveParticipant removes a member from the conversation on behalf of an existing participant and returns the
+// updated conversation. Participants may also remove themselves.
+func (s *Store) RemoveParticipant(actorID, conversationID, participantID string) (*Conversation, error) {
+	var conv *Conversation
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		conv, err = getAuthorizedConversation(txn, conversationID, actorID)
 		if err != nil {
 			return err
 		}
-		if !isAuthorized {
-			return ErrConversationUnauthorized
+		if !conv.HasParticipant(participantID) {
+			return ErrParticipantNotFound
 		}
 
-		return txn.Set(messageItem(conversationID, msgID), content)
+		remaining := make([]string, 0, len(conv.ParticipantIDs)-1)
+		for _, id := range conv.ParticipantIDs {
+			if id != participantID {
+				remaining = append(remaining, id)
+			}
+		}
+		conv.ParticipantIDs = remaining
+		if err := txn.Delete(memberItemKey(participantID, conversationID)); err != nil {
+			return err
+		}
+
+		return writeConversation(txn, conversationID, conv)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conv, nil
+}
+
+// GetMessages returns a page of messages of the conversation together with the cursor of the next page. The cursor
+// is empty when there are no more messages to read.
+func (s *Store) GetMessages(userID, conversationID string, query MessageQuery) ([]Message, string, error) {
+	limit := query.Limit
+	if limit <= 0 || limit > MaxMessagesPageSize {
+		limit = MaxMessagesPageSize
+	}
+
+	var messages []Message
+	var nextCursor string
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		if err := authorizeParticipant(txn, conversationID, userID); err != nil {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = query.reverse()
+		opts.Prefix = messageItem(conversationID, "")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(messageSeekKey(conversationID, query)); it.Valid(); it.Next() {
+			item := it.Item()
+			if query.Cursor != "" && string(item.Key()) == string(messageItem(conversationID, query.Cursor)) {
+				continue
+			}
+
+			var msg Message
+			err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &msg)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to unmarshall message: %w", err)
+			}
+
+			if (query.Before > 0 && msg.CreatedAt >= query.Before) || msg.CreatedAt <= query.After {
+				break
+			}
+
+			if len(messages) == limit {
+				nextCursor = messages[len(messages)-1].ID
+				break
+			}
+			messages = append(messages, msg)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return messages, nextCursor, nil
+}
+
+// GetContacts returns the IDs of the users sharing at least one conversation with the user, in ascending order
+func (s *Store) GetContacts(userID string) ([]string, error) {
+	var contacts []string
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := memberItemKey(userID, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			conversationID := strings.TrimPrefix(string(it.Item().Key()), string(prefix))
+			conv, err := getAuthorizedConversation(txn, conversationID, userID)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range conv.ParticipantIDs {
+				if id != userID && !slices.Contains(contacts, id) {
+					contacts = append(contacts, id)
+				}
+			}
+		}
+		return nil
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return msgID, nil
+	slices.Sort(contacts)
+	return contacts, nil
 }
 
 func conversationItemKey(conversationID string) []byte {
 	return []byte("conv#" + conversationID)
 }
 
+// memberItemKey indexes the conversations of a user
+func memberItemKey(userID, conversationID string) []byte {
+	return []byte("member#" + userID + ":" + conversationID)
+}
+
 func messageItem(conversationID, messageID string) []byte {
 	return []byte("msg#" + conversationID + ":" + messageID)
 }
 
+// referenceItem indexes the messages referring to an earlier message of the conversation
+func referenceItem(conversationID, refersTo, messageID string) []byte {
+	return []byte("ref#" + conversationID + ":" + refersTo + ":" + messageID)
+}
+
+// messageSeekKey returns the key the message iterator should start from. Message IDs are UUIDv7 whose first
+// 48 bits hold the creation time in milliseconds, so a time bound maps directly onto a key prefix.
+func messageSeekKey(conversationID string, query MessageQuery) []byte {
+	if query.Cursor != "" {
+		return messageItem(conversationID, query.Cursor)
+	}
+	if query.reverse() {
+		return messageItem(conversationID, timeOrderedPrefix(query.Before))
+	}
+	if query.After > 0 {
+		return messageItem(conversationID, timeOrderedPrefix(query.After+1))
+	}
+	return messageItem(conversationID, "")
+}
+
+func timeOrderedPrefix(unixMilli int64) string {
+	return fmt.Sprintf("%08x-%04x", uint64(unixMilli)>>16, uint64(unixMilli)&0xffff)
+}
+
+// unixMilliFromUUIDv7 returns the creation time encoded in the first 48 bits of a UUIDv7
+func unixMilliFromUUIDv7(id uuid.UUID) int64 {
+	var ms int64
+	for _, b := range id[:6] {
+		ms = ms<<8 | int64(b)
+	}
+	return ms
+}
+
+func authorizeParticipant(txn *badger.Txn, conversationID, userID string) error {
+	_, err := getAuthorizedConversation(txn, conversationID, userID)
+	return err
+}
+
+func getAuthorizedConversation(txn *badger.Txn, conversationID, userID string) (*Conversation, error) {
+	convItem, err := txn.Get(conversationItemKey(conversationID))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+
+	var conv Conversation
+	err = convItem.Value(func(val []byte) error {
+		return json.Unmarshal(val, &conv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !conv.HasParticipant(userID) {
+		return nil, ErrConversationUnauthorized
+	}
+
+	return &conv, nil
+}
+
+func writeConversation(txn *badger.Txn, conversationID string, conv *Conversation) error {
+	convJSON, err := json.Marshal(conv)
+	if err != nil {
+		return fmt.Errorf("failed to marshall conversation: %w", err)
+	}
+	return txn.Set(conversationItemKey(conversationID), convJSON)
+}
+
 // GetConversation retrieves a conversation by ID
 func (s *Store) GetConversation(conversationID string) (*Conversation, error) {
 	var conv Conversation
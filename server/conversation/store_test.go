@@ -0,0 +1,485 @@
+package conversation
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStore_CreateMessage(t *testing.T) {
+	t.Run("returns error when sender is not a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		_, err := store.CreateMessage("mallory", 1, "conv-1", []byte("ciphertext"))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationUnauthorized)
+	})
+
+	t.Run("message IDs are ordered by creation time", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		first, err := store.CreateMessage("alice", 1, "conv-1", []byte("1"))
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		second, err := store.CreateMessage("bob", 1, "conv-1", []byte("2"))
+		require.NoError(t, err)
+
+		// Assert
+		assert.Less(t, first.ID, second.ID)
+		assert.Less(t, first.CreatedAt, second.CreatedAt)
+	})
+
+	t.Run("stores message with a TTL when the conversation has an expiration timer", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+		_, err := store.SetExpirationTimer("alice", "conv-1", 60)
+		require.NoError(t, err)
+
+		// Act
+		msg, err := store.CreateMessage("bob", 1, "conv-1", []byte("ciphertext"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, msg.CreatedAt+60_000, msg.ExpiresAt)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(messageItem("conv-1", msg.ID))
+			require.NoError(t, err)
+			assert.InDelta(t, time.Now().Add(time.Minute).Unix(), int64(item.ExpiresAt()), 2)
+			return nil
+		}))
+	})
+
+	t.Run("stores message without TTL when the conversation has no expiration timer", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		msg, err := store.CreateMessage("bob", 1, "conv-1", []byte("ciphertext"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Zero(t, msg.ExpiresAt)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(messageItem("conv-1", msg.ID))
+			require.NoError(t, err)
+			assert.Zero(t, item.ExpiresAt())
+			return nil
+		}))
+	})
+}
+
+func TestStore_DeleteMessage(t *testing.T) {
+	t.Run("purges the message of its sender", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+		msg, err := store.CreateMessage("alice", 1, "conv-1", []byte("ciphertext"))
+		require.NoError(t, err)
+
+		// Act
+		err = store.DeleteMessage("alice", "conv-1", msg.ID)
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := store.GetMessages("alice", "conv-1", MessageQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("purges the messages referring to the message", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+		msg, err := store.CreateMessage("alice", 1, "conv-1", []byte("ciphertext"))
+		require.NoError(t, err)
+		_, err = store.CreateReference("alice", 1, "conv-1", msg.ID, []byte("edit"))
+		require.NoError(t, err)
+		_, err = store.CreateReference("bob", 1, "conv-1", msg.ID, []byte("reaction"))
+		require.NoError(t, err)
+		other, err := store.CreateMessage("bob", 1, "conv-1", []byte("other"))
+		require.NoError(t, err)
+
+		// Act
+		err = store.DeleteMessage("alice", "conv-1", msg.ID)
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := store.GetMessages("alice", "conv-1", MessageQuery{})
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, other.ID, messages[0].ID)
+		err = db.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			prefix := []byte("ref#")
+			it.Seek(prefix)
+			assert.False(t, it.ValidForPrefix(prefix), "the references should be deleted")
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("returns error when actor is not the sender", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+		msg, err := store.CreateMessage("alice", 1, "conv-1", []byte("ciphertext"))
+		require.NoError(t, err)
+
+		// Act
+		err = store.DeleteMessage("bob", "conv-1", msg.ID)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrMessageUnauthorized)
+		messages, _, err := store.GetMessages("alice", "conv-1", MessageQuery{})
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+
+	t.Run("returns error when message doesn't exist", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		err := store.DeleteMessage("alice", "conv-1", "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+}
+
+func TestStore_SetExpirationTimer(t *testing.T) {
+	t.Run("sets the expiration timer of the conversation", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		conv, err := store.SetExpirationTimer("bob", "conv-1", 3600)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), conv.ExpirationTimer)
+		stored, err := store.GetConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), stored.ExpirationTimer)
+	})
+
+	t.Run("returns error when actor is not a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		_, err := store.SetExpirationTimer("mallory", "conv-1", 60)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationUnauthorized)
+	})
+}
+
+func TestStore_AddParticipants(t *testing.T) {
+	t.Run("appends new participants to the conversation", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		conv, err := store.AddParticipants("bob", "conv-1", []string{"carol", "dave"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob", "carol", "dave"}, conv.ParticipantIDs)
+		stored, err := store.GetConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, conv.ParticipantIDs, stored.ParticipantIDs)
+	})
+
+	t.Run("returns error when actor is not a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		_, err := store.AddParticipants("mallory", "conv-1", []string{"mallory"})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationUnauthorized)
+	})
+
+	t.Run("leaves conversation unchanged when a user is already a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		_, err := store.AddParticipants("alice", "conv-1", []string{"carol", "bob"})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrParticipantExists)
+		stored, err := store.GetConversation("conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob"}, stored.ParticipantIDs)
+	})
+}
+
+func TestStore_RemoveParticipant(t *testing.T) {
+	t.Run("removes participant from the conversation", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob", "carol"}))
+
+		// Act
+		conv, err := store.RemoveParticipant("alice", "conv-1", "bob")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "carol"}, conv.ParticipantIDs)
+		_, err = store.CreateMessage("bob", 1, "conv-1", []byte("ciphertext"))
+		assert.ErrorIs(t, err, ErrConversationUnauthorized)
+	})
+
+	t.Run("returns error when user is not a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+
+		// Act
+		_, err := store.RemoveParticipant("alice", "conv-1", "carol")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrParticipantNotFound)
+	})
+}
+
+func TestStore_GetContacts(t *testing.T) {
+	t.Run("returns participants of every conversation of the user", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "carol"}))
+		require.NoError(t, store.CreateConversation("conv-2", []string{"bob", "alice", "carol"}))
+		require.NoError(t, store.CreateConversation("conv-3", []string{"dave", "erin"}))
+		_, err := store.AddParticipants("alice", "conv-1", []string{"frank"})
+		require.NoError(t, err)
+
+		// Act
+		contacts, err := store.GetContacts("alice")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bob", "carol", "frank"}, contacts)
+	})
+
+	t.Run("forgets conversations the user was removed from", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+		_, err := store.RemoveParticipant("bob", "conv-1", "alice")
+		require.NoError(t, err)
+
+		// Act
+		contacts, err := store.GetContacts("alice")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, contacts)
+	})
+}
+
+func TestStore_GetMessages(t *testing.T) {
+	t.Run("returns messages in creation order with sender and timestamp", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		created := createTestMessages(t, store, 3)
+
+		// Act
+		messages, cursor, err := store.GetMessages("bob", "conv-1", MessageQuery{})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, cursor)
+		require.Len(t, messages, 3)
+		for i, msg := range messages {
+			assert.Equal(t, created[i].ID, msg.ID)
+			assert.Equal(t, "alice", msg.SenderID)
+			assert.Equal(t, uint32(1), msg.SenderDeviceID)
+			assert.Equal(t, created[i].CreatedAt, msg.CreatedAt)
+			assert.Equal(t, created[i].Content, msg.Content)
+		}
+	})
+
+	t.Run("pages through messages using cursor", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		created := createTestMessages(t, store, 5)
+
+		// Act
+		page1, cursor1, err := store.GetMessages("bob", "conv-1", MessageQuery{Limit: 2})
+		require.NoError(t, err)
+		page2, cursor2, err := store.GetMessages("bob", "conv-1", MessageQuery{Limit: 2, Cursor: cursor1})
+		require.NoError(t, err)
+		page3, cursor3, err := store.GetMessages("bob", "conv-1", MessageQuery{Limit: 2, Cursor: cursor2})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, []string{created[0].ID, created[1].ID}, messageIDs(page1))
+		assert.Equal(t, []string{created[2].ID, created[3].ID}, messageIDs(page2))
+		assert.Equal(t, []string{created[4].ID}, messageIDs(page3))
+		assert.Empty(t, cursor3)
+	})
+
+	t.Run("walks backwards from before bound", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		created := createTestMessages(t, store, 4)
+
+		// Act
+		page1, cursor, err := store.GetMessages("bob", "conv-1", MessageQuery{Limit: 2, Before: created[3].CreatedAt})
+		require.NoError(t, err)
+		page2, _, err := store.GetMessages("bob", "conv-1", MessageQuery{Limit: 2, Before: created[3].CreatedAt, Cursor: cursor})
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, []string{created[2].ID, created[1].ID}, messageIDs(page1))
+		assert.Equal(t, []string{created[0].ID}, messageIDs(page2))
+	})
+
+	t.Run("returns only messages between after and before bounds", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		created := createTestMessages(t, store, 5)
+
+		// Act
+		query := MessageQuery{After: created[0].CreatedAt, Before: created[4].CreatedAt}
+		messages, _, err := store.GetMessages("bob", "conv-1", query)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{created[1].ID, created[2].ID, created[3].ID}, messageIDs(messages))
+	})
+
+	t.Run("returns error when user is not a participant", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		createTestMessages(t, store, 1)
+
+		// Act
+		_, _, err := store.GetMessages("mallory", "conv-1", MessageQuery{})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationUnauthorized)
+	})
+
+	t.Run("returns error when conversation doesn't exist", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+
+		// Act
+		_, _, err := store.GetMessages("bob", "non-existent", MessageQuery{})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrConversationNotFound)
+	})
+}
+
+func createTestMessages(t *testing.T, store *Store, n int) []Message {
+	t.Helper()
+
+	require.NoError(t, store.CreateConversation("conv-1", []string{"alice", "bob"}))
+	messages := make([]Message, 0, n)
+	for i := 0; i < n; i++ {
+		msg, err := store.CreateMessage("alice", 1, "conv-1", []byte{byte(i)})
+		require.NoError(t, err)
+		messages = append(messages, msg)
+		// Keep creation timestamps distinct so time bounds are unambiguous
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	return messages
+}
+
+func messageIDs(messages []Message) []string {
+	ids := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func testDB(t *testing.T) (*badger.DB, func()) {
+	t.Helper()
+
+	opts := badger.DefaultOptions("").
+		WithInMemory(true).
+		WithLogger(nil).
+		WithNumMemtables(1).
+		WithNumLevelZeroTables(1).
+		WithNumLevelZeroTablesStall(2).
+		WithValueLogFileSize(1 << 20)
+
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+
+	return db, func() {
+		err := db.Close()
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"signal-chat/internal/apitypes"
+)
+
+// CodecBinder binds protobuf request bodies and leaves the other content types to echo's binder
+type CodecBinder struct {
+	echo.DefaultBinder
+}
+
+// Bind binds the path parameters, the query parameters of GET, DELETE and HEAD requests and the body like echo does
+func (b *CodecBinder) Bind(i interface{}, c echo.Context) error {
+	codec, _ := apitypes.CodecForContentType(c.Request().Header.Get(echo.HeaderContentType))
+	if codec != apitypes.ProtobufCodec {
+		return b.DefaultBinder.Bind(i, c)
+	}
+
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+	method := c.Request().Method
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body").SetInternal(err)
+	}
+	if err := codec.Unmarshal(body, i); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+// respond sends the response as protobuf when the client accepts it and as JSON otherwise. Errors are always sent as
+// JSON by echo's error handler.
+func respond(c echo.Context, code int, resp any) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if !apitypes.AcceptsProtobuf(c.Request().Header.Get(echo.HeaderAccept)) {
+		return c.JSON(code, resp)
+	}
+
+	data, err := apitypes.ProtobufCodec.Marshal(resp)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encode response").SetInternal(err)
+	}
+	return c.Blob(code, apitypes.ContentTypeProtobuf, data)
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"github.com/dgraph-io/badger/v4"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"signal-chat/server/ws"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	// Parse command line flags
 	host := flag.String("host", "localhost", "Host to listen on")
 	port := flag.Int("port", 8080, "Port to listen on")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 15*time.Second, "Time given to running requests and open connections on shutdown")
+	metrics := flag.Bool("metrics", false, "Expose metrics on /debug/vars")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma separated CIDR ranges of reverse proxies trusted to set X-Forwarded-For")
+	brokerAddr := flag.String("broker", "", "Address to start a bus broker on, the server joins it")
+	busAddr := flag.String("bus", "", "Address of a bus broker to join, e.g. one started by another server process with -broker")
+	busNetwork := flag.String("bus-network", "tcp", "Network of the bus broker, either tcp or unix")
 	flag.Parse()
 
 	// Initialize database
@@ -21,13 +36,60 @@ func main() {
 	defer db.Close()
 
 	// Initialize server
-	server, err := NewServer(db)
+	config := DefaultServerConfig()
+	config.Metrics = *metrics
+	if *trustedProxies != "" {
+		config.TrustedProxies = strings.Split(*trustedProxies, ",")
+	}
+	if *brokerAddr != "" {
+		broker, err := ws.ListenBroker(*busNetwork, *brokerAddr)
+		if err != nil {
+			log.Fatalf("Failed to start bus broker: %v", err)
+		}
+		defer broker.Close()
+		if *busAddr == "" {
+			*busAddr = broker.Addr().String()
+		}
+	}
+	if *busAddr != "" {
+		bus, err := ws.DialBus(*busNetwork, *busAddr)
+		if err != nil {
+			log.Fatalf("Failed to join bus broker: %v", err)
+		}
+		defer bus.Close()
+		config.Bus = bus
+	}
+	server, err := NewServerWithConfig(db, config)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go server.RunInboxJanitor(ctx)
+
 	// Start server
-	if err := server.Start(*host, *port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start(*host, *port)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		// A second signal terminates the process right away
+		stop()
+	}
+
+	// The database is closed once the handlers are done with it
+	log.Printf("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server gracefully: %v", err)
 	}
 }
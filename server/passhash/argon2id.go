@@ -0,0 +1,119 @@
+package passhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2id hashes passwords with argon2id and encodes them in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+type Argon2id struct {
+	// Memory is the amount of memory used in KiB
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// NewArgon2id returns the argon2id scheme with the parameters recommended by RFC 9106 for memory constrained
+// environments
+func NewArgon2id() *Argon2id {
+	return &Argon2id{
+		Memory:  64 * 1024,
+		Time:    3,
+		Threads: 4,
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+}
+
+func (a *Argon2id) Hash(password []byte) ([]byte, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	encoded := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.Memory,
+		a.Time,
+		a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(encoded), nil
+}
+
+func (a *Argon2id) Verify(password, encoded []byte) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	computed := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+func (a *Argon2id) Supports(encoded []byte) bool {
+	return bytes.HasPrefix(encoded, []byte(argon2idPrefix))
+}
+
+func (a *Argon2id) NeedsRehash(encoded []byte) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.Memory ||
+		params.Time != a.Time ||
+		params.Threads != a.Threads ||
+		uint32(len(salt)) != a.SaltLen ||
+		uint32(len(key)) != a.KeyLen
+}
+
+func decodeArgon2id(encoded []byte) (Argon2id, []byte, []byte, error) {
+	// The leading $ produces an empty first part
+	parts := strings.Split(string(encoded), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrUnsupportedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+	}
+	if version != argon2.Version {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnsupportedHash, version)
+	}
+
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedHash, err)
+	}
+
+	return params, salt, key, nil
+}
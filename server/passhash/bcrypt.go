@@ -0,0 +1,38 @@
+package passhash
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Bcrypt struct {
+	Cost int
+}
+
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{Cost: cost}
+}
+
+func (b *Bcrypt) Hash(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, b.Cost)
+}
+
+func (b *Bcrypt) Verify(password, encoded []byte) error {
+	err := bcrypt.CompareHashAndPassword(encoded, password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatchedPassword
+	}
+	return err
+}
+
+func (b *Bcrypt) Supports(encoded []byte) bool {
+	return bytes.HasPrefix(encoded, []byte("$2a$")) ||
+		bytes.HasPrefix(encoded, []byte("$2b$")) ||
+		bytes.HasPrefix(encoded, []byte("$2y$"))
+}
+
+func (b *Bcrypt) NeedsRehash(encoded []byte) bool {
+	cost, err := bcrypt.Cost(encoded)
+	return err != nil || cost != b.Cost
+}
@@ -0,0 +1,74 @@
+package passhash
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMismatchedPassword = errors.New("password does not match the stored hash")
+	ErrUnsupportedHash    = errors.New("unsupported password hash format")
+)
+
+// Scheme is a password hashing algorithm together with its parameters
+type Scheme interface {
+	// Hash returns the encoded hash of the password. The encoding must carry everything needed to verify it later.
+	Hash(password []byte) ([]byte, error)
+	// Verify compares the password with the encoded hash in constant time and returns ErrMismatchedPassword
+	// when they don't match
+	Verify(password, encoded []byte) error
+	// Supports reports whether the encoded hash was produced by this algorithm
+	Supports(encoded []byte) bool
+	// NeedsRehash reports whether the encoded hash was produced with parameters different from the current ones
+	NeedsRehash(encoded []byte) bool
+}
+
+// Hasher hashes new passwords with the preferred scheme and verifies hashes produced by any of its known schemes
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+	// dummyHash is verified against when there is no stored hash so that the response time doesn't reveal
+	// whether a user exists
+	dummyHash []byte
+}
+
+// NewHasher creates a hasher that produces hashes with preferred and still accepts hashes of the legacy schemes
+func NewHasher(preferred Scheme, legacy ...Scheme) (*Hasher, error) {
+	dummyHash, err := preferred.Hash([]byte("dummy password"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy hash: %w", err)
+	}
+
+	return &Hasher{
+		preferred: preferred,
+		schemes:   append([]Scheme{preferred}, legacy...),
+		dummyHash: dummyHash,
+	}, nil
+}
+
+func (h *Hasher) Hash(password []byte) ([]byte, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify checks the password against the encoded hash. On success, it also reports whether the hash should be
+// replaced with one produced by the preferred scheme.
+func (h *Hasher) Verify(password, encoded []byte) (bool, error) {
+	for _, s := range h.schemes {
+		if !s.Supports(encoded) {
+			continue
+		}
+		if err := s.Verify(password, encoded); err != nil {
+			return false, err
+		}
+		return s != h.preferred || s.NeedsRehash(encoded), nil
+	}
+
+	return false, ErrUnsupportedHash
+}
+
+// VerifyDummy spends the same amount of work as Verify without any stored hash. It always returns
+// ErrMismatchedPassword.
+func (h *Hasher) VerifyDummy(password []byte) error {
+	_ = h.preferred.Verify(password, h.dummyHash)
+	return ErrMismatchedPassword
+}
@@ -0,0 +1,105 @@
+package passhash
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestHasher_Verify(t *testing.T) {
+	t.Run("accepts correct password for each scheme", func(t *testing.T) {
+		schemes := map[string]Scheme{
+			"bcrypt":   NewBcrypt(bcrypt.MinCost),
+			"argon2id": testArgon2id(),
+		}
+		for name, scheme := range schemes {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				hasher, err := NewHasher(scheme)
+				require.NoError(t, err)
+				encoded, err := hasher.Hash([]byte("secret"))
+				require.NoError(t, err)
+
+				// Act
+				rehash, err := hasher.Verify([]byte("secret"), encoded)
+
+				// Assert
+				require.NoError(t, err)
+				assert.False(t, rehash)
+			})
+		}
+	})
+
+	t.Run("rejects wrong password for each scheme", func(t *testing.T) {
+		schemes := map[string]Scheme{
+			"bcrypt":   NewBcrypt(bcrypt.MinCost),
+			"argon2id": testArgon2id(),
+		}
+		for name, scheme := range schemes {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				hasher, err := NewHasher(scheme)
+				require.NoError(t, err)
+				encoded, err := hasher.Hash([]byte("secret"))
+				require.NoError(t, err)
+
+				// Act
+				_, err = hasher.Verify([]byte("wrong"), encoded)
+
+				// Assert
+				assert.ErrorIs(t, err, ErrMismatchedPassword)
+			})
+		}
+	})
+
+	t.Run("requests rehash when hash was produced by a legacy scheme", func(t *testing.T) {
+		// Arrange
+		legacy := NewBcrypt(bcrypt.MinCost)
+		encoded, err := legacy.Hash([]byte("secret"))
+		require.NoError(t, err)
+		hasher, err := NewHasher(testArgon2id(), legacy)
+		require.NoError(t, err)
+
+		// Act
+		rehash, err := hasher.Verify([]byte("secret"), encoded)
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, rehash)
+	})
+
+	t.Run("requests rehash when parameters of the preferred scheme changed", func(t *testing.T) {
+		// Arrange
+		encoded, err := NewBcrypt(bcrypt.MinCost).Hash([]byte("secret"))
+		require.NoError(t, err)
+		hasher, err := NewHasher(NewBcrypt(bcrypt.MinCost + 1))
+		require.NoError(t, err)
+
+		// Act
+		rehash, err := hasher.Verify([]byte("secret"), encoded)
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, rehash)
+	})
+
+	t.Run("returns error for hash of unknown scheme", func(t *testing.T) {
+		// Arrange
+		hasher, err := NewHasher(NewBcrypt(bcrypt.MinCost))
+		require.NoError(t, err)
+
+		// Act
+		_, err = hasher.Verify([]byte("secret"), []byte("plaintext"))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrUnsupportedHash)
+	})
+}
+
+func testArgon2id() *Argon2id {
+	a := NewArgon2id()
+	a.Memory = 1024
+	a.Time = 1
+	return a
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is the persisted state of a single rate limited key
+type bucket struct {
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Failures is the number of consecutive failed attempts
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// refill adds the tokens accumulated since the last update
+func (b *bucket) refill(cfg Config, now time.Time) {
+	if cfg.RefillInterval > 0 {
+		elapsed := now.Sub(b.UpdatedAt)
+		b.Tokens = math.Min(float64(cfg.Capacity), b.Tokens+float64(elapsed)/float64(cfg.RefillInterval))
+	}
+	b.UpdatedAt = now
+}
+
+// retryAfter returns how long the caller has to wait before the next attempt is allowed
+func (b *bucket) retryAfter(cfg Config, now time.Time) time.Duration {
+	var wait time.Duration
+	if now.Before(b.LockedUntil) {
+		wait = b.LockedUntil.Sub(now)
+	}
+	if b.Tokens < 1 {
+		missing := time.Duration((1 - b.Tokens) * float64(cfg.RefillInterval))
+		wait = max(wait, missing)
+	}
+	return wait
+}
+
+// lockout returns the lockout duration after the current number of failures
+func (b *bucket) lockout(cfg Config) time.Duration {
+	if cfg.LockoutThreshold <= 0 || b.Failures < cfg.LockoutThreshold {
+		return 0
+	}
+
+	// Double the lockout with every failure past the threshold
+	exp := min(b.Failures-cfg.LockoutThreshold, 30)
+	d := cfg.LockoutBase * time.Duration(1<<exp)
+	if d > cfg.LockoutMax || d <= 0 {
+		return cfg.LockoutMax
+	}
+	return d
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"time"
+)
+
+// maxAttempts is the number of times an update of the buckets is retried when it conflicts with a concurrent one
+const maxAttempts = 10
+
+type Config struct {
+	// Capacity is the maximum number of attempts that can be made in a row
+	Capacity int
+	// RefillInterval is the time it takes to regain a single attempt
+	RefillInterval time.Duration
+	// LockoutThreshold is the number of consecutive failures after which the key gets locked. Zero disables lockout.
+	LockoutThreshold int
+	// LockoutBase is the duration of the first lockout. Every further failure doubles it up to LockoutMax.
+	LockoutBase time.Duration
+	LockoutMax  time.Duration
+}
+
+// Limiter is a token bucket rate limiter whose buckets are persisted in Badger so limits survive restarts
+type Limiter struct {
+	db     *badger.DB
+	prefix string
+	cfg    Config
+	now    func() time.Time
+}
+
+// NewLimiter creates a limiter whose keys are stored under the given prefix
+func NewLimiter(db *badger.DB, prefix string, cfg Config) *Limiter {
+	return &Limiter{
+		db:     db,
+		prefix: prefix,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Allow consumes an attempt for each of the keys. If any of them is exhausted or locked, nothing is consumed and the
+// time to wait before the next attempt is returned.
+func (l *Limiter) Allow(keys ...string) (time.Duration, error) {
+	var retryAfter time.Duration
+
+	err := l.update(func(txn *badger.Txn) error {
+		retryAfter = 0
+		now := l.now()
+		buckets := make([]bucket, len(keys))
+		for i, key := range keys {
+			b, err := l.getBucket(txn, key, now)
+			if err != nil {
+				return err
+			}
+			b.refill(l.cfg, now)
+			retryAfter = max(retryAfter, b.retryAfter(l.cfg, now))
+			buckets[i] = b
+		}
+		if retryAfter > 0 {
+			return nil
+		}
+
+		for i, key := range keys {
+			buckets[i].Tokens--
+			if err := l.setBucket(txn, key, buckets[i], now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return retryAfter, nil
+}
+
+// RecordFailure registers a failed attempt for each of the keys and locks those that failed too many times in a row
+func (l *Limiter) RecordFailure(keys ...string) error {
+	return l.update(func(txn *badger.Txn) error {
+		now := l.now()
+		for _, key := range keys {
+			b, err := l.getBucket(txn, key, now)
+			if err != nil {
+				return err
+			}
+			b.refill(l.cfg, now)
+			b.Failures++
+			if lockout := b.lockout(l.cfg); lockout > 0 {
+				b.LockedUntil = now.Add(lockout)
+			}
+			if err := l.setBucket(txn, key, b, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Reset clears the failures and lockout of the keys after a successful attempt
+func (l *Limiter) Reset(keys ...string) error {
+	return l.update(func(txn *badger.Txn) error {
+		now := l.now()
+		for _, key := range keys {
+			b, err := l.getBucket(txn, key, now)
+			if err != nil {
+				return err
+			}
+			b.Failures = 0
+			b.LockedUntil = time.Time{}
+			if err := l.setBucket(txn, key, b, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update runs the function in a transaction, again when the transaction conflicts with a concurrent attempt for the
+// same keys
+func (l *Limiter) update(fn func(txn *badger.Txn) error) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = l.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to update rate limit buckets: %w", err)
+}
+
+func (l *Limiter) getBucket(txn *badger.Txn, key string, now time.Time) (bucket, error) {
+	item, err := txn.Get(l.itemKey(key))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return bucket{Tokens: float64(l.cfg.Capacity), UpdatedAt: now}, nil
+		}
+		return bucket{}, err
+	}
+
+	var b bucket
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &b)
+	})
+	if err != nil {
+		return bucket{}, fmt.Errorf("failed to unmarshall rate limit bucket: %w", err)
+	}
+
+	return b, nil
+}
+
+func (l *Limiter) setBucket(txn *badger.Txn, key string, b bucket, now time.Time) error {
+	bucketJSON, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshall rate limit bucket: %w", err)
+	}
+
+	// Keep the bucket only as long as it carries any state that differs from a fresh one
+	missing := float64(l.cfg.Capacity) - b.Tokens
+	ttl := time.Duration(missing*float64(l.cfg.RefillInterval)) + l.cfg.RefillInterval
+	if b.Failures > 0 {
+		ttl = max(ttl, l.cfg.LockoutMax)
+	}
+	ttl = max(ttl, b.LockedUntil.Sub(now))
+
+	entry := badger.NewEntry(l.itemKey(key), bucketJSON)
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+	return txn.SetEntry(entry)
+}
+
+func (l *Limiter) itemKey(key string) []byte {
+	return []byte("ratelimit#" + l.prefix + ":" + key)
+}
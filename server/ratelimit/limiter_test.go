@@ -0,0 +1,229 @@
+package ratelimit
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Run("allows attempts up to capacity and refills over time", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, clock := testLimiter(db, Config{Capacity: 2, RefillInterval: time.Minute})
+
+		// Act
+		first, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		second, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		third, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+		afterRefill, err := limiter.Allow("alice")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Zero(t, first)
+		assert.Zero(t, second)
+		assert.Equal(t, time.Minute, third)
+		assert.Zero(t, afterRefill)
+	})
+
+	t.Run("doesn't consume any key when one of them is exhausted", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, _ := testLimiter(db, Config{Capacity: 1, RefillInterval: time.Minute})
+		_, err := limiter.Allow("ip")
+		require.NoError(t, err)
+
+		// Act
+		retryAfter, err := limiter.Allow("alice", "ip")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, time.Minute, retryAfter)
+		retryAfter, err = limiter.Allow("alice")
+		require.NoError(t, err)
+		assert.Zero(t, retryAfter, "alice's bucket should have been left untouched")
+	})
+
+	t.Run("state survives a new limiter on the same database", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		cfg := Config{Capacity: 1, RefillInterval: time.Hour}
+		limiter, _ := testLimiter(db, cfg)
+		_, err := limiter.Allow("alice")
+		require.NoError(t, err)
+
+		// Act
+		restarted := NewLimiter(db, "test", cfg)
+		retryAfter, err := restarted.Allow("alice")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Greater(t, retryAfter, time.Duration(0))
+	})
+
+	t.Run("retries attempts that conflict with concurrent ones for the same key", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, _ := testLimiter(db, Config{Capacity: 3, RefillInterval: time.Minute})
+
+		// Act
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		errs := make(chan error, 8)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				retryAfter, err := limiter.Allow("alice")
+				if err != nil {
+					errs <- err
+					return
+				}
+				if retryAfter == 0 {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Assert
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(3), allowed.Load())
+	})
+}
+
+func TestLimiter_RecordFailure(t *testing.T) {
+	t.Run("locks key with exponentially growing lockout", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, clock := testLimiter(db, Config{
+			Capacity:         100,
+			RefillInterval:   time.Second,
+			LockoutThreshold: 3,
+			LockoutBase:      time.Minute,
+			LockoutMax:       time.Hour,
+		})
+		for i := 0; i < 2; i++ {
+			require.NoError(t, limiter.RecordFailure("alice"))
+		}
+		retryAfter, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		require.Zero(t, retryAfter, "key should not be locked below the threshold")
+
+		// Act
+		require.NoError(t, limiter.RecordFailure("alice"))
+		firstLockout, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		clock.Advance(firstLockout)
+		require.NoError(t, limiter.RecordFailure("alice"))
+		secondLockout, err := limiter.Allow("alice")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, time.Minute, firstLockout)
+		assert.Equal(t, 2*time.Minute, secondLockout)
+	})
+
+	t.Run("caps lockout at maximum", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, _ := testLimiter(db, Config{
+			Capacity:         100,
+			RefillInterval:   time.Second,
+			LockoutThreshold: 1,
+			LockoutBase:      time.Minute,
+			LockoutMax:       5 * time.Minute,
+		})
+
+		// Act
+		for i := 0; i < 10; i++ {
+			require.NoError(t, limiter.RecordFailure("alice"))
+		}
+		retryAfter, err := limiter.Allow("alice")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, retryAfter)
+	})
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	t.Run("clears lockout after successful attempt", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		limiter, _ := testLimiter(db, Config{
+			Capacity:         100,
+			RefillInterval:   time.Second,
+			LockoutThreshold: 1,
+			LockoutBase:      time.Minute,
+			LockoutMax:       time.Hour,
+		})
+		require.NoError(t, limiter.RecordFailure("alice"))
+
+		// Act
+		err := limiter.Reset("alice")
+
+		// Assert
+		require.NoError(t, err)
+		retryAfter, err := limiter.Allow("alice")
+		require.NoError(t, err)
+		assert.Zero(t, retryAfter)
+	})
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func testLimiter(db *badger.DB, cfg Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewLimiter(db, "test", cfg)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func testDB(t *testing.T) (*badger.DB, func()) {
+	t.Helper()
+
+	opts := badger.DefaultOptions("").
+		WithInMemory(true).
+		WithLogger(nil).
+		WithNumMemtables(1).
+		WithNumLevelZeroTables(1).
+		WithNumLevelZeroTablesStall(2).
+		WithValueLogFileSize(1 << 20)
+
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+
+	return db, func() {
+		err := db.Close()
+		require.NoError(t, err)
+	}
+}
@@ -1,23 +1,37 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/crypto/bcrypt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"signal-chat/internal/apitypes"
 	"signal-chat/server/conversation"
+	"signal-chat/server/passhash"
+	"signal-chat/server/ratelimit"
+	"signal-chat/server/session"
 	"signal-chat/server/ws"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Devices asking for protobuf get it, the others JSON
+	Subprotocols: []string{apitypes.WSSubprotocolProtobuf},
 	// Allow clients from any origin (only for development; specify origins in production)
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -25,16 +39,31 @@ var upgrader = websocket.Upgrader{
 }
 
 type Authenticator interface {
-	GenerateToken(userID string) (string, error)
-	Authenticate(r *http.Request) (string, error)
+	GenerateToken(userID string, deviceID uint32, deviceLabel string) (SessionTokens, error)
+	Authenticate(r *http.Request) (Identity, error)
+	RefreshToken(refreshToken string) (SessionTokens, error)
+	ListSessions(userID string) ([]session.Session, error)
+	RevokeSession(userID, sessionID string) error
+	RevokeDevice(userID string, deviceID uint32) error
 	RevokeToken(r *http.Request) error
 }
 
 type WebsocketManager interface {
-	RegisterClient(clientID string, conn ws.Connection) error
-	UnregisterClient(clientID string)
-	BroadcastNewConversation(senderID string, req apitypes.CreateConversationRequest) error
-	BroadcastNewMessage(senderID, messageID string, req apitypes.SendMessageRequest) error
+	SetRequestHandler(handler ws.RequestHandler)
+	RegisterClient(userID string, deviceID uint32, cursor uint64, conn ws.Connection) error
+	UnregisterClient(userID string, deviceID uint32)
+	BroadcastNewConversation(senderID string, senderDeviceID uint32, req apitypes.CreateConversationRequest) error
+	BroadcastNewMessage(senderID string, senderDeviceID uint32, messageID string, createdAt, expiresAt int64, req apitypes.SendMessageRequest) error
+	BroadcastParticipantsAdded(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.AddParticipantsRequest) error
+	BroadcastParticipantRemoved(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.RemoveParticipantRequest) error
+	BroadcastSenderKeys(senderID string, senderDeviceID uint32, req apitypes.DistributeSenderKeysRequest) error
+	BroadcastExpirationTimer(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.SetExpirationTimerRequest) error
+	BroadcastReceipt(senderID string, senderDeviceID uint32, req apitypes.SendReceiptRequest) error
+	NotifyPreKeysLow(userID string, deviceID uint32, remaining int) error
+	CheckInboxes(senderID string, senderDeviceID uint32, conversationID string) error
+	Presence(userIDs []string) ([]apitypes.Presence, error)
+	SetHideLastSeen(userID string, hide bool) error
+	Shutdown(ctx context.Context) error
 }
 
 type Server struct {
@@ -43,19 +72,81 @@ type Server struct {
 	conversationStore *conversation.Store
 	auth              Authenticator
 	wsManager         WebsocketManager
+	signInLimiter     *ratelimit.Limiter
+	signUpLimiter     *ratelimit.Limiter
+	// preKeyLowWatermark is the number of remaining one-time pre-keys below which the owner is asked to upload more
+	preKeyLowWatermark int
+	inboxJanitor       *ws.InboxJanitor
+	inboxSweepInterval time.Duration
 }
 
 type ServerConfig struct {
 	ReadTimeout  int
 	WriteTimeout int
 	MaxBodySize  string
+	// SessionTTL is the number of seconds of inactivity after which an auth token expires
+	SessionTTL int
+	// RefreshTTL is the number of seconds a refresh token stays valid after it has been issued
+	RefreshTTL int
+	// PasswordHash is the algorithm used to hash new passwords, either "bcrypt" or "argon2id". Hashes produced by
+	// the other algorithm are still accepted and upgraded on the next sign-in.
+	PasswordHash string
+	// SignInBurst is the number of sign-in attempts that can be made in a row per username and per client IP
+	SignInBurst int
+	// SignInRefill is the number of seconds it takes to regain a single sign-in attempt
+	SignInRefill int
+	// SignUpBurst is the number of accounts that can be registered in a row from a single client IP
+	SignUpBurst int
+	// SignUpRefill is the number of seconds it takes to regain a single sign-up attempt
+	SignUpRefill int
+	// TrustedProxies are the IP ranges, in CIDR notation, of the reverse proxies whose X-Forwarded-For header tells the
+	// client IP. Without any, the client IP is the address of the connection and the header is ignored.
+	TrustedProxies []string
+	// LockoutThreshold is the number of consecutive failed sign-ins after which the username or client IP is locked
+	LockoutThreshold int
+	// LockoutBase is the number of seconds of the first lockout. Every further failure doubles it up to LockoutMax.
+	LockoutBase int
+	LockoutMax  int
+	// PreKeyLowWatermark is the number of remaining one-time pre-keys below which the owner is notified to upload more
+	PreKeyLowWatermark int
+	// Bus links the websocket managers of the server instances. Without one, messages only reach devices connected to
+	// this instance live.
+	Bus ws.Bus
+	// InboxMaxMessages and InboxMaxBytes bound the messages waiting for a device to acknowledge them, zero disables the
+	// limit. InboxEviction decides what happens to messages for a full inbox, either "drop-oldest" or "reject".
+	InboxMaxMessages int
+	InboxMaxBytes    int
+	InboxEviction    string
+	// InboxTTL is the number of seconds after which a message that wasn't acknowledged is dropped, zero keeps it
+	InboxTTL int
+	// InboxSweepInterval is the number of seconds between two reports of the inbox depths, which also delete the
+	// inboxes of removed devices
+	InboxSweepInterval int
+	// Metrics exposes the expvar metrics, including the inbox depths, on /debug/vars
+	Metrics bool
 }
 
 func DefaultServerConfig() ServerConfig {
 	return ServerConfig{
-		ReadTimeout:  60,
-		WriteTimeout: 60,
-		MaxBodySize:  "10MB",
+		ReadTimeout:        60,
+		WriteTimeout:       60,
+		MaxBodySize:        "10MB",
+		SessionTTL:         24 * 60 * 60,
+		RefreshTTL:         30 * 24 * 60 * 60,
+		PasswordHash:       "bcrypt",
+		SignInBurst:        10,
+		SignInRefill:       60,
+		SignUpBurst:        5,
+		SignUpRefill:       60 * 60,
+		LockoutThreshold:   5,
+		LockoutBase:        60,
+		LockoutMax:         24 * 60 * 60,
+		PreKeyLowWatermark: 10,
+		InboxMaxMessages:   10000,
+		InboxMaxBytes:      100 << 20,
+		InboxEviction:      "drop-oldest",
+		InboxTTL:           30 * 24 * 60 * 60,
+		InboxSweepInterval: 60 * 60,
 	}
 }
 
@@ -71,8 +162,16 @@ func NewServerWithConfig(db *badger.DB, config ServerConfig) (*Server, error) {
 	e.Server.WriteTimeout = time.Duration(config.WriteTimeout) * time.Second
 	e.Server.MaxHeaderBytes = 1 << 20 // 1MB
 
-	// Set custom validator
+	// The client IP keys the sign-in and sign-up limits, so it's only taken from headers set by trusted proxies
+	ipExtractor, err := newIPExtractor(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	e.IPExtractor = ipExtractor
+
+	// Set custom validator and a binder that also decodes protobuf bodies
 	e.Validator = NewCustomValidator()
+	e.Binder = &CodecBinder{}
 
 	// Add middleware
 	e.Use(middleware.Logger())
@@ -80,33 +179,157 @@ func NewServerWithConfig(db *badger.DB, config ServerConfig) (*Server, error) {
 	e.Use(middleware.CORS())
 	e.Use(middleware.BodyLimit(config.MaxBodySize))
 
+	hasher, err := newPasswordHasher(config.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	userStore := NewUserStore(db, hasher)
+	migrated, err := userStore.MigrateKeyBundles()
+	if err != nil {
+		return nil, err
+	}
+	if migrated > 0 {
+		log.Printf("Moved %d key bundles to the primary device of their user", migrated)
+	}
 	convStore := conversation.NewStore(db)
+	authManager := NewAuthManager(
+		session.NewStore(db),
+		time.Duration(config.SessionTTL)*time.Second,
+		time.Duration(config.RefreshTTL)*time.Second,
+	)
+
+	signInLimiter := ratelimit.NewLimiter(db, "signin", ratelimit.Config{
+		Capacity:         config.SignInBurst,
+		RefillInterval:   time.Duration(config.SignInRefill) * time.Second,
+		LockoutThreshold: config.LockoutThreshold,
+		LockoutBase:      time.Duration(config.LockoutBase) * time.Second,
+		LockoutMax:       time.Duration(config.LockoutMax) * time.Second,
+	})
+	signUpLimiter := ratelimit.NewLimiter(db, "signup", ratelimit.Config{
+		Capacity:       config.SignUpBurst,
+		RefillInterval: time.Duration(config.SignUpRefill) * time.Second,
+	})
+
+	bus := config.Bus
+	if bus == nil {
+		bus = ws.NewLocalBus()
+	}
+
+	inboxLimits, err := newInboxLimits(config)
+	if err != nil {
+		return nil, err
+	}
+	wsManager := ws.NewManagerWithBus(db, convStore, userStore, bus)
+	wsManager.SetInboxLimits(inboxLimits)
+	migrated, err = wsManager.MigratePresence()
+	if err != nil {
+		return nil, err
+	}
+	if migrated > 0 {
+		log.Printf("Moved %d presence records to their new keys", migrated)
+	}
 
 	server := &Server{
-		router:            e,
-		userStore:         &UserStore{db: db},
-		conversationStore: convStore,
-		auth:              NewAuthManager(),
-		wsManager:         ws.NewManager(db, convStore),
+		router:             e,
+		userStore:          userStore,
+		conversationStore:  convStore,
+		auth:               authManager,
+		wsManager:          wsManager,
+		signInLimiter:      signInLimiter,
+		signUpLimiter:      signUpLimiter,
+		preKeyLowWatermark: config.PreKeyLowWatermark,
+		inboxJanitor:       ws.NewInboxJanitor(db, userStore),
+		inboxSweepInterval: time.Duration(config.InboxSweepInterval) * time.Second,
 	}
+	server.wsManager.SetRequestHandler(server.handleWebSocketRequest)
 
 	// Register routes
 	e.GET(apitypes.EndpointUser, server.handleGetUser)
 	e.GET(apitypes.EndpointPreKeyBundle, server.handleGetUserKeys)
+	e.GET(apitypes.EndpointUserDevices, server.handleGetDevices)
 	e.GET(apitypes.EndpointUsers, server.handleGetAllUsers)
+	e.POST(apitypes.EndpointPreKeys, server.handleUploadPreKeys)
+	e.POST(apitypes.EndpointSignedPreKey, server.handleUploadSignedPreKey)
 
 	e.POST(apitypes.EndpointSignUp, server.handleSignUp)
 	e.POST(apitypes.EndpointSignIn, server.handleSignIn)
 	e.POST(apitypes.EndpointSignOut, server.handleSignOut)
+	e.POST(apitypes.EndpointRefresh, server.handleRefreshSession)
+	e.GET(apitypes.EndpointSessions, server.handleGetSessions)
+	e.DELETE(apitypes.EndpointSession, server.handleRevokeSession)
+	e.DELETE(apitypes.EndpointDevice, server.handleRemoveDevice)
 	e.POST(apitypes.EndpointConversations, server.handleCreateConversation)
 	e.POST(apitypes.EndpointMessages, server.handleCreateMessage)
+	e.POST(apitypes.EndpointReceipts, server.handleSendReceipt)
+	e.GET(apitypes.EndpointPresence, server.handleGetPresence)
+	e.POST(apitypes.EndpointPresenceSettings, server.handleUpdatePresenceSettings)
+	e.GET(apitypes.EndpointConversationMessages, server.handleGetMessages)
+	e.DELETE(apitypes.EndpointConversationMessage, server.handleDeleteMessage)
+	e.POST(apitypes.EndpointConversationParticipants, server.handleAddParticipants)
+	e.DELETE(apitypes.EndpointConversationParticipant, server.handleRemoveParticipant)
+	e.POST(apitypes.EndpointConversationKeys, server.handleDistributeSenderKeys)
+	e.PUT(apitypes.EndpointConversationTimer, server.handleSetExpirationTimer)
 
 	// Add WebSocket endpoint
 	e.GET("/ws", server.handleWebSocketConnection)
 
+	if config.Metrics {
+		e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+	}
+
 	return server, nil
 }
 
+func newIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+func newInboxLimits(config ServerConfig) (ws.InboxLimits, error) {
+	limits := ws.InboxLimits{
+		MaxMessages: config.InboxMaxMessages,
+		MaxBytes:    config.InboxMaxBytes,
+		TTL:         time.Duration(config.InboxTTL) * time.Second,
+	}
+
+	switch config.InboxEviction {
+	case "drop-oldest":
+		limits.Eviction = ws.EvictOldest
+	case "reject":
+		limits.Eviction = ws.RejectNew
+	default:
+		return ws.InboxLimits{}, fmt.Errorf("unsupported inbox eviction policy: %q", config.InboxEviction)
+	}
+
+	return limits, nil
+}
+
+func newPasswordHasher(algorithm string) (*passhash.Hasher, error) {
+	bcryptScheme := passhash.NewBcrypt(bcrypt.DefaultCost)
+	argon2idScheme := passhash.NewArgon2id()
+
+	switch algorithm {
+	case "bcrypt":
+		return passhash.NewHasher(bcryptScheme, argon2idScheme)
+	case "argon2id":
+		return passhash.NewHasher(argon2idScheme, bcryptScheme)
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %q", algorithm)
+	}
+}
+
 // Start starts the server on the specified host and port
 func (s *Server) Start(host string, port int) error {
 	addr := fmt.Sprintf("%s:%d", host, port)
@@ -114,6 +337,32 @@ func (s *Server) Start(host string, port int) error {
 	return s.router.Start(addr)
 }
 
+// RunInboxJanitor reports the depth of the inboxes and deletes the ones of removed devices periodically, until the
+// context is done
+func (s *Server) RunInboxJanitor(ctx context.Context) {
+	if s.inboxSweepInterval <= 0 {
+		return
+	}
+	s.inboxJanitor.Run(ctx, s.inboxSweepInterval)
+}
+
+// Shutdown stops accepting connections and requests, waits for the running handlers and tells the connected devices
+// that the server is restarting. It returns once the websocket connections are closed or the context is done, the
+// database can be closed afterwards.
+func (s *Server) Shutdown(ctx context.Context) error {
+	routerErr := s.router.Shutdown(ctx)
+	if routerErr != nil {
+		routerErr = fmt.Errorf("failed to stop http server: %w", routerErr)
+	}
+
+	wsErr := s.wsManager.Shutdown(ctx)
+	if wsErr != nil {
+		wsErr = fmt.Errorf("failed to close websocket connections: %w", wsErr)
+	}
+
+	return errors.Join(routerErr, wsErr)
+}
+
 func (s *Server) handleSignUp(c echo.Context) error {
 	var req apitypes.SignUpRequest
 	if err := c.Bind(&req); err != nil {
@@ -123,6 +372,10 @@ func (s *Server) handleSignUp(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if err := s.throttle(c, s.signUpLimiter, "ip:"+c.RealIP()); err != nil {
+		return err
+	}
+
 	usr, err := s.userStore.CreateUser(req.Username, req.Password, req.KeyBundle)
 	if err != nil {
 		if errors.Is(err, ErrEmailExists) {
@@ -131,16 +384,19 @@ func (s *Server) handleSignUp(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create new user")
 	}
 
-	token, err := s.auth.GenerateToken(usr.ID)
+	tokens, err := s.auth.GenerateToken(usr.ID, PrimaryDeviceID, deviceLabel(c, req.DeviceLabel))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate user token")
 	}
 
 	resp := apitypes.SignUpResponse{
-		UserID:    usr.ID,
-		AuthToken: token,
+		UserID:       usr.ID,
+		DeviceID:     PrimaryDeviceID,
+		AuthToken:    tokens.AuthToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Unix(),
 	}
-	return c.JSON(http.StatusOK, resp)
+	return respond(c, http.StatusOK, resp)
 }
 
 func (s *Server) handleSignIn(c echo.Context) error {
@@ -152,24 +408,57 @@ func (s *Server) handleSignIn(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	limiterKeys := []string{"user:" + req.Username, "ip:" + c.RealIP()}
+	if err := s.throttle(c, s.signInLimiter, limiterKeys...); err != nil {
+		return err
+	}
+
 	usr, err := s.userStore.VerifyCredentials(req.Username, req.Password)
 	if err != nil {
 		if errors.Is(err, ErrInvalidCredentials) {
+			if err := s.signInLimiter.RecordFailure(limiterKeys...); err != nil {
+				log.Printf("Failed to record failed sign-in: %v", err)
+			}
 			return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify credentials")
 	}
+	// Only the username is cleared, failures from the same IP may be guesses at other accounts
+	if err := s.signInLimiter.Reset(limiterKeys[0]); err != nil {
+		log.Printf("Failed to reset sign-in failures: %v", err)
+	}
+
+	// Sign in on a known device or register a new one with its own key bundle
+	deviceID := req.DeviceID
+	if req.KeyBundle != nil {
+		deviceID, err = s.userStore.AddDevice(usr.ID, *req.KeyBundle)
+	} else {
+		_, err = s.userStore.GetDevice(usr.ID, deviceID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDeviceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, ErrTooManyDevices):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to register device")
+		}
+	}
 
-	token, err := s.auth.GenerateToken(usr.ID)
+	tokens, err := s.auth.GenerateToken(usr.ID, deviceID, deviceLabel(c, req.DeviceLabel))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate user token")
 	}
 
 	resp := apitypes.SignInResponse{
-		UserID:    usr.ID,
-		AuthToken: token,
+		UserID:       usr.ID,
+		DeviceID:     deviceID,
+		AuthToken:    tokens.AuthToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Unix(),
 	}
-	return c.JSON(http.StatusOK, resp)
+	return respond(c, http.StatusOK, resp)
 }
 
 func (s *Server) handleSignOut(c echo.Context) error {
@@ -180,6 +469,107 @@ func (s *Server) handleSignOut(c echo.Context) error {
 	return echo.NewHTTPError(http.StatusOK)
 }
 
+func (s *Server) handleRefreshSession(c echo.Context) error {
+	var req apitypes.RefreshSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tokens, err := s.auth.RefreshToken(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTokenUnauthorized), errors.Is(err, ErrTokenExpired):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired refresh token")
+		case errors.Is(err, ErrEmptyToken), errors.Is(err, ErrDecodeToken):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to refresh session")
+		}
+	}
+
+	resp := apitypes.RefreshSessionResponse{
+		AuthToken:    tokens.AuthToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Unix(),
+	}
+	return respond(c, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetSessions(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	sessions, err := s.auth.ListSessions(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list sessions")
+	}
+
+	resp := apitypes.GetSessionsResponse{Sessions: make([]apitypes.Session, 0, len(sessions))}
+	for _, sess := range sessions {
+		resp.Sessions = append(resp.Sessions, apitypes.Session{
+			ID:          sess.ID,
+			DeviceID:    sess.DeviceID,
+			DeviceLabel: sess.DeviceLabel,
+			IssuedAt:    sess.IssuedAt.Unix(),
+			ExpiresAt:   sess.ExpiresAt.Unix(),
+			LastSeenAt:  sess.LastSeenAt.Unix(),
+		})
+	}
+	return respond(c, http.StatusOK, resp)
+}
+
+func (s *Server) handleRevokeSession(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	err := s.auth.RevokeSession(userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleRemoveDevice(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	deviceID, paramErr := deviceIDParam(c, "id")
+	if paramErr != nil {
+		return paramErr
+	}
+
+	if err := s.userStore.RemoveDevice(userID, deviceID); err != nil {
+		switch {
+		case errors.Is(err, ErrDeviceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, ErrPrimaryDevice):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove device")
+		}
+	}
+
+	if err := s.auth.RevokeDevice(userID, deviceID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke device sessions")
+	}
+	s.wsManager.UnregisterClient(userID, deviceID)
+
+	return c.NoContent(http.StatusOK)
+}
+
 func (s *Server) handleGetUser(c echo.Context) error {
 	if _, err := s.authenticate(c); err != nil {
 		return err
@@ -195,7 +585,7 @@ func (s *Server) handleGetUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, apitypes.GetUserResponse{User: user})
+	return respond(c, http.StatusOK, apitypes.GetUserResponse{User: user})
 }
 
 func (s *Server) handleGetAllUsers(c echo.Context) error {
@@ -208,7 +598,7 @@ func (s *Server) handleGetAllUsers(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, apitypes.GetAllUsersResponse{Users: users})
+	return respond(c, http.StatusOK, apitypes.GetAllUsersResponse{Users: users})
 }
 
 func (s *Server) handleGetUserKeys(c echo.Context) error {
@@ -217,7 +607,36 @@ func (s *Server) handleGetUserKeys(c echo.Context) error {
 	}
 
 	id := c.Param("id")
-	bundle, err := s.userStore.GetPreKeyBundle(id)
+	deviceID, paramErr := deviceIDParam(c, "deviceId")
+	if paramErr != nil {
+		return paramErr
+	}
+
+	bundle, remaining, err := s.userStore.GetPreKeyBundle(id, deviceID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrDeviceNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// Notify the owner once when the supply drops below the watermark and again when it runs out
+	crossedWatermark := remaining < s.preKeyLowWatermark && remaining+1 >= s.preKeyLowWatermark
+	if crossedWatermark || remaining == 0 {
+		if err := s.wsManager.NotifyPreKeysLow(id, deviceID, remaining); err != nil {
+			log.Printf("Failed to notify device %d of user %s about low pre-keys: %v", deviceID, id, err)
+		}
+	}
+
+	return respond(c, http.StatusOK, apitypes.GetPreKeyBundleResponse{PreKeyBundle: bundle})
+}
+
+func (s *Server) handleGetDevices(c echo.Context) error {
+	if _, err := s.authenticate(c); err != nil {
+		return err
+	}
+
+	devices, err := s.userStore.GetDevices(c.Param("id"))
 	if err != nil {
 		if errors.Is(err, ErrUserNotFound) {
 			return echo.NewHTTPError(http.StatusNotFound)
@@ -225,11 +644,70 @@ func (s *Server) handleGetUserKeys(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, apitypes.GetPreKeyBundleResponse{PreKeyBundle: bundle})
+	return respond(c, http.StatusOK, apitypes.GetDevicesResponse{Devices: devices})
+}
+
+func (s *Server) handleUploadPreKeys(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.UploadPreKeysRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	count, err := s.userStore.AddPreKeys(identity.UserID, identity.DeviceID, req.PreKeys)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrDeviceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, ErrDuplicatePreKey):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, ErrTooManyPreKeys):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return respond(c, http.StatusOK, apitypes.UploadPreKeysResponse{Count: count})
+}
+
+func (s *Server) handleUploadSignedPreKey(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.UploadSignedPreKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.userStore.SetSignedPreKey(identity.UserID, identity.DeviceID, req.SignedPreKey); err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrDeviceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, ErrInvalidSignature):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
 }
 
 func (s *Server) handleCreateConversation(c echo.Context) error {
-	userID, authErr := s.authenticate(c)
+	identity, authErr := s.authenticateDevice(c)
 	if authErr != nil {
 		return authErr
 	}
@@ -242,30 +720,43 @@ func (s *Server) handleCreateConversation(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	resp, httpErr := s.createConversation(identity, req)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return respond(c, http.StatusOK, resp)
+}
+
+// createConversation creates the conversation and notifies its participants, for both the HTTP and websocket API
+func (s *Server) createConversation(identity Identity, req apitypes.CreateConversationRequest) (apitypes.CreateConversationResponse, *echo.HTTPError) {
+	// Other devices of the creator are listed as participants too, so they get the creator's sender key
 	participantIDs := make([]string, 0, len(req.OtherParticipants)+1)
-	participantIDs = append(participantIDs, userID)
+	participantIDs = append(participantIDs, identity.UserID)
 	for _, r := range req.OtherParticipants {
-		participantIDs = append(participantIDs, r.ID)
+		if !slices.Contains(participantIDs, r.ID) {
+			participantIDs = append(participantIDs, r.ID)
+		}
 	}
 
 	err := s.conversationStore.CreateConversation(req.ConversationID, participantIDs)
 	if err != nil {
 		if errors.Is(err, conversation.ErrConversationExists) {
-			return echo.NewHTTPError(http.StatusConflict, "failed to create conversation")
+			return apitypes.CreateConversationResponse{}, echo.NewHTTPError(http.StatusConflict, "failed to create conversation")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create conversation")
+		return apitypes.CreateConversationResponse{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to create conversation")
 	}
 
 	// Broadcast the new conversation to all participants
-	if err := s.wsManager.BroadcastNewConversation(userID, req); err != nil {
+	if err := s.wsManager.BroadcastNewConversation(identity.UserID, identity.DeviceID, req); err != nil {
 		log.Printf("Failed to broadcast new conversation: %v", err)
 	}
 
-	return c.NoContent(http.StatusOK)
+	return apitypes.CreateConversationResponse{ConversationID: req.ConversationID}, nil
 }
 
 func (s *Server) handleCreateMessage(c echo.Context) error {
-	userID, authErr := s.authenticate(c)
+	identity, authErr := s.authenticateDevice(c)
 	if authErr != nil {
 		return authErr
 	}
@@ -278,66 +769,534 @@ func (s *Server) handleCreateMessage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	messageID, err := s.conversationStore.CreateMessage(userID, req.ConversationID, req.Content)
+	resp, httpErr := s.createMessage(identity, req)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return respond(c, http.StatusOK, resp)
+}
+
+// createMessage stores the message and notifies the conversation participants, for both the HTTP and websocket API
+func (s *Server) createMessage(identity Identity, req apitypes.SendMessageRequest) (apitypes.SendMessageResponse, *echo.HTTPError) {
+	conv, err := s.conversationStore.GetConversation(req.ConversationID)
 	if err != nil {
 		if errors.Is(err, conversation.ErrConversationNotFound) {
-			return echo.NewHTTPError(http.StatusNotFound)
+			return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusNotFound)
+		}
+		return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to create message")
+	}
+	// Only participants may learn whether the inboxes of the conversation are full
+	if !conv.HasParticipant(identity.UserID) {
+		return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	// Full inboxes that reject new messages are checked before the message is stored, so that it's either delivered
+	// to every device or not sent at all
+	if err := s.wsManager.CheckInboxes(identity.UserID, identity.DeviceID, req.ConversationID); errors.Is(err, ws.ErrInboxFull) {
+		return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusInsufficientStorage, ws.ErrInboxFull.Error())
+	}
+
+	msg, err := s.conversationStore.CreateReference(identity.UserID, identity.DeviceID, req.ConversationID, req.RefersTo, req.Content)
+	if err != nil {
+		if errors.Is(err, conversation.ErrConversationNotFound) {
+			return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusNotFound)
 		} else if errors.Is(err, conversation.ErrConversationUnauthorized) {
-			return echo.NewHTTPError(http.StatusUnauthorized)
+			return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusUnauthorized)
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create message")
+		return apitypes.SendMessageResponse{}, echo.NewHTTPError(http.StatusInternalServerError, "failed to create message")
 	}
 
 	// Broadcast the new message to all participants
-	if err := s.wsManager.BroadcastNewMessage(userID, messageID, req); err != nil {
+	if err := s.wsManager.BroadcastNewMessage(identity.UserID, identity.DeviceID, msg.ID, msg.CreatedAt, msg.ExpiresAt, req); err != nil {
 		log.Printf("Failed to broadcast new message: %v", err)
 		// Continue even if broadcasting fails
 	}
 
-	return c.JSON(http.StatusOK, apitypes.SendMessageResponse{MessageID: messageID})
+	return apitypes.SendMessageResponse{MessageID: msg.ID, CreatedAt: msg.CreatedAt, ExpiresAt: msg.ExpiresAt}, nil
 }
 
-func (s *Server) handleWebSocketConnection(c echo.Context) error {
+func (s *Server) handleSendReceipt(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.SendReceiptRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if httpErr := s.sendReceipt(identity, req); httpErr != nil {
+		return httpErr
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// sendReceipt relays the receipt to the devices of the message author, for both the HTTP and websocket API
+func (s *Server) sendReceipt(identity Identity, req apitypes.SendReceiptRequest) *echo.HTTPError {
+	conv, err := s.conversationStore.GetConversation(req.ConversationID)
+	if err != nil {
+		if errors.Is(err, conversation.ErrConversationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to send receipt")
+	}
+	if !conv.HasParticipant(identity.UserID) {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	if !conv.HasParticipant(req.AuthorID) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("user %s is not a participant of the conversation", req.AuthorID))
+	}
+
+	if err := s.wsManager.BroadcastReceipt(identity.UserID, identity.DeviceID, req); err != nil {
+		log.Printf("Failed to broadcast receipt: %v", err)
+	}
+
+	return nil
+}
+
+// handleGetPresence returns the presence of the requested users that share a conversation with the user
+func (s *Server) handleGetPresence(c echo.Context) error {
 	userID, authErr := s.authenticate(c)
 	if authErr != nil {
 		return authErr
 	}
 
+	var req apitypes.GetPresenceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	contacts, err := s.conversationStore.GetContacts(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get presence")
+	}
+
+	userIDs := make([]string, 0, len(req.UserIDs))
+	for _, id := range req.UserIDs {
+		if _, isContact := slices.BinarySearch(contacts, id); (isContact || id == userID) && !slices.Contains(userIDs, id) {
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	presence, err := s.wsManager.Presence(userIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get presence")
+	}
+
+	return respond(c, http.StatusOK, apitypes.GetPresenceResponse{Presence: presence})
+}
+
+func (s *Server) handleUpdatePresenceSettings(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.UpdatePresenceSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.wsManager.SetHideLastSeen(userID, req.HideLastSeen); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update presence settings")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleGetMessages(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.GetMessagesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	query := conversation.MessageQuery{
+		Cursor: req.Cursor,
+		Limit:  req.Limit,
+		Before: req.Before,
+		After:  req.After,
+	}
+	messages, nextCursor, err := s.conversationStore.GetMessages(userID, req.ConversationID, query)
+	if err != nil {
+		if errors.Is(err, conversation.ErrConversationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		} else if errors.Is(err, conversation.ErrConversationUnauthorized) {
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get messages")
+	}
+
+	resp := apitypes.GetMessagesResponse{
+		Messages:   make([]apitypes.Message, 0, len(messages)),
+		NextCursor: nextCursor,
+	}
+	for _, msg := range messages {
+		resp.Messages = append(resp.Messages, apitypes.Message{
+			ID:             msg.ID,
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			SenderDeviceID: msg.SenderDeviceID,
+			Content:        msg.Content,
+			CreatedAt:      msg.CreatedAt,
+			ExpiresAt:      msg.ExpiresAt,
+		})
+	}
+	return respond(c, http.StatusOK, resp)
+}
+
+func (s *Server) handleAddParticipants(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.AddParticipantsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Entries for other devices of the sender only carry a key distribution message
+	participantIDs := make([]string, 0, len(req.Participants))
+	for _, p := range req.Participants {
+		if p.ID != identity.UserID && !slices.Contains(participantIDs, p.ID) {
+			participantIDs = append(participantIDs, p.ID)
+		}
+	}
+	if len(participantIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "no participants to add")
+	}
+
+	conv, err := s.conversationStore.AddParticipants(identity.UserID, req.ConversationID, participantIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, conversation.ErrConversationNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, conversation.ErrConversationUnauthorized):
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		case errors.Is(err, conversation.ErrParticipantExists):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add participants")
+		}
+	}
+
+	if err := s.wsManager.BroadcastParticipantsAdded(identity.UserID, identity.DeviceID, conv.ParticipantIDs, req); err != nil {
+		log.Printf("Failed to broadcast added participants: %v", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleRemoveParticipant(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.RemoveParticipantRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	conv, err := s.conversationStore.RemoveParticipant(identity.UserID, req.ConversationID, req.ParticipantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, conversation.ErrConversationNotFound), errors.Is(err, conversation.ErrParticipantNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, conversation.ErrConversationUnauthorized):
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove participant")
+		}
+	}
+
+	// Never hand the rotated key to the participant that has just been removed
+	keyDistributions := make([]apitypes.Participant, 0, len(req.KeyDistributions))
+	for _, p := range req.KeyDistributions {
+		if conv.HasParticipant(p.ID) {
+			keyDistributions = append(keyDistributions, p)
+		}
+	}
+	req.KeyDistributions = keyDistributions
+
+	if err := s.wsManager.BroadcastParticipantRemoved(identity.UserID, identity.DeviceID, conv.ParticipantIDs, req); err != nil {
+		log.Printf("Failed to broadcast removed participant: %v", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// handleDeleteMessage purges the ciphertext of a message its sender deleted for everyone, and of the edits and
+// reactions referring to it. The participants are told by the sender with an encrypted tombstone.
+func (s *Server) handleDeleteMessage(c echo.Context) error {
+	userID, authErr := s.authenticate(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	err := s.conversationStore.DeleteMessage(userID, c.Param("id"), c.Param("messageId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, conversation.ErrConversationNotFound), errors.Is(err, conversation.ErrMessageNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, conversation.ErrConversationUnauthorized), errors.Is(err, conversation.ErrMessageUnauthorized):
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete message")
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// handleSetExpirationTimer changes the expiration timer of a conversation and announces it to the other participants.
+// The timer applies to messages sent afterwards.
+func (s *Server) handleSetExpirationTimer(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.SetExpirationTimerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	conv, err := s.conversationStore.SetExpirationTimer(identity.UserID, req.ConversationID, req.ExpirationTimer)
+	if err != nil {
+		switch {
+		case errors.Is(err, conversation.ErrConversationNotFound):
+			return echo.NewHTTPError(http.StatusNotFound)
+		case errors.Is(err, conversation.ErrConversationUnauthorized):
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set expiration timer")
+		}
+	}
+
+	if err := s.wsManager.BroadcastExpirationTimer(identity.UserID, identity.DeviceID, conv.ParticipantIDs, req); err != nil {
+		log.Printf("Failed to broadcast expiration timer: %v", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleDistributeSenderKeys(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	var req apitypes.DistributeSenderKeysRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	conv, err := s.conversationStore.GetConversation(req.ConversationID)
+	if err != nil {
+		if errors.Is(err, conversation.ErrConversationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to distribute sender keys")
+	}
+	if !conv.HasParticipant(identity.UserID) {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	for _, p := range req.Participants {
+		if !conv.HasParticipant(p.ID) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("user %s is not a participant of the conversation", p.ID))
+		}
+	}
+
+	if err := s.wsManager.BroadcastSenderKeys(identity.UserID, identity.DeviceID, req); err != nil {
+		log.Printf("Failed to broadcast sender keys: %v", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) handleWebSocketConnection(c echo.Context) error {
+	identity, authErr := s.authenticateDevice(c)
+	if authErr != nil {
+		return authErr
+	}
+
+	cursor, cursorErr := cursorParam(c)
+	if cursorErr != nil {
+		return cursorErr
+	}
+
 	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to upgrade to WebSocket")
 	}
 
-	err = s.wsManager.RegisterClient(userID, conn)
+	// The manager unregisters the client once the connection is closed. The request context can't tell, it's done
+	// as soon as this handler returns.
+	err = s.wsManager.RegisterClient(identity.UserID, identity.DeviceID, cursor, conn)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register websocket listener")
 	}
 
-	// Set up a cleanup function to unregister the client when the connection is closed
-	go func() {
-		// Wait for the connection to be closed
-		<-c.Request().Context().Done()
-		s.wsManager.UnregisterClient(userID)
-	}()
+	return nil
+}
+
+// handleWebSocketRequest answers a request a device sent over its websocket. The device was authenticated when it
+// connected, the request goes through the same validation and authorization as its HTTP counterpart.
+func (s *Server) handleWebSocketRequest(userID string, deviceID uint32, request apitypes.WSMessage) apitypes.WSMessage {
+	identity := Identity{UserID: userID, DeviceID: deviceID}
+
+	var resp any
+	var httpErr *echo.HTTPError
+	switch request.Type {
+	case apitypes.MessageTypeCreateConversation:
+		var req apitypes.CreateConversationRequest
+		if httpErr = s.bindWebSocketRequest(request, &req); httpErr == nil {
+			resp, httpErr = s.createConversation(identity, req)
+		}
+	case apitypes.MessageTypeSendMessage:
+		var req apitypes.SendMessageRequest
+		if httpErr = s.bindWebSocketRequest(request, &req); httpErr == nil {
+			resp, httpErr = s.createMessage(identity, req)
+		}
+	case apitypes.MessageTypeSendReceipt:
+		var req apitypes.SendReceiptRequest
+		if httpErr = s.bindWebSocketRequest(request, &req); httpErr == nil {
+			httpErr = s.sendReceipt(identity, req)
+		}
+	default:
+		httpErr = echo.NewHTTPError(http.StatusBadRequest, "unsupported request type")
+	}
+
+	if httpErr == nil {
+		data, err := json.Marshal(resp)
+		if err == nil {
+			return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse, Data: data}
+		}
+		httpErr = echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal response")
+	}
+
+	data, _ := json.Marshal(apitypes.WSErrorPayload{Status: httpErr.Code, Message: fmt.Sprint(httpErr.Message)})
+	return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeError, Data: data}
+}
+
+// bindWebSocketRequest decodes and validates the payload of a websocket request like echo does for HTTP requests
+func (s *Server) bindWebSocketRequest(request apitypes.WSMessage, req any) *echo.HTTPError {
+	if err := json.Unmarshal(request.Data, req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := s.router.Validator.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// throttle consumes an attempt from the limiter for each of the keys and responds with 429 Too Many Requests when
+// the client has to wait
+func (s *Server) throttle(c echo.Context, limiter *ratelimit.Limiter, keys ...string) *echo.HTTPError {
+	retryAfter, err := limiter.Allow(keys...)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check rate limit")
+	}
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests")
+	}
 
 	return nil
 }
 
 func (s *Server) authenticate(c echo.Context) (string, *echo.HTTPError) {
-	userID, err := s.auth.Authenticate(c.Request())
+	identity, err := s.authenticateDevice(c)
+	if err != nil {
+		return "", err
+	}
+
+	return identity.UserID, nil
+}
+
+// authenticateDevice is like authenticate but also returns the device the session was started on
+func (s *Server) authenticateDevice(c echo.Context) (Identity, *echo.HTTPError) {
+	identity, err := s.auth.Authenticate(c.Request())
 
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrTokenUnauthorized):
-			return "", echo.NewHTTPError(http.StatusUnauthorized, "unauthorized token")
+			return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "unauthorized token")
+		case errors.Is(err, ErrTokenExpired):
+			return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "token expired")
 		case errors.Is(err, ErrMissingAuthHeader),
 			errors.Is(err, ErrEmptyToken),
 			errors.Is(err, ErrDecodeToken):
-			return "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return Identity{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		default:
-			return "", echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
+			return Identity{}, echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
 		}
 	}
 
-	return userID, nil
+	return identity, nil
+}
+
+// deviceIDParam parses the device ID from the named path parameter
+func deviceIDParam(c echo.Context, name string) (uint32, *echo.HTTPError) {
+	deviceID, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid device ID")
+	}
+	return uint32(deviceID), nil
+}
+
+// cursorParam parses the sequence number of the last websocket message the device processed, 0 when it's missing
+func cursorParam(c echo.Context) (uint64, *echo.HTTPError) {
+	value := c.QueryParam(apitypes.WSCursorParam)
+	if value == "" {
+		return 0, nil
+	}
+
+	cursor, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	return cursor, nil
+}
+
+// deviceLabel returns the device label supplied by the client or falls back to its user agent
+func deviceLabel(c echo.Context, label string) string {
+	if label != "" {
+		return label
+	}
+	return c.Request().UserAgent()
 }
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"signal-chat/internal/apitypes"
+	"signal-chat/server/conversation"
+	"signal-chat/server/ws"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Replicas(t *testing.T) {
+	t.Run("delivers message live to a device connected to another instance over a local bus", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		bus := ws.NewLocalBus()
+
+		// Act & Assert
+		testReplicaDelivery(t, db, bus, bus)
+	})
+
+	t.Run("delivers message live to a device connected to another instance over a broker", func(t *testing.T) {
+		// Arrange
+		// The database is closed last, the buses may still hand messages to the servers until they're closed
+		db, cleanup := testDB(t)
+		defer cleanup()
+		broker, err := ws.ListenBroker("unix", filepath.Join(t.TempDir(), "bus.sock"))
+		require.NoError(t, err)
+		defer broker.Close()
+
+		first, err := ws.DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer first.Close()
+		second, err := ws.DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer second.Close()
+
+		// Act & Assert
+		testReplicaDelivery(t, db, first, second)
+	})
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	t.Run("closes websocket connections with the restart reason", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		replica := testServer(t, db, ws.NewLocalBus())
+		defer replica.Close()
+		alice := testSession(t, replica.server, "alice")
+
+		header := http.Header{"Authorization": []string{"Bearer " + alice.authToken}}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(replica.URL, "http")+"/ws", header)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// The device answers the close frame while it's reading
+		readErr := make(chan error, 1)
+		go func() {
+			_, _, err := conn.ReadMessage()
+			readErr <- err
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		// Act
+		err = replica.server.Shutdown(ctx)
+
+		// Assert
+		require.NoError(t, err, "shutdown should return once the device closed the connection")
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, <-readErr, &closeErr)
+		assert.Equal(t, websocket.CloseServiceRestart, closeErr.Code)
+		assert.Equal(t, apitypes.WSCloseReasonRestart, closeErr.Text)
+	})
+}
+
+func TestServer_Protobuf(t *testing.T) {
+	t.Run("answers protobuf requests in protobuf and delivers binary websocket messages", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		replica := testServer(t, db, ws.NewLocalBus())
+		defer replica.Close()
+		alice := testSession(t, replica.server, "alice")
+		bob := testSession(t, replica.server, "bob")
+		require.NoError(t, replica.server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		dialer := websocket.Dialer{Subprotocols: []string{apitypes.WSSubprotocolProtobuf}}
+		header := http.Header{"Authorization": []string{"Bearer " + bob.authToken}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(replica.URL, "http")+"/ws", header)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		body, err := apitypes.ProtobufCodec.Marshal(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, replica.URL+apitypes.EndpointMessages, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+alice.authToken)
+		req.Header.Set("Content-Type", apitypes.ContentTypeProtobuf)
+		req.Header.Set("Accept", apitypes.ContentTypeProtobuf)
+
+		// Act
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		// Assert
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, apitypes.ContentTypeProtobuf, resp.Header.Get("Content-Type"))
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var sendResp apitypes.SendMessageResponse
+		require.NoError(t, apitypes.ProtobufCodec.Unmarshal(respBody, &sendResp))
+		assert.NotEmpty(t, sendResp.MessageID)
+
+		assert.Equal(t, apitypes.WSSubprotocolProtobuf, conn.Subprotocol())
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		frameType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.BinaryMessage, frameType)
+		var msg apitypes.WSMessage
+		require.NoError(t, apitypes.ProtobufCodec.UnmarshalWSMessage(data, &msg))
+		assert.Equal(t, apitypes.MessageTypeNewMessage, msg.Type)
+		var payload apitypes.WSNewMessagePayload
+		require.NoError(t, json.Unmarshal(msg.Data, &payload))
+		assert.Equal(t, sendResp.MessageID, payload.MessageID)
+		assert.Equal(t, []byte("ciphertext"), payload.Content)
+	})
+
+	t.Run("rejects malformed protobuf body", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		replica := testServer(t, db, ws.NewLocalBus())
+		defer replica.Close()
+
+		req, err := http.NewRequest(http.MethodPost, replica.URL+apitypes.EndpointSignIn, strings.NewReader("not protobuf"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", apitypes.ContentTypeProtobuf)
+
+		// Act
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestServer_SignInThrottle(t *testing.T) {
+	signIn := func(server *Server, username, password, forwardedFor string) int {
+		body, _ := json.Marshal(apitypes.SignInRequest{Username: username, Password: password, DeviceID: PrimaryDeviceID})
+		req := httptest.NewRequest(http.MethodPost, apitypes.EndpointSignIn, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("X-Real-IP", forwardedFor)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("ignores forwarded client IPs without trusted proxies", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.SignInBurst = 1
+		server, err := NewServerWithConfig(db, config)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, signIn(server, "alice", "guess", "203.0.113.1"))
+
+		// Act
+		code := signIn(server, "bob", "guess", "203.0.113.2")
+
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, code)
+	})
+
+	t.Run("takes the client IP forwarded by a trusted proxy", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.SignInBurst = 1
+		config.TrustedProxies = []string{"192.0.2.0/24"}
+		server, err := NewServerWithConfig(db, config)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, signIn(server, "alice", "guess", "203.0.113.1"))
+
+		// Act
+		code := signIn(server, "bob", "guess", "203.0.113.2")
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("keeps the failures of the IP after a successful sign-in", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.LockoutThreshold = 2
+		server, err := NewServerWithConfig(db, config)
+		require.NoError(t, err)
+		testSession(t, server, "alice")
+		require.Equal(t, http.StatusUnauthorized, signIn(server, "bob", "guess", ""))
+		require.Equal(t, http.StatusOK, signIn(server, "alice", "password", ""))
+
+		// Act
+		code := signIn(server, "carol", "guess", "")
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, http.StatusTooManyRequests, signIn(server, "dave", "guess", ""), "the IP should be locked out")
+	})
+}
+
+func TestServer_InboxLimits(t *testing.T) {
+	t.Run("rejects messages for a device whose inbox is full", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.InboxMaxMessages = 1
+		config.InboxEviction = "reject"
+		server, err := NewServerWithConfig(db, config)
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		send := func() *httptest.ResponseRecorder {
+			body, err := json.Marshal(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, apitypes.EndpointMessages, bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+alice.authToken)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			return rec
+		}
+		require.Equal(t, http.StatusOK, send().Code)
+
+		// Act
+		rec := send()
+
+		// Assert
+		assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+		assert.Contains(t, rec.Body.String(), "recipient inbox full")
+
+		messages, _, err := server.conversationStore.GetMessages(alice.userID, "conv-1", conversation.MessageQuery{})
+		require.NoError(t, err)
+		assert.Len(t, messages, 1, "the rejected message should not be stored")
+	})
+
+	t.Run("does not tell non-participants that an inbox is full", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.InboxMaxMessages = 1
+		config.InboxEviction = "reject"
+		server, err := NewServerWithConfig(db, config)
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		mallory := testSession(t, server, "mallory")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		send := func(session testIdentity) *httptest.ResponseRecorder {
+			body, err := json.Marshal(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, apitypes.EndpointMessages, bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+session.authToken)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			return rec
+		}
+		require.Equal(t, http.StatusOK, send(alice).Code)
+
+		// Act
+		rec := send(mallory)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("fails to start with an unknown eviction policy", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		config := DefaultServerConfig()
+		config.InboxEviction = "drop-newest"
+
+		// Act
+		_, err := NewServerWithConfig(db, config)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestServer_SendMessage(t *testing.T) {
+	t.Run("rejects content larger than the limit over HTTP and websocket", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		body, err := json.Marshal(apitypes.SendMessageRequest{
+			ConversationID: "conv-1",
+			Content:        make([]byte, apitypes.MaxMessageContentSize+1),
+		})
+		require.NoError(t, err)
+
+		// Act
+		req := httptest.NewRequest(http.MethodPost, apitypes.EndpointMessages, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+alice.authToken)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+
+		wsResp := server.handleWebSocketRequest(alice.userID, PrimaryDeviceID, apitypes.WSMessage{
+			ID:   "req-1",
+			Type: apitypes.MessageTypeSendMessage,
+			Data: body,
+		})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		require.Equal(t, apitypes.MessageTypeError, wsResp.Type)
+		var wsErr apitypes.WSErrorPayload
+		require.NoError(t, json.Unmarshal(wsResp.Data, &wsErr))
+		assert.Equal(t, http.StatusBadRequest, wsErr.Status)
+
+		messages, _, err := server.conversationStore.GetMessages(alice.userID, "conv-1", conversation.MessageQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+}
+
+func TestServer_ExpirationTimer(t *testing.T) {
+	t.Run("expires messages sent after a participant set the timer", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		serve := func(method, target string, identity testIdentity, payload any) *httptest.ResponseRecorder {
+			body, err := json.Marshal(payload)
+			require.NoError(t, err)
+			req := httptest.NewRequest(method, target, bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+identity.authToken)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		// Act
+		rec := serve(http.MethodPut, "/v1/conversations/conv-1/timer", bob, apitypes.SetExpirationTimerRequest{ExpirationTimer: 60})
+		require.Equal(t, http.StatusOK, rec.Code)
+		rec = serve(http.MethodPost, apitypes.EndpointMessages, alice, apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+
+		// Assert
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp apitypes.SendMessageResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, resp.CreatedAt+60_000, resp.ExpiresAt)
+
+		messages, _, err := server.conversationStore.GetMessages(bob.userID, "conv-1", conversation.MessageQuery{})
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, resp.ExpiresAt, messages[0].ExpiresAt)
+	})
+
+	t.Run("rejects timer changes from non-participants", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		mallory := testSession(t, server, "mallory")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID}))
+
+		req := httptest.NewRequest(http.MethodPut, "/v1/conversations/conv-1/timer", strings.NewReader(`{"expirationTimer":60}`))
+		req.Header.Set("Authorization", "Bearer "+mallory.authToken)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		// Act
+		server.router.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestServer_DeleteMessage(t *testing.T) {
+	t.Run("purges a message only for its sender", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+		msg, err := server.conversationStore.CreateMessage(alice.userID, PrimaryDeviceID, "conv-1", []byte("ciphertext"))
+		require.NoError(t, err)
+
+		deleteAs := func(identity testIdentity) int {
+			req := httptest.NewRequest(http.MethodDelete, "/v1/conversations/conv-1/messages/"+msg.ID, nil)
+			req.Header.Set("Authorization", "Bearer "+identity.authToken)
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		// Act
+		bobStatus := deleteAs(bob)
+		aliceStatus := deleteAs(alice)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, bobStatus)
+		assert.Equal(t, http.StatusOK, aliceStatus)
+		assert.Equal(t, http.StatusNotFound, deleteAs(alice), "the message should have been purged")
+	})
+
+	t.Run("purges the revisions of an edited message with it", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		bob := testSession(t, server, "bob")
+		require.NoError(t, server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+		send := func(req apitypes.SendMessageRequest) apitypes.SendMessageResponse {
+			body, err := json.Marshal(req)
+			require.NoError(t, err)
+			httpReq := httptest.NewRequest(http.MethodPost, apitypes.EndpointMessages, bytes.NewReader(body))
+			httpReq.Header.Set("Authorization", "Bearer "+alice.authToken)
+			httpReq.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, httpReq)
+			require.Equal(t, http.StatusOK, rec.Code)
+			var resp apitypes.SendMessageResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			return resp
+		}
+		original := send(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+		send(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("revision"), RefersTo: original.MessageID})
+
+		// Act
+		req := httptest.NewRequest(http.MethodDelete, "/v1/conversations/conv-1/messages/"+original.MessageID, nil)
+		req.Header.Set("Authorization", "Bearer "+alice.authToken)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+
+		// Assert
+		require.Equal(t, http.StatusOK, rec.Code)
+		messages, _, err := server.conversationStore.GetMessages(bob.userID, "conv-1", conversation.MessageQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, messages, "no ciphertext of the message or its revision should remain")
+	})
+}
+
+func TestServer_UploadPreKeys(t *testing.T) {
+	t.Run("rejects pre-keys that aren't 32 byte public keys", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+
+		upload := func(publicKey []byte) int {
+			body, err := json.Marshal(apitypes.UploadPreKeysRequest{PreKeys: []apitypes.PreKey{{ID: 1, PublicKey: publicKey}}})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, apitypes.EndpointPreKeys, bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+alice.authToken)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		// Act
+		short := upload(make([]byte, 16))
+		long := upload(make([]byte, 33))
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, short)
+		assert.Equal(t, http.StatusBadRequest, long)
+		assert.Equal(t, http.StatusOK, upload(make([]byte, 32)))
+	})
+}
+
+func TestServer_PresenceSettings(t *testing.T) {
+	t.Run("rejects an invalid body without changing the settings", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+
+		server, err := NewServerWithConfig(db, DefaultServerConfig())
+		require.NoError(t, err)
+		alice := testSession(t, server, "alice")
+		require.NoError(t, server.wsManager.SetHideLastSeen(alice.userID, true))
+
+		// Act
+		req := httptest.NewRequest(http.MethodPost, apitypes.EndpointPresenceSettings, strings.NewReader(`{"hideLastSeen":"no"}`))
+		req.Header.Set("Authorization", "Bearer "+alice.authToken)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte("presence#" + alice.userID))
+			require.NoError(t, err)
+			value, err := item.ValueCopy(nil)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"lastSeen":0,"hideLastSeen":true}`, string(value), "last seen should stay hidden")
+			return nil
+		}))
+	})
+}
+
+// testReplicaDelivery runs two server instances sharing a database, connects bob to the second one and sends a message
+// from alice through the first one
+func testReplicaDelivery(t *testing.T, db *badger.DB, firstBus, secondBus ws.Bus) {
+	t.Helper()
+
+	first := testServer(t, db, firstBus)
+	defer first.Close()
+	second := testServer(t, db, secondBus)
+	defer second.Close()
+
+	alice := testSession(t, first.server, "alice")
+	bob := testSession(t, first.server, "bob")
+	require.NoError(t, first.server.conversationStore.CreateConversation("conv-1", []string{alice.userID, bob.userID}))
+
+	header := http.Header{"Authorization": []string{"Bearer " + bob.authToken}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(second.URL, "http")+"/ws", header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	body, err := json.Marshal(apitypes.SendMessageRequest{ConversationID: "conv-1", Content: []byte("ciphertext")})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, first.URL+apitypes.EndpointMessages, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+alice.authToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg apitypes.WSMessage
+	require.NoError(t, conn.ReadJSON(&msg), "message should have been delivered without reconnecting")
+	assert.Equal(t, apitypes.MessageTypeNewMessage, msg.Type)
+	var payload apitypes.WSNewMessagePayload
+	require.NoError(t, json.Unmarshal(msg.Data, &payload))
+	assert.Equal(t, alice.userID, payload.SenderID)
+	assert.Equal(t, []byte("ciphertext"), payload.Content)
+}
+
+type replica struct {
+	*httptest.Server
+	server *Server
+}
+
+func testServer(t *testing.T, db *badger.DB, bus ws.Bus) replica {
+	t.Helper()
+
+	config := DefaultServerConfig()
+	config.Bus = bus
+	server, err := NewServerWithConfig(db, config)
+	require.NoError(t, err)
+
+	return replica{Server: httptest.NewServer(server.router), server: server}
+}
+
+type testIdentity struct {
+	userID    string
+	authToken string
+}
+
+func testSession(t *testing.T, server *Server, username string) testIdentity {
+	t.Helper()
+
+	user, err := server.userStore.CreateUser(username, "password", apitypes.KeyBundle{})
+	require.NoError(t, err)
+	tokens, err := server.auth.GenerateToken(user.ID, PrimaryDeviceID, "test")
+	require.NoError(t, err)
+
+	return testIdentity{userID: user.ID, authToken: tokens.AuthToken}
+}
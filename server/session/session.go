@@ -0,0 +1,26 @@
+package session
+
+import "time"
+
+type Session struct {
+	ID               string    `json:"id"`
+	UserID           string    `json:"user_id"`
+	DeviceID         uint32    `json:"device_id"`
+	TokenHash        string    `json:"token_hash"`
+	RefreshTokenHash string    `json:"refresh_token_hash"`
+	DeviceLabel      string    `json:"device_label"`
+	IssuedAt         time.Time `json:"issued_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	LastSeenAt       time.Time `json:"last_seen_at"`
+}
+
+// IsExpired reports whether the access token of the session is no longer valid at the given time
+func (s *Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// IsRefreshExpired reports whether the refresh token of the session is no longer valid at the given time
+func (s *Session) IsRefreshExpired(now time.Time) bool {
+	return !now.Before(s.RefreshExpiresAt)
+}
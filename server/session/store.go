@@ -0,0 +1,291 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// maxUpdateAttempts bounds the retries of a touch or rotation that conflicts with concurrent updates of the session
+const maxUpdateAttempts = 3
+
+type Store struct {
+	db *badger.DB
+}
+
+func NewStore(db *badger.DB) *Store {
+	return &Store{db: db}
+}
+
+// Save creates or updates a session together with its token lookup keys. All keys expire
+// together with the refresh token so abandoned sessions are garbage collected by Badger.
+func (s *Store) Save(sess Session, now time.Time) error {
+	ttl := sess.RefreshExpiresAt.Sub(now)
+	if ttl <= 0 {
+		return fmt.Errorf("cannot save session %s: refresh token already expired", sess.ID)
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return saveSession(txn, sess, ttl)
+	})
+}
+
+// Rotate looks up the session of the refresh token and saves it once rotate updated it, in a single transaction. Of
+// concurrent rotations with the same refresh token only the first succeeds, the others get ErrSessionNotFound as the
+// token was replaced. Errors returned by rotate are passed on and leave the session as it is.
+func (s *Store) Rotate(refreshTokenHash string, now time.Time, rotate func(sess *Session) error) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err = s.db.Update(func(txn *badger.Txn) error {
+			sess, err := getByIndex(txn, refreshTokenItemKey(refreshTokenHash))
+			if err != nil {
+				return err
+			}
+			if err := rotate(&sess); err != nil {
+				return err
+			}
+
+			ttl := sess.RefreshExpiresAt.Sub(now)
+			if ttl <= 0 {
+				return fmt.Errorf("cannot save session %s: refresh token already expired", sess.ID)
+			}
+			return saveSession(txn, sess, ttl)
+		})
+		// Re-read on conflict, the concurrent write may have rotated the refresh token
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// saveSession writes the session with its lookup keys, dropping the ones of rotated tokens
+func saveSession(txn *badger.Txn, sess Session, ttl time.Duration) error {
+	sessJSON, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("failed to marshall session: %w", err)
+	}
+
+	old, err := getSession(txn, sess.ID)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	if err == nil {
+		// Drop lookup keys of rotated tokens
+		if old.TokenHash != sess.TokenHash {
+			if err := txn.Delete(tokenItemKey(old.TokenHash)); err != nil {
+				return err
+			}
+		}
+		if old.RefreshTokenHash != sess.RefreshTokenHash {
+			if err := txn.Delete(refreshTokenItemKey(old.RefreshTokenHash)); err != nil {
+				return err
+			}
+		}
+	}
+
+	entries := []*badger.Entry{
+		badger.NewEntry(sessionItemKey(sess.ID), sessJSON).WithTTL(ttl),
+		badger.NewEntry(tokenItemKey(sess.TokenHash), []byte(sess.ID)).WithTTL(ttl),
+		badger.NewEntry(refreshTokenItemKey(sess.RefreshTokenHash), []byte(sess.ID)).WithTTL(ttl),
+		badger.NewEntry(userSessionItemKey(sess.UserID, sess.ID), nil).WithTTL(ttl),
+	}
+	for _, e := range entries {
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch records activity on the session and moves the expiration of its access token. Only these fields are written,
+// in the same transaction that checks that the access token is still the given one, so that a concurrent refresh isn't
+// undone. ErrSessionNotFound is returned when the token was rotated or revoked in the meantime.
+func (s *Store) Touch(id, tokenHash string, lastSeenAt, expiresAt time.Time) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err = s.db.Update(func(txn *badger.Txn) error {
+			sess, err := getSession(txn, id)
+			if err != nil {
+				return err
+			}
+			if sess.TokenHash != tokenHash {
+				return ErrSessionNotFound
+			}
+
+			ttl := sess.RefreshExpiresAt.Sub(lastSeenAt)
+			if ttl <= 0 {
+				return fmt.Errorf("cannot touch session %s: refresh token already expired", sess.ID)
+			}
+
+			sess.LastSeenAt = lastSeenAt
+			sess.ExpiresAt = expiresAt
+			sessJSON, err := json.Marshal(sess)
+			if err != nil {
+				return fmt.Errorf("failed to marshall session: %w", err)
+			}
+			return txn.SetEntry(badger.NewEntry(sessionItemKey(sess.ID), sessJSON).WithTTL(ttl))
+		})
+		// Re-read on conflict, the concurrent write may have rotated the token
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// GetByTokenHash returns the session that owns the given access token hash
+func (s *Store) GetByTokenHash(tokenHash string) (Session, error) {
+	return s.getByIndex(tokenItemKey(tokenHash))
+}
+
+// GetByRefreshTokenHash returns the session that owns the given refresh token hash
+func (s *Store) GetByRefreshTokenHash(refreshTokenHash string) (Session, error) {
+	return s.getByIndex(refreshTokenItemKey(refreshTokenHash))
+}
+
+// Get returns the session with the given ID
+func (s *Store) Get(id string) (Session, error) {
+	var sess Session
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		sess, err = getSession(txn, id)
+		return err
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	return sess, nil
+}
+
+// ListByUser returns all sessions of the given user that haven't been garbage collected yet
+func (s *Store) ListByUser(userID string) ([]Session, error) {
+	var sessions []Session
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := userSessionItemKey(userID, "")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			id := string(it.Item().Key()[len(prefix):])
+			sess, err := getSession(txn, id)
+			if err != nil {
+				if errors.Is(err, ErrSessionNotFound) {
+					continue
+				}
+				return err
+			}
+			sessions = append(sessions, sess)
+		}
+		return nil
+	})
+
+	return sessions, err
+}
+
+// Delete removes the session and all of its lookup keys
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		sess, err := getSession(txn, id)
+		if err != nil {
+			return err
+		}
+
+		keys := [][]byte{
+			sessionItemKey(sess.ID),
+			tokenItemKey(sess.TokenHash),
+			refreshTokenItemKey(sess.RefreshTokenHash),
+			userSessionItemKey(sess.UserID, sess.ID),
+		}
+		for _, k := range keys {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) getByIndex(indexKey []byte) (Session, error) {
+	var sess Session
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		sess, err = getByIndex(txn, indexKey)
+		return err
+	})
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	return sess, nil
+}
+
+// getByIndex returns the session whose ID is stored under the lookup key
+func getByIndex(txn *badger.Txn, indexKey []byte) (Session, error) {
+	item, err := txn.Get(indexKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return Session{}, ErrSessionNotFound
+		}
+		return Session{}, err
+	}
+
+	var id string
+	err = item.Value(func(val []byte) error {
+		id = string(val)
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	return getSession(txn, id)
+}
+
+func getSession(txn *badger.Txn, id string) (Session, error) {
+	item, err := txn.Get(sessionItemKey(id))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return Session{}, ErrSessionNotFound
+		}
+		return Session{}, err
+	}
+
+	var sess Session
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &sess)
+	})
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to unmarshall session: %w", err)
+	}
+
+	return sess, nil
+}
+
+func sessionItemKey(id string) []byte {
+	return []byte("session#" + id)
+}
+
+func tokenItemKey(tokenHash string) []byte {
+	return []byte("sessiontoken#" + tokenHash)
+}
+
+func refreshTokenItemKey(refreshTokenHash string) []byte {
+	return []byte("sessionrefresh#" + refreshTokenHash)
+}
+
+func userSessionItemKey(userID, sessionID string) []byte {
+	return []byte("usersession#" + userID + ":" + sessionID)
+}
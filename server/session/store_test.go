@@ -0,0 +1,209 @@
+package session
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStore_Save(t *testing.T) {
+	t.Run("session can be looked up by both token hashes", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+
+		// Act
+		err := store.Save(sess, time.Now())
+
+		// Assert
+		require.NoError(t, err)
+		byToken, err := store.GetByTokenHash(sess.TokenHash)
+		require.NoError(t, err)
+		assert.Equal(t, sess.ID, byToken.ID)
+		assert.Equal(t, sess.UserID, byToken.UserID)
+		byRefresh, err := store.GetByRefreshTokenHash(sess.RefreshTokenHash)
+		require.NoError(t, err)
+		assert.Equal(t, sess.ID, byRefresh.ID)
+	})
+
+	t.Run("rotated tokens no longer resolve to the session", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+		require.NoError(t, store.Save(sess, time.Now()))
+		oldTokenHash, oldRefreshHash := sess.TokenHash, sess.RefreshTokenHash
+
+		// Act
+		sess.TokenHash = "new-token-hash"
+		sess.RefreshTokenHash = "new-refresh-hash"
+		err := store.Save(sess, time.Now())
+
+		// Assert
+		require.NoError(t, err)
+		_, err = store.GetByTokenHash(oldTokenHash)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		_, err = store.GetByRefreshTokenHash(oldRefreshHash)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		got, err := store.GetByTokenHash("new-token-hash")
+		require.NoError(t, err)
+		assert.Equal(t, sess.ID, got.ID)
+	})
+
+	t.Run("returns error when refresh token already expired", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+		sess.RefreshExpiresAt = time.Now().Add(-time.Second)
+
+		// Act
+		err := store.Save(sess, time.Now())
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestStore_Touch(t *testing.T) {
+	t.Run("moves the access expiration", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+		require.NoError(t, store.Save(sess, time.Now()))
+		lastSeenAt := sess.LastSeenAt.Add(time.Minute)
+		expiresAt := sess.ExpiresAt.Add(time.Minute)
+
+		// Act
+		err := store.Touch(sess.ID, sess.TokenHash, lastSeenAt, expiresAt)
+
+		// Assert
+		require.NoError(t, err)
+		got, err := store.Get(sess.ID)
+		require.NoError(t, err)
+		assert.True(t, lastSeenAt.Equal(got.LastSeenAt))
+		assert.True(t, expiresAt.Equal(got.ExpiresAt))
+	})
+
+	t.Run("doesn't undo a token rotation", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+		require.NoError(t, store.Save(sess, time.Now()))
+		rotated := sess
+		rotated.TokenHash = "new-token-hash"
+		rotated.RefreshTokenHash = "new-refresh-hash"
+		require.NoError(t, store.Save(rotated, time.Now()))
+
+		// Act
+		err := store.Touch(sess.ID, sess.TokenHash, time.Now(), time.Now().Add(time.Hour))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		got, err := store.GetByTokenHash("new-token-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "new-refresh-hash", got.RefreshTokenHash)
+	})
+}
+
+func TestStore_ListByUser(t *testing.T) {
+	t.Run("returns only sessions of the given user", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		require.NoError(t, store.Save(testSession("sess-1", "user-1"), time.Now()))
+		require.NoError(t, store.Save(testSession("sess-2", "user-1"), time.Now()))
+		require.NoError(t, store.Save(testSession("sess-3", "user-2"), time.Now()))
+
+		// Act
+		sessions, err := store.ListByUser("user-1")
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.ElementsMatch(t, []string{"sess-1", "sess-2"}, []string{sessions[0].ID, sessions[1].ID})
+	})
+}
+
+func TestStore_Delete(t *testing.T) {
+	t.Run("removes session and its lookup keys", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+		sess := testSession("sess-1", "user-1")
+		require.NoError(t, store.Save(sess, time.Now()))
+
+		// Act
+		err := store.Delete(sess.ID)
+
+		// Assert
+		require.NoError(t, err)
+		_, err = store.Get(sess.ID)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		_, err = store.GetByTokenHash(sess.TokenHash)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		sessions, err := store.ListByUser(sess.UserID)
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	t.Run("returns error for non-existent session", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewStore(db)
+
+		// Act
+		err := store.Delete("non-existent")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+	})
+}
+
+func testSession(id, userID string) Session {
+	now := time.Now()
+	return Session{
+		ID:               id,
+		UserID:           userID,
+		TokenHash:        id + "-token-hash",
+		RefreshTokenHash: id + "-refresh-hash",
+		DeviceLabel:      "laptop",
+		IssuedAt:         now,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+		RefreshExpiresAt: now.Add(24 * time.Hour),
+	}
+}
+
+func testDB(t *testing.T) (*badger.DB, func()) {
+	t.Helper()
+
+	opts := badger.DefaultOptions("").
+		WithInMemory(true).
+		WithLogger(nil).
+		WithNumMemtables(1).
+		WithNumLevelZeroTables(1).
+		WithNumLevelZeroTablesStall(2).
+		WithValueLogFileSize(1 << 20)
+
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+
+	return db, func() {
+		err := db.Close()
+		require.NoError(t, err)
+	}
+}
@@ -2,30 +2,56 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/crossle/libsignal-protocol-go/ecc"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"log"
 	"math/big"
 	"signal-chat/internal/apitypes"
+	"signal-chat/server/passhash"
+	"slices"
+	"strconv"
 )
 
 var (
 	ErrEmailExists        = errors.New("user with same email already exists")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrDuplicatePreKey    = errors.New("pre-key with the same ID already exists")
+	ErrTooManyPreKeys     = errors.New("too many pre-keys")
+	ErrInvalidSignature   = errors.New("signed pre-key signature doesn't match identity key")
+	ErrDeviceNotFound     = errors.New("device not found")
+	ErrTooManyDevices     = errors.New("too many devices")
+	ErrPrimaryDevice      = errors.New("primary device can't be removed")
+)
+
+const (
+	// MaxPreKeys is the maximum number of one-time pre-keys stored for a single device
+	MaxPreKeys = 1000
+	// MaxDevices is the maximum number of devices registered to a single user
+	MaxDevices = 5
+	// PrimaryDeviceID is the ID of the device the user signed up with
+	PrimaryDeviceID uint32 = 1
 )
 
 type UserStore struct {
-	db *badger.DB
+	db     *badger.DB
+	hasher *passhash.Hasher
+}
+
+func NewUserStore(db *badger.DB, hasher *passhash.Hasher) *UserStore {
+	return &UserStore{db: db, hasher: hasher}
 }
 
 func (r *UserStore) CreateUser(username, password string, keyBundle apitypes.KeyBundle) (apitypes.User, error) {
 	userID := uuid.New().String()
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := r.hasher.Hash([]byte(password))
 	if err != nil {
 		return apitypes.User{}, err
 	}
@@ -63,7 +89,7 @@ func (r *UserStore) CreateUser(username, password string, keyBundle apitypes.Key
 			return err
 		}
 
-		return txn.Set(keyBundleItemKey(userID), keyBundleJSON)
+		return txn.Set(keyBundleItemKey(userID, PrimaryDeviceID), keyBundleJSON)
 	})
 
 	if err != nil {
@@ -134,49 +160,239 @@ func (r *UserStore) GetUserByID(id string) (apitypes.User, error) {
 	return user, nil
 }
 
-func (r *UserStore) GetPreKeyBundle(userID string) (apitypes.PreKeyBundle, error) {
+// GetPreKeyBundle returns the key bundle of the user's device with one of its one-time pre-keys, which is removed
+// from the store. It also returns the number of one-time pre-keys left. When the device has no one-time pre-keys
+// left, the bundle is returned without one.
+func (r *UserStore) GetPreKeyBundle(userID string, deviceID uint32) (apitypes.PreKeyBundle, int, error) {
 	var preKeyBundle apitypes.PreKeyBundle
+	var remaining int
 
-	err := r.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(keyBundleItemKey(userID))
+	err := r.db.Update(func(txn *badger.Txn) error {
+		keyBundle, err := getKeyBundle(txn, userID, deviceID)
 		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return ErrUserNotFound
-			}
 			return err
 		}
 
-		return item.Value(func(val []byte) error {
-			var keyBundle apitypes.KeyBundle
-			err = json.Unmarshal(val, &keyBundle)
+		preKeyBundle.RegistrationID = keyBundle.RegistrationID
+		preKeyBundle.IdentityKey = keyBundle.IdentityKey
+		preKeyBundle.SignedPreKey = keyBundle.SignedPreKey
+		if len(keyBundle.PreKeys) == 0 {
+			return nil
+		}
+
+		selected, newPreKeys, err := takeRandomItem(keyBundle.PreKeys)
+		if err != nil {
+			return fmt.Errorf("failed to select pre key: %w", err)
+		}
+		preKeyBundle.PreKey = &selected
+
+		keyBundle.PreKeys = newPreKeys
+		remaining = len(newPreKeys)
+		return setKeyBundle(txn, userID, deviceID, keyBundle)
+	})
+
+	if err != nil {
+		return apitypes.PreKeyBundle{}, 0, err
+	}
+
+	return preKeyBundle, remaining, nil
+}
+
+// MigrateKeyBundles moves the key bundles stored before accounts had several devices, under keys#<userID>, to the
+// primary device of their user. It returns the number of moved bundles and does nothing once all of them were moved.
+func (r *UserStore) MigrateKeyBundles() (int, error) {
+	var legacyKeys [][]byte
+	err := r.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("keys#")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if !bytes.Contains(it.Item().Key()[len(prefix):], []byte(":")) {
+				legacyKeys = append(legacyKeys, it.Item().KeyCopy(nil))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range legacyKeys {
+		userID := string(key[len("keys#"):])
+		err := r.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
 			if err != nil {
 				return err
 			}
-
-			preKeyBundle.IdentityKey = keyBundle.IdentityKey
-			preKeyBundle.SignedPreKey = keyBundle.SignedPreKey
-			selected, newPreKeys, err := takeRandomItem(keyBundle.PreKeys)
+			keyBundle, err := item.ValueCopy(nil)
 			if err != nil {
-				return fmt.Errorf("failed to select pre key: %w", err)
+				return err
 			}
-			preKeyBundle.PreKey = selected
 
-			keyBundle.PreKeys = newPreKeys
-			keyBundleJSON, err := json.Marshal(keyBundle)
-			if err != nil {
-				return fmt.Errorf("failed to marshal key bundle: %w", err)
+			if err := txn.Set(keyBundleItemKey(userID, PrimaryDeviceID), keyBundle); err != nil {
+				return err
 			}
-			return txn.Set(keyBundleItemKey(userID), keyBundleJSON)
+			return txn.Delete(key)
 		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate key bundle of user %s: %w", userID, err)
+		}
+	}
+
+	return len(legacyKeys), nil
+}
+
+// AddDevice registers a new device of the user with its own key bundle and returns the ID assigned to it
+func (r *UserStore) AddDevice(userID string, keyBundle apitypes.KeyBundle) (uint32, error) {
+	var deviceID uint32
+
+	err := r.db.Update(func(txn *badger.Txn) error {
+		devices, err := getDevices(txn, userID)
+		if err != nil {
+			return err
+		}
+		if len(devices) >= MaxDevices {
+			return ErrTooManyDevices
+		}
+
+		// Device IDs are never reused, so peers can't mistake a new device for one they already have a session with
+		deviceID = devices[len(devices)-1].ID + 1
+		item, err := txn.Get(lastDeviceItemKey(userID))
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				deviceID = max(deviceID, binary.BigEndian.Uint32(val)+1)
+				return nil
+			})
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if err := txn.Set(lastDeviceItemKey(userID), binary.BigEndian.AppendUint32(nil, deviceID)); err != nil {
+			return err
+		}
+		return setKeyBundle(txn, userID, deviceID, keyBundle)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return deviceID, nil
+}
+
+// RemoveDevice deletes the device of the user together with its key bundle. The primary device can't be removed.
+func (r *UserStore) RemoveDevice(userID string, deviceID uint32) error {
+	if deviceID == PrimaryDeviceID {
+		return ErrPrimaryDevice
+	}
+
+	return r.db.Update(func(txn *badger.Txn) error {
+		if _, err := getKeyBundle(txn, userID, deviceID); err != nil {
+			return err
+		}
+		return txn.Delete(keyBundleItemKey(userID, deviceID))
+	})
+}
+
+// GetDevice returns the device of the user with the given ID
+func (r *UserStore) GetDevice(userID string, deviceID uint32) (apitypes.Device, error) {
+	var device apitypes.Device
+
+	err := r.db.View(func(txn *badger.Txn) error {
+		keyBundle, err := getKeyBundle(txn, userID, deviceID)
+		if err != nil {
+			return err
+		}
+
+		device = apitypes.Device{ID: deviceID, RegistrationID: keyBundle.RegistrationID}
+		return nil
+	})
+
+	if err != nil {
+		return apitypes.Device{}, err
+	}
+
+	return device, nil
+}
+
+// GetDevices returns the devices registered to the user ordered by their IDs
+func (r *UserStore) GetDevices(userID string) ([]apitypes.Device, error) {
+	var devices []apitypes.Device
+
+	err := r.db.View(func(txn *badger.Txn) error {
+		var err error
+		devices, err = getDevices(txn, userID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// AddPreKeys stores additional one-time pre-keys of the user's device and returns the number of pre-keys available
+func (r *UserStore) AddPreKeys(userID string, deviceID uint32, preKeys []apitypes.PreKey) (int, error) {
+	var count int
+
+	err := r.db.Update(func(txn *badger.Txn) error {
+		keyBundle, err := getKeyBundle(txn, userID, deviceID)
+		if err != nil {
+			return err
+		}
+
+		if len(keyBundle.PreKeys)+len(preKeys) > MaxPreKeys {
+			return ErrTooManyPreKeys
+		}
+
+		ids := make(map[uint32]struct{}, len(keyBundle.PreKeys)+len(preKeys))
+		for _, k := range keyBundle.PreKeys {
+			ids[k.ID] = struct{}{}
+		}
+		for _, k := range preKeys {
+			if _, exists := ids[k.ID]; exists {
+				return ErrDuplicatePreKey
+			}
+			ids[k.ID] = struct{}{}
+		}
+
+		keyBundle.PreKeys = append(keyBundle.PreKeys, preKeys...)
+		count = len(keyBundle.PreKeys)
+		return setKeyBundle(txn, userID, deviceID, keyBundle)
 	})
 
 	if err != nil {
-		return apitypes.PreKeyBundle{}, err
+		return 0, err
 	}
 
-	return preKeyBundle, nil
+	return count, nil
 }
 
+// SetSignedPreKey replaces the signed pre-key of the user's device after checking that it was signed with the
+// device's identity key
+func (r *UserStore) SetSignedPreKey(userID string, deviceID uint32, signedPreKey apitypes.SignedPreKey) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		keyBundle, err := getKeyBundle(txn, userID, deviceID)
+		if err != nil {
+			return err
+		}
+
+		if !verifySignedPreKey(keyBundle.IdentityKey, signedPreKey) {
+			return ErrInvalidSignature
+		}
+
+		keyBundle.SignedPreKey = signedPreKey
+		return setKeyBundle(txn, userID, deviceID, keyBundle)
+	})
+}
+
+// VerifyCredentials returns the user with the given username if the password matches. Unknown usernames and wrong
+// passwords both result in ErrInvalidCredentials and take roughly the same time.
 func (r *UserStore) VerifyCredentials(username, password string) (apitypes.User, error) {
 	user := apitypes.User{
 		Username: username,
@@ -204,29 +420,151 @@ func (r *UserStore) VerifyCredentials(username, password string) (apitypes.User,
 		if err != nil {
 			return err
 		}
-		return credItem.Value(func(val []byte) error {
-			storedHash = val
-			return nil
-		})
+		storedHash, err = credItem.ValueCopy(nil)
+		return err
 	})
 
 	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			_ = r.hasher.VerifyDummy([]byte(password))
+			return apitypes.User{}, ErrInvalidCredentials
+		}
 		return apitypes.User{}, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	needsRehash, err := r.hasher.Verify([]byte(password), storedHash)
 	if err != nil {
-		return apitypes.User{}, err
+		if errors.Is(err, passhash.ErrMismatchedPassword) {
+			return apitypes.User{}, ErrInvalidCredentials
+		}
+		return apitypes.User{}, fmt.Errorf("failed to verify password: %w", err)
 	}
-	if !bytes.Equal(storedHash, hashedPassword) {
-		return apitypes.User{}, ErrInvalidCredentials
+
+	if needsRehash {
+		// The user is already authenticated at this point, so failing to upgrade the hash must not fail the sign-in
+		if err := r.rehashPassword(username, password, storedHash); err != nil {
+			log.Printf("Failed to rehash password of user %s: %v", user.ID, err)
+		}
 	}
 
 	return user, nil
 }
 
-func keyBundleItemKey(userID string) []byte {
-	return []byte("keys#" + userID)
+// rehashPassword replaces the stored hash with one produced by the preferred hashing scheme unless the credentials
+// have changed in the meantime
+func (r *UserStore) rehashPassword(username, password string, oldHash []byte) error {
+	newHash, err := r.hasher.Hash([]byte(password))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(txn *badger.Txn) error {
+		credItem, err := txn.Get(credItemKey(username))
+		if err != nil {
+			return err
+		}
+		currentHash, err := credItem.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(currentHash, oldHash) {
+			return nil
+		}
+
+		return txn.Set(credItemKey(username), newHash)
+	})
+}
+
+// verifySignedPreKey checks the XEdDSA signature the client calculated over the serialized (type-prefixed) public key
+func verifySignedPreKey(identityKey []byte, signedPreKey apitypes.SignedPreKey) bool {
+	if len(identityKey) != 32 || len(signedPreKey.PublicKey) != 32 || len(signedPreKey.Signature) != 64 {
+		return false
+	}
+
+	signingKey := ecc.NewDjbECPublicKey([32]byte(identityKey))
+	message := append([]byte{ecc.DjbType}, signedPreKey.PublicKey...)
+	return ecc.VerifySignature(signingKey, message, [64]byte(signedPreKey.Signature))
+}
+
+func getKeyBundle(txn *badger.Txn, userID string, deviceID uint32) (apitypes.KeyBundle, error) {
+	item, err := txn.Get(keyBundleItemKey(userID, deviceID))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			if _, err := txn.Get(userItemKey(userID)); err != nil {
+				return apitypes.KeyBundle{}, ErrUserNotFound
+			}
+			return apitypes.KeyBundle{}, ErrDeviceNotFound
+		}
+		return apitypes.KeyBundle{}, err
+	}
+
+	var keyBundle apitypes.KeyBundle
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &keyBundle)
+	})
+	if err != nil {
+		return apitypes.KeyBundle{}, fmt.Errorf("failed to unmarshal key bundle: %w", err)
+	}
+
+	return keyBundle, nil
+}
+
+func setKeyBundle(txn *badger.Txn, userID string, deviceID uint32, keyBundle apitypes.KeyBundle) error {
+	keyBundleJSON, err := json.Marshal(keyBundle)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key bundle: %w", err)
+	}
+	return txn.Set(keyBundleItemKey(userID, deviceID), keyBundleJSON)
+}
+
+// getDevices lists the devices of the user by walking its key bundles
+func getDevices(txn *badger.Txn, userID string) ([]apitypes.Device, error) {
+	var devices []apitypes.Device
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := keyBundleItemPrefix(userID)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		deviceID, err := strconv.ParseUint(string(item.Key()[len(prefix):]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse device ID: %w", err)
+		}
+
+		var keyBundle apitypes.KeyBundle
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &keyBundle)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal key bundle: %w", err)
+		}
+
+		devices = append(devices, apitypes.Device{ID: uint32(deviceID), RegistrationID: keyBundle.RegistrationID})
+	}
+
+	if len(devices) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	// Keys are ordered lexicographically, so device 10 would come before device 2
+	slices.SortFunc(devices, func(a, b apitypes.Device) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return devices, nil
+}
+
+func keyBundleItemKey(userID string, deviceID uint32) []byte {
+	return append(keyBundleItemPrefix(userID), strconv.FormatUint(uint64(deviceID), 10)...)
+}
+
+func keyBundleItemPrefix(userID string) []byte {
+	return []byte("keys#" + userID + ":")
+}
+
+func lastDeviceItemKey(userID string) []byte {
+	return []byte("lastdevice#" + userID)
 }
 
 func credItemKey(username string) []byte {
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/crossle/libsignal-protocol-go/keys/identity"
+	"github.com/crossle/libsignal-protocol-go/serialize"
+	"github.com/crossle/libsignal-protocol-go/util/keyhelper"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"signal-chat/internal/apitypes"
+	"signal-chat/server/passhash"
+	"testing"
+)
+
+func TestUserStore_VerifyCredentials(t *testing.T) {
+	t.Run("returns user for correct password", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		created, err := store.CreateUser("alice", "secret", apitypes.KeyBundle{})
+		require.NoError(t, err)
+
+		// Act
+		user, err := store.VerifyCredentials("alice", "secret")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, created, user)
+	})
+
+	t.Run("returns ErrInvalidCredentials for wrong password", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		_, err := store.CreateUser("alice", "secret", apitypes.KeyBundle{})
+		require.NoError(t, err)
+
+		// Act
+		_, err = store.VerifyCredentials("alice", "wrong")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("returns ErrInvalidCredentials for unknown user", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+
+		// Act
+		_, err := store.VerifyCredentials("mallory", "secret")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("rehashes password with preferred scheme on successful sign-in", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		bcryptScheme := passhash.NewBcrypt(bcrypt.MinCost)
+		argon2idScheme := testArgon2id()
+		oldStore := NewUserStore(db, testHasher(t, bcryptScheme))
+		_, err := oldStore.CreateUser("alice", "secret", apitypes.KeyBundle{})
+		require.NoError(t, err)
+		store := NewUserStore(db, testHasher(t, argon2idScheme, bcryptScheme))
+
+		// Act
+		_, err = store.VerifyCredentials("alice", "secret")
+
+		// Assert
+		require.NoError(t, err)
+		storedHash := readCredentials(t, db, "alice")
+		assert.True(t, argon2idScheme.Supports(storedHash), "stored hash should have been upgraded to argon2id")
+		_, err = store.VerifyCredentials("alice", "secret")
+		assert.NoError(t, err, "user should still be able to sign in with the upgraded hash")
+	})
+
+	t.Run("keeps stored hash when password is wrong", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		bcryptScheme := passhash.NewBcrypt(bcrypt.MinCost)
+		oldStore := NewUserStore(db, testHasher(t, bcryptScheme))
+		_, err := oldStore.CreateUser("alice", "secret", apitypes.KeyBundle{})
+		require.NoError(t, err)
+		originalHash := readCredentials(t, db, "alice")
+		store := NewUserStore(db, testHasher(t, testArgon2id(), bcryptScheme))
+
+		// Act
+		_, err = store.VerifyCredentials("alice", "wrong")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Equal(t, originalHash, readCredentials(t, db, "alice"))
+	})
+}
+
+func TestUserStore_GetPreKeyBundle(t *testing.T) {
+	t.Run("consumes one pre-key and reports how many are left", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1, 2))
+		require.NoError(t, err)
+
+		// Act
+		first, firstRemaining, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		second, secondRemaining, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, 1, firstRemaining)
+		assert.Equal(t, 0, secondRemaining)
+		assert.ElementsMatch(t, []uint32{1, 2}, []uint32{first.PreKey.ID, second.PreKey.ID})
+	})
+
+	t.Run("returns bundle without pre-key when pre-keys are exhausted", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		keyBundle := testSignedKeyBundle(t, testIdentityKeyPair(t))
+		user, err := store.CreateUser("alice", "secret", keyBundle)
+		require.NoError(t, err)
+		_, _, err = store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+
+		// Act
+		bundle, remaining, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 0, remaining)
+		assert.Nil(t, bundle.PreKey)
+		assert.Equal(t, keyBundle.IdentityKey, bundle.IdentityKey)
+		assert.Equal(t, keyBundle.SignedPreKey, bundle.SignedPreKey)
+	})
+
+	t.Run("returns registration ID of the user", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		keyBundle := testKeyBundle(1)
+		keyBundle.RegistrationID = 4242
+		user, err := store.CreateUser("alice", "secret", keyBundle)
+		require.NoError(t, err)
+
+		// Act
+		bundle, _, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint32(4242), bundle.RegistrationID)
+	})
+
+	t.Run("returns ErrUserNotFound for unknown user", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+
+		// Act
+		_, _, err := store.GetPreKeyBundle("non-existent", PrimaryDeviceID)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("returns ErrDeviceNotFound for unknown device", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+
+		// Act
+		_, _, err = store.GetPreKeyBundle(user.ID, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrDeviceNotFound)
+	})
+}
+
+func TestUserStore_Devices(t *testing.T) {
+	t.Run("registers devices with their own key bundles", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		primaryBundle := testKeyBundle(1)
+		primaryBundle.RegistrationID = 100
+		user, err := store.CreateUser("alice", "secret", primaryBundle)
+		require.NoError(t, err)
+		secondaryBundle := testKeyBundle(7)
+		secondaryBundle.RegistrationID = 200
+
+		// Act
+		deviceID, err := store.AddDevice(user.ID, secondaryBundle)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint32(2), deviceID)
+		devices, err := store.GetDevices(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Device{{ID: 1, RegistrationID: 100}, {ID: 2, RegistrationID: 200}}, devices)
+		bundle, _, err := store.GetPreKeyBundle(user.ID, deviceID)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(7), bundle.PreKey.ID)
+		assert.Equal(t, uint32(200), bundle.RegistrationID)
+	})
+
+	t.Run("returns ErrTooManyDevices when the limit is reached", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+		for i := 1; i < MaxDevices; i++ {
+			_, err := store.AddDevice(user.ID, testKeyBundle(1))
+			require.NoError(t, err)
+		}
+
+		// Act
+		_, err = store.AddDevice(user.ID, testKeyBundle(1))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrTooManyDevices)
+	})
+
+	t.Run("doesn't reuse the ID of a removed device", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+		removedID, err := store.AddDevice(user.ID, testKeyBundle(1))
+		require.NoError(t, err)
+		require.NoError(t, store.RemoveDevice(user.ID, removedID))
+
+		// Act
+		deviceID, err := store.AddDevice(user.ID, testKeyBundle(1))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint32(3), deviceID)
+		devices, err := store.GetDevices(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Device{{ID: 1}, {ID: 3}}, devices)
+		_, err = store.GetDevice(user.ID, removedID)
+		assert.ErrorIs(t, err, ErrDeviceNotFound)
+	})
+
+	t.Run("refuses to remove the primary device", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+
+		// Act
+		err = store.RemoveDevice(user.ID, PrimaryDeviceID)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrPrimaryDevice)
+	})
+}
+
+func TestUserStore_MigrateKeyBundles(t *testing.T) {
+	t.Run("moves key bundles of the single device layout to the primary device", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+		legacyBundle, err := json.Marshal(testKeyBundle(5))
+		require.NoError(t, err)
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			if err := txn.Delete(keyBundleItemKey(user.ID, PrimaryDeviceID)); err != nil {
+				return err
+			}
+			return txn.Set([]byte("keys#"+user.ID), legacyBundle)
+		}))
+
+		// Act
+		migrated, err := store.MigrateKeyBundles()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated)
+		bundle, _, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(5), bundle.PreKey.ID)
+		migrated, err = store.MigrateKeyBundles()
+		require.NoError(t, err)
+		assert.Zero(t, migrated, "migrated bundles should not be moved again")
+	})
+}
+
+func TestUserStore_AddPreKeys(t *testing.T) {
+	t.Run("appends pre-keys to the key bundle", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+
+		// Act
+		count, err := store.AddPreKeys(user.ID, PrimaryDeviceID, testKeyBundle(2, 3).PreKeys)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		_, remaining, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, remaining)
+	})
+
+	t.Run("returns ErrDuplicatePreKey when a pre-key ID is already stored", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(1))
+		require.NoError(t, err)
+
+		// Act
+		_, err = store.AddPreKeys(user.ID, PrimaryDeviceID, testKeyBundle(2, 1).PreKeys)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrDuplicatePreKey)
+		_, remaining, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, remaining)
+	})
+
+	t.Run("returns ErrTooManyPreKeys when the limit would be exceeded", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		ids := make([]uint32, MaxPreKeys)
+		for i := range ids {
+			ids[i] = uint32(i + 1)
+		}
+		user, err := store.CreateUser("alice", "secret", testKeyBundle(ids...))
+		require.NoError(t, err)
+
+		// Act
+		_, err = store.AddPreKeys(user.ID, PrimaryDeviceID, testKeyBundle(MaxPreKeys+1).PreKeys)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrTooManyPreKeys)
+	})
+}
+
+func TestUserStore_SetSignedPreKey(t *testing.T) {
+	t.Run("replaces signed pre-key signed with the identity key", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		identityKeyPair := testIdentityKeyPair(t)
+		user, err := store.CreateUser("alice", "secret", testSignedKeyBundle(t, identityKeyPair))
+		require.NoError(t, err)
+		signedPreKey := testSignedPreKey(t, identityKeyPair, 1)
+
+		// Act
+		err = store.SetSignedPreKey(user.ID, PrimaryDeviceID, signedPreKey)
+
+		// Assert
+		require.NoError(t, err)
+		bundle, _, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		assert.Equal(t, signedPreKey, bundle.SignedPreKey)
+	})
+
+	t.Run("returns ErrInvalidSignature when signed with another identity key", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		store := NewUserStore(db, testHasher(t, passhash.NewBcrypt(bcrypt.MinCost)))
+		keyBundle := testSignedKeyBundle(t, testIdentityKeyPair(t))
+		user, err := store.CreateUser("alice", "secret", keyBundle)
+		require.NoError(t, err)
+
+		// Act
+		err = store.SetSignedPreKey(user.ID, PrimaryDeviceID, testSignedPreKey(t, testIdentityKeyPair(t), 1))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		bundle, _, err := store.GetPreKeyBundle(user.ID, PrimaryDeviceID)
+		require.NoError(t, err)
+		assert.Equal(t, keyBundle.SignedPreKey, bundle.SignedPreKey)
+	})
+}
+
+func testIdentityKeyPair(t *testing.T) *identity.KeyPair {
+	t.Helper()
+
+	keyPair, err := keyhelper.GenerateIdentityKeyPair()
+	require.NoError(t, err)
+	return keyPair
+}
+
+func testSignedKeyBundle(t *testing.T, identityKeyPair *identity.KeyPair) apitypes.KeyBundle {
+	t.Helper()
+
+	keyBundle := testKeyBundle(1)
+	identityPub := identityKeyPair.PublicKey().PublicKey().PublicKey()
+	keyBundle.IdentityKey = identityPub[:]
+	keyBundle.SignedPreKey = testSignedPreKey(t, identityKeyPair, 0)
+	return keyBundle
+}
+
+func testSignedPreKey(t *testing.T, identityKeyPair *identity.KeyPair, id uint32) apitypes.SignedPreKey {
+	t.Helper()
+
+	serializer := serialize.NewJSONSerializer()
+	record, err := keyhelper.GenerateSignedPreKey(identityKeyPair, id, serializer.SignedPreKeyRecord)
+	require.NoError(t, err)
+	pub := record.KeyPair().PublicKey().PublicKey()
+	signature := record.Signature()
+	return apitypes.SignedPreKey{ID: id, PublicKey: pub[:], Signature: signature[:]}
+}
+
+func testKeyBundle(preKeyIDs ...uint32) apitypes.KeyBundle {
+	preKeys := make([]apitypes.PreKey, 0, len(preKeyIDs))
+	for _, id := range preKeyIDs {
+		preKeys = append(preKeys, apitypes.PreKey{ID: id, PublicKey: make([]byte, 33)})
+	}
+	return apitypes.KeyBundle{PreKeys: preKeys}
+}
+
+func testHasher(t *testing.T, preferred passhash.Scheme, legacy ...passhash.Scheme) *passhash.Hasher {
+	t.Helper()
+
+	hasher, err := passhash.NewHasher(preferred, legacy...)
+	require.NoError(t, err)
+	return hasher
+}
+
+func testArgon2id() *passhash.Argon2id {
+	a := passhash.NewArgon2id()
+	a.Memory = 1024
+	a.Time = 1
+	return a
+}
+
+func readCredentials(t *testing.T, db *badger.DB, username string) []byte {
+	t.Helper()
+
+	var hash []byte
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(credItemKey(username))
+		if err != nil {
+			return err
+		}
+		hash, err = item.ValueCopy(nil)
+		return err
+	})
+	require.NoError(t, err)
+	return hash
+}
+
+func testDB(t *testing.T) (*badger.DB, func()) {
+	t.Helper()
+
+	opts := badger.DefaultOptions("").
+		WithInMemory(true).
+		WithLogger(nil).
+		WithNumMemtables(1).
+		WithNumLevelZeroTables(1).
+		WithNumLevelZeroTablesStall(2).
+		WithValueLogFileSize(1 << 20)
+
+	db, err := badger.Open(opts)
+	require.NoError(t, err)
+
+	return db, func() {
+		err := db.Close()
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,232 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// busWriteTimeout is the time a bus connection has to accept a message before it's dropped
+	busWriteTimeout = 5 * time.Second
+	// busReconnectDelay is the delay before the first attempt to reconnect to the broker, it doubles after every
+	// failed attempt up to busMaxReconnectDelay
+	busReconnectDelay    = 100 * time.Millisecond
+	busMaxReconnectDelay = 10 * time.Second
+)
+
+// Broker links the buses of several server instances, of this process or of others. It relays every message published
+// by one of them to all the others. The messages stored for a device travel on the bus, so every instance keeps its
+// own database.
+type Broker struct {
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	mu       sync.Mutex
+}
+
+// ListenBroker starts a broker accepting bus connections on the address, e.g. a Unix socket path or a loopback TCP
+// address
+func ListenBroker(network, address string) (*Broker, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go b.serve()
+
+	return b, nil
+}
+
+// Addr returns the address the broker listens on
+func (b *Broker) Addr() net.Addr {
+	return b.listener.Addr()
+}
+
+// Close stops accepting connections and disconnects every bus
+func (b *Broker) Close() error {
+	err := b.listener.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = make(map[net.Conn]struct{})
+
+	return err
+}
+
+func (b *Broker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Bus broker stopped accepting connections: %v", err)
+			}
+			return
+		}
+
+		go b.relay(conn)
+	}
+}
+
+// relay forwards the messages published on the connection to every other connection
+func (b *Broker) relay(conn net.Conn) {
+	decoder := json.NewDecoder(conn)
+
+	b.mu.Lock()
+	b.conns[conn] = struct{}{}
+	b.mu.Unlock()
+	defer b.drop(conn)
+
+	for {
+		var msg json.RawMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return
+		}
+
+		b.mu.Lock()
+		for other := range b.conns {
+			if other == conn {
+				continue
+			}
+			_ = other.SetWriteDeadline(time.Now().Add(busWriteTimeout))
+			if _, err := other.Write(append(msg, '\n')); err != nil {
+				log.Printf("Bus broker dropped connection %s: %v", other.RemoteAddr(), err)
+				other.Close()
+				delete(b.conns, other)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *Broker) drop(conn net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	conn.Close()
+	delete(b.conns, conn)
+}
+
+// SocketBus is the bus of a server instance connected to a Broker. Messages are handed to the subscribers of this
+// instance directly and relayed by the broker to the other instances. The connection is re-established with backoff
+// when it's lost, messages published in the meantime only reach this instance.
+type SocketBus struct {
+	network string
+	address string
+	conn    net.Conn
+	local   *LocalBus
+	encoder *json.Encoder
+	mu      sync.Mutex
+	closed  atomic.Bool
+}
+
+// DialBus connects to the broker listening on the address
+func DialBus(network, address string) (*SocketBus, error) {
+	b := &SocketBus{
+		network: network,
+		address: address,
+		local:   NewLocalBus(),
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	b.conn = conn
+	b.encoder = json.NewEncoder(conn)
+	go b.readLoop(conn, json.NewDecoder(conn))
+
+	return b, nil
+}
+
+func (b *SocketBus) Publish(msg BusMessage) error {
+	if err := b.local.Publish(msg); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.conn.SetWriteDeadline(time.Now().Add(busWriteTimeout)); err != nil {
+		return err
+	}
+	return b.encoder.Encode(msg)
+}
+
+func (b *SocketBus) Subscribe(handler func(msg BusMessage)) {
+	b.local.Subscribe(handler)
+}
+
+func (b *SocketBus) Close() error {
+	b.closed.Store(true)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conn.Close()
+}
+
+// readLoop hands the messages relayed by the broker to the subscribers of this instance and reconnects when the
+// connection is lost
+func (b *SocketBus) readLoop(conn net.Conn, decoder *json.Decoder) {
+	for {
+		var msg BusMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if b.closed.Load() {
+				return
+			}
+			log.Printf("Bus disconnected from broker: %v", err)
+
+			conn, decoder = b.reconnect()
+			if conn == nil {
+				return
+			}
+			continue
+		}
+
+		if err := b.local.Publish(msg); err != nil {
+			log.Printf("Failed to handle bus message: %v", err)
+		}
+	}
+}
+
+// reconnect dials the broker until it succeeds or the bus is closed, in which case it returns a nil connection
+func (b *SocketBus) reconnect() (net.Conn, *json.Decoder) {
+	delay := busReconnectDelay
+	for {
+		time.Sleep(delay)
+		if b.closed.Load() {
+			return nil, nil
+		}
+
+		conn, err := net.Dial(b.network, b.address)
+		if err != nil {
+			log.Printf("Failed to reconnect bus to broker: %v", err)
+			delay = min(delay*2, busMaxReconnectDelay)
+			continue
+		}
+
+		b.mu.Lock()
+		// The bus may have been closed while dialing
+		if b.closed.Load() {
+			b.mu.Unlock()
+			conn.Close()
+			return nil, nil
+		}
+		b.conn = conn
+		b.encoder = json.NewEncoder(conn)
+		b.mu.Unlock()
+
+		log.Printf("Bus reconnected to broker")
+		return conn, json.NewDecoder(conn)
+	}
+}
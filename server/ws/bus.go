@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"signal-chat/internal/apitypes"
+	"sync"
+)
+
+// Bus carries deliveries between the managers of all server instances, so that a device gets its messages live
+// whichever instance it's connected to
+type Bus interface {
+	// Publish hands the message to every subscriber, including the ones of this instance
+	Publish(msg BusMessage) error
+	// Subscribe registers a handler for every message published on the bus
+	Subscribe(handler func(msg BusMessage))
+	Close() error
+}
+
+// BusMessage either carries a message appended to the inbox of a device, or an ephemeral message for every connected
+// device of the user. The instances don't share their inboxes: the instance the device is connected to moves the
+// message to its own inbox, and tells the origin to remove it from its inbox.
+type BusMessage struct {
+	UserID   string `json:"userID"`
+	DeviceID uint32 `json:"deviceID,omitempty"`
+	// Origin is the instance whose inbox holds Message, or the one Moved is sent back to
+	Origin string `json:"origin,omitempty"`
+	// Message was appended to the inbox of the device on the origin instance, with its sequence number there
+	Message *apitypes.WSMessage `json:"message,omitempty"`
+	// ExpiresAt is the time in Unix milliseconds Message must not outlive, zero only applies the TTL of the inbox
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	// Moved is the sequence number of a message the origin can remove, as another instance moved it to its inbox
+	Moved uint64 `json:"moved,omitempty"`
+	// Ephemeral is sent to the devices of the user without being stored, DeviceID is ignored
+	Ephemeral *apitypes.WSMessage `json:"ephemeral,omitempty"`
+}
+
+// LocalBus links the managers of a single process
+type LocalBus struct {
+	handlers []func(msg BusMessage)
+	mu       sync.RWMutex
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish hands the message to the handlers one after another. The handlers may publish messages themselves.
+func (b *LocalBus) Publish(msg BusMessage) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (b *LocalBus) Subscribe(handler func(msg BusMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *LocalBus) Close() error {
+	return nil
+}
@@ -0,0 +1,218 @@
+package ws
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"signal-chat/internal/apitypes"
+	"signal-chat/server/conversation"
+	"testing"
+	"time"
+)
+
+func TestLocalBus(t *testing.T) {
+	t.Run("should hand published messages to every subscriber", func(t *testing.T) {
+		// Arrange
+		bus := NewLocalBus()
+		var first, second []BusMessage
+		bus.Subscribe(func(msg BusMessage) { first = append(first, msg) })
+		bus.Subscribe(func(msg BusMessage) { second = append(second, msg) })
+
+		// Act
+		err := bus.Publish(BusMessage{UserID: "user-1", DeviceID: 2})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []BusMessage{{UserID: "user-1", DeviceID: 2}}, first)
+		assert.Equal(t, first, second)
+	})
+}
+
+func TestSocketBus(t *testing.T) {
+	t.Run("should relay published messages to the other instances through the broker", func(t *testing.T) {
+		// Arrange
+		broker, err := ListenBroker("unix", filepath.Join(t.TempDir(), "bus.sock"))
+		require.NoError(t, err)
+		defer broker.Close()
+
+		publisher, err := DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer publisher.Close()
+		subscriber, err := DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer subscriber.Close()
+
+		local := make(chan BusMessage, 10)
+		remote := make(chan BusMessage, 10)
+		publisher.Subscribe(func(msg BusMessage) { local <- msg })
+		subscriber.Subscribe(func(msg BusMessage) { remote <- msg })
+
+		// Wait for the broker to accept both connections
+		require.Eventually(t, func() bool {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+			return len(broker.conns) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		event := &apitypes.WSMessage{ID: "msg-1", Type: apitypes.MessageTypeTyping, Data: json.RawMessage(`{"typing":true}`)}
+
+		// Act
+		err = publisher.Publish(BusMessage{UserID: "user-1", Ephemeral: event})
+
+		// Assert
+		require.NoError(t, err)
+		expected := BusMessage{UserID: "user-1", Ephemeral: event}
+		assert.Equal(t, expected, <-local)
+		select {
+		case msg := <-remote:
+			assert.Equal(t, expected, msg)
+		case <-time.After(time.Second):
+			t.Fatal("Message was not relayed to the other instance")
+		}
+
+		// Wait for a possible echo from the broker
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, local, "the broker should not send messages back to the publisher")
+	})
+
+	t.Run("should reconnect when the broker restarts", func(t *testing.T) {
+		// Arrange
+		address := filepath.Join(t.TempDir(), "bus.sock")
+		broker, err := ListenBroker("unix", address)
+		require.NoError(t, err)
+
+		publisher, err := DialBus("unix", address)
+		require.NoError(t, err)
+		defer publisher.Close()
+		subscriber, err := DialBus("unix", address)
+		require.NoError(t, err)
+		defer subscriber.Close()
+
+		remote := make(chan BusMessage, 10)
+		subscriber.Subscribe(func(msg BusMessage) { remote <- msg })
+
+		require.NoError(t, broker.Close())
+		broker, err = ListenBroker("unix", address)
+		require.NoError(t, err)
+		defer broker.Close()
+
+		require.Eventually(t, func() bool {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+			return len(broker.conns) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// Act
+		err = publisher.Publish(BusMessage{UserID: "user-1", DeviceID: 1})
+
+		// Assert
+		require.NoError(t, err)
+		select {
+		case msg := <-remote:
+			assert.Equal(t, BusMessage{UserID: "user-1", DeviceID: 1}, msg)
+		case <-time.After(time.Second):
+			t.Fatal("Message was not relayed after reconnecting")
+		}
+	})
+}
+
+func TestManager_Bus(t *testing.T) {
+	t.Run("should deliver messages live to devices connected to another instance", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		bus := NewLocalBus()
+		sender := NewManagerWithBus(db, convRepo, NewFakeDeviceStore(), bus)
+		receiver := NewManagerWithBus(db, convRepo, NewFakeDeviceStore(), bus)
+
+		recipientConn := NewFakeWebSocketConn()
+		require.NoError(t, receiver.RegisterClient("user-2", 1, 0, recipientConn))
+
+		// Act
+		err := sender.BroadcastNewMessage("user-1", 1, "msg-123", time.Now().UnixMilli(), 0, apitypes.SendMessageRequest{
+			ConversationID: "conv-123",
+			Content:        []byte("encrypted-message"),
+		})
+		require.NoError(t, err)
+		typingErr := sender.RelayTyping("user-1", 1, apitypes.WSTypingPayload{ConversationID: "conv-123", Typing: true})
+
+		// Assert
+		require.NoError(t, typingErr)
+		var types []apitypes.WSMessageType
+		for len(types) < 2 {
+			select {
+			case msgBytes := <-recipientConn.writeChan:
+				var msg apitypes.WSMessage
+				require.NoError(t, json.Unmarshal(msgBytes, &msg))
+				types = append(types, msg.Type)
+			case <-time.After(time.Second):
+				t.Fatalf("Only received %v", types)
+			}
+		}
+		assert.ElementsMatch(t, []apitypes.WSMessageType{apitypes.MessageTypeNewMessage, apitypes.MessageTypeTyping}, types)
+	})
+
+	t.Run("should move messages to the inbox of the instance with its own database the device is connected to", func(t *testing.T) {
+		// Arrange
+		senderDB, senderDBClose := testDB(t)
+		defer senderDBClose()
+		receiverDB, receiverDBClose := testDB(t)
+		defer receiverDBClose()
+
+		broker, err := ListenBroker("unix", filepath.Join(t.TempDir(), "bus.sock"))
+		require.NoError(t, err)
+		defer broker.Close()
+		senderBus, err := DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer senderBus.Close()
+		receiverBus, err := DialBus("unix", broker.Addr().String())
+		require.NoError(t, err)
+		defer receiverBus.Close()
+		require.Eventually(t, func() bool {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+			return len(broker.conns) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		sender := NewManagerWithBus(senderDB, convRepo, NewFakeDeviceStore(), senderBus)
+		receiver := NewManagerWithBus(receiverDB, convRepo, NewFakeDeviceStore(), receiverBus)
+
+		recipientConn := NewFakeWebSocketConn()
+		require.NoError(t, receiver.RegisterClient("user-2", 1, 0, recipientConn))
+
+		// Act
+		err = sender.BroadcastNewMessage("user-1", 1, "msg-123", time.Now().UnixMilli(), 0, apitypes.SendMessageRequest{
+			ConversationID: "conv-123",
+			Content:        []byte("encrypted-message"),
+		})
+		require.NoError(t, err)
+
+		// Assert
+		var msg apitypes.WSMessage
+		select {
+		case msgBytes := <-recipientConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+		case <-time.After(time.Second):
+			t.Fatal("Message was not delivered to the device connected to the other instance")
+		}
+		assert.Equal(t, apitypes.MessageTypeNewMessage, msg.Type)
+		var payload apitypes.WSNewMessagePayload
+		require.NoError(t, json.Unmarshal(msg.Data, &payload))
+		assert.Equal(t, []byte("encrypted-message"), payload.Content)
+
+		stored, _, err := receiver.inbox(clientID("user-2", 1)).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, stored, 1, "the message should wait in the inbox of the receiver for its ACK")
+		assert.Equal(t, msg.Seq, stored[0].Seq)
+		assert.Eventually(t, func() bool {
+			messages, _, err := sender.inbox(clientID("user-2", 1)).LoadPage(0, pageSize, pageBytes)
+			return err == nil && len(messages) == 0
+		}, time.Second, 10*time.Millisecond, "the sender should remove the moved message from its inbox")
+	})
+}
@@ -11,15 +11,18 @@ import (
 	"time"
 )
 
-type pendingACK struct {
-	message *apitypes.WSMessage
-	sentAt  time.Time
-}
+const (
+	// pageSize and pageBytes bound the number and the total size of the messages read from the inbox at once, and so
+	// the size of a single sync message
+	pageSize  = 100
+	pageBytes = 64 << 10
+)
 
-type MessageStorage interface {
-	Store(messages []*apitypes.WSMessage) error
-	Delete(messageIDs []string) error
-	LoadAll() ([]apitypes.WSMessage, error)
+// MessageInbox is the durable queue the client delivers messages from
+type MessageInbox interface {
+	Append(message *apitypes.WSMessage) error
+	Ack(seq uint64) error
+	LoadPage(seq uint64, limit, maxBytes int) ([]apitypes.WSMessage, bool, error)
 }
 
 type Client struct {
@@ -29,36 +32,66 @@ type Client struct {
 	pingPeriod     time.Duration
 	id             string
 	conn           Connection
-	storage        MessageStorage
-	// Buffered channel of outbound messages.
-	pendingACKs         map[string]pendingACK
-	mu                  sync.RWMutex
-	lastSentTime        int64
-	counter             uint64
-	closeOnce           sync.Once
-	closed              atomic.Bool
-	send                chan []byte
-	writeDone           chan struct{}
+	codec          apitypes.Codec
+	inbox          MessageInbox
+	mu             sync.RWMutex
+	// delivered is the sequence number of the last message handed to the connection, acked is the sequence number
+	// up to which the device confirmed processing
+	delivered   uint64
+	acked       uint64
+	deliveredAt time.Time
+	// syncing is set while the backlog stored before the device connected is sent page by page
+	syncing   bool
+	closeOnce sync.Once
+	closed    atomic.Bool
+	// closeCode and closeReason are sent in the close frame, they are set once before the send channel is closed
+	closeCode   int
+	closeReason string
+	send        chan []byte
+	notify      chan struct{}
+	done        chan struct{}
+	writeDone   chan struct{}
+	// stopped is closed once the connection is closed and the last request of the device was answered
+	stopped             chan struct{}
 	disconnectedHandler func()
+	requestHandler      func(request apitypes.WSMessage) apitypes.WSMessage
+	eventHandler        func(event apitypes.WSMessage)
 }
 
-func NewClient(id string, conn Connection, storage MessageStorage) *Client {
+// NewClient starts delivering the inbox to the connection. cursor is the sequence number of the last message the
+// device processed, messages up to it are acknowledged right away.
+func NewClient(id string, conn Connection, inbox MessageInbox, cursor uint64) *Client {
 	client := &Client{
-		maxMessageSize: 512,
+		maxMessageSize: apitypes.WSMaxMessageSize,
 		writeWait:      10 * time.Second,
 		readWait:       60 * time.Second,
 		pingPeriod:     55 * time.Second,
 		id:             id,
 		conn:           conn,
-		storage:        storage,
+		codec:          apitypes.CodecForSubprotocol(conn.Subprotocol()),
+		inbox:          inbox,
+		delivered:      cursor,
+		acked:          cursor,
+		syncing:        true,
 		send:           make(chan []byte, 256),
-		pendingACKs:    make(map[string]pendingACK),
+		notify:         make(chan struct{}, 1),
+		done:           make(chan struct{}),
 		writeDone:      make(chan struct{}, 1),
+		stopped:        make(chan struct{}),
+	}
+
+	if cursor > 0 {
+		if err := inbox.Ack(cursor); err != nil {
+			log.Printf("client %s: failed to acknowledge messages up to cursor %d: %v", id, cursor, err)
+		}
 	}
 
+	// The first page of the backlog is queued before any live delivery
+	client.deliverPending()
+
 	go client.writePump()
 	go client.readPump()
-	go client.syncClient()
+	go client.deliverPump()
 
 	return client
 }
@@ -69,123 +102,158 @@ func (c *Client) SetDisconnectedHandler(handler func()) {
 	c.disconnectedHandler = handler
 }
 
-func (c *Client) SendMessage(message *apitypes.WSMessage) error {
-	if c.closed.Load() {
-		if err := c.storage.Store([]*apitypes.WSMessage{message}); err != nil {
-			return fmt.Errorf("client connection is closed; failed to store message: %w", err)
-		}
-		return nil
-	}
+// SetRequestHandler sets the handler answering the requests of the device with a response or error message
+func (c *Client) SetRequestHandler(handler func(request apitypes.WSMessage) apitypes.WSMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestHandler = handler
+}
 
-	payload, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
+// SetEventHandler sets the handler of the ephemeral events sent by the device
+func (c *Client) SetEventHandler(handler func(event apitypes.WSMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eventHandler = handler
+}
 
+// Notify tells the client that new messages were appended to its inbox
+func (c *Client) Notify() {
 	select {
-	case c.send <- payload:
-		c.mu.Lock()
-		c.pendingACKs[message.ID] = pendingACK{message: message, sentAt: time.Now()}
-		c.mu.Unlock()
-		return nil
+	case c.notify <- struct{}{}:
 	default:
-		// send channel is closed or full
-		if err := c.storage.Store([]*apitypes.WSMessage{message}); err != nil {
-			return fmt.Errorf("client send channel closed or full; failed to store message: %w", err)
-		}
-		return nil
+		// a delivery is already pending
 	}
 }
 
 func (c *Client) Close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// Restart closes the connection telling the device that the server is restarting. The messages queued before are
+// still written.
+func (c *Client) Restart() {
+	c.closeWith(websocket.CloseServiceRestart, apitypes.WSCloseReasonRestart)
+}
+
+// Stopped returns a channel that is closed once the connection is closed and the last request of the device was
+// answered
+func (c *Client) Stopped() <-chan struct{} {
+	return c.stopped
+}
+
+func (c *Client) closeWith(code int, reason string) {
 	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.closeCode = code
+		c.closeReason = reason
 		c.closed.Store(true)
+		close(c.done)
 		close(c.send)
-		c.storePendingMessages()
 	})
 }
 
-func (c *Client) handleExpiredACKs() error {
-	now := time.Now()
+// resendUnacknowledged rewinds delivery to the last acknowledged message when the device didn't confirm the
+// delivered ones in time
+func (c *Client) resendUnacknowledged() {
+	c.mu.Lock()
+	expired := c.acked < c.delivered && time.Since(c.deliveredAt) > c.readWait
+	if expired {
+		c.delivered = c.acked
+	}
+	c.mu.Unlock()
+
+	if expired {
+		c.Notify()
+	}
+}
 
-	expired := make([]*apitypes.WSMessage, 0, len(c.pendingACKs))
+func (c *Client) handleAcknowledgement(ack apitypes.WSMessage) error {
 	c.mu.Lock()
-	for id, pending := range c.pendingACKs {
-		if now.Sub(pending.sentAt) > c.readWait {
-			delete(c.pendingACKs, id)
-			expired = append(expired, pending.message)
-		}
+	if ack.Seq <= c.acked {
+		c.mu.Unlock()
+		return nil
 	}
+	c.acked = ack.Seq
+	c.delivered = max(c.delivered, ack.Seq)
 	c.mu.Unlock()
 
-	for _, msg := range expired {
-		if err := c.storage.Store([]*apitypes.WSMessage{msg}); err != nil {
-			return fmt.Errorf("failed to store message with expired ACK: %w", err)
-		}
+	if err := c.inbox.Ack(ack.Seq); err != nil {
+		return fmt.Errorf("failed to delete acknowledged messages from db: %w", err)
 	}
 
+	// Delivery pauses while a sync page is unacknowledged or the send buffer is full, the ACK lets it continue
+	c.Notify()
 	return nil
 }
 
-func (c *Client) storePendingMessages() {
-	messages := make([]*apitypes.WSMessage, 0, len(c.pendingACKs))
+// deliverPump delivers new messages as they are appended to the inbox
+func (c *Client) deliverPump() {
+	for {
+		select {
+		case <-c.notify:
+			c.deliverPending()
+		case <-c.done:
+			return
+		}
+	}
+}
 
+// deliverPending sends the messages appended to the inbox after the last delivered one. While the client is syncing,
+// the backlog is sent as sync messages of one page each, and the next page only once the device acknowledged the
+// previous one.
+func (c *Client) deliverPending() {
 	c.mu.RLock()
-	for _, pending := range c.pendingACKs {
-		messages = append(messages, pending.message)
-	}
+	cursor := c.delivered
+	syncing := c.syncing
+	inFlight := c.delivered > c.acked
 	c.mu.RUnlock()
 
-	if err := c.storage.Store(messages); err != nil {
-		log.Printf("client %s: failed to store pending messages: %v", c.id, err)
-	}
-
-	c.mu.Lock()
-	c.pendingACKs = make(map[string]pendingACK)
-	c.mu.Unlock()
-}
-
-func (c *Client) handleAcknowledgement(ack apitypes.WSMessage) error {
-	c.mu.Lock()
-	pending, ok := c.pendingACKs[ack.ID]
-	if !ok {
-		c.mu.Unlock()
-		return nil
+	if syncing {
+		if !inFlight {
+			c.syncPage(cursor)
+		}
+		return
 	}
-	delete(c.pendingACKs, ack.ID)
-	c.mu.Unlock()
 
-	if pending.message.Type == apitypes.MessageTypeSync {
-		var syncPayload apitypes.WSSyncPayload
-		if err := json.Unmarshal(pending.message.Data, &syncPayload); err != nil {
-			return err
+	for {
+		messages, hasMore, err := c.inbox.LoadPage(cursor, pageSize, pageBytes)
+		if err != nil {
+			log.Printf("client %s: failed to load websocket messages from storage: %v", c.id, err)
+			return
 		}
 
-		messageIDs := make([]string, 0, len(syncPayload.Messages))
-		for _, msg := range syncPayload.Messages {
-			messageIDs = append(messageIDs, msg.ID)
+		for i := range messages {
+			if !c.enqueue(&messages[i]) {
+				// The remaining messages stay in the inbox until the device catches up
+				return
+			}
 		}
 
-		if err := c.storage.Delete(messageIDs); err != nil {
-			return fmt.Errorf("failed to delete synced messages from db: %w", err)
+		if !hasMore {
+			return
 		}
+		cursor = messages[len(messages)-1].Seq
 	}
-
-	return nil
 }
 
-func (c *Client) syncClient() {
-	messages, err := c.storage.LoadAll()
+// syncPage sends the page of the backlog following the cursor as a single sync message and ends syncing after the last
+// page
+func (c *Client) syncPage(cursor uint64) {
+	messages, hasMore, err := c.inbox.LoadPage(cursor, pageSize, pageBytes)
 	if err != nil {
 		log.Printf("client %s: failed to load websocket messages from storage: %v", c.id, err)
 		return
 	}
 	if len(messages) == 0 {
+		c.finishSync()
 		return
 	}
 
 	payload := apitypes.WSSyncPayload{
 		Messages: messages,
+		HasMore:  hasMore,
 	}
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -193,15 +261,136 @@ func (c *Client) syncClient() {
 		return
 	}
 
+	// The sync message carries the sequence number of its last message, so its ACK acknowledges the whole page
 	syncMsg := &apitypes.WSMessage{
 		ID:   generateMessageID(),
+		Seq:  messages[len(messages)-1].Seq,
 		Type: apitypes.MessageTypeSync,
 		Data: payloadJSON,
 	}
-	if err = c.SendMessage(syncMsg); err != nil {
-		log.Printf("client %s: failed to send sync message to the client: %v", c.id, err)
+	if !c.enqueue(syncMsg) {
+		log.Printf("client %s: failed to send sync message to the client", c.id)
+		return
+	}
+
+	if !hasMore {
+		c.finishSync()
+	}
+}
+
+func (c *Client) finishSync() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncing = false
+}
+
+// enqueue hands the message to the write pump, messages larger than the read limit of the device are skipped. It
+// returns false when the client is closed or its send buffer is full.
+func (c *Client) enqueue(message *apitypes.WSMessage) bool {
+	payload, err := c.codec.MarshalWSMessage(message)
+	if err != nil {
+		log.Printf("client %s: failed to marshal websocket message: %v", c.id, err)
+		return false
+	}
+
+	if int64(len(payload)) > c.maxMessageSize {
+		log.Printf("client %s: skipping stored websocket message %d of %d bytes, it exceeds the read limit of the device", c.id, message.Seq, len(payload))
+		c.skip(message.Seq)
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.trySend(payload) {
+		return false
+	}
+	c.delivered = max(c.delivered, message.Seq)
+	c.deliveredAt = time.Now()
+	return true
+}
+
+// skip passes over a stored message the device can't read, it would close the connection and get the same message
+// again after reconnecting. Without earlier messages waiting for their ACK the message is acknowledged right away, so
+// that syncing continues, otherwise it's removed with the ACK of a later message.
+func (c *Client) skip(seq uint64) {
+	c.mu.Lock()
+	inFlight := c.delivered > c.acked
+	c.delivered = max(c.delivered, seq)
+	c.mu.Unlock()
+
+	if !inFlight {
+		if err := c.handleAcknowledgement(apitypes.WSMessage{Seq: seq}); err != nil {
+			log.Printf("client %s: failed to acknowledge skipped message %d: %v", c.id, seq, err)
+		}
+	}
+}
+
+// trySend queues the payload without blocking, the caller must hold the lock
+func (c *Client) trySend(payload []byte) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	select {
+	case c.send <- payload:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleRequest answers the request right away, responses aren't stored in the inbox as the device waits for them
+func (c *Client) handleRequest(request apitypes.WSMessage) {
+	c.mu.RLock()
+	handler := c.requestHandler
+	c.mu.RUnlock()
+
+	if handler == nil {
 		return
 	}
+
+	response := handler(request)
+	// The payload of a response depends on the type of the request it answers
+	payload, err := c.codec.MarshalWSResponse(&response, request.Type)
+	if err != nil {
+		log.Printf("client %s: failed to marshal response to request %s: %v", c.id, request.ID, err)
+		return
+	}
+	if !c.sendPayload(payload) {
+		log.Printf("client %s: failed to send response to request %s", c.id, request.ID)
+	}
+}
+
+// handleEvent passes an ephemeral event of the device to the event handler
+func (c *Client) handleEvent(event apitypes.WSMessage) {
+	c.mu.RLock()
+	handler := c.eventHandler
+	c.mu.RUnlock()
+
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// sendEphemeral sends a message that isn't stored in the inbox, it's dropped when the client is closed or its send
+// buffer is full
+func (c *Client) sendEphemeral(message *apitypes.WSMessage) bool {
+	payload, err := c.codec.MarshalWSMessage(message)
+	if err != nil {
+		log.Printf("client %s: failed to marshal websocket message: %v", c.id, err)
+		return false
+	}
+
+	return c.sendPayload(payload)
+}
+
+// sendPayload queues an encoded message that isn't stored in the inbox
+func (c *Client) sendPayload(payload []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.trySend(payload)
 }
 
 func (c *Client) notifyDisconnected() {
@@ -219,15 +408,14 @@ func (c *Client) readPump() {
 		c.writeDone <- struct{}{}
 		_ = c.conn.Close()
 		c.Close()
+		close(c.stopped)
 	}()
 
 	c.conn.SetReadLimit(c.maxMessageSize)
 	_ = c.conn.SetReadDeadline(time.Now().Add(c.readWait))
 
 	c.conn.SetPongHandler(func(string) error {
-		if err := c.handleExpiredACKs(); err != nil {
-			log.Printf("client %s: failed to persist expired ACKs: %v", c.id, err)
-		}
+		c.resendUnacknowledged()
 		_ = c.conn.SetReadDeadline(time.Now().Add(c.readWait))
 		return nil
 	})
@@ -235,7 +423,7 @@ func (c *Client) readPump() {
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseServiceRestart) {
 				log.Printf("client %s: unexpected websocket error: %v", c.id, err)
 			}
 
@@ -244,7 +432,7 @@ func (c *Client) readPump() {
 		}
 
 		var wsMsg apitypes.WSMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
+		if err := c.codec.UnmarshalWSMessage(message, &wsMsg); err != nil {
 			log.Printf("client %s: malformed websocket message: %v", c.id, err)
 			continue
 		}
@@ -252,8 +440,12 @@ func (c *Client) readPump() {
 		switch wsMsg.Type {
 		case apitypes.MessageTypeAck:
 			if err := c.handleAcknowledgement(wsMsg); err != nil {
-				log.Printf("client %s: failed to handle ACK for message ID %s: %v", c.id, wsMsg.ID, err)
+				log.Printf("client %s: failed to handle ACK up to sequence number %d: %v", c.id, wsMsg.Seq, err)
 			}
+		case apitypes.MessageTypeSendMessage, apitypes.MessageTypeCreateConversation, apitypes.MessageTypeSendReceipt:
+			c.handleRequest(wsMsg)
+		case apitypes.MessageTypeTyping:
+			c.handleEvent(wsMsg)
 		default:
 			// Ignore other message types from clients
 		}
@@ -263,6 +455,11 @@ func (c *Client) readPump() {
 func (c *Client) writePump() {
 	ticker := time.NewTicker(c.pingPeriod)
 
+	frameType := websocket.TextMessage
+	if c.codec.Binary() {
+		frameType = websocket.BinaryMessage
+	}
+
 	defer func() {
 		ticker.Stop()
 	}()
@@ -272,14 +469,14 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
 			if !ok {
-				if err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+				if err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason)); err != nil {
 					log.Printf("client %s: failed to write closing websocket message: %v", c.id, err)
 					_ = c.conn.Close() // make readPump fail fast on error
 				}
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := c.conn.WriteMessage(frameType, message); err != nil {
 				log.Printf("client %s: failed to write websocket message: %v", c.id, err)
 				_ = c.conn.Close() // make readPump fail fast on error
 				return
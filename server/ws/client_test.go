@@ -2,7 +2,9 @@ package ws
 
 import (
 	"encoding/json"
+	"fmt"
 	"signal-chat/internal/apitypes"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,12 +12,12 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestClient_SendMessage(t *testing.T) {
-	t.Run("should send message and receive ACK", func(t *testing.T) {
+func TestClient_Notify(t *testing.T) {
+	t.Run("should deliver appended message and remove it from inbox after ACK", func(t *testing.T) {
 		// Arrange
 		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
-		client := NewClient("test-client", fakeConn, fakeStore)
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
 
 		// Act
 		message := &apitypes.WSMessage{
@@ -23,8 +25,8 @@ func TestClient_SendMessage(t *testing.T) {
 			Type: apitypes.MessageTypeNewMessage,
 			Data: json.RawMessage(`{"content": "Hello"}`),
 		}
-		err := client.SendMessage(message)
-		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(message))
+		client.Notify()
 
 		// Wait for message to be sent
 		time.Sleep(100 * time.Millisecond)
@@ -42,6 +44,7 @@ func TestClient_SendMessage(t *testing.T) {
 		// Send ACK back
 		ackMsg := apitypes.WSMessage{
 			ID:   sentMsg.ID,
+			Seq:  sentMsg.Seq,
 			Type: apitypes.MessageTypeAck,
 		}
 		ackBytes, err := json.Marshal(ackMsg)
@@ -52,18 +55,42 @@ func TestClient_SendMessage(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 
 		// Assert
-		// Check that message is not in storage (since it was ACKed)
-		messages, err := fakeStore.LoadAll()
+		assert.Equal(t, uint64(1), sentMsg.Seq)
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
-		assert.Empty(t, messages, "Message should not be in storage after ACK")
+		assert.Empty(t, messages, "Message should not be in inbox after ACK")
+	})
+
+	t.Run("should deliver messages in sequence order", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
+
+		// Act
+		for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+			require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+			client.Notify()
+		}
+
+		// Assert
+		for i := 1; i <= 3; i++ {
+			select {
+			case msgBytes := <-fakeConn.writeChan:
+				var msg apitypes.WSMessage
+				require.NoError(t, json.Unmarshal(msgBytes, &msg))
+				assert.Equal(t, uint64(i), msg.Seq)
+			case <-time.After(time.Second):
+				t.Fatalf("Message %d was not sent", i)
+			}
+		}
 	})
 
-	t.Run("should store message when ACK is not received", func(t *testing.T) {
+	t.Run("should keep message in inbox when ACK is not received", func(t *testing.T) {
 		// Arrange
 		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
-		client := NewClient("test-client", fakeConn, fakeStore)
-		client.readWait = 100 * time.Millisecond
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
 
 		// Act
 		message := &apitypes.WSMessage{
@@ -71,8 +98,8 @@ func TestClient_SendMessage(t *testing.T) {
 			Type: apitypes.MessageTypeNewMessage,
 			Data: json.RawMessage(`{"content": "Hello"}`),
 		}
-		err := client.SendMessage(message)
-		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(message))
+		client.Notify()
 
 		// Wait for message to be sent
 		time.Sleep(100 * time.Millisecond)
@@ -91,69 +118,35 @@ func TestClient_SendMessage(t *testing.T) {
 		time.Sleep(200 * time.Millisecond)
 
 		// Assert
-		// Check that message is in storage (since ACK was not received)
-		messages, err := fakeStore.LoadAll()
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages, 1)
-		assert.Equal(t, sentMsg.ID, messages[0].ID)
+		assert.Equal(t, sentMsg.Seq, messages[0].Seq)
 		assert.Equal(t, sentMsg.Type, messages[0].Type)
 		assert.JSONEq(t, string(sentMsg.Data), string(messages[0].Data))
 	})
-
-	t.Run("should store message when connection is closed", func(t *testing.T) {
-		// Arrange
-		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
-		client := NewClient("test-client", fakeConn, fakeStore)
-
-		// Act
-		// Close the connection
-		client.Close()
-
-		// Try to send a message
-		message := &apitypes.WSMessage{
-			ID:   "msg-789",
-			Type: apitypes.MessageTypeNewMessage,
-			Data: json.RawMessage(`{"content": "Hello"}`),
-		}
-		err := client.SendMessage(message)
-
-		// Assert
-		assert.NoError(t, err)
-
-		// Check that message is in storage
-		messages, err := fakeStore.LoadAll()
-		require.NoError(t, err)
-		require.Len(t, messages, 1)
-		assert.Equal(t, apitypes.MessageTypeNewMessage, messages[0].Type)
-		assert.Equal(t, `{"content": "Hello"}`, string(messages[0].Data))
-	})
 }
 
 func TestClient_SyncClient(t *testing.T) {
 	t.Run("should sync stored messages to client", func(t *testing.T) {
 		// Arrange
 		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
+		fakeInbox := NewFakeInbox()
 
 		// Store some messages
-		storedMessages := []*apitypes.WSMessage{
-			{
-				ID:   "msg1",
-				Type: apitypes.MessageTypeNewMessage,
-				Data: json.RawMessage(`{"content": "Hello"}`),
-			},
-			{
-				ID:   "msg2",
-				Type: apitypes.MessageTypeNewConversation,
-				Data: json.RawMessage(`{"conversationId": "conv1"}`),
-			},
-		}
-		err := fakeStore.Store(storedMessages)
-		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{
+			ID:   "msg1",
+			Type: apitypes.MessageTypeNewMessage,
+			Data: json.RawMessage(`{"content": "Hello"}`),
+		}))
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{
+			ID:   "msg2",
+			Type: apitypes.MessageTypeNewConversation,
+			Data: json.RawMessage(`{"conversationId": "conv1"}`),
+		}))
 
 		// Act
-		_ = NewClient("test-client", fakeConn, fakeStore)
+		_ = NewClient("test-client", fakeConn, fakeInbox, 0)
 
 		// Wait for sync to complete
 		time.Sleep(100 * time.Millisecond)
@@ -170,17 +163,21 @@ func TestClient_SyncClient(t *testing.T) {
 
 		// Assert
 		assert.Equal(t, apitypes.MessageTypeSync, syncMsg.Type)
+		assert.Equal(t, uint64(2), syncMsg.Seq, "sync message should carry the sequence number of its last message")
 
 		var syncPayload apitypes.WSSyncPayload
-		err = json.Unmarshal(syncMsg.Data, &syncPayload)
+		err := json.Unmarshal(syncMsg.Data, &syncPayload)
 		require.NoError(t, err)
 
 		require.Len(t, syncPayload.Messages, 2)
+		assert.False(t, syncPayload.HasMore)
 		assert.Equal(t, "msg1", syncPayload.Messages[0].ID)
+		assert.Equal(t, uint64(1), syncPayload.Messages[0].Seq)
 		assert.Equal(t, apitypes.MessageTypeNewMessage, syncPayload.Messages[0].Type)
 		assert.JSONEq(t, `{"content": "Hello"}`, string(syncPayload.Messages[0].Data))
 
 		assert.Equal(t, "msg2", syncPayload.Messages[1].ID)
+		assert.Equal(t, uint64(2), syncPayload.Messages[1].Seq)
 		assert.Equal(t, apitypes.MessageTypeNewConversation, syncPayload.Messages[1].Type)
 		assert.JSONEq(t, `{"conversationId": "conv1"}`, string(syncPayload.Messages[1].Data))
 	})
@@ -188,21 +185,17 @@ func TestClient_SyncClient(t *testing.T) {
 	t.Run("should delete messages after successful sync ACK", func(t *testing.T) {
 		// Arrange
 		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
+		fakeInbox := NewFakeInbox()
 
 		// Store some messages
-		storedMessages := []*apitypes.WSMessage{
-			{
-				ID:   "msg1",
-				Type: apitypes.MessageTypeNewMessage,
-				Data: json.RawMessage(`{"content": "Hello"}`),
-			},
-		}
-		err := fakeStore.Store(storedMessages)
-		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{
+			ID:   "msg1",
+			Type: apitypes.MessageTypeNewMessage,
+			Data: json.RawMessage(`{"content": "Hello"}`),
+		}))
 
 		// Act
-		_ = NewClient("test-client", fakeConn, fakeStore)
+		_ = NewClient("test-client", fakeConn, fakeInbox, 0)
 
 		// Wait for sync to complete
 		time.Sleep(100 * time.Millisecond)
@@ -220,6 +213,7 @@ func TestClient_SyncClient(t *testing.T) {
 		// Send ACK back
 		ackMsg := apitypes.WSMessage{
 			ID:   syncMsg.ID,
+			Seq:  syncMsg.Seq,
 			Type: apitypes.MessageTypeAck,
 		}
 		ackBytes, err := json.Marshal(ackMsg)
@@ -230,19 +224,139 @@ func TestClient_SyncClient(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 
 		// Assert
-		// Check that messages are deleted from storage
-		messages, err := fakeStore.LoadAll()
+		// Check that messages are deleted from the inbox
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		assert.Empty(t, messages, "Messages should be deleted after sync ACK")
 	})
+
+	t.Run("should send the next page only after the previous one was acknowledged", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		for i := 0; i < pageSize+1; i++ {
+			require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: fmt.Sprintf("msg%d", i), Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		_ = NewClient("test-client", fakeConn, fakeInbox, 0)
+
+		// Wait for sync to start
+		time.Sleep(100 * time.Millisecond)
+
+		var firstPage apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &firstPage))
+		default:
+			t.Fatal("No sync message was sent")
+		}
+		select {
+		case <-fakeConn.writeChan:
+			t.Fatal("Next page was sent before the ACK")
+		default:
+		}
+
+		ackBytes, err := json.Marshal(apitypes.WSMessage{ID: firstPage.ID, Seq: firstPage.Seq, Type: apitypes.MessageTypeAck})
+		require.NoError(t, err)
+		fakeConn.readChan <- ackBytes
+
+		var secondPage apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &secondPage))
+		case <-time.After(time.Second):
+			t.Fatal("Next page was not sent after the ACK")
+		}
+
+		// Assert
+		var firstPayload apitypes.WSSyncPayload
+		require.NoError(t, json.Unmarshal(firstPage.Data, &firstPayload))
+		assert.Len(t, firstPayload.Messages, pageSize)
+		assert.True(t, firstPayload.HasMore)
+		assert.Equal(t, uint64(pageSize), firstPage.Seq)
+
+		var secondPayload apitypes.WSSyncPayload
+		require.NoError(t, json.Unmarshal(secondPage.Data, &secondPayload))
+		require.Len(t, secondPayload.Messages, 1)
+		assert.Equal(t, fmt.Sprintf("msg%d", pageSize), secondPayload.Messages[0].ID)
+		assert.False(t, secondPayload.HasMore)
+		assert.Equal(t, apitypes.MessageTypeSync, secondPage.Type)
+	})
+
+	t.Run("should resume after the cursor of the device", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		_ = NewClient("test-client", fakeConn, fakeInbox, 2)
+
+		// Wait for sync to complete
+		time.Sleep(100 * time.Millisecond)
+
+		// Assert
+		var syncMsg apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &syncMsg))
+		default:
+			t.Fatal("No sync message was sent")
+		}
+
+		var syncPayload apitypes.WSSyncPayload
+		require.NoError(t, json.Unmarshal(syncMsg.Data, &syncPayload))
+		require.Len(t, syncPayload.Messages, 1)
+		assert.Equal(t, "msg3", syncPayload.Messages[0].ID)
+
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Len(t, messages, 1, "messages up to the cursor should be acknowledged")
+	})
+
+	t.Run("should skip a stored message larger than the read limit of the device", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		oversized, err := json.Marshal(strings.Repeat("a", apitypes.WSMaxMessageSize))
+		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: "oversized", Type: apitypes.MessageTypeNewMessage, Data: oversized}))
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: "msg2", Type: apitypes.MessageTypeNewMessage}))
+
+		// Act
+		_ = NewClient("test-client", fakeConn, fakeInbox, 0)
+
+		var syncMsg apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &syncMsg))
+		case <-time.After(time.Second):
+			t.Fatal("Sync did not continue after the oversized message")
+		}
+
+		// Assert
+		var syncPayload apitypes.WSSyncPayload
+		require.NoError(t, json.Unmarshal(syncMsg.Data, &syncPayload))
+		require.Len(t, syncPayload.Messages, 1)
+		assert.Equal(t, "msg2", syncPayload.Messages[0].ID)
+		assert.False(t, syncPayload.HasMore)
+
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "the oversized message should be removed from the inbox")
+		assert.Equal(t, "msg2", messages[0].ID)
+	})
 }
 
 func TestClient_Close(t *testing.T) {
-	t.Run("should store not ACKed messages when closed", func(t *testing.T) {
+	t.Run("should keep not ACKed messages in inbox when closed", func(t *testing.T) {
 		// Arrange
 		fakeConn := NewFakeWebSocketConn()
-		fakeStore := NewFakeMessageStore()
-		client := NewClient("test-client", fakeConn, fakeStore)
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
 
 		// Send a message
 		message := &apitypes.WSMessage{
@@ -250,9 +364,8 @@ func TestClient_Close(t *testing.T) {
 			Type: apitypes.MessageTypeNewMessage,
 			Data: json.RawMessage(`{"content": "Hello"}`),
 		}
-		err := client.SendMessage(message)
-		client.readWait = 10 * time.Millisecond
-		require.NoError(t, err)
+		require.NoError(t, fakeInbox.Append(message))
+		client.Notify()
 
 		// Wait for message to be sent
 		time.Sleep(100 * time.Millisecond)
@@ -261,11 +374,98 @@ func TestClient_Close(t *testing.T) {
 		client.Close()
 
 		// Assert
-		// Check that message is in storage
-		messages, err := fakeStore.LoadAll()
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages, 1)
 		assert.Equal(t, apitypes.MessageTypeNewMessage, messages[0].Type)
 		assert.Equal(t, `{"content": "Hello"}`, string(messages[0].Data))
 	})
+
+	t.Run("should ignore notifications after close", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
+		client.Close()
+
+		// Act/Assert
+		require.NoError(t, fakeInbox.Append(&apitypes.WSMessage{ID: "msg-1", Type: apitypes.MessageTypeNewMessage}))
+		assert.NotPanics(t, client.Notify)
+	})
+}
+
+func TestClient_HandleRequest(t *testing.T) {
+	t.Run("should send the response of the request handler", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeInbox := NewFakeInbox()
+		client := NewClient("test-client", fakeConn, fakeInbox, 0)
+
+		var handled apitypes.WSMessage
+		client.SetRequestHandler(func(request apitypes.WSMessage) apitypes.WSMessage {
+			handled = request
+			return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse, Data: json.RawMessage(`{"messageID":"msg-1"}`)}
+		})
+
+		// Act
+		requestBytes, err := json.Marshal(apitypes.WSMessage{
+			ID:   "req-1",
+			Type: apitypes.MessageTypeSendMessage,
+			Data: json.RawMessage(`{"conversationID":"conv1"}`),
+		})
+		require.NoError(t, err)
+		fakeConn.readChan <- requestBytes
+
+		// Assert
+		var response apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &response))
+		case <-time.After(time.Second):
+			t.Fatal("No response was sent")
+		}
+
+		assert.Equal(t, "req-1", handled.ID)
+		assert.JSONEq(t, `{"conversationID":"conv1"}`, string(handled.Data))
+		assert.Equal(t, "req-1", response.ID)
+		assert.Equal(t, apitypes.MessageTypeResponse, response.Type)
+		assert.Zero(t, response.Seq, "responses should not be part of the inbox")
+		messages, _, err := fakeInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+	t.Run("should answer protobuf requests in protobuf when the subprotocol was negotiated", func(t *testing.T) {
+		// Arrange
+		fakeConn := NewFakeWebSocketConn()
+		fakeConn.subprotocol = apitypes.WSSubprotocolProtobuf
+		client := NewClient("test-client", fakeConn, NewFakeInbox(), 0)
+
+		var handled apitypes.WSMessage
+		client.SetRequestHandler(func(request apitypes.WSMessage) apitypes.WSMessage {
+			handled = request
+			return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse, Data: json.RawMessage(`{"messageID":"msg-1"}`)}
+		})
+
+		// Act
+		requestBytes, err := apitypes.ProtobufCodec.MarshalWSMessage(&apitypes.WSMessage{
+			ID:   "req-1",
+			Type: apitypes.MessageTypeSendMessage,
+			Data: json.RawMessage(`{"conversationID":"conv1","content":"AQI="}`),
+		})
+		require.NoError(t, err)
+		fakeConn.readChan <- requestBytes
+
+		// Assert
+		var response apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, apitypes.ProtobufCodec.UnmarshalWSMessage(msgBytes, &response))
+		case <-time.After(time.Second):
+			t.Fatal("No response was sent")
+		}
+
+		assert.JSONEq(t, `{"conversationID":"conv1","content":"AQI="}`, string(handled.Data), "handler should get the payload as JSON")
+		assert.Equal(t, "req-1", response.ID)
+		assert.JSONEq(t, `{"messageID":"msg-1"}`, string(response.Data))
+	})
 }
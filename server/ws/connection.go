@@ -11,4 +11,6 @@ type Connection interface {
 	SetReadDeadline(t time.Time) error
 	SetWriteDeadline(t time.Time) error
 	SetPongHandler(handler func(string) error)
+	// Subprotocol returns the negotiated subprotocol, which selects the encoding of the messages
+	Subprotocol() string
 }
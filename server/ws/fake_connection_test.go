@@ -15,6 +15,7 @@ type FakeWebSocketConn struct {
 	closeChan    chan struct{}
 	closed       bool
 	readDeadline time.Time
+	subprotocol  string
 	mu           sync.Mutex
 }
 
@@ -119,3 +120,8 @@ func (f *FakeWebSocketConn) SetPongHandler(handler func(string) error) {
 func (f *FakeWebSocketConn) SetReadLimit(limit int64) {
 	// Not implemented for tests
 }
+
+// Subprotocol returns the negotiated subprotocol
+func (f *FakeWebSocketConn) Subprotocol() string {
+	return f.subprotocol
+}
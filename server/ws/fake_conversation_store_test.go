@@ -1,6 +1,9 @@
 package ws
 
-import "signal-chat/server/conversation"
+import (
+	"signal-chat/server/conversation"
+	"slices"
+)
 
 // FakeConversationStore implements the ConversationStore interface for testing
 type FakeConversationStore struct {
@@ -22,6 +25,23 @@ func (m *FakeConversationStore) GetConversation(id string) (*conversation.Conver
 	return nil, conversation.ErrConversationNotFound
 }
 
+// GetContacts returns the participants of every conversation of the user
+func (m *FakeConversationStore) GetContacts(userID string) ([]string, error) {
+	var contacts []string
+	for _, conv := range m.conversations {
+		if !conv.HasParticipant(userID) {
+			continue
+		}
+		for _, id := range conv.ParticipantIDs {
+			if id != userID && !slices.Contains(contacts, id) {
+				contacts = append(contacts, id)
+			}
+		}
+	}
+	slices.Sort(contacts)
+	return contacts, nil
+}
+
 // AddConversation adds a conversation to the mock repository
 func (m *FakeConversationStore) AddConversation(id string, conv *conversation.Conversation) {
 	m.conversations[id] = conv
@@ -0,0 +1,39 @@
+package ws
+
+import "signal-chat/internal/apitypes"
+
+// FakeDeviceStore implements the DeviceStore interface for testing
+type FakeDeviceStore struct {
+	devices map[string][]apitypes.Device
+	errors  map[string]error
+}
+
+func NewFakeDeviceStore() *FakeDeviceStore {
+	return &FakeDeviceStore{
+		devices: make(map[string][]apitypes.Device),
+		errors:  make(map[string]error),
+	}
+}
+
+// GetDevices returns the devices added for the user. Users without added devices have a single device with ID 1.
+func (f *FakeDeviceStore) GetDevices(userID string) ([]apitypes.Device, error) {
+	if err, exists := f.errors[userID]; exists {
+		return nil, err
+	}
+	if devices, exists := f.devices[userID]; exists {
+		return devices, nil
+	}
+	return []apitypes.Device{{ID: 1}}, nil
+}
+
+// AddDevices registers devices with the given IDs for the user
+func (f *FakeDeviceStore) AddDevices(userID string, deviceIDs ...uint32) {
+	for _, id := range deviceIDs {
+		f.devices[userID] = append(f.devices[userID], apitypes.Device{ID: id})
+	}
+}
+
+// FailDevices makes the device lookups of the user return the given error
+func (f *FakeDeviceStore) FailDevices(userID string, err error) {
+	f.errors[userID] = err
+}
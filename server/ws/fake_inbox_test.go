@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"signal-chat/internal/apitypes"
+	"sync"
+)
+
+// FakeInbox implements a fake message inbox for testing
+type FakeInbox struct {
+	messages []apitypes.WSMessage
+	lastSeq  uint64
+	mu       sync.Mutex
+}
+
+func NewFakeInbox() *FakeInbox {
+	return &FakeInbox{
+		messages: make([]apitypes.WSMessage, 0),
+	}
+}
+
+func (f *FakeInbox) Append(message *apitypes.WSMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastSeq++
+	message.Seq = f.lastSeq
+	f.messages = append(f.messages, *message)
+	return nil
+}
+
+func (f *FakeInbox) Ack(seq uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	remaining := make([]apitypes.WSMessage, 0, len(f.messages))
+	for _, msg := range f.messages {
+		if msg.Seq > seq {
+			remaining = append(remaining, msg)
+		}
+	}
+
+	f.messages = remaining
+	return nil
+}
+
+func (f *FakeInbox) LoadPage(seq uint64, limit, maxBytes int) ([]apitypes.WSMessage, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []apitypes.WSMessage
+	size := 0
+	for _, msg := range f.messages {
+		if msg.Seq <= seq {
+			continue
+		}
+		if len(result) == limit || (len(result) > 0 && size+len(msg.Data) > maxBytes) {
+			return result, true, nil
+		}
+		size += len(msg.Data)
+		result = append(result, msg)
+	}
+	return result, false, nil
+}
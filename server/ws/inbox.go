@@ -0,0 +1,474 @@
+package ws
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"signal-chat/internal/apitypes"
+	"time"
+)
+
+// ErrInboxFull is returned for messages that don't fit in the inbox of a device that rejects new messages when full
+var ErrInboxFull = errors.New("recipient inbox full")
+
+const (
+	// maxInboxAttempts is the number of times a change of an inbox is retried when it conflicts with a concurrent one
+	maxInboxAttempts = 10
+	// ackBatchSize is the number of messages acknowledged per transaction
+	ackBatchSize = 1000
+)
+
+// EvictionPolicy decides what happens to a new message that doesn't fit in an inbox
+type EvictionPolicy int
+
+const (
+	// EvictOldest drops the oldest messages of the inbox to make room for the new one
+	EvictOldest EvictionPolicy = iota
+	// RejectNew keeps the inbox as it is and rejects the new message with ErrInboxFull
+	RejectNew
+)
+
+// InboxLimits bounds the messages stored for a device until it acknowledges them. Zero values mean no limit. An empty
+// inbox always accepts a message, so a single message larger than MaxBytes is still delivered.
+type InboxLimits struct {
+	MaxMessages int
+	MaxBytes    int
+	// TTL is the time after which a message is dropped even if it wasn't delivered
+	TTL      time.Duration
+	Eviction EvictionPolicy
+}
+
+// exceeded tells whether an inbox holding count messages of the given total size is over the limits
+func (l InboxLimits) exceeded(count, size int) bool {
+	return (l.MaxMessages > 0 && count > l.MaxMessages) || (l.MaxBytes > 0 && size > l.MaxBytes)
+}
+
+// Inbox is the durable, ordered queue of websocket messages of a single device. Every message gets the next sequence
+// number of the inbox before it's delivered, and stays stored until the device acknowledges it, it expires or it's
+// evicted to make room for newer messages. The number and total size of the stored messages are kept next to the
+// sequence number so that appending doesn't have to walk the inbox. Expired messages stay counted until they're
+// evicted or acknowledged, or the janitor recounts the inbox.
+type Inbox struct {
+	db       *badger.DB
+	clientID string
+	limits   InboxLimits
+}
+
+// storedMessage is the key and the size of a message stored in the inbox
+type storedMessage struct {
+	key  []byte
+	size int
+}
+
+// Append assigns the next sequence number to the message and stores it at the end of the inbox. It returns
+// ErrInboxFull when the inbox is full and rejects new messages.
+func (i *Inbox) Append(message *apitypes.WSMessage) error {
+	return i.AppendExpiring(message, time.Time{})
+}
+
+// AppendExpiring appends a message that must not outlive the given time, like the notification of a disappearing
+// message. A zero time only applies the TTL of the inbox, and messages that already expired aren't stored.
+func (i *Inbox) AppendExpiring(message *apitypes.WSMessage, expiresAt time.Time) error {
+	ttl := i.limits.TTL
+	if !expiresAt.IsZero() {
+		remaining := time.Until(expiresAt)
+		if remaining <= 0 {
+			return nil
+		}
+		if ttl <= 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt < maxInboxAttempts; attempt++ {
+		evicted := 0
+		err = i.db.Update(func(txn *badger.Txn) error {
+			seq, err := i.lastSeq(txn)
+			if err != nil {
+				return err
+			}
+			seq++
+
+			message.Seq = seq
+			data, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+
+			usage, err := i.usage(txn)
+			if err != nil {
+				return err
+			}
+			evicted, err = i.makeRoom(txn, &usage, len(data))
+			if err != nil {
+				return err
+			}
+
+			entry := badger.NewEntry(i.toMessageKey(seq), data)
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+			if err := txn.SetEntry(entry); err != nil {
+				return err
+			}
+			if err := txn.Set(i.toSeqKey(), binary.BigEndian.AppendUint64(nil, seq)); err != nil {
+				return err
+			}
+			usage.messages++
+			usage.bytes += len(data)
+			return i.setUsage(txn, usage)
+		})
+		// Another message was appended concurrently, retry with the next sequence number
+		if errors.Is(err, badger.ErrConflict) {
+			continue
+		}
+		if errors.Is(err, ErrInboxFull) {
+			inboxRejected.Add(1)
+		}
+		if err == nil && evicted > 0 {
+			inboxEvicted.Add(int64(evicted))
+		}
+		return err
+	}
+	return fmt.Errorf("failed to append to inbox of client %s: %w", i.clientID, err)
+}
+
+// makeRoom makes room for a new message of the given size according to the eviction policy, updates the usage of the
+// inbox and returns the number of messages it evicted. The oldest messages are only walked when the inbox is full.
+func (i *Inbox) makeRoom(txn *badger.Txn, usage *inboxUsage, size int) (int, error) {
+	if !i.limits.exceeded(usage.messages+1, usage.bytes+size) {
+		return 0, nil
+	}
+
+	if i.limits.Eviction == RejectNew {
+		// The usage may still count expired messages, so make sure the inbox is really full before rejecting
+		stored, storedSize := i.storedMessages(txn)
+		*usage = inboxUsage{messages: len(stored), bytes: storedSize}
+		if len(stored) > 0 && i.limits.exceeded(usage.messages+1, usage.bytes+size) {
+			return 0, ErrInboxFull
+		}
+		return 0, nil
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	evicted := 0
+	prefix := i.toMessagePrefix()
+	for it.Seek(prefix); i.limits.exceeded(usage.messages+1, usage.bytes+size); it.Next() {
+		if !it.ValidForPrefix(prefix) {
+			// The remaining usage is of expired messages
+			*usage = inboxUsage{}
+			break
+		}
+
+		item := it.Item()
+		if err := txn.Delete(item.KeyCopy(nil)); err != nil {
+			return 0, err
+		}
+		usage.messages = max(usage.messages-1, 0)
+		usage.bytes = max(usage.bytes-int(item.ValueSize()), 0)
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+// Full tells whether the inbox rejects new messages. An inbox that evicts its oldest messages is never full.
+func (i *Inbox) Full() (bool, error) {
+	if i.limits.Eviction != RejectNew || (i.limits.MaxMessages <= 0 && i.limits.MaxBytes <= 0) {
+		return false, nil
+	}
+
+	var full bool
+	err := i.db.View(func(txn *badger.Txn) error {
+		usage, err := i.usage(txn)
+		if err != nil {
+			return err
+		}
+		if usage.messages == 0 || !i.limits.exceeded(usage.messages+1, usage.bytes+1) {
+			return nil
+		}
+
+		// The usage may still count expired messages
+		stored, storedSize := i.storedMessages(txn)
+		full = len(stored) > 0 && i.limits.exceeded(len(stored)+1, storedSize+1)
+		return nil
+	})
+	return full, err
+}
+
+// Delete removes the inbox with its messages, sequence number and usage
+func (i *Inbox) Delete() error {
+	var stored []storedMessage
+	err := i.db.View(func(txn *badger.Txn) error {
+		stored, _ = i.storedMessages(txn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	wb := i.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, message := range stored {
+		if err := wb.Delete(message.key); err != nil {
+			return err
+		}
+	}
+	if err := wb.Delete(i.toSeqKey()); err != nil {
+		return err
+	}
+	if err := wb.Delete(i.toUsageKey()); err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
+
+// storedMessages returns the messages stored in the inbox in sequence order, without their values, and their total
+// size. Expired messages are left out.
+func (i *Inbox) storedMessages(txn *badger.Txn) ([]storedMessage, int) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var stored []storedMessage
+	size := 0
+	prefix := i.toMessagePrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		stored = append(stored, storedMessage{key: item.KeyCopy(nil), size: int(item.ValueSize())})
+		size += int(item.ValueSize())
+	}
+
+	return stored, size
+}
+
+// Ack removes all messages up to and including the given sequence number
+func (i *Inbox) Ack(seq uint64) error {
+	for {
+		done, err := i.ackBatch(seq)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// ackBatch removes up to ackBatchSize messages up to and including the given sequence number, and reports whether
+// none are left
+func (i *Inbox) ackBatch(seq uint64) (bool, error) {
+	var done bool
+	var err error
+	for attempt := 0; attempt < maxInboxAttempts; attempt++ {
+		err = i.db.Update(func(txn *badger.Txn) error {
+			usage, err := i.usage(txn)
+			if err != nil {
+				return err
+			}
+
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			deleted := 0
+			prefix := i.toMessagePrefix()
+			it.Seek(prefix)
+			for ; it.ValidForPrefix(prefix) && i.toSeq(it.Item().Key()) <= seq; it.Next() {
+				if deleted == ackBatchSize {
+					break
+				}
+				item := it.Item()
+				if err := txn.Delete(item.KeyCopy(nil)); err != nil {
+					return err
+				}
+				usage.messages = max(usage.messages-1, 0)
+				usage.bytes = max(usage.bytes-int(item.ValueSize()), 0)
+				deleted++
+			}
+
+			valid := it.ValidForPrefix(prefix)
+			done = !valid || i.toSeq(it.Item().Key()) > seq
+			if !valid {
+				// The remaining usage is of expired messages
+				usage = inboxUsage{}
+			}
+			if deleted == 0 && valid {
+				return nil
+			}
+			return i.setUsage(txn, usage)
+		})
+		// A message was appended concurrently, retry with its usage
+		if !errors.Is(err, badger.ErrConflict) {
+			return done, err
+		}
+	}
+	return false, fmt.Errorf("failed to acknowledge messages of client %s: %w", i.clientID, err)
+}
+
+// Remove deletes the message with the given sequence number if it's still stored
+func (i *Inbox) Remove(seq uint64) error {
+	var err error
+	for attempt := 0; attempt < maxInboxAttempts; attempt++ {
+		err = i.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(i.toMessageKey(seq))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			usage, err := i.usage(txn)
+			if err != nil {
+				return err
+			}
+			if err := txn.Delete(i.toMessageKey(seq)); err != nil {
+				return err
+			}
+			usage.messages = max(usage.messages-1, 0)
+			usage.bytes = max(usage.bytes-int(item.ValueSize()), 0)
+			return i.setUsage(txn, usage)
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to remove message %d from inbox of client %s: %w", seq, i.clientID, err)
+}
+
+// recount stores the number and total size of the messages in the inbox, leaving out the expired ones
+func (i *Inbox) recount() error {
+	var err error
+	for attempt := 0; attempt < maxInboxAttempts; attempt++ {
+		err = i.db.Update(func(txn *badger.Txn) error {
+			stored, storedSize := i.storedMessages(txn)
+			return i.setUsage(txn, inboxUsage{messages: len(stored), bytes: storedSize})
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to recount inbox of client %s: %w", i.clientID, err)
+}
+
+// LoadPage returns the stored messages with a sequence number greater than the given one, in sequence order. It stops
+// after limit messages or before their total size exceeds maxBytes, and reports whether more messages follow. The first
+// message is always returned, so a single large message can't block the inbox.
+func (i *Inbox) LoadPage(seq uint64, limit, maxBytes int) ([]apitypes.WSMessage, bool, error) {
+	var messages []apitypes.WSMessage
+	var hasMore bool
+
+	err := i.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = limit
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		size := 0
+		prefix := i.toMessagePrefix()
+		for it.Seek(i.toMessageKey(seq + 1)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if len(messages) == limit || (len(messages) > 0 && size+int(item.ValueSize()) > maxBytes) {
+				hasMore = true
+				return nil
+			}
+			size += int(item.ValueSize())
+
+			err := item.Value(func(v []byte) error {
+				var msg apitypes.WSMessage
+				if err := json.Unmarshal(v, &msg); err != nil {
+					return fmt.Errorf("failed to unmarshal stored websocket message: %w", err)
+				}
+				messages = append(messages, msg)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return messages, hasMore, nil
+}
+
+func (i *Inbox) lastSeq(txn *badger.Txn) (uint64, error) {
+	item, err := txn.Get(i.toSeqKey())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var seq uint64
+	err = item.Value(func(v []byte) error {
+		seq = binary.BigEndian.Uint64(v)
+		return nil
+	})
+	return seq, err
+}
+
+// usage returns the number and total size of the messages in the inbox. Inboxes stored before their usage was kept
+// are counted once.
+func (i *Inbox) usage(txn *badger.Txn) (inboxUsage, error) {
+	item, err := txn.Get(i.toUsageKey())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		stored, storedSize := i.storedMessages(txn)
+		return inboxUsage{messages: len(stored), bytes: storedSize}, nil
+	}
+	if err != nil {
+		return inboxUsage{}, err
+	}
+
+	var usage inboxUsage
+	err = item.Value(func(v []byte) error {
+		if len(v) != 16 {
+			return fmt.Errorf("invalid usage of inbox %s", i.clientID)
+		}
+		usage.messages = int(binary.BigEndian.Uint64(v[:8]))
+		usage.bytes = int(binary.BigEndian.Uint64(v[8:]))
+		return nil
+	})
+	return usage, err
+}
+
+func (i *Inbox) setUsage(txn *badger.Txn, usage inboxUsage) error {
+	value := binary.BigEndian.AppendUint64(nil, uint64(usage.messages))
+	value = binary.BigEndian.AppendUint64(value, uint64(usage.bytes))
+	return txn.Set(i.toUsageKey(), value)
+}
+
+func (i *Inbox) toMessagePrefix() []byte {
+	return []byte(fmt.Sprintf("%s%s:", inboxMessagePrefix, i.clientID))
+}
+
+// toMessageKey encodes the sequence number in big-endian so that keys sort in sequence order
+func (i *Inbox) toMessageKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(i.toMessagePrefix(), seq)
+}
+
+func (i *Inbox) toSeq(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[len(key)-8:])
+}
+
+func (i *Inbox) toSeqKey() []byte {
+	return []byte(fmt.Sprintf("%s%s", inboxSeqPrefix, i.clientID))
+}
+
+func (i *Inbox) toUsageKey() []byte {
+	return []byte(fmt.Sprintf("%s%s", inboxUsagePrefix, i.clientID))
+}
@@ -0,0 +1,453 @@
+package ws
+
+import (
+	"encoding/json"
+	"github.com/dgraph-io/badger/v4"
+	"signal-chat/internal/apitypes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInbox(t *testing.T) {
+	t.Run("should assign increasing sequence numbers to appended messages", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+
+		// Act
+		msg1 := &apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage, Data: json.RawMessage(`{"content":"Hello"}`)}
+		msg2 := &apitypes.WSMessage{ID: "msg2", Type: apitypes.MessageTypeNewConversation, Data: json.RawMessage(`{"conversationId":"conv1"}`)}
+		require.NoError(t, inbox.Append(msg1))
+		require.NoError(t, inbox.Append(msg2))
+
+		// Assert
+		assert.Equal(t, uint64(1), msg1.Seq)
+		assert.Equal(t, uint64(2), msg2.Seq)
+
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg1", messages[0].ID)
+		assert.Equal(t, uint64(1), messages[0].Seq)
+		assert.JSONEq(t, `{"content":"Hello"}`, string(messages[0].Data))
+		assert.Equal(t, "msg2", messages[1].ID)
+		assert.Equal(t, uint64(2), messages[1].Seq)
+		assert.JSONEq(t, `{"conversationId":"conv1"}`, string(messages[1].Data))
+	})
+
+	t.Run("should load only messages after the given sequence number", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		messages, _, err := inbox.LoadPage(1, pageSize, pageBytes)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg2", messages[0].ID)
+		assert.Equal(t, "msg3", messages[1].ID)
+	})
+
+	t.Run("should stop a page at the message limit", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		firstPage, firstHasMore, err1 := inbox.LoadPage(0, 2, pageBytes)
+		secondPage, secondHasMore, err2 := inbox.LoadPage(2, 2, pageBytes)
+
+		// Assert
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.Len(t, firstPage, 2)
+		assert.Equal(t, "msg1", firstPage[0].ID)
+		assert.Equal(t, "msg2", firstPage[1].ID)
+		assert.True(t, firstHasMore)
+		require.Len(t, secondPage, 1)
+		assert.Equal(t, "msg3", secondPage[0].ID)
+		assert.False(t, secondHasMore)
+	})
+
+	t.Run("should stop a page before it exceeds the size limit", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		data := json.RawMessage(`"` + strings.Repeat("a", 100) + `"`)
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage, Data: data}))
+		}
+
+		// Act
+		messages, hasMore, err := inbox.LoadPage(0, pageSize, 150)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "the first message should be returned even if it's larger than the limit")
+		assert.Equal(t, "msg1", messages[0].ID)
+		assert.True(t, hasMore)
+	})
+
+	t.Run("should delete messages up to the acknowledged sequence number", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		err := inbox.Ack(2)
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "msg3", messages[0].ID)
+	})
+
+	t.Run("should remove a single message and its usage", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		err := inbox.Remove(2)
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, inbox.Remove(2), "removing a missing message should succeed")
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg1", messages[0].ID)
+		assert.Equal(t, "msg3", messages[1].ID)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			_, storedSize := inbox.storedMessages(txn)
+			usage, err := inbox.usage(txn)
+			require.NoError(t, err)
+			assert.Equal(t, inboxUsage{messages: 2, bytes: storedSize}, usage)
+			return nil
+		}))
+	})
+
+	t.Run("should keep counting sequence numbers after all messages were acknowledged", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage}))
+		require.NoError(t, inbox.Ack(1))
+
+		// Act
+		msg := &apitypes.WSMessage{ID: "msg2", Type: apitypes.MessageTypeNewMessage}
+		err := inbox.Append(msg)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), msg.Seq)
+	})
+
+	t.Run("should keep inboxes of different devices separate", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox1 := &Inbox{db: db, clientID: "user-1:1"}
+		inbox2 := &Inbox{db: db, clientID: "user-1:11"}
+		require.NoError(t, inbox1.Append(&apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage}))
+		require.NoError(t, inbox2.Append(&apitypes.WSMessage{ID: "msg2", Type: apitypes.MessageTypeNewMessage}))
+
+		// Act
+		err := inbox1.Ack(1)
+
+		// Assert
+		require.NoError(t, err)
+		messages1, _, err := inbox1.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, messages1)
+
+		messages2, _, err := inbox2.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages2, 1)
+		assert.Equal(t, "msg2", messages2[0].ID)
+	})
+
+	t.Run("should return no messages for an empty inbox", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+
+		// Act
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+	t.Run("should evict the oldest messages when the inbox is full", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 2, Eviction: EvictOldest}}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+		evicted := inboxEvicted.Value()
+
+		// Act
+		err := inbox.Append(&apitypes.WSMessage{ID: "msg3", Type: apitypes.MessageTypeNewMessage})
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg2", messages[0].ID)
+		assert.Equal(t, "msg3", messages[1].ID)
+		assert.Equal(t, evicted+1, inboxEvicted.Value())
+	})
+
+	t.Run("should evict as many messages as needed to stay under the size limit", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxBytes: 500, Eviction: EvictOldest}}
+		small := json.RawMessage(`"` + strings.Repeat("a", 50) + `"`)
+		for _, id := range []string{"msg1", "msg2", "msg3"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage, Data: small}))
+		}
+
+		// Act
+		large := json.RawMessage(`"` + strings.Repeat("a", 300) + `"`)
+		err := inbox.Append(&apitypes.WSMessage{ID: "msg4", Type: apitypes.MessageTypeNewMessage, Data: large})
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg3", messages[0].ID)
+		assert.Equal(t, "msg4", messages[1].ID)
+	})
+
+	t.Run("should reject new messages when the inbox is full and the policy rejects them", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 2, Eviction: RejectNew}}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+		rejected := inboxRejected.Value()
+
+		// Act
+		err := inbox.Append(&apitypes.WSMessage{ID: "msg3", Type: apitypes.MessageTypeNewMessage})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInboxFull)
+		assert.Equal(t, rejected+1, inboxRejected.Value())
+		full, err := inbox.Full()
+		require.NoError(t, err)
+		assert.True(t, full)
+
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg1", messages[0].ID)
+	})
+
+	t.Run("should accept messages again once the device acknowledged some", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 2, Eviction: RejectNew}}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+		require.NoError(t, inbox.Ack(1))
+
+		// Act
+		full, err := inbox.Full()
+
+		// Assert
+		require.NoError(t, err)
+		assert.False(t, full)
+		assert.NoError(t, inbox.Append(&apitypes.WSMessage{ID: "msg3", Type: apitypes.MessageTypeNewMessage}))
+	})
+
+	t.Run("should keep the number and size of the stored messages up to date", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 3, Eviction: EvictOldest}}
+		for _, id := range []string{"msg1", "msg2", "msg3", "msg4"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		err := inbox.Ack(2)
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			stored, storedSize := inbox.storedMessages(txn)
+			usage, err := inbox.usage(txn)
+			require.NoError(t, err)
+			assert.Equal(t, inboxUsage{messages: 2, bytes: storedSize}, usage)
+			assert.Len(t, stored, 2)
+			return nil
+		}))
+	})
+
+	t.Run("should count inboxes stored before their usage was kept", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 2, Eviction: EvictOldest}}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			return txn.Delete(inbox.toUsageKey())
+		}))
+
+		// Act
+		err := inbox.Append(&apitypes.WSMessage{ID: "msg3", Type: apitypes.MessageTypeNewMessage})
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "msg2", messages[0].ID)
+	})
+
+	t.Run("should not reject messages for a usage that counts expired messages", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{MaxMessages: 2, Eviction: RejectNew}}
+		require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage}))
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			return inbox.setUsage(txn, inboxUsage{messages: 2, bytes: 100})
+		}))
+
+		// Act
+		err := inbox.Append(&apitypes.WSMessage{ID: "msg2", Type: apitypes.MessageTypeNewMessage})
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Len(t, messages, 2)
+	})
+
+	t.Run("should store messages with the TTL of the inbox", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{TTL: time.Hour}}
+
+		// Act
+		msg := &apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage}
+		err := inbox.Append(msg)
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(inbox.toMessageKey(msg.Seq))
+			require.NoError(t, err)
+			assert.InDelta(t, time.Now().Add(time.Hour).Unix(), int64(item.ExpiresAt()), 5)
+
+			item, err = txn.Get(inbox.toSeqKey())
+			require.NoError(t, err)
+			assert.Zero(t, item.ExpiresAt(), "the sequence number must outlive the messages")
+			return nil
+		}))
+	})
+
+	t.Run("should store expiring messages with the shorter of their remaining lifetime and the TTL", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1", limits: InboxLimits{TTL: time.Hour}}
+
+		// Act
+		msg := &apitypes.WSMessage{ID: "msg1", Type: apitypes.MessageTypeNewMessage}
+		err := inbox.AppendExpiring(msg, time.Now().Add(time.Minute))
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(inbox.toMessageKey(msg.Seq))
+			require.NoError(t, err)
+			assert.InDelta(t, time.Now().Add(time.Minute).Unix(), int64(item.ExpiresAt()), 5)
+			return nil
+		}))
+	})
+
+	t.Run("should not store messages that already expired", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+
+		// Act
+		err := inbox.AppendExpiring(&apitypes.WSMessage{ID: "msg1"}, time.Now().Add(-time.Second))
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, 10, 1<<20)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("should delete the messages, the sequence number and the usage", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, inbox.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+
+		// Act
+		err := inbox.Delete()
+
+		// Assert
+		require.NoError(t, err)
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get(inbox.toSeqKey())
+			assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+			_, err = txn.Get(inbox.toUsageKey())
+			assert.ErrorIs(t, err, badger.ErrKeyNotFound)
+			return nil
+		}))
+	})
+}
@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	inboxMessagePrefix = "inbox:"
+	inboxSeqPrefix     = "inboxseq:"
+	inboxUsagePrefix   = "inboxusage:"
+)
+
+// InboxReport describes the inboxes found by a sweep
+type InboxReport struct {
+	// Queues is the number of inboxes holding messages, Messages and Bytes the number and total size of the messages
+	Queues   int
+	Messages int
+	Bytes    int
+	// MaxDepth is the number of messages in the fullest inbox
+	MaxDepth int
+	// Abandoned is the number of inboxes of devices that are no longer registered, which were deleted
+	Abandoned int
+}
+
+// inboxUsage is the number and total size of the messages of an inbox
+type inboxUsage struct {
+	messages int
+	bytes    int
+}
+
+// InboxJanitor measures the inboxes and deletes the ones left behind by removed devices. Expired messages are dropped
+// by the database, the inboxes of devices that are still registered are never deleted as their sequence numbers must
+// keep increasing.
+type InboxJanitor struct {
+	db          *badger.DB
+	deviceStore DeviceStore
+}
+
+func NewInboxJanitor(db *badger.DB, deviceStore DeviceStore) *InboxJanitor {
+	return &InboxJanitor{
+		db:          db,
+		deviceStore: deviceStore,
+	}
+}
+
+// Run sweeps the inboxes every interval until the context is done
+func (j *InboxJanitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := j.Sweep()
+			if err != nil {
+				log.Printf("Failed to sweep inboxes: %v", err)
+				continue
+			}
+			log.Printf("Inbox sweep: %d queues with %d messages (%d bytes), deepest has %d, deleted %d abandoned",
+				report.Queues, report.Messages, report.Bytes, report.MaxDepth, report.Abandoned)
+		}
+	}
+}
+
+// Sweep deletes the inboxes of devices that are no longer registered, recounts the remaining ones and publishes their
+// depth
+func (j *InboxJanitor) Sweep() (InboxReport, error) {
+	usages, err := j.scan()
+	if err != nil {
+		return InboxReport{}, fmt.Errorf("failed to scan inboxes: %w", err)
+	}
+
+	var report InboxReport
+	registered := make(map[string][]uint32)
+	for id, usage := range usages {
+		userID, deviceID, err := parseClientID(id)
+		if err != nil {
+			log.Printf("Skipping inbox with malformed client ID %q: %v", id, err)
+			continue
+		}
+
+		deviceIDs, exists := registered[userID]
+		if !exists {
+			devices, err := j.deviceStore.GetDevices(userID)
+			if err != nil {
+				return InboxReport{}, fmt.Errorf("failed to get devices of user %s: %w", userID, err)
+			}
+			for _, device := range devices {
+				deviceIDs = append(deviceIDs, device.ID)
+			}
+			registered[userID] = deviceIDs
+		}
+
+		if !slices.Contains(deviceIDs, deviceID) {
+			if err := (&Inbox{db: j.db, clientID: id}).Delete(); err != nil {
+				return InboxReport{}, fmt.Errorf("failed to delete inbox of client %s: %w", id, err)
+			}
+			report.Abandoned++
+			continue
+		}
+
+		// Expired messages are still counted by the inbox until it's recounted
+		if err := (&Inbox{db: j.db, clientID: id}).recount(); err != nil {
+			return InboxReport{}, fmt.Errorf("failed to recount inbox of client %s: %w", id, err)
+		}
+
+		if usage.messages > 0 {
+			report.Queues++
+			report.Messages += usage.messages
+			report.Bytes += usage.bytes
+			report.MaxDepth = max(report.MaxDepth, usage.messages)
+		}
+	}
+
+	inboxQueues.Set(int64(report.Queues))
+	inboxMessages.Set(int64(report.Messages))
+	inboxBytes.Set(int64(report.Bytes))
+	inboxMaxDepth.Set(int64(report.MaxDepth))
+	inboxAbandoned.Add(int64(report.Abandoned))
+
+	return report, nil
+}
+
+// scan returns the usage of every inbox by client ID, including the ones whose messages were all acknowledged or
+// expired
+func (j *InboxJanitor) scan() (map[string]*inboxUsage, error) {
+	usages := make(map[string]*inboxUsage)
+
+	err := j.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(inboxMessagePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			// Message keys end with the separator and the 8 bytes of the sequence number
+			key := it.Item().Key()
+			id := string(bytes.TrimPrefix(key[:len(key)-9], prefix))
+			usage, exists := usages[id]
+			if !exists {
+				usage = &inboxUsage{}
+				usages[id] = usage
+			}
+			usage.messages++
+			usage.bytes += int(it.Item().ValueSize())
+		}
+
+		prefix = []byte(inboxSeqPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			id := string(bytes.TrimPrefix(it.Item().Key(), prefix))
+			if _, exists := usages[id]; !exists {
+				usages[id] = &inboxUsage{}
+			}
+		}
+		return nil
+	})
+
+	return usages, err
+}
+
+// parseClientID splits the ID of a device's client into the user ID and the device ID
+func parseClientID(id string) (string, uint32, error) {
+	separator := strings.LastIndex(id, ":")
+	if separator < 0 {
+		return "", 0, fmt.Errorf("missing device ID")
+	}
+
+	deviceID, err := strconv.ParseUint(id[separator+1:], 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid device ID: %w", err)
+	}
+
+	return id[:separator], uint32(deviceID), nil
+}
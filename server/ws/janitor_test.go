@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"signal-chat/internal/apitypes"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboxJanitor_Sweep(t *testing.T) {
+	t.Run("should delete the inboxes of removed devices and report the others", func(t *testing.T) {
+		// Arrange
+		db, cleanup := testDB(t)
+		defer cleanup()
+		deviceStore := NewFakeDeviceStore()
+		deviceStore.AddDevices("user-1", 1, 2)
+
+		kept := &Inbox{db: db, clientID: clientID("user-1", 1)}
+		for _, id := range []string{"msg1", "msg2"} {
+			require.NoError(t, kept.Append(&apitypes.WSMessage{ID: id, Type: apitypes.MessageTypeNewMessage}))
+		}
+		acked := &Inbox{db: db, clientID: clientID("user-1", 2)}
+		require.NoError(t, acked.Append(&apitypes.WSMessage{ID: "msg3", Type: apitypes.MessageTypeNewMessage}))
+		require.NoError(t, acked.Ack(1))
+		removed := &Inbox{db: db, clientID: clientID("user-1", 3)}
+		require.NoError(t, removed.Append(&apitypes.WSMessage{ID: "msg4", Type: apitypes.MessageTypeNewMessage}))
+		abandoned := inboxAbandoned.Value()
+		// The usage of the kept inbox still counts a message that expired
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			return kept.setUsage(txn, inboxUsage{messages: 3, bytes: 1000})
+		}))
+
+		janitor := NewInboxJanitor(db, deviceStore)
+
+		// Act
+		report, err := janitor.Sweep()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Queues)
+		assert.Equal(t, 2, report.Messages)
+		assert.Equal(t, 2, report.MaxDepth)
+		assert.Positive(t, report.Bytes)
+		assert.Equal(t, 1, report.Abandoned)
+
+		assert.Equal(t, int64(2), inboxMessages.Value())
+		assert.Equal(t, abandoned+1, inboxAbandoned.Value())
+
+		messages, _, err := removed.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+		messages, _, err = kept.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Len(t, messages, 2)
+		require.NoError(t, db.View(func(txn *badger.Txn) error {
+			usage, err := kept.usage(txn)
+			require.NoError(t, err)
+			assert.Equal(t, inboxUsage{messages: 2, bytes: report.Bytes}, usage)
+			return nil
+		}))
+
+		// The acknowledged inbox keeps counting where it stopped
+		msg := &apitypes.WSMessage{ID: "msg5", Type: apitypes.MessageTypeNewMessage}
+		require.NoError(t, acked.Append(msg))
+		assert.Equal(t, uint64(2), msg.Seq)
+	})
+}
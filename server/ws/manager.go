@@ -1,25 +1,57 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/dgraph-io/badger/v4"
 	"log"
 	"signal-chat/internal/apitypes"
 	"signal-chat/server/conversation"
+	"slices"
 	"sync"
 	"time"
 )
 
+// typingInterval is the minimum time between two typing events of a device in a conversation that are relayed
+const typingInterval = time.Second
+
 // ConversationStore defines the interface for conversation storage operations
 type ConversationStore interface {
 	GetConversation(id string) (*conversation.Conversation, error)
+	GetContacts(userID string) ([]string, error)
+}
+
+// DeviceStore defines the interface for looking up the devices registered to a user
+type DeviceStore interface {
+	GetDevices(userID string) ([]apitypes.Device, error)
+}
+
+// RequestHandler answers a request sent by a device of a user over its websocket
+type RequestHandler func(userID string, deviceID uint32, request apitypes.WSMessage) apitypes.WSMessage
+
+// address identifies a single device of a user
+type address struct {
+	userID   string
+	deviceID uint32
+}
+
+// typingKey identifies the typing state of a device in a conversation
+type typingKey struct {
+	sender         address
+	conversationID string
+}
+
+// typingState is the last typing event relayed for a typingKey
+type typingState struct {
+	typing    bool
+	relayedAt time.Time
 }
 
 // Manager manages WebSocket connections and message distribution
 type Manager struct {
-	// Registered clients
-	clients map[string]*Client
+	// Registered clients by user and device
+	clients map[string]map[uint32]*Client
 
 	// Mutex to protect concurrent access to clients map
 	mu sync.RWMutex
@@ -29,65 +61,180 @@ type Manager struct {
 
 	// Conversation repository for querying conversation data
 	conversationRepo ConversationStore
+
+	// Device store for fanning out messages to every device of a user
+	deviceStore DeviceStore
+
+	// Bus reaching the clients connected to every server instance, instance identifies this one on it
+	bus      Bus
+	instance string
+
+	// Handler answering the requests devices send over their websocket
+	requestHandler RequestHandler
+
+	// Last relayed typing events, for rate limiting
+	typing   map[typingKey]typingState
+	typingMu sync.Mutex
+
+	// Users whose last device disconnected, by user, until their contacts are told they went offline
+	offline       map[string]*time.Timer
+	presenceDelay time.Duration
+
+	// Limits of the inboxes of the devices
+	inboxLimits InboxLimits
+}
+
+// NewManager creates a new WebSocket manager for a single server instance
+func NewManager(db *badger.DB, conversationRepo ConversationStore, deviceStore DeviceStore) *Manager {
+	return NewManagerWithBus(db, conversationRepo, deviceStore, NewLocalBus())
 }
 
-// NewManager creates a new WebSocket manager
-func NewManager(db *badger.DB, conversationRepo ConversationStore) *Manager {
-	return &Manager{
-		clients:          make(map[string]*Client),
+// NewManagerWithBus creates a new WebSocket manager that delivers messages through the bus, so that devices connected
+// to other server instances get them live
+func NewManagerWithBus(db *badger.DB, conversationRepo ConversationStore, deviceStore DeviceStore, bus Bus) *Manager {
+	m := &Manager{
+		clients:          make(map[string]map[uint32]*Client),
 		db:               db,
 		conversationRepo: conversationRepo,
+		deviceStore:      deviceStore,
+		bus:              bus,
+		instance:         generateMessageID(),
+		typing:           make(map[typingKey]typingState),
+		offline:          make(map[string]*time.Timer),
+		presenceDelay:    presenceDelay,
 	}
+	bus.Subscribe(m.handleBusMessage)
+
+	return m
 }
 
-// RegisterClient registers a new WebSocket connection for a user
-func (m *Manager) RegisterClient(clientID string, conn Connection) error {
+// SetRequestHandler sets the handler answering the requests of clients registered afterwards
+func (m *Manager) SetRequestHandler(handler RequestHandler) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.requestHandler = handler
+}
 
-	// Check if client already exists and close the old connection
-	if existingClient, exists := m.clients[clientID]; exists {
-		existingClient.Close()
+// SetInboxLimits sets the limits of the inboxes. It must be called before devices connect and messages are sent.
+func (m *Manager) SetInboxLimits(limits InboxLimits) {
+	m.inboxLimits = limits
+}
+
+// CheckInboxes returns ErrInboxFull when the inbox of a device of another participant of the conversation rejects new
+// messages, so that the sender can be told before the message is stored
+func (m *Manager) CheckInboxes(senderID string, senderDeviceID uint32, conversationID string) error {
+	conv, err := m.conversationRepo.GetConversation(conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conv: %w", err)
 	}
 
-	// Create a new message store for this client
-	messageStore := &MessageStore{
-		db:       m.db,
-		clientID: clientID,
+	recipients := m.devicesOf(conv.ParticipantIDs, address{userID: senderID, deviceID: senderDeviceID})
+
+	for _, recipient := range recipients {
+		id := clientID(recipient.userID, recipient.deviceID)
+		full, err := m.inbox(id).Full()
+		if err != nil {
+			return fmt.Errorf("failed to check inbox of client %s: %w", id, err)
+		}
+		if full {
+			return fmt.Errorf("inbox of client %s: %w", id, ErrInboxFull)
+		}
+	}
+
+	return nil
+}
+
+// RegisterClient registers a new WebSocket connection for a device of a user. Delivery resumes after the cursor, the
+// sequence number of the last message the device processed. The contacts of the user are told when its first device
+// connects.
+func (m *Manager) RegisterClient(userID string, deviceID uint32, cursor uint64, conn Connection) error {
+	m.mu.Lock()
+
+	devices, exists := m.clients[userID]
+	if !exists {
+		devices = make(map[uint32]*Client)
+		m.clients[userID] = devices
 	}
 
-	// Create a new client
-	client := NewClient(clientID, conn, messageStore)
-	m.clients[clientID] = client
+	// Check if the device is already connected and close the old connection
+	if existingClient, exists := devices[deviceID]; exists {
+		existingClient.Close()
+	}
 
-	log.Printf("Client registered: %s", clientID)
+	id := clientID(userID, deviceID)
+
+	// Create a new client delivering the device's inbox
+	client := NewClient(id, conn, m.inbox(id), cursor)
+	if handler := m.requestHandler; handler != nil {
+		client.SetRequestHandler(func(request apitypes.WSMessage) apitypes.WSMessage {
+			return handler(userID, deviceID, request)
+		})
+	}
+	client.SetEventHandler(func(event apitypes.WSMessage) {
+		m.handleEvent(userID, deviceID, event)
+	})
+	client.SetDisconnectedHandler(func() {
+		m.removeClient(userID, deviceID, client)
+	})
+	devices[deviceID] = client
+	cameOnline := !exists && m.cancelOffline(userID)
+	m.mu.Unlock()
+
+	log.Printf("Client registered: %s", id)
+	if cameOnline {
+		m.broadcastPresence(apitypes.WSPresencePayload{UserID: userID, Online: true})
+	}
 	return nil
 }
 
-// UnregisterClient removes a client from the manager
-func (m *Manager) UnregisterClient(clientID string) {
+// UnregisterClient removes the client of a device from the manager. The contacts of the user are told once none of
+// its devices reconnected within presenceDelay.
+func (m *Manager) UnregisterClient(userID string, deviceID uint32) {
+	m.removeClient(userID, deviceID, nil)
+}
+
+// removeClient removes the client of a device. If only is set, the client is only removed if it's still the one of
+// the device, which leaves a newer connection of the device alone.
+func (m *Manager) removeClient(userID string, deviceID uint32, only *Client) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if client, exists := m.clients[clientID]; exists {
+	devices := m.clients[userID]
+	if client, exists := devices[deviceID]; exists && (only == nil || client == only) {
 		client.Close()
-		delete(m.clients, clientID)
-		log.Printf("Client unregistered: %s", clientID)
+		delete(devices, deviceID)
+		if len(devices) == 0 {
+			delete(m.clients, userID)
+			m.scheduleOffline(userID)
+		}
+		log.Printf("Client unregistered: %s", clientID(userID, deviceID))
+	}
+
+	m.typingMu.Lock()
+	defer m.typingMu.Unlock()
+	for key := range m.typing {
+		if key.sender == (address{userID: userID, deviceID: deviceID}) {
+			delete(m.typing, key)
+		}
 	}
 }
 
-// BroadcastNewConversation sends a notification about a new conversation to all participants
-func (m *Manager) BroadcastNewConversation(senderID string, req apitypes.CreateConversationRequest) error {
-	participantIDs := make([]string, 0, len(req.OtherParticipants))
+// BroadcastNewConversation sends a notification about a new conversation to every device that received a key
+// distribution message
+func (m *Manager) BroadcastNewConversation(senderID string, senderDeviceID uint32, req apitypes.CreateConversationRequest) error {
+	participantIDs := make([]string, 0, len(req.OtherParticipants)+1)
 	participantIDs = append(participantIDs, senderID)
 	for _, participant := range req.OtherParticipants {
-		participantIDs = append(participantIDs, participant.ID)
+		if !slices.Contains(participantIDs, participant.ID) {
+			participantIDs = append(participantIDs, participant.ID)
+		}
 	}
 
 	for _, participant := range req.OtherParticipants {
 		payload := apitypes.WSNewConversationPayload{
 			ConversationID:         req.ConversationID,
 			SenderID:               senderID,
+			SenderDeviceID:         senderDeviceID,
 			ParticipantIDs:         participantIDs,
 			KeyDistributionMessage: participant.KeyDistributionMessage,
 		}
@@ -97,91 +244,441 @@ func (m *Manager) BroadcastNewConversation(senderID string, req apitypes.CreateC
 			return err
 		}
 
-		m.sendMessageToClient(participant.ID, apitypes.MessageTypeNewConversation, payloadBytes)
+		m.sendMessageToDevice(participant.ID, participant.DeviceID, apitypes.MessageTypeNewConversation, payloadBytes)
 	}
 
 	return nil
 }
 
-// BroadcastNewMessage sends a notification about a new message to all participants in a conversation
-func (m *Manager) BroadcastNewMessage(senderID, messageID string, req apitypes.SendMessageRequest) error {
+// BroadcastNewMessage sends a notification about a new message to every device of the conversation participants
+// except the one that sent it. Notifications of disappearing messages are dropped from the inboxes once the message
+// expires at the given time in Unix milliseconds, zero keeps them.
+func (m *Manager) BroadcastNewMessage(senderID string, senderDeviceID uint32, messageID string, createdAt, expiresAt int64, req apitypes.SendMessageRequest) error {
 	// get conversation from the repository
 	conv, err := m.conversationRepo.GetConversation(req.ConversationID)
 	if err != nil {
 		return fmt.Errorf("failed to get conv: %w", err)
 	}
 
-	// Filter out the sender from participants
-	recipientIDs := make([]string, 0, len(conv.ParticipantIDs)-1)
-	for _, p := range conv.ParticipantIDs {
-		if p != senderID {
-			recipientIDs = append(recipientIDs, p)
+	payload := apitypes.WSNewMessagePayload{
+		ConversationID: req.ConversationID,
+		MessageID:      messageID,
+		SenderID:       senderID,
+		SenderDeviceID: senderDeviceID,
+		Content:        req.Content,
+		CreatedAt:      createdAt,
+		ExpiresAt:      expiresAt,
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	recipients := m.devicesOf(conv.ParticipantIDs, address{userID: senderID, deviceID: senderDeviceID})
+
+	var expiry time.Time
+	if expiresAt > 0 {
+		expiry = time.UnixMilli(expiresAt)
+	}
+	for _, recipient := range recipients {
+		m.sendExpiringMessageToDevice(recipient.userID, recipient.deviceID, apitypes.MessageTypeNewMessage, payloadBytes, expiry)
+	}
+
+	return nil
+}
+
+// BroadcastExpirationTimer notifies every device of the conversation participants except the one that changed it
+// about the new expiration timer of the conversation
+func (m *Manager) BroadcastExpirationTimer(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.SetExpirationTimerRequest) error {
+	payload := apitypes.WSExpirationTimerPayload{
+		ConversationID:  req.ConversationID,
+		SenderID:        senderID,
+		SenderDeviceID:  senderDeviceID,
+		ExpirationTimer: req.ExpirationTimer,
+		CreatedAt:       time.Now().UnixMilli(),
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	recipients := m.devicesOf(participantIDs, address{userID: senderID, deviceID: senderDeviceID})
+
+	for _, recipient := range recipients {
+		m.sendMessageToDevice(recipient.userID, recipient.deviceID, apitypes.MessageTypeExpirationTimer, payloadBytes)
+	}
+
+	return nil
+}
+
+// BroadcastParticipantsAdded notifies every device of the conversation members about newly added participants.
+// Devices that have a key distribution message in the request also receive the sender key of the device that added
+// them.
+func (m *Manager) BroadcastParticipantsAdded(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.AddParticipantsRequest) error {
+	addedIDs := make([]string, 0, len(req.Participants))
+	keyMessages := make(map[address][]byte, len(req.Participants))
+	for _, participant := range req.Participants {
+		if !slices.Contains(addedIDs, participant.ID) && participant.ID != senderID {
+			addedIDs = append(addedIDs, participant.ID)
 		}
+		keyMessages[address{userID: participant.ID, deviceID: participant.DeviceID}] = participant.KeyDistributionMessage
 	}
 
-	// Send to all recipients
-	for _, id := range recipientIDs {
-		// Create recipient-specific payload with all participants except the receiver
-		recipientPayload := apitypes.WSNewMessagePayload{
-			ConversationID: req.ConversationID,
-			MessageID:      messageID,
-			SenderID:       senderID,
-			Content:        req.Content,
-			CreatedAt:      time.Now().Unix(),
+	recipients := m.devicesOf(participantIDs, address{userID: senderID, deviceID: senderDeviceID})
+
+	for _, recipient := range recipients {
+		payload := apitypes.WSParticipantAddedPayload{
+			ConversationID:         req.ConversationID,
+			SenderID:               senderID,
+			SenderDeviceID:         senderDeviceID,
+			ParticipantIDs:         participantIDs,
+			AddedIDs:               addedIDs,
+			KeyDistributionMessage: keyMessages[recipient],
 		}
 
-		payloadBytes, err := json.Marshal(recipientPayload)
+		payloadBytes, err := json.Marshal(payload)
 		if err != nil {
 			return err
 		}
 
-		m.sendMessageToClient(id, apitypes.MessageTypeNewMessage, payloadBytes)
+		m.sendMessageToDevice(recipient.userID, recipient.deviceID, apitypes.MessageTypeParticipantAdded, payloadBytes)
 	}
 
 	return nil
 }
 
-// sendMessageToClient sends a message to a specific client or stores it if the client is offline
-func (m *Manager) sendMessageToClient(userID string, msgType apitypes.WSMessageType, payload []byte) {
-	m.mu.RLock()
-	client, exists := m.clients[userID]
-	m.mu.RUnlock()
+// BroadcastParticipantRemoved notifies every device of the remaining members and of the removed participant about
+// the removal. Devices of remaining members also receive the rotated sender key of the device that removed the
+// participant.
+func (m *Manager) BroadcastParticipantRemoved(senderID string, senderDeviceID uint32, participantIDs []string, req apitypes.RemoveParticipantRequest) error {
+	keyMessages := make(map[address][]byte, len(req.KeyDistributions))
+	for _, participant := range req.KeyDistributions {
+		keyMessages[address{userID: participant.ID, deviceID: participant.DeviceID}] = participant.KeyDistributionMessage
+	}
+
+	recipientIDs := make([]string, 0, len(participantIDs)+1)
+	recipientIDs = append(recipientIDs, participantIDs...)
+	recipientIDs = append(recipientIDs, req.ParticipantID)
+
+	recipients := m.devicesOf(recipientIDs, address{userID: senderID, deviceID: senderDeviceID})
+
+	for _, recipient := range recipients {
+		payload := apitypes.WSParticipantRemovedPayload{
+			ConversationID:         req.ConversationID,
+			SenderID:               senderID,
+			SenderDeviceID:         senderDeviceID,
+			RemovedID:              req.ParticipantID,
+			ParticipantIDs:         participantIDs,
+			KeyDistributionMessage: keyMessages[recipient],
+		}
+
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		m.sendMessageToDevice(recipient.userID, recipient.deviceID, apitypes.MessageTypeParticipantRemoved, payloadBytes)
+	}
+
+	return nil
+}
+
+// BroadcastSenderKeys relays sender key distribution messages to the given devices
+func (m *Manager) BroadcastSenderKeys(senderID string, senderDeviceID uint32, req apitypes.DistributeSenderKeysRequest) error {
+	for _, participant := range req.Participants {
+		payload := apitypes.WSSenderKeyPayload{
+			ConversationID:         req.ConversationID,
+			SenderID:               senderID,
+			SenderDeviceID:         senderDeviceID,
+			KeyDistributionMessage: participant.KeyDistributionMessage,
+		}
+
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		m.sendMessageToDevice(participant.ID, participant.DeviceID, apitypes.MessageTypeSenderKeyDistribution, payloadBytes)
+	}
+
+	return nil
+}
+
+// BroadcastReceipt relays a delivery or read receipt to every device of the author of the acknowledged messages. Like
+// other messages, receipts are stored for devices that are offline.
+func (m *Manager) BroadcastReceipt(senderID string, senderDeviceID uint32, req apitypes.SendReceiptRequest) error {
+	payload := apitypes.WSReceiptPayload{
+		ConversationID: req.ConversationID,
+		SenderID:       senderID,
+		SenderDeviceID: senderDeviceID,
+		MessageIDs:     req.MessageIDs,
+		Type:           req.Type,
+		CreatedAt:      time.Now().UnixMilli(),
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	recipients := m.devicesOf([]string{req.AuthorID}, address{userID: senderID, deviceID: senderDeviceID})
+
+	for _, recipient := range recipients {
+		m.sendMessageToDevice(recipient.userID, recipient.deviceID, apitypes.MessageTypeReceipt, payloadBytes)
+	}
+
+	return nil
+}
+
+// NotifyPreKeysLow tells the device that its supply of one-time pre-keys is running low
+func (m *Manager) NotifyPreKeysLow(userID string, deviceID uint32, remaining int) error {
+	payloadBytes, err := json.Marshal(apitypes.WSPreKeysLowPayload{Remaining: remaining})
+	if err != nil {
+		return err
+	}
+
+	m.sendMessageToDevice(userID, deviceID, apitypes.MessageTypePreKeysLow, payloadBytes)
+	return nil
+}
+
+// handleEvent handles an ephemeral event sent by a device
+func (m *Manager) handleEvent(userID string, deviceID uint32, event apitypes.WSMessage) {
+	switch event.Type {
+	case apitypes.MessageTypeTyping:
+		var payload apitypes.WSTypingPayload
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			log.Printf("Malformed typing event from client %s: %v", clientID(userID, deviceID), err)
+			return
+		}
+		if err := m.RelayTyping(userID, deviceID, payload); err != nil {
+			log.Printf("Failed to relay typing event from client %s: %v", clientID(userID, deviceID), err)
+		}
+	default:
+		log.Printf("Unsupported event type %d from client %s", event.Type, clientID(userID, deviceID))
+	}
+}
+
+// RelayTyping relays a typing event to the connected devices of the other conversation participants. The event is
+// never stored, devices that are offline miss it. A device starting to type is relayed at most once per typingInterval.
+func (m *Manager) RelayTyping(senderID string, senderDeviceID uint32, payload apitypes.WSTypingPayload) error {
+	conv, err := m.conversationRepo.GetConversation(payload.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conv: %w", err)
+	}
+	if !conv.HasParticipant(senderID) {
+		return fmt.Errorf("user %s is not a participant of conversation %s", senderID, payload.ConversationID)
+	}
+
+	sender := address{userID: senderID, deviceID: senderDeviceID}
+	if !m.allowTyping(typingKey{sender: sender, conversationID: payload.ConversationID}, payload.Typing) {
+		return nil
+	}
+
+	payload.SenderID = senderID
+	payload.SenderDeviceID = senderDeviceID
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
 
+	message := &apitypes.WSMessage{
+		ID:   generateMessageID(),
+		Type: apitypes.MessageTypeTyping,
+		Data: payloadBytes,
+	}
+
+	for _, participantID := range conv.ParticipantIDs {
+		if participantID == senderID {
+			continue
+		}
+		if err := m.bus.Publish(BusMessage{UserID: participantID, Ephemeral: message}); err != nil {
+			return fmt.Errorf("failed to publish typing event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// allowTyping tells whether the typing event should be relayed and records it if so. Starting to type within
+// typingInterval of the last relayed event is dropped, stopping is relayed once after starting.
+func (m *Manager) allowTyping(key typingKey, typing bool) bool {
+	m.typingMu.Lock()
+	defer m.typingMu.Unlock()
+
+	last, exists := m.typing[key]
+	if typing && exists && time.Since(last.relayedAt) < typingInterval {
+		return false
+	}
+	if !typing && (!exists || !last.typing) {
+		return false
+	}
+
+	m.typing[key] = typingState{typing: typing, relayedAt: time.Now()}
+	return true
+}
+
+// devicesOf returns the addresses of all devices of the given users except the excluded one. Users whose devices
+// can't be looked up are skipped so that the others still receive the broadcast.
+func (m *Manager) devicesOf(userIDs []string, excluded address) []address {
+	var addresses []address
+	for _, userID := range userIDs {
+		devices, err := m.deviceStore.GetDevices(userID)
+		if err != nil {
+			log.Printf("Failed to get devices of user %s: %v", userID, err)
+			continue
+		}
+
+		for _, device := range devices {
+			addr := address{userID: userID, deviceID: device.ID}
+			if addr != excluded {
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+
+	return addresses
+}
+
+// sendMessageToDevice appends a message to the inbox of a specific device and publishes it for the client of the device
+// on whichever server instance it's connected to. Offline devices receive the message when they connect.
+func (m *Manager) sendMessageToDevice(userID string, deviceID uint32, msgType apitypes.WSMessageType, payload []byte) {
+	m.sendExpiringMessageToDevice(userID, deviceID, msgType, payload, time.Time{})
+}
+
+// sendExpiringMessageToDevice sends a message to a specific device that is dropped from its inbox at the given time
+func (m *Manager) sendExpiringMessageToDevice(userID string, deviceID uint32, msgType apitypes.WSMessageType, payload []byte, expiresAt time.Time) {
 	message := &apitypes.WSMessage{
 		ID:   generateMessageID(),
 		Type: msgType,
 		Data: payload,
 	}
 
-	if !exists {
-		// Client is offline, store the message in the database
-		messageStore := &MessageStore{
-			db:       m.db,
-			clientID: userID,
+	id := clientID(userID, deviceID)
+	if err := m.inbox(id).AppendExpiring(message, expiresAt); err != nil {
+		log.Printf("Failed to store message for client %s: %v", id, err)
+		return
+	}
+	if message.Seq == 0 {
+		// The message already expired and wasn't stored
+		return
+	}
+
+	busMsg := BusMessage{UserID: userID, DeviceID: deviceID, Origin: m.instance, Message: message}
+	if !expiresAt.IsZero() {
+		busMsg.ExpiresAt = expiresAt.UnixMilli()
+	}
+	if err := m.bus.Publish(busMsg); err != nil {
+		log.Printf("Failed to publish message for client %s: %v", id, err)
+	}
+}
+
+// handleBusMessage delivers a message published on the bus to the clients connected to this instance
+func (m *Manager) handleBusMessage(msg BusMessage) {
+	if msg.Moved > 0 {
+		if msg.Origin == m.instance {
+			id := clientID(msg.UserID, msg.DeviceID)
+			if err := m.inbox(id).Remove(msg.Moved); err != nil {
+				log.Printf("Failed to remove moved message %d of client %s: %v", msg.Moved, id, err)
+			}
 		}
+		return
+	}
 
-		if err := messageStore.Store([]*apitypes.WSMessage{message}); err != nil {
-			log.Printf("Failed to store message for offline client %s: %v", userID, err)
+	m.mu.RLock()
+	if msg.Ephemeral != nil {
+		for _, client := range m.clients[msg.UserID] {
+			client.sendEphemeral(msg.Ephemeral)
 		}
+		m.mu.RUnlock()
 		return
 	}
+	client, exists := m.clients[msg.UserID][msg.DeviceID]
+	m.mu.RUnlock()
 
-	// Client is online, send the message directly
-	if err := client.SendMessage(message); err != nil {
-		log.Printf("Failed to send message to client %s: %v", userID, err)
+	if !exists {
+		return
 	}
+	if msg.Message != nil && msg.Origin != m.instance && !m.moveToInbox(msg) {
+		return
+	}
+	client.Notify()
 }
 
-// CloseAll closes all client connections
-func (m *Manager) CloseAll() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// moveToInbox appends a message stored by another instance to the inbox of the device on this instance, which the
+// device is connected to, and tells the origin to remove it. It reports whether the message was moved.
+func (m *Manager) moveToInbox(msg BusMessage) bool {
+	// The message is shared with the other subscribers of the bus
+	message := *msg.Message
+	var expiresAt time.Time
+	if msg.ExpiresAt > 0 {
+		expiresAt = time.UnixMilli(msg.ExpiresAt)
+	}
 
-	for clientID, client := range m.clients {
-		client.Close()
-		log.Printf("Client connection closed: %s", clientID)
+	id := clientID(msg.UserID, msg.DeviceID)
+	if err := m.inbox(id).AppendExpiring(&message, expiresAt); err != nil {
+		log.Printf("Failed to move message %d from instance %s to client %s: %v", msg.Message.Seq, msg.Origin, id, err)
+		return false
+	}
+
+	moved := BusMessage{UserID: msg.UserID, DeviceID: msg.DeviceID, Origin: msg.Origin, Moved: msg.Message.Seq}
+	if err := m.bus.Publish(moved); err != nil {
+		log.Printf("Failed to publish moved message of client %s: %v", id, err)
+	}
+	return true
+}
+
+// inbox returns the message inbox of the client with the given ID
+func (m *Manager) inbox(clientID string) *Inbox {
+	return &Inbox{
+		db:       m.db,
+		clientID: clientID,
+		limits:   m.inboxLimits,
+	}
+}
+
+// Shutdown tells every connected device that the server is restarting and waits until the connections are closed, so
+// that the requests the devices sent before are answered. Connections still open when the context is done are dropped.
+// Messages the devices didn't acknowledge stay in their inboxes and are delivered once they reconnect.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	var clients []*Client
+	for userID, devices := range m.clients {
+		for deviceID, client := range devices {
+			client.Restart()
+			clients = append(clients, client)
+			log.Printf("Client connection closed: %s", clientID(userID, deviceID))
+		}
 	}
 
 	// Clear the clients map
-	m.clients = make(map[string]*Client)
+	m.clients = make(map[string]map[uint32]*Client)
+
+	// The devices are expected to reconnect once the server is back, so their contacts aren't told they went offline
+	for _, timer := range m.offline {
+		timer.Stop()
+	}
+	m.offline = make(map[string]*time.Timer)
+	m.mu.Unlock()
+
+	for i, client := range clients {
+		select {
+		case <-client.Stopped():
+		case <-ctx.Done():
+			// The devices didn't answer the close frame in time. A request still being handled isn't waited for, so a
+			// stuck handler can't hold up the shutdown.
+			for _, client := range clients[i:] {
+				_ = client.conn.Close()
+			}
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// clientID returns the identifier of a device's client, which also names its offline message queue
+func clientID(userID string, deviceID uint32) string {
+	return fmt.Sprintf("%s:%d", userID, deviceID)
 }
@@ -1,11 +1,15 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"signal-chat/internal/apitypes"
 	"testing"
 	"time"
 
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"signal-chat/server/conversation"
@@ -19,7 +23,7 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		defer dbClose()
 
 		convRepo := NewMockConversationRepository()
-		manager := NewManager(db, convRepo)
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
 
 		// Create a test conversation
 		conv := &conversation.Conversation{
@@ -37,13 +41,14 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		// Create fake clients for recipients
 		fakeConn1 := NewFakeWebSocketConn()
 		fakeConn2 := NewFakeWebSocketConn()
-		err := manager.RegisterClient("user-2", fakeConn1)
+		err := manager.RegisterClient("user-2", 1, 0, fakeConn1)
 		require.NoError(t, err)
-		err = manager.RegisterClient("user-3", fakeConn2)
+		err = manager.RegisterClient("user-3", 1, 0, fakeConn2)
 		require.NoError(t, err)
+		assert.Equal(t, "user-3", receivePresence(t, fakeConn1).UserID, "user-2 should be told that user-3 came online")
 
 		// Act
-		err = manager.BroadcastNewMessage(senderID, messageID, req)
+		err = manager.BroadcastNewMessage(senderID, 1, messageID, time.Now().UnixMilli(), 0, req)
 		require.NoError(t, err)
 
 		// Wait for messages to be sent
@@ -98,7 +103,7 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		defer dbClose()
 
 		convRepo := NewMockConversationRepository()
-		manager := NewManager(db, convRepo)
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
 
 		// Create a test conversation
 		conv := &conversation.Conversation{
@@ -114,16 +119,13 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		}
 
 		// Act
-		err := manager.BroadcastNewMessage(senderID, messageID, req)
+		err := manager.BroadcastNewMessage(senderID, 1, messageID, time.Now().UnixMilli(), 0, req)
 		require.NoError(t, err)
 
 		// Assert
 		// Check that messages were stored for offline recipients
-		messageStore1 := &MessageStore{
-			db:       db,
-			clientID: "user-2",
-		}
-		messages1, err := messageStore1.LoadAll()
+		inbox1 := &Inbox{db: db, clientID: "user-2:1"}
+		messages1, _, err := inbox1.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages1, 1)
 		assert.Equal(t, apitypes.MessageTypeNewMessage, messages1[0].Type)
@@ -136,11 +138,8 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		assert.Equal(t, req.ConversationID, wsPayload1.ConversationID)
 		assert.Equal(t, req.Content, wsPayload1.Content)
 
-		messageStore2 := &MessageStore{
-			db:       db,
-			clientID: "user-3",
-		}
-		messages2, err := messageStore2.LoadAll()
+		inbox2 := &Inbox{db: db, clientID: "user-3:1"}
+		messages2, _, err := inbox2.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages2, 1)
 		assert.Equal(t, apitypes.MessageTypeNewMessage, messages2[0].Type)
@@ -154,13 +153,41 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		assert.Equal(t, req.Content, wsPayload2.Content)
 	})
 
+	t.Run("should deliver to other participants when devices of one can't be looked up", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		deviceStore := NewFakeDeviceStore()
+		deviceStore.FailDevices("user-2", errors.New("lookup failed"))
+		manager := NewManager(db, convRepo, deviceStore)
+		convRepo.AddConversation("conv-123", &conversation.Conversation{
+			ParticipantIDs: []string{"user-1", "user-2", "user-3"},
+		})
+		req := apitypes.SendMessageRequest{
+			ConversationID: "conv-123",
+			Content:        []byte("encrypted-message"),
+		}
+
+		// Act
+		err := manager.BroadcastNewMessage("user-1", 1, "msg-456", time.Now().UnixMilli(), 0, req)
+
+		// Assert
+		require.NoError(t, err)
+		inbox := &Inbox{db: db, clientID: "user-3:1"}
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+
 	t.Run("should return error for non-existent conversation", func(t *testing.T) {
 		// Arrange
 		db, dbClose := testDB(t)
 		defer dbClose()
 
 		convRepo := NewMockConversationRepository()
-		manager := NewManager(db, convRepo)
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
 
 		senderID := "user-1"
 		messageID := "msg-789"
@@ -170,7 +197,7 @@ func TestManager_BroadcastNewMessage(t *testing.T) {
 		}
 
 		// Act
-		err := manager.BroadcastNewMessage(senderID, messageID, req)
+		err := manager.BroadcastNewMessage(senderID, 1, messageID, time.Now().UnixMilli(), 0, req)
 
 		// Assert
 		assert.Error(t, err)
@@ -186,7 +213,7 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 		defer dbClose()
 
 		convRepo := NewMockConversationRepository()
-		manager := NewManager(db, convRepo)
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
 
 		senderID := "user-1"
 		req := apitypes.CreateConversationRequest{
@@ -194,10 +221,12 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 			OtherParticipants: []apitypes.Participant{
 				{
 					ID:                     "user-2",
+					DeviceID:               1,
 					KeyDistributionMessage: []byte("key-distribution-message"),
 				},
 				{
 					ID:                     "user-3",
+					DeviceID:               1,
 					KeyDistributionMessage: []byte("key-distribution-message"),
 				},
 			},
@@ -206,13 +235,13 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 		// Create fake clients for recipients
 		fakeConn1 := NewFakeWebSocketConn()
 		fakeConn2 := NewFakeWebSocketConn()
-		err := manager.RegisterClient("user-2", fakeConn1)
+		err := manager.RegisterClient("user-2", 1, 0, fakeConn1)
 		require.NoError(t, err)
-		err = manager.RegisterClient("user-3", fakeConn2)
+		err = manager.RegisterClient("user-3", 1, 0, fakeConn2)
 		require.NoError(t, err)
 
 		// Act
-		err = manager.BroadcastNewConversation(senderID, req)
+		err = manager.BroadcastNewConversation(senderID, 1, req)
 		require.NoError(t, err)
 
 		// Wait for messages to be sent
@@ -267,7 +296,7 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 		defer dbClose()
 
 		convRepo := NewMockConversationRepository()
-		manager := NewManager(db, convRepo)
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
 
 		senderID := "user-1"
 		req := apitypes.CreateConversationRequest{
@@ -275,26 +304,25 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 			OtherParticipants: []apitypes.Participant{
 				{
 					ID:                     "user-2",
+					DeviceID:               1,
 					KeyDistributionMessage: []byte("key-distribution-message"),
 				},
 				{
 					ID:                     "user-3",
+					DeviceID:               1,
 					KeyDistributionMessage: []byte("key-distribution-message"),
 				},
 			},
 		}
 
 		// Act
-		err := manager.BroadcastNewConversation(senderID, req)
+		err := manager.BroadcastNewConversation(senderID, 1, req)
 		require.NoError(t, err)
 
 		// Assert
 		// Check that messages were stored for offline recipients
-		messageStore1 := &MessageStore{
-			db:       db,
-			clientID: "user-2",
-		}
-		messages1, err := messageStore1.LoadAll()
+		inbox1 := &Inbox{db: db, clientID: "user-2:1"}
+		messages1, _, err := inbox1.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages1, 1)
 		assert.Equal(t, apitypes.MessageTypeNewConversation, messages1[0].Type)
@@ -307,11 +335,8 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 		assert.Equal(t, []string{"user-1", "user-2", "user-3"}, payload1.ParticipantIDs)
 		assert.Equal(t, req.OtherParticipants[0].KeyDistributionMessage, payload1.KeyDistributionMessage)
 
-		messageStore2 := &MessageStore{
-			db:       db,
-			clientID: "user-3",
-		}
-		messages2, err := messageStore2.LoadAll()
+		inbox2 := &Inbox{db: db, clientID: "user-3:1"}
+		messages2, _, err := inbox2.LoadPage(0, pageSize, pageBytes)
 		require.NoError(t, err)
 		require.Len(t, messages2, 1)
 		assert.Equal(t, apitypes.MessageTypeNewConversation, messages2[0].Type)
@@ -325,3 +350,685 @@ func TestManager_BroadcastNewConversation(t *testing.T) {
 		assert.Equal(t, req.OtherParticipants[1].KeyDistributionMessage, payload2.KeyDistributionMessage)
 	})
 }
+
+func TestManager_BroadcastParticipantsAdded(t *testing.T) {
+	t.Run("should send sender key only to added participants", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+
+		senderID := "user-1"
+		participantIDs := []string{"user-1", "user-2", "user-3"}
+		req := apitypes.AddParticipantsRequest{
+			ConversationID: "conv-123",
+			Participants: []apitypes.Participant{
+				{ID: "user-3", DeviceID: 1, KeyDistributionMessage: []byte("key-distribution-message")},
+			},
+		}
+
+		// Act
+		err := manager.BroadcastParticipantsAdded(senderID, 1, participantIDs, req)
+		require.NoError(t, err)
+
+		// Assert
+		senderInbox := &Inbox{db: db, clientID: senderID + ":1"}
+		senderMessages, _, err := senderInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, senderMessages)
+
+		existingInbox := &Inbox{db: db, clientID: "user-2:1"}
+		existingMessages, _, err := existingInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, existingMessages, 1)
+		assert.Equal(t, apitypes.MessageTypeParticipantAdded, existingMessages[0].Type)
+		var existingPayload apitypes.WSParticipantAddedPayload
+		require.NoError(t, json.Unmarshal(existingMessages[0].Data, &existingPayload))
+		assert.Equal(t, participantIDs, existingPayload.ParticipantIDs)
+		assert.Equal(t, []string{"user-3"}, existingPayload.AddedIDs)
+		assert.Empty(t, existingPayload.KeyDistributionMessage)
+
+		addedInbox := &Inbox{db: db, clientID: "user-3:1"}
+		addedMessages, _, err := addedInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, addedMessages, 1)
+		var addedPayload apitypes.WSParticipantAddedPayload
+		require.NoError(t, json.Unmarshal(addedMessages[0].Data, &addedPayload))
+		assert.Equal(t, senderID, addedPayload.SenderID)
+		assert.Equal(t, req.Participants[0].KeyDistributionMessage, addedPayload.KeyDistributionMessage)
+	})
+}
+
+func TestManager_BroadcastParticipantRemoved(t *testing.T) {
+	t.Run("should notify removed participant without sender key", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+
+		senderID := "user-1"
+		participantIDs := []string{"user-1", "user-2"}
+		req := apitypes.RemoveParticipantRequest{
+			ConversationID: "conv-123",
+			ParticipantID:  "user-3",
+			KeyDistributions: []apitypes.Participant{
+				{ID: "user-2", DeviceID: 1, KeyDistributionMessage: []byte("rotated-key")},
+			},
+		}
+
+		// Act
+		err := manager.BroadcastParticipantRemoved(senderID, 1, participantIDs, req)
+		require.NoError(t, err)
+
+		// Assert
+		remainingInbox := &Inbox{db: db, clientID: "user-2:1"}
+		remainingMessages, _, err := remainingInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, remainingMessages, 1)
+		assert.Equal(t, apitypes.MessageTypeParticipantRemoved, remainingMessages[0].Type)
+		var remainingPayload apitypes.WSParticipantRemovedPayload
+		require.NoError(t, json.Unmarshal(remainingMessages[0].Data, &remainingPayload))
+		assert.Equal(t, "user-3", remainingPayload.RemovedID)
+		assert.Equal(t, participantIDs, remainingPayload.ParticipantIDs)
+		assert.Equal(t, []byte("rotated-key"), remainingPayload.KeyDistributionMessage)
+
+		removedInbox := &Inbox{db: db, clientID: "user-3:1"}
+		removedMessages, _, err := removedInbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, removedMessages, 1)
+		var removedPayload apitypes.WSParticipantRemovedPayload
+		require.NoError(t, json.Unmarshal(removedMessages[0].Data, &removedPayload))
+		assert.Equal(t, "user-3", removedPayload.RemovedID)
+		assert.Empty(t, removedPayload.KeyDistributionMessage)
+	})
+}
+
+func TestManager_BroadcastReceipt(t *testing.T) {
+	t.Run("should queue receipt for every device of the author", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		deviceStore := NewFakeDeviceStore()
+		deviceStore.AddDevices("user-1", 1, 2)
+		manager := NewManager(db, NewMockConversationRepository(), deviceStore)
+		req := apitypes.SendReceiptRequest{
+			ConversationID: "conv-123",
+			AuthorID:       "user-1",
+			MessageIDs:     []string{"msg-1", "msg-2"},
+			Type:           apitypes.ReceiptTypeRead,
+		}
+
+		// Act
+		err := manager.BroadcastReceipt("user-2", 1, req)
+		require.NoError(t, err)
+
+		// Assert
+		for _, id := range []string{"user-1:1", "user-1:2"} {
+			messages, _, err := (&Inbox{db: db, clientID: id}).LoadPage(0, pageSize, pageBytes)
+			require.NoError(t, err)
+			require.Len(t, messages, 1, "receipt should be stored for %s", id)
+			assert.Equal(t, apitypes.MessageTypeReceipt, messages[0].Type)
+
+			var payload apitypes.WSReceiptPayload
+			require.NoError(t, json.Unmarshal(messages[0].Data, &payload))
+			assert.Equal(t, "conv-123", payload.ConversationID)
+			assert.Equal(t, "user-2", payload.SenderID)
+			assert.Equal(t, uint32(1), payload.SenderDeviceID)
+			assert.Equal(t, []string{"msg-1", "msg-2"}, payload.MessageIDs)
+			assert.Equal(t, apitypes.ReceiptTypeRead, payload.Type)
+			assert.NotZero(t, payload.CreatedAt)
+		}
+
+		senderMessages, _, err := (&Inbox{db: db, clientID: "user-2:1"}).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, senderMessages)
+	})
+}
+
+func TestManager_BroadcastExpirationTimer(t *testing.T) {
+	t.Run("should queue the new timer for every device except the sender's", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		deviceStore := NewFakeDeviceStore()
+		deviceStore.AddDevices("user-1", 1, 2)
+		manager := NewManager(db, NewMockConversationRepository(), deviceStore)
+		req := apitypes.SetExpirationTimerRequest{ConversationID: "conv-123", ExpirationTimer: 3600}
+
+		// Act
+		err := manager.BroadcastExpirationTimer("user-1", 1, []string{"user-1", "user-2"}, req)
+		require.NoError(t, err)
+
+		// Assert
+		for _, id := range []string{"user-1:2", "user-2:1"} {
+			messages, _, err := (&Inbox{db: db, clientID: id}).LoadPage(0, pageSize, pageBytes)
+			require.NoError(t, err)
+			require.Len(t, messages, 1, "timer should be stored for %s", id)
+			assert.Equal(t, apitypes.MessageTypeExpirationTimer, messages[0].Type)
+
+			var payload apitypes.WSExpirationTimerPayload
+			require.NoError(t, json.Unmarshal(messages[0].Data, &payload))
+			assert.Equal(t, "conv-123", payload.ConversationID)
+			assert.Equal(t, "user-1", payload.SenderID)
+			assert.Equal(t, int64(3600), payload.ExpirationTimer)
+		}
+
+		senderMessages, _, err := (&Inbox{db: db, clientID: "user-1:1"}).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, senderMessages)
+	})
+}
+
+func TestManager_NotifyPreKeysLow(t *testing.T) {
+	t.Run("should queue notification for offline user", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+
+		// Act
+		err := manager.NotifyPreKeysLow("user-1", 1, 3)
+		require.NoError(t, err)
+
+		// Assert
+		inbox := &Inbox{db: db, clientID: "user-1:1"}
+		messages, _, err := inbox.LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, apitypes.MessageTypePreKeysLow, messages[0].Type)
+		var payload apitypes.WSPreKeysLowPayload
+		require.NoError(t, json.Unmarshal(messages[0].Data, &payload))
+		assert.Equal(t, 3, payload.Remaining)
+	})
+}
+
+func TestManager_MultipleDevices(t *testing.T) {
+	t.Run("should keep connections of other devices when a device connects", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		laptopConn := NewFakeWebSocketConn()
+		desktopConn := NewFakeWebSocketConn()
+
+		// Act
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, laptopConn))
+		require.NoError(t, manager.RegisterClient("user-1", 2, 0, desktopConn))
+
+		// Wait for the clients to sync
+		time.Sleep(100 * time.Millisecond)
+
+		// Assert
+		laptopConn.mu.Lock()
+		defer laptopConn.mu.Unlock()
+		assert.False(t, laptopConn.closed)
+		assert.Len(t, manager.clients["user-1"], 2)
+	})
+
+	t.Run("should fan out message to every device except the sending one", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		devices := NewFakeDeviceStore()
+		devices.AddDevices("user-1", 1, 2)
+		devices.AddDevices("user-2", 1, 3)
+		manager := NewManager(db, convRepo, devices)
+
+		onlineConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, onlineConn))
+
+		req := apitypes.SendMessageRequest{ConversationID: "conv-123", Content: []byte("encrypted-message")}
+
+		// Act
+		err := manager.BroadcastNewMessage("user-1", 1, "msg-123", time.Now().UnixMilli(), 0, req)
+		require.NoError(t, err)
+
+		// Assert
+		select {
+		case msgBytes := <-onlineConn.writeChan:
+			var msg apitypes.WSMessage
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+			var payload apitypes.WSNewMessagePayload
+			require.NoError(t, json.Unmarshal(msg.Data, &payload))
+			assert.Equal(t, "user-1", payload.SenderID)
+			assert.Equal(t, uint32(1), payload.SenderDeviceID)
+		case <-time.After(time.Second):
+			t.Fatal("No message was sent to the online device")
+		}
+
+		for _, id := range []string{"user-2:3", "user-1:2"} {
+			messages, _, err := (&Inbox{db: db, clientID: id}).LoadPage(0, pageSize, pageBytes)
+			require.NoError(t, err)
+			assert.Len(t, messages, 1, id)
+		}
+
+		senderMessages, _, err := (&Inbox{db: db, clientID: "user-1:1"}).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, senderMessages)
+	})
+
+	t.Run("should deliver sender keys to the addressed device only", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		devices := NewFakeDeviceStore()
+		devices.AddDevices("user-2", 1, 2)
+		manager := NewManager(db, NewMockConversationRepository(), devices)
+
+		req := apitypes.DistributeSenderKeysRequest{
+			ConversationID: "conv-123",
+			Participants: []apitypes.Participant{
+				{ID: "user-2", DeviceID: 2, KeyDistributionMessage: []byte("key-for-device-2")},
+			},
+		}
+
+		// Act
+		err := manager.BroadcastSenderKeys("user-1", 1, req)
+		require.NoError(t, err)
+
+		// Assert
+		primaryMessages, _, err := (&Inbox{db: db, clientID: "user-2:1"}).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		assert.Empty(t, primaryMessages)
+
+		secondaryMessages, _, err := (&Inbox{db: db, clientID: "user-2:2"}).LoadPage(0, pageSize, pageBytes)
+		require.NoError(t, err)
+		require.Len(t, secondaryMessages, 1)
+		var payload apitypes.WSSenderKeyPayload
+		require.NoError(t, json.Unmarshal(secondaryMessages[0].Data, &payload))
+		assert.Equal(t, []byte("key-for-device-2"), payload.KeyDistributionMessage)
+		assert.Equal(t, uint32(1), payload.SenderDeviceID)
+	})
+}
+
+func TestManager_RequestHandler(t *testing.T) {
+	t.Run("should answer requests with the identity of the requesting device", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		manager.SetRequestHandler(func(userID string, deviceID uint32, request apitypes.WSMessage) apitypes.WSMessage {
+			data, _ := json.Marshal(map[string]any{"userID": userID, "deviceID": deviceID})
+			return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse, Data: data}
+		})
+
+		fakeConn := NewFakeWebSocketConn()
+		err := manager.RegisterClient("user-1", 2, 0, fakeConn)
+		require.NoError(t, err)
+
+		// Act
+		requestBytes, err := json.Marshal(apitypes.WSMessage{ID: "req-1", Type: apitypes.MessageTypeCreateConversation})
+		require.NoError(t, err)
+		fakeConn.readChan <- requestBytes
+
+		// Assert
+		var response apitypes.WSMessage
+		select {
+		case msgBytes := <-fakeConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &response))
+		case <-time.After(time.Second):
+			t.Fatal("No response was sent")
+		}
+
+		assert.Equal(t, "req-1", response.ID)
+		assert.JSONEq(t, `{"userID":"user-1","deviceID":2}`, string(response.Data))
+	})
+}
+
+func TestManager_RelayTyping(t *testing.T) {
+	t.Run("should relay typing only to connected devices of other participants without storing it", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2", "user-3"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+
+		senderConn := NewFakeWebSocketConn()
+		recipientConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, senderConn))
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, recipientConn))
+		assert.Equal(t, "user-2", receivePresence(t, senderConn).UserID, "user-1 should be told that user-2 came online")
+
+		// Act
+		err := manager.RelayTyping("user-1", 1, apitypes.WSTypingPayload{ConversationID: "conv-123", Typing: true})
+		require.NoError(t, err)
+
+		// Assert
+		var msg apitypes.WSMessage
+		select {
+		case msgBytes := <-recipientConn.writeChan:
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+		case <-time.After(time.Second):
+			t.Fatal("No typing event was sent to user-2")
+		}
+		assert.Equal(t, apitypes.MessageTypeTyping, msg.Type)
+		assert.Zero(t, msg.Seq)
+
+		var payload apitypes.WSTypingPayload
+		require.NoError(t, json.Unmarshal(msg.Data, &payload))
+		assert.Equal(t, apitypes.WSTypingPayload{ConversationID: "conv-123", SenderID: "user-1", SenderDeviceID: 1, Typing: true}, payload)
+
+		select {
+		case <-senderConn.writeChan:
+			t.Fatal("Typing event should not be sent back to the sender")
+		default:
+		}
+
+		for _, id := range []string{"user-2:1", "user-3:1"} {
+			messages, _, err := (&Inbox{db: db, clientID: id}).LoadPage(0, pageSize, pageBytes)
+			require.NoError(t, err)
+			assert.Empty(t, messages, "typing events should never be stored")
+		}
+	})
+
+	t.Run("should rate limit typing events of a device", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+
+		recipientConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, recipientConn))
+
+		// Act
+		for _, typing := range []bool{true, true, true, false, false, true} {
+			err := manager.RelayTyping("user-1", 1, apitypes.WSTypingPayload{ConversationID: "conv-123", Typing: typing})
+			require.NoError(t, err)
+		}
+
+		// Wait for messages to be sent
+		time.Sleep(100 * time.Millisecond)
+
+		// Assert
+		var relayed []bool
+		for len(recipientConn.writeChan) > 0 {
+			var msg apitypes.WSMessage
+			require.NoError(t, json.Unmarshal(<-recipientConn.writeChan, &msg))
+			var payload apitypes.WSTypingPayload
+			require.NoError(t, json.Unmarshal(msg.Data, &payload))
+			relayed = append(relayed, payload.Typing)
+		}
+		assert.Equal(t, []bool{true, false}, relayed, "only the first start and the first stop should be relayed")
+	})
+
+	t.Run("should reject typing events of non-participants", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+
+		recipientConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, recipientConn))
+
+		// Act
+		err := manager.RelayTyping("user-3", 1, apitypes.WSTypingPayload{ConversationID: "conv-123", Typing: true})
+
+		// Wait for messages to be sent
+		time.Sleep(100 * time.Millisecond)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Empty(t, recipientConn.writeChan)
+	})
+}
+
+func TestManager_Presence(t *testing.T) {
+	t.Run("should tell connected contacts when a user comes online and goes offline", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+		manager.presenceDelay = 50 * time.Millisecond
+
+		contactConn := NewFakeWebSocketConn()
+		strangerConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, contactConn))
+		require.NoError(t, manager.RegisterClient("user-3", 1, 0, strangerConn))
+
+		// Act
+		before := time.Now().UnixMilli()
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, NewFakeWebSocketConn()))
+		require.NoError(t, manager.RegisterClient("user-1", 2, 0, NewFakeWebSocketConn()))
+		online := receivePresence(t, contactConn)
+		manager.UnregisterClient("user-1", 1)
+		manager.UnregisterClient("user-1", 2)
+		offline := receivePresence(t, contactConn)
+
+		// Assert
+		assert.Equal(t, apitypes.WSPresencePayload{UserID: "user-1", Online: true}, online)
+		assert.False(t, offline.Online)
+		assert.GreaterOrEqual(t, offline.LastSeen, before)
+		assert.Empty(t, contactConn.writeChan, "connecting a second device should not be broadcast")
+		assert.Empty(t, strangerConn.writeChan, "presence should only be sent to contacts")
+
+		presence, err := manager.Presence([]string{"user-1", "user-2"})
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Presence{
+			{UserID: "user-1", LastSeen: offline.LastSeen},
+			{UserID: "user-2", Online: true},
+		}, presence)
+	})
+
+	t.Run("should not broadcast reconnects within the presence delay", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+		manager.presenceDelay = 50 * time.Millisecond
+
+		contactConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, contactConn))
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, NewFakeWebSocketConn()))
+		receivePresence(t, contactConn)
+
+		// Act
+		for i := 0; i < 3; i++ {
+			manager.UnregisterClient("user-1", 1)
+			require.NoError(t, manager.RegisterClient("user-1", 1, 0, NewFakeWebSocketConn()))
+		}
+
+		// Wait past the presence delay
+		time.Sleep(150 * time.Millisecond)
+
+		// Assert
+		assert.Empty(t, contactConn.writeChan)
+		presence, err := manager.Presence([]string{"user-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Presence{{UserID: "user-1", Online: true}}, presence)
+	})
+
+	t.Run("should hide last seen of users that opted out", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		convRepo := NewMockConversationRepository()
+		convRepo.AddConversation("conv-123", &conversation.Conversation{ParticipantIDs: []string{"user-1", "user-2"}})
+		manager := NewManager(db, convRepo, NewFakeDeviceStore())
+		manager.presenceDelay = 10 * time.Millisecond
+		require.NoError(t, manager.SetHideLastSeen("user-1", true))
+
+		contactConn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-2", 1, 0, contactConn))
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, NewFakeWebSocketConn()))
+		receivePresence(t, contactConn)
+
+		// Act
+		manager.UnregisterClient("user-1", 1)
+		offline := receivePresence(t, contactConn)
+
+		// Assert
+		assert.Equal(t, apitypes.WSPresencePayload{UserID: "user-1"}, offline)
+		presence, err := manager.Presence([]string{"user-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Presence{{UserID: "user-1"}}, presence)
+		record, err := manager.readPresence("user-1")
+		require.NoError(t, err)
+		assert.NotZero(t, record.LastSeen, "last seen should still be stored")
+	})
+
+	t.Run("should move presence records stored under the legacy prefix", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		require.NoError(t, db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte("presence:user-1"), []byte(`{"lastSeen":1700000000000,"hideLastSeen":true}`))
+		}))
+
+		// Act
+		migrated, err := manager.MigratePresence()
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated)
+		record, err := manager.readPresence("user-1")
+		require.NoError(t, err)
+		assert.Equal(t, presenceRecord{LastSeen: 1700000000000, HideLastSeen: true}, record)
+
+		migrated, err = manager.MigratePresence()
+		require.NoError(t, err)
+		assert.Zero(t, migrated, "migrated records should not be moved again")
+	})
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	t.Run("should tell devices the server is restarting and wait until their connections are closed", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		conn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, conn))
+
+		// The device closes the connection once it got the close frame
+		closeFrame := make(chan []byte, 1)
+		go func() {
+			frame := <-conn.writeChan
+			closeFrame <- frame
+			_ = conn.Close()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Act
+		err := manager.Shutdown(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, websocket.FormatCloseMessage(websocket.CloseServiceRestart, apitypes.WSCloseReasonRestart), <-closeFrame)
+		presence, err := manager.Presence([]string{"user-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []apitypes.Presence{{UserID: "user-1"}}, presence, "user should not be reported online anymore")
+	})
+
+	t.Run("should drop connections that aren't closed in time", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		conn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, conn))
+		client := manager.clients["user-1"][1]
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		err := manager.Shutdown(ctx)
+
+		// Assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		select {
+		case <-client.Stopped():
+		case <-time.After(time.Second):
+			t.Fatal("Client should stop once its connection is dropped")
+		}
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		assert.True(t, conn.closed)
+	})
+	t.Run("should not wait for a request handler that doesn't return", func(t *testing.T) {
+		// Arrange
+		db, dbClose := testDB(t)
+		defer dbClose()
+
+		manager := NewManager(db, NewMockConversationRepository(), NewFakeDeviceStore())
+		handling := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		manager.SetRequestHandler(func(userID string, deviceID uint32, request apitypes.WSMessage) apitypes.WSMessage {
+			close(handling)
+			<-release
+			return apitypes.WSMessage{ID: request.ID, Type: apitypes.MessageTypeResponse}
+		})
+		conn := NewFakeWebSocketConn()
+		require.NoError(t, manager.RegisterClient("user-1", 1, 0, conn))
+		requestBytes, err := json.Marshal(apitypes.WSMessage{ID: "req-1", Type: apitypes.MessageTypeCreateConversation})
+		require.NoError(t, err)
+		conn.readChan <- requestBytes
+		<-handling
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		done := make(chan error, 1)
+		go func() { done <- manager.Shutdown(ctx) }()
+
+		// Assert
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(time.Second):
+			t.Fatal("Shutdown should return once the context is done")
+		}
+	})
+}
+
+// receivePresence waits for the next presence event sent over the connection
+func receivePresence(t *testing.T, conn *FakeWebSocketConn) apitypes.WSPresencePayload {
+	t.Helper()
+
+	var msg apitypes.WSMessage
+	select {
+	case msgBytes := <-conn.writeChan:
+		require.NoError(t, json.Unmarshal(msgBytes, &msg))
+	case <-time.After(time.Second):
+		t.Fatal("No presence event was sent")
+	}
+	require.Equal(t, apitypes.MessageTypePresence, msg.Type)
+
+	var payload apitypes.WSPresencePayload
+	require.NoError(t, json.Unmarshal(msg.Data, &payload))
+	return payload
+}
@@ -0,0 +1,19 @@
+package ws
+
+import "expvar"
+
+// Metrics of the inboxes, published with expvar
+var (
+	// inboxQueues, inboxMessages and inboxBytes are the number of non-empty inboxes and the number and total size of
+	// the messages waiting in them, as of the last sweep. inboxMaxDepth is the number of messages in the fullest inbox.
+	inboxQueues   = expvar.NewInt("ws_inbox_queues")
+	inboxMessages = expvar.NewInt("ws_inbox_messages")
+	inboxBytes    = expvar.NewInt("ws_inbox_bytes")
+	inboxMaxDepth = expvar.NewInt("ws_inbox_max_depth")
+
+	// inboxEvicted, inboxRejected and inboxAbandoned count the messages dropped from full inboxes, the messages
+	// rejected by full inboxes and the inboxes of removed devices deleted since the server started
+	inboxEvicted   = expvar.NewInt("ws_inbox_evicted_total")
+	inboxRejected  = expvar.NewInt("ws_inbox_rejected_total")
+	inboxAbandoned = expvar.NewInt("ws_inbox_abandoned_total")
+)
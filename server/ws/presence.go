@@ -0,0 +1,232 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"log"
+	"signal-chat/internal/apitypes"
+	"time"
+)
+
+// presenceDelay is the time a user has to stay disconnected before its contacts are told it went offline, so that
+// flapping reconnects aren't broadcast
+const presenceDelay = 5 * time.Second
+
+// legacyPresencePrefix is the prefix presence records were stored under before they followed the name# convention
+const legacyPresencePrefix = "presence:"
+
+// presenceRecord is the stored presence of a user
+type presenceRecord struct {
+	LastSeen     int64 `json:"lastSeen"`
+	HideLastSeen bool  `json:"hideLastSeen"`
+}
+
+// Presence returns whether the given users are online and, for offline users that don't hide it, when they were last
+// seen. A user stays online for presenceDelay after its last device disconnected. Only the devices connected to this
+// server instance are taken into account.
+func (m *Manager) Presence(userIDs []string) ([]apitypes.Presence, error) {
+	presence := make([]apitypes.Presence, 0, len(userIDs))
+	for _, userID := range userIDs {
+		m.mu.RLock()
+		_, connected := m.clients[userID]
+		_, disconnecting := m.offline[userID]
+		m.mu.RUnlock()
+
+		p := apitypes.Presence{UserID: userID, Online: connected || disconnecting}
+		if !p.Online {
+			record, err := m.readPresence(userID)
+			if err != nil {
+				return nil, err
+			}
+			if !record.HideLastSeen {
+				p.LastSeen = record.LastSeen
+			}
+		}
+		presence = append(presence, p)
+	}
+
+	return presence, nil
+}
+
+// SetHideLastSeen sets whether the contacts of the user see when it was last online
+func (m *Manager) SetHideLastSeen(userID string, hide bool) error {
+	return m.updatePresence(userID, func(record *presenceRecord) {
+		record.HideLastSeen = hide
+	})
+}
+
+// cancelOffline is called when the first device of a user connects and tells whether the contacts have to be told
+// that the user came online. They don't if the user reconnected before they were told it went offline.
+// The caller must hold m.mu.
+func (m *Manager) cancelOffline(userID string) bool {
+	timer, disconnecting := m.offline[userID]
+	if !disconnecting {
+		return true
+	}
+
+	timer.Stop()
+	delete(m.offline, userID)
+	return false
+}
+
+// scheduleOffline is called when the last device of a user disconnects and tells the contacts that the user went
+// offline unless it reconnects within presenceDelay. The caller must hold m.mu.
+func (m *Manager) scheduleOffline(userID string) {
+	lastSeen := time.Now().UnixMilli()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(m.presenceDelay, func() {
+		m.mu.Lock()
+		if m.offline[userID] != timer {
+			m.mu.Unlock()
+			return
+		}
+		delete(m.offline, userID)
+		m.mu.Unlock()
+
+		m.wentOffline(userID, lastSeen)
+	})
+	m.offline[userID] = timer
+}
+
+// wentOffline stores when the user was last seen and tells its contacts
+func (m *Manager) wentOffline(userID string, lastSeen int64) {
+	var hidden bool
+	err := m.updatePresence(userID, func(record *presenceRecord) {
+		record.LastSeen = lastSeen
+		hidden = record.HideLastSeen
+	})
+	if err != nil {
+		log.Printf("Failed to store last seen of user %s: %v", userID, err)
+	}
+
+	payload := apitypes.WSPresencePayload{UserID: userID}
+	if !hidden {
+		payload.LastSeen = lastSeen
+	}
+	m.broadcastPresence(payload)
+}
+
+// broadcastPresence sends the presence of a user to the connected devices of its contacts. Like typing events,
+// presence events are never stored, devices query the presence of their contacts when they connect.
+// Connections are tracked per server instance, so contacts of a user whose devices are connected to several instances
+// are told it went offline as soon as one of the instances loses its last device.
+func (m *Manager) broadcastPresence(payload apitypes.WSPresencePayload) {
+	contacts, err := m.conversationRepo.GetContacts(payload.UserID)
+	if err != nil {
+		log.Printf("Failed to get contacts of user %s: %v", payload.UserID, err)
+		return
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal presence of user %s: %v", payload.UserID, err)
+		return
+	}
+
+	message := &apitypes.WSMessage{
+		ID:   generateMessageID(),
+		Type: apitypes.MessageTypePresence,
+		Data: payloadBytes,
+	}
+
+	for _, contactID := range contacts {
+		if err := m.bus.Publish(BusMessage{UserID: contactID, Ephemeral: message}); err != nil {
+			log.Printf("Failed to publish presence of user %s: %v", payload.UserID, err)
+			return
+		}
+	}
+}
+
+func (m *Manager) readPresence(userID string) (presenceRecord, error) {
+	var record presenceRecord
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(presenceKey(userID))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		})
+	})
+
+	return record, err
+}
+
+func (m *Manager) updatePresence(userID string, update func(record *presenceRecord)) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		var record presenceRecord
+		item, err := txn.Get(presenceKey(userID))
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error { return json.Unmarshal(val, &record) }); err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		update(&record)
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return txn.Set(presenceKey(userID), data)
+	})
+}
+
+// MigratePresence moves the presence records stored under presence:<userID> to presence#<userID>, so that users who
+// hid their last seen time keep it hidden. It returns the number of moved records and does nothing once all of them
+// were moved.
+func (m *Manager) MigratePresence() (int, error) {
+	var legacyKeys [][]byte
+	err := m.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(legacyPresencePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			legacyKeys = append(legacyKeys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range legacyKeys {
+		userID := string(key[len(legacyPresencePrefix):])
+		err := m.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			record, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			if err := txn.Set(presenceKey(userID), record); err != nil {
+				return err
+			}
+			return txn.Delete(key)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate presence of user %s: %w", userID, err)
+		}
+	}
+
+	return len(legacyKeys), nil
+}
+
+func presenceKey(userID string) []byte {
+	return []byte("presence#" + userID)
+}